@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-audio/aiff"
+	"github.com/go-audio/audio"
+)
+
+// LoadAIFF reads an AIFF file and returns its data in the same form as LoadWAV
+func LoadAIFF(path string) (*WAVData, error) {
+	// Open AIFF file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AIFF file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Decode AIFF
+	decoder := aiff.NewDecoder(f)
+	if !decoder.IsValidFile() {
+		return nil, fmt.Errorf("%w: AIFF file %s", ErrInvalidFile, path)
+	}
+
+	// Read format information
+	sampleRate := decoder.SampleRate
+	channels := int(decoder.NumChans)
+	bitDepth := int(decoder.BitDepth)
+
+	// Read all audio data (the decoder converts big-endian samples to native ints)
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PCM data from %s: %w", path, err)
+	}
+
+	// Check if file contains any audio data
+	if len(buf.Data) == 0 {
+		return nil, fmt.Errorf("AIFF %w: %s", ErrNoAudioData, path)
+	}
+
+	// Convert int samples to float64 with the same scale as LoadWAV
+	data := make([]float64, len(buf.Data))
+	maxVal := pcmScale(bitDepth)
+	for i, sample := range buf.Data {
+		data[i] = float64(sample) / float64(maxVal)
+	}
+
+	return &WAVData{
+		Path:       path,
+		SampleRate: sampleRate,
+		Channels:   channels,
+		BitDepth:   bitDepth,
+		Data:       data,
+		Format: &audio.Format{
+			NumChannels: channels,
+			SampleRate:  sampleRate,
+		},
+	}, nil
+}
+
+// WriteAIFF writes audio data to an AIFF file
+// Float samples need AIFF-C, which the encoder does not support
+func WriteAIFF(path string, data []float64, sampleRate, channels, bitDepth int, float bool) error {
+	if float {
+		return fmt.Errorf("float output is not supported for AIFF: %s", path)
+	}
+
+	// Create output file
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create AIFF file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Create encoder
+	encoder := aiff.NewEncoder(f, sampleRate, bitDepth, channels)
+
+	buf := &audio.IntBuffer{
+		Data: toPCM(data, bitDepth),
+		Format: &audio.Format{
+			NumChannels: channels,
+			SampleRate:  sampleRate,
+		},
+	}
+
+	// Write to file
+	if err := encoder.Write(buf); err != nil {
+		return fmt.Errorf("failed to write AIFF data to %s: %w", path, err)
+	}
+
+	// Close rewrites the header sizes, so its error matters
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finalize AIFF file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestAIFFRoundTrip(t *testing.T) {
+	data := sine(1000, 44100, 441)
+
+	for _, bitDepth := range []int{16, 24} {
+		for _, ext := range []string{".aif", ".aiff"} {
+			path := filepath.Join(t.TempDir(), "roundtrip"+ext)
+			if err := WriteAudio(path, data, 44100, 2, bitDepth, false); err != nil {
+				t.Fatalf("%d-bit %s: WriteAudio: %v", bitDepth, ext, err)
+			}
+			loaded, err := LoadAudio(path)
+			if err != nil {
+				t.Fatalf("%d-bit %s: LoadAudio: %v", bitDepth, ext, err)
+			}
+
+			if loaded.SampleRate != 44100 || loaded.Channels != 2 || loaded.BitDepth != bitDepth {
+				t.Errorf("%d-bit %s: read %d Hz, %d channels, %d-bit", bitDepth, ext, loaded.SampleRate, loaded.Channels, loaded.BitDepth)
+			}
+			if len(loaded.Data) != len(data) {
+				t.Fatalf("%d-bit %s: read %d samples, want %d", bitDepth, ext, len(loaded.Data), len(data))
+			}
+			step := 1 / float64(pcmScale(bitDepth))
+			for i := range data {
+				if math.Abs(loaded.Data[i]-data[i]) > step {
+					t.Fatalf("%d-bit %s: sample %d = %g, want %g", bitDepth, ext, i, loaded.Data[i], data[i])
+				}
+			}
+		}
+	}
+}
+
+func TestWriteAIFFRejectsFloat(t *testing.T) {
+	if err := WriteAIFF(filepath.Join(t.TempDir(), "float.aiff"), []float64{0}, 44100, 1, 32, true); err == nil {
+		t.Error("WriteAIFF wrote float samples")
+	}
+}
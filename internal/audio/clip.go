@@ -0,0 +1,30 @@
+package audio
+
+import "math"
+
+// ClipStats describes the samples of a buffer beyond full scale, which integer PCM output clamps
+type ClipStats struct {
+	Samples int     // Number of samples beyond ±1.0
+	Peak    float64 // Largest absolute sample value
+}
+
+// OvershootDB returns how far the peak exceeds full scale in dB (0 if nothing clips)
+func (s ClipStats) OvershootDB() float64 {
+	if s.Samples == 0 {
+		return 0
+	}
+	return 20 * math.Log10(s.Peak)
+}
+
+// MeasureClipping counts the samples of data that writing it as integer PCM would clamp
+func MeasureClipping(data []float64) ClipStats {
+	var stats ClipStats
+	for _, sample := range data {
+		level := math.Abs(sample)
+		if level > 1 {
+			stats.Samples++
+		}
+		stats.Peak = math.Max(stats.Peak, level)
+	}
+	return stats
+}
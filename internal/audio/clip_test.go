@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureClipping(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []float64
+		wantSamples int
+		wantPeak    float64
+		wantDB      float64
+	}{
+		{"within full scale", []float64{0.5, -1, 1, 0}, 0, 1, 0},
+		{"beyond full scale", []float64{0.5, 2, -1.5, 1, -2}, 3, 2, 20 * math.Log10(2)},
+		{"silence", make([]float64, 4), 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		stats := MeasureClipping(tt.data)
+		if stats.Samples != tt.wantSamples || stats.Peak != tt.wantPeak {
+			t.Errorf("%s: %d samples clipped (peak %g), want %d (peak %g)", tt.name, stats.Samples, stats.Peak, tt.wantSamples, tt.wantPeak)
+		}
+		if db := stats.OvershootDB(); math.Abs(db-tt.wantDB) > 1e-9 {
+			t.Errorf("%s: overshoot %g dB, want %g dB", tt.name, db, tt.wantDB)
+		}
+	}
+}
+
+func TestClippedBufferWrite(t *testing.T) {
+	// Every other sample exceeds full scale by 6 dB
+	data := make([]float64, 1000)
+	for i := range data {
+		data[i] = 0.5 * math.Sin(float64(i)/10)
+		if i%2 == 0 {
+			data[i] *= 4
+		}
+	}
+	stats := MeasureClipping(data)
+	if stats.Samples == 0 {
+		t.Fatal("test buffer does not clip")
+	}
+
+	// Integer output clamps exactly the counted samples to full scale
+	clamped := roundTrip(t, data, 1, 16, false)
+	step := 1 / float64(pcmScale(16))
+	pinned := 0
+	for i, v := range clamped.Data {
+		if math.Abs(data[i]) > 1 {
+			pinned++
+			if math.Abs(math.Abs(v)-1) > step || v*data[i] < 0 {
+				t.Errorf("clipped sample %d = %g, want full scale with the sign of %g", i, v, data[i])
+			}
+		} else if math.Abs(v-data[i]) > step {
+			t.Errorf("sample %d = %g, want %g", i, v, data[i])
+		}
+	}
+	if pinned != stats.Samples {
+		t.Errorf("%d samples clamped, MeasureClipping counted %d", pinned, stats.Samples)
+	}
+
+	// Normalizing first keeps the shape of the whole buffer instead
+	normalized := roundTrip(t, NormalizePeak(data, 1), 1, 16, false)
+	if after := MeasureClipping(normalized.Data); after.Samples != 0 {
+		t.Errorf("%d samples still clip after normalizing", after.Samples)
+	}
+	for i, v := range normalized.Data {
+		if want := data[i] / stats.Peak; math.Abs(v-want) > step {
+			t.Fatalf("normalized sample %d = %g, want %g", i, v, want)
+		}
+	}
+}
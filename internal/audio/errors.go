@@ -0,0 +1,13 @@
+package audio
+
+import "errors"
+
+// Sentinel errors wrapped by the loaders, so callers can tell failures apart with errors.Is
+// Errors from opening a file wrap the os error, so errors.Is(err, fs.ErrNotExist) also works.
+var (
+	ErrInvalidWAV         = errors.New("invalid WAV file")
+	ErrInvalidFile        = errors.New("invalid audio file") // A FLAC, MP3, AIFF or Ogg file the decoder rejects
+	ErrNoAudioData        = errors.New("file contains no audio data")
+	ErrUnsupportedFormat  = errors.New("unsupported audio format")
+	ErrSampleRateMismatch = errors.New("sample rate mismatch")
+)
@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+const (
+	flacBlockSize   = 4096 // Samples per channel in each encoded FLAC frame
+	flacMaxChannels = 8    // Channel assignments defined by FLAC
+	flacMaxBitDepth = 24   // Deepest samples the encoder writes correctly
+)
+
+// LoadFLAC reads a FLAC file and returns its data in the same form as LoadWAV
+func LoadFLAC(path string) (*WAVData, error) {
+	// Open FLAC stream
+	stream, err := flac.ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FLAC file %s: %w", path, err)
+	}
+	defer stream.Close()
+
+	// Read format information
+	sampleRate := int(stream.Info.SampleRate)
+	channels := int(stream.Info.NChannels)
+	bitDepth := int(stream.Info.BitsPerSample)
+
+	// Decode all frames, interleaving channels like WAV PCM data
+	allData := make([]int, 0, int(stream.Info.NSamples)*channels)
+
+	for {
+		frame, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode FLAC frame from %s: %w", path, err)
+		}
+
+		blockSize := int(frame.BlockSize)
+		for i := 0; i < blockSize; i++ {
+			for ch := 0; ch < channels; ch++ {
+				allData = append(allData, int(frame.Subframes[ch].Samples[i]))
+			}
+		}
+	}
+
+	// Check if file contains any audio data
+	if len(allData) == 0 {
+		return nil, fmt.Errorf("FLAC %w: %s", ErrNoAudioData, path)
+	}
+
+	// Convert int samples to float64 (normalized to -1.0 to 1.0)
+	data := make([]float64, len(allData))
+	maxVal := pcmScale(bitDepth)
+	for i, sample := range allData {
+		data[i] = float64(sample) / float64(maxVal)
+	}
+
+	return &WAVData{
+		Path:       path,
+		SampleRate: sampleRate,
+		Channels:   channels,
+		BitDepth:   bitDepth,
+		Data:       data,
+		Format: &audio.Format{
+			NumChannels: channels,
+			SampleRate:  sampleRate,
+		},
+	}, nil
+}
+
+// WriteFLAC writes audio data to a FLAC file with samples of bitDepth bits (at most 24)
+// Frames are stored verbatim or with the fixed predictor the encoder picks for each one.
+func WriteFLAC(path string, data []float64, sampleRate, channels, bitDepth int, float bool) error {
+	if float {
+		return fmt.Errorf("float output is not supported for FLAC: %s", path)
+	}
+	if bitDepth > flacMaxBitDepth {
+		return fmt.Errorf("FLAC output supports up to %d bits, got %d: %s", flacMaxBitDepth, bitDepth, path)
+	}
+	if channels < 1 || channels > flacMaxChannels {
+		return fmt.Errorf("FLAC output supports 1 to %d channels, got %d: %s", flacMaxChannels, channels, path)
+	}
+
+	// Create output file
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create FLAC file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// The stream info is complete up front, so the encoder writes through a buffer and never seeks back
+	// to rewrite it (it would record the short last frame as the minimum block size, which decoders reject)
+	pcm := toPCM(data, bitDepth)
+	frames := len(pcm) / channels
+	w := bufio.NewWriter(f)
+	encoder, err := flac.NewEncoder(w, &meta.StreamInfo{
+		BlockSizeMin:  flacBlockSize,
+		BlockSizeMax:  flacBlockSize,
+		SampleRate:    uint32(sampleRate),
+		NChannels:     uint8(channels),
+		BitsPerSample: uint8(bitDepth),
+		NSamples:      uint64(frames),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create FLAC encoder for %s: %w", path, err)
+	}
+
+	// Split the interleaved samples into one subframe per channel for each block
+	for start := 0; start < frames; start += flacBlockSize {
+		blockSize := min(flacBlockSize, frames-start)
+		subframes := make([]*frame.Subframe, channels)
+		for ch := range subframes {
+			samples := make([]int32, blockSize)
+			for i := range samples {
+				samples[i] = int32(pcm[(start+i)*channels+ch])
+			}
+			subframes[ch] = &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   samples,
+				NSamples:  blockSize,
+			}
+		}
+
+		block := &frame.Frame{
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(blockSize),
+				SampleRate:        uint32(sampleRate),
+				Channels:          frame.Channels(channels - 1),
+				BitsPerSample:     uint8(bitDepth),
+			},
+			Subframes: subframes,
+		}
+		if err := encoder.WriteFrame(block); err != nil {
+			return fmt.Errorf("failed to write FLAC data to %s: %w", path, err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finalize FLAC file %s: %w", path, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write FLAC data to %s: %w", path, err)
+	}
+	return f.Close()
+}
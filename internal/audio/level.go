@@ -0,0 +1,27 @@
+package audio
+
+import "math"
+
+// LevelStats describes the level of a buffer as linear amplitudes (1.0 = full scale)
+type LevelStats struct {
+	RMS  float64 // Root mean square of the samples
+	Peak float64 // Largest absolute sample value
+}
+
+// MeasureLevel returns the level of the samples of all parts taken together
+// Several parts (e.g. the files of a split mixed recording) are measured as if they were one buffer.
+func MeasureLevel(parts ...[]float64) LevelStats {
+	var stats LevelStats
+	sum, count := 0.0, 0
+	for _, data := range parts {
+		for _, sample := range data {
+			sum += sample * sample
+			stats.Peak = math.Max(stats.Peak, math.Abs(sample))
+		}
+		count += len(data)
+	}
+	if count > 0 {
+		stats.RMS = math.Sqrt(sum / float64(count))
+	}
+	return stats
+}
@@ -0,0 +1,28 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		parts     [][]float64
+		rms, peak float64
+	}{
+		{"square wave", [][]float64{{0.5, -0.5, 0.5, -0.5}}, 0.5, 0.5},
+		// sqrt((0.36 + 0.64 + 0 + 0) / 4) = 0.5
+		{"mixed levels", [][]float64{{0.6, -0.8, 0, 0}}, 0.5, 0.8},
+		{"split into parts", [][]float64{{0.6}, {-0.8, 0}, {0}}, 0.5, 0.8},
+		{"silence", [][]float64{{0, 0}}, 0, 0},
+		{"empty", nil, 0, 0},
+	}
+
+	for _, tt := range tests {
+		got := MeasureLevel(tt.parts...)
+		if math.Abs(got.RMS-tt.rms) > 1e-12 || got.Peak != tt.peak {
+			t.Errorf("%s: RMS %g, peak %g; want %g, %g", tt.name, got.RMS, got.Peak, tt.rms, tt.peak)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-audio/wav"
+)
+
+// Loader decodes an audio file into normalized WAVData
+type Loader func(path string) (*WAVData, error)
+
+// loaders maps lowercase file extensions to their decoders
+var loaders = map[string]Loader{
+	".wav":  LoadWAV,
+	".flac": LoadFLAC,
+	".mp3":  LoadMP3,
+	".aif":  LoadAIFF,
+	".aiff": LoadAIFF,
+	".ogg":  LoadOgg,
+	".oga":  LoadOgg,
+}
+
+// Writer encodes normalized audio data into a file
+// float selects 32-bit IEEE float samples where the format supports them
+type Writer func(path string, data []float64, sampleRate, channels, bitDepth int, float bool) error
+
+// writers maps lowercase file extensions to their encoders
+var writers = map[string]Writer{
+	".wav":  WriteWAV,
+	".flac": WriteFLAC,
+	".aif":  WriteAIFF,
+	".aiff": WriteAIFF,
+}
+
+// LoadAudio reads an audio file, choosing the decoder from its extension
+// StdinPath reads WAV data from standard input instead.
+func LoadAudio(path string) (*WAVData, error) {
+	if path == StdinPath {
+		return loadStdin()
+	}
+	loader, ok := loaders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+	return loader(path)
+}
+
+// WriteAudio writes an audio file, choosing the encoder from its extension
+func WriteAudio(path string, data []float64, sampleRate, channels, bitDepth int, float bool) error {
+	writer, ok := writers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return fmt.Errorf("%w for output: %s", ErrUnsupportedFormat, path)
+	}
+	return writer(path, data, sampleRate, channels, bitDepth, float)
+}
+
+// WriteAudioTagged is WriteAudio that also stores tags read from a WAV file (nil = none)
+// Only WAV output carries them; other formats are written without.
+func WriteAudioTagged(path string, data []float64, sampleRate, channels, bitDepth int, float bool, tags *wav.Metadata) error {
+	if tags != nil && strings.ToLower(filepath.Ext(path)) == ".wav" {
+		return WriteWAVTagged(path, data, sampleRate, channels, bitDepth, float, tags)
+	}
+	return WriteAudio(path, data, sampleRate, channels, bitDepth, float)
+}
+
+// CanWrite reports whether the file extension has a registered encoder
+func CanWrite(path string) bool {
+	_, ok := writers[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// IsSupported reports whether the file extension has a registered decoder
+func IsSupported(path string) bool {
+	_, ok := loaders[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
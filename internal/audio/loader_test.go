@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAudioFLACMatchesWAV(t *testing.T) {
+	data := sine(440, 48000, 3*flacBlockSize+17) // Not a whole number of FLAC frames
+
+	for _, bitDepth := range []int{16, 24} {
+		decoded := make(map[string]*WAVData)
+		for _, ext := range []string{".wav", ".flac"} {
+			path := filepath.Join(t.TempDir(), "out"+ext)
+			if err := WriteAudio(path, data, 48000, 2, bitDepth, false); err != nil {
+				t.Fatalf("%d-bit %s: WriteAudio: %v", bitDepth, ext, err)
+			}
+			loaded, err := LoadAudio(path)
+			if err != nil {
+				t.Fatalf("%d-bit %s: LoadAudio: %v", bitDepth, ext, err)
+			}
+			decoded[ext] = loaded
+		}
+
+		wav, flac := decoded[".wav"], decoded[".flac"]
+		if flac.SampleRate != wav.SampleRate || flac.Channels != wav.Channels || flac.BitDepth != wav.BitDepth {
+			t.Errorf("%d-bit: FLAC read as %d Hz, %d channels, %d-bit; WAV as %d Hz, %d channels, %d-bit", bitDepth,
+				flac.SampleRate, flac.Channels, flac.BitDepth, wav.SampleRate, wav.Channels, wav.BitDepth)
+		}
+		if len(flac.Data) != len(wav.Data) {
+			t.Fatalf("%d-bit: FLAC has %d samples, WAV %d", bitDepth, len(flac.Data), len(wav.Data))
+		}
+		// Both are lossless, so the quantized samples are identical
+		for i := range wav.Data {
+			if flac.Data[i] != wav.Data[i] {
+				t.Fatalf("%d-bit: sample %d is %g in FLAC and %g in WAV", bitDepth, i, flac.Data[i], wav.Data[i])
+			}
+		}
+	}
+}
+
+func TestWriteAudioUnsupportedExtension(t *testing.T) {
+	data := sine(440, 8000, 100)
+	for _, name := range []string{"out.mp3", "out.ogg", "out.txt", "out"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WriteAudio(path, data, 8000, 2, 16, false); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: error %v, want ErrUnsupportedFormat", name, err)
+		}
+		if CanWrite(path) {
+			t.Errorf("%s: CanWrite = true", name)
+		}
+	}
+}
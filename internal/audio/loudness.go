@@ -0,0 +1,168 @@
+package audio
+
+import "math"
+
+// ITU-R BS.1770 / EBU R128 measurement constants
+const (
+	loudnessBlockSeconds = 0.4   // Gating block length
+	loudnessStepSeconds  = 0.1   // Gating block hop (75% overlap)
+	loudnessAbsoluteGate = -70.0 // Blocks quieter than this (LUFS) are ignored
+	loudnessRelativeGate = -10.0 // Blocks this far (LU) below the ungated mean are ignored
+	loudnessOffset       = -0.691
+	truePeakOversampling = 4 // True peak is read from a signal interpolated to 4x the sample rate
+)
+
+// biquad is a second-order IIR filter section (a0 normalized to 1)
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+// kWeighting returns the two filter stages of the BS.1770 K-weighting at sampleRate:
+// a high shelf modelling the head and a high-pass (the RLB curve)
+// The coefficients are derived from the analog prototypes so that any sample rate matches the 48 kHz reference.
+func kWeighting(sampleRate int) [2]biquad {
+	fs := float64(sampleRate)
+
+	// Stage 1: +4 dB high shelf around 1.7 kHz
+	k := math.Tan(math.Pi * 1681.974450955533 / fs)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// Stage 2: high-pass at 38 Hz
+	k = math.Tan(math.Pi * 38.13547087602444 / fs)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return [2]biquad{shelf, highPass}
+}
+
+// channelWeight returns the BS.1770 weight of a channel: surround channels of a 5.1 layout
+// count 1.41 times and the LFE channel is left out; every other layout weighs channels equally
+func channelWeight(channel, channels int) float64 {
+	if channels != 6 {
+		return 1
+	}
+	switch channel {
+	case 3:
+		return 0
+	case 4, 5:
+		return 1.41
+	default:
+		return 1
+	}
+}
+
+// MeasureLUFS returns the integrated loudness of interleaved data in LUFS per ITU-R BS.1770-4 (EBU R128):
+// K-weighted mean square over 400 ms blocks, gated at -70 LUFS and 10 LU below the mean of the remaining blocks
+// Audio shorter than one block or entirely below the absolute gate returns -Inf.
+func MeasureLUFS(data []float64, sampleRate, channels int) float64 {
+	frames := len(data) / channels
+	block := int(loudnessBlockSeconds * float64(sampleRate))
+	step := int(loudnessStepSeconds * float64(sampleRate))
+	if block == 0 || frames < block {
+		return math.Inf(-1)
+	}
+
+	// Weighted sum over channels of the K-weighted squares, per frame
+	filters := kWeighting(sampleRate)
+	squares := make([]float64, frames)
+	for ch := 0; ch < channels; ch++ {
+		weight := channelWeight(ch, channels)
+		if weight == 0 {
+			continue
+		}
+		var state [2][4]float64 // x1, x2, y1, y2 of each stage
+		for n := 0; n < frames; n++ {
+			x := data[n*channels+ch]
+			for s, f := range filters {
+				st := &state[s]
+				y := f.b0*x + f.b1*st[0] + f.b2*st[1] - f.a1*st[2] - f.a2*st[3]
+				st[1], st[0] = st[0], x
+				st[3], st[2] = st[2], y
+				x = y
+			}
+			squares[n] += weight * x * x
+		}
+	}
+
+	// Mean square of each block, from a running sum
+	prefix := make([]float64, frames+1)
+	for n, v := range squares {
+		prefix[n+1] = prefix[n] + v
+	}
+	var powers []float64
+	for start := 0; start+block <= frames; start += step {
+		powers = append(powers, (prefix[start+block]-prefix[start])/float64(block))
+	}
+
+	loudness := func(power float64) float64 {
+		return loudnessOffset + 10*math.Log10(power)
+	}
+	gatedMean := func(threshold float64) (float64, int) {
+		sum, count := 0.0, 0
+		for _, p := range powers {
+			if loudness(p) > threshold {
+				sum += p
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, 0
+		}
+		return sum / float64(count), count
+	}
+
+	ungated, count := gatedMean(loudnessAbsoluteGate)
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	relativeGate := math.Max(loudness(ungated)+loudnessRelativeGate, loudnessAbsoluteGate)
+	gated, count := gatedMean(relativeGate)
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(gated)
+}
+
+// TruePeak estimates the largest absolute value of the continuous signal behind interleaved data,
+// which can exceed the largest sample between samples, by interpolating to 4x the sample rate
+func TruePeak(data []float64, channels int) float64 {
+	peak := peakLevel(data)
+	for i := 1; i < truePeakOversampling; i++ {
+		peak = math.Max(peak, peakLevel(FractionalDelay(data, float64(i)/truePeakOversampling, channels)))
+	}
+	return peak
+}
+
+// LoudnessGain returns the linear gain that brings data to targetLUFS, lowered where needed so that the
+// true peak stays at or below ceilingDBTP (limited reports that), and the loudness measured before the gain
+// Audio too short or quiet to measure gets a gain of 1.
+func LoudnessGain(data []float64, sampleRate, channels int, targetLUFS, ceilingDBTP float64) (gain, loudness float64, limited bool) {
+	loudness = MeasureLUFS(data, sampleRate, channels)
+	if math.IsInf(loudness, -1) {
+		return 1, loudness, false
+	}
+
+	gain = math.Pow(10, (targetLUFS-loudness)/20)
+	ceiling := math.Pow(10, ceilingDBTP/20)
+	if peak := TruePeak(data, channels); peak*gain > ceiling {
+		return ceiling / peak, loudness, true
+	}
+	return gain, loudness, false
+}
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// toneChannels returns seconds of a sine at freq with the given peak amplitude in every one of channels
+func toneChannels(freq, amplitude float64, rate, channels int, seconds float64) []float64 {
+	frames := int(seconds * float64(rate))
+	data := make([]float64, frames*channels)
+	for i := range frames {
+		v := amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+		for ch := range channels {
+			data[i*channels+ch] = v
+		}
+	}
+	return data
+}
+
+// minus23dBFS is the peak amplitude of a sine at -23 dBFS
+var minus23dBFS = math.Pow(10, -23.0/20)
+
+func TestMeasureLUFSSine(t *testing.T) {
+	// EBU Tech 3341: a 1 kHz sine at -23 dBFS in both stereo channels reads -23 LUFS,
+	// and a full-scale sine in one channel reads -3.01 LUFS
+	tests := []struct {
+		name      string
+		amplitude float64
+		rate      int
+		channels  int
+		want      float64
+	}{
+		{"stereo -23 dBFS at 48 kHz", minus23dBFS, 48000, 2, -23},
+		{"stereo -23 dBFS at 44.1 kHz", minus23dBFS, 44100, 2, -23},
+		{"mono full scale", 1, 48000, 1, -3.01},
+		{"mono -20 dBFS at 16 kHz", 0.1, 16000, 1, -23.01},
+	}
+
+	for _, tt := range tests {
+		got := MeasureLUFS(toneChannels(1000, tt.amplitude, tt.rate, tt.channels, 5), tt.rate, tt.channels)
+		if math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: %.2f LUFS, want %.2f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMeasureLUFSGating(t *testing.T) {
+	tone := toneChannels(1000, minus23dBFS, 48000, 2, 5)
+
+	// Silence after the tone is gated out; only the few blocks across the end of the tone lower the loudness
+	withSilence := append(append([]float64{}, tone...), make([]float64, len(tone))...)
+	if got := MeasureLUFS(withSilence, 48000, 2); math.Abs(got+23) > 0.2 {
+		t.Errorf("tone then silence: %.2f LUFS, want -23", got)
+	}
+
+	// The LFE channel of a 5.1 layout is not counted
+	surround := make([]float64, len(tone)/2*6)
+	for i := range len(tone) / 2 {
+		surround[i*6] = tone[2*i]
+		surround[i*6+1] = tone[2*i+1]
+		surround[i*6+3] = 0.9
+	}
+	if got := MeasureLUFS(surround, 48000, 6); math.Abs(got+23) > 0.1 {
+		t.Errorf("5.1 with LFE: %.2f LUFS, want -23", got)
+	}
+
+	if got := MeasureLUFS(make([]float64, 48000), 48000, 1); !math.IsInf(got, -1) {
+		t.Errorf("silence: %g LUFS, want -Inf", got)
+	}
+	if got := MeasureLUFS(tone[:2*1000], 48000, 2); !math.IsInf(got, -1) {
+		t.Errorf("shorter than a block: %g LUFS, want -Inf", got)
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	// A sine at a quarter of the sample rate sampled 45° off its peaks never has a sample above 0.707
+	data := make([]float64, 4000)
+	for i := range data {
+		data[i] = math.Sin(math.Pi/2*float64(i) + math.Pi/4)
+	}
+	if sample := peakLevel(data); sample > 0.71 {
+		t.Fatalf("sample peak %g, want about 0.707", sample)
+	}
+	if peak := TruePeak(data, 1); math.Abs(peak-1) > 0.02 {
+		t.Errorf("true peak %g, want 1", peak)
+	}
+}
+
+func TestLoudnessGain(t *testing.T) {
+	tone := toneChannels(1000, minus23dBFS, 48000, 2, 5) // -23 LUFS, true peak -23 dBTP
+
+	gain, loudness, limited := LoudnessGain(tone, 48000, 2, -16, -1)
+	if math.Abs(loudness+23) > 0.1 || limited {
+		t.Errorf("measured %.2f LUFS (limited %v), want -23 unlimited", loudness, limited)
+	}
+	if got := MeasureLUFS(ApplyGain(tone, gain), 48000, 2); math.Abs(got+16) > 0.1 {
+		t.Errorf("after gain: %.2f LUFS, want -16", got)
+	}
+
+	// Reaching 0 LUFS would need +23 dB, which the -1 dBTP ceiling holds back
+	gain, _, limited = LoudnessGain(tone, 48000, 2, 0, -1)
+	if !limited {
+		t.Error("gain above the true-peak ceiling was not limited")
+	}
+	if peak := 20 * math.Log10(TruePeak(ApplyGain(tone, gain), 2)); math.Abs(peak+1) > 0.01 {
+		t.Errorf("limited true peak %.2f dBTP, want -1", peak)
+	}
+
+	if gain, _, _ := LoudnessGain(make([]float64, 48000), 48000, 1, -16, -1); gain != 1 {
+		t.Errorf("silence gain %g, want 1", gain)
+	}
+}
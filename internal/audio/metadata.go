@@ -0,0 +1,95 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+
+	"github.com/go-audio/riff"
+	"github.com/go-audio/wav"
+)
+
+// maxTagChunkBytes bounds the LIST chunk read into memory, so a corrupt size cannot exhaust it
+const maxTagChunkBytes = 1 << 20
+
+// readWAVTags reads the text tags of the LIST/INFO chunk of a WAV stream and rewinds r
+// Chunks are skipped by seeking, so the audio is not read again. Cue points and sampler loops
+// refer to sample positions that padding and trimming move, so they are left out.
+// A missing or unreadable chunk returns nil, as tags never stop a file from loading.
+func readWAVTags(r io.ReadSeeker) *wav.Metadata {
+	defer r.Seek(0, io.SeekStart)
+
+	var header [12]byte
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil
+	}
+
+	// Walk the chunks; a LIST chunk holding anything other than INFO leaves the decoder's metadata unset
+	decoder := &wav.Decoder{}
+	for decoder.Metadata == nil {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			break
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		skip := size
+		if string(chunk[0:4]) == "LIST" && size <= maxTagChunkBytes {
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				break
+			}
+			if err := wav.DecodeListChunk(decoder, &riff.Chunk{ID: wav.CIDList, Size: len(data), R: bytes.NewReader(data)}); err != nil {
+				return nil
+			}
+			skip = 0
+		}
+		// Chunks are padded to an even size
+		if _, err := r.Seek(skip+size%2, io.SeekCurrent); err != nil {
+			break
+		}
+	}
+	if decoder.Metadata == nil {
+		return nil
+	}
+
+	tags := *decoder.Metadata
+	tags.SamplerInfo, tags.CuePoints = nil, nil
+	if reflect.ValueOf(tags).IsZero() {
+		return nil
+	}
+	return &tags
+}
+
+// WithComment returns a copy of tags with note appended to its comments (tags may be nil)
+func WithComment(tags *wav.Metadata, note string) *wav.Metadata {
+	var noted wav.Metadata
+	if tags != nil {
+		noted = *tags
+	}
+	if noted.Comments != "" {
+		noted.Comments += " "
+	}
+	noted.Comments += note
+	return &noted
+}
+
+// encodableTags pads each tag so its NUL-terminated length is even
+// The encoder does not add the pad byte RIFF requires after odd-sized entries, which the decoder then skips,
+// so an extra NUL keeps both the chunk valid and the tags readable.
+func encodableTags(tags *wav.Metadata) *wav.Metadata {
+	padded := *tags
+	for _, value := range []*string{
+		&padded.Artist, &padded.Comments, &padded.Copyright, &padded.CreationDate, &padded.Engineer,
+		&padded.Technician, &padded.Genre, &padded.Keywords, &padded.Medium, &padded.Title,
+		&padded.Product, &padded.Subject, &padded.Software, &padded.Source, &padded.Location, &padded.TrackNbr,
+	} {
+		if *value != "" && len(*value)%2 == 0 {
+			*value += "\x00"
+		}
+	}
+	return &padded
+}
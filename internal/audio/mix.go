@@ -0,0 +1,70 @@
+package audio
+
+import "math"
+
+// SumTracks mixes equal-rate mono tracks into one by adding them sample for sample
+// Shorter tracks count as silence past their end, so the mix is as long as the longest track.
+// If the sum exceeds full scale it is scaled down as a whole so that its peak is exactly 1.0.
+func SumTracks(tracks [][]float64) []float64 {
+	length := 0
+	for _, track := range tracks {
+		length = max(length, len(track))
+	}
+
+	mix := make([]float64, length)
+	for _, track := range tracks {
+		for i, sample := range track {
+			mix[i] += sample
+		}
+	}
+
+	// Leave headroom instead of letting the output clip
+	if peak := peakLevel(mix); peak > 1 {
+		for i := range mix {
+			mix[i] /= peak
+		}
+	}
+	return mix
+}
+
+// NormalizePeak returns a copy of data scaled so that its largest absolute sample is target
+// Silent data is returned unchanged
+func NormalizePeak(data []float64, target float64) []float64 {
+	peak := peakLevel(data)
+	if peak == 0 {
+		return data
+	}
+
+	result := make([]float64, len(data))
+	for i, sample := range data {
+		result[i] = sample * target / peak
+	}
+	return result
+}
+
+// ApplyGain returns a copy of data multiplied by gain
+func ApplyGain(data []float64, gain float64) []float64 {
+	result := make([]float64, len(data))
+	for i, sample := range data {
+		result[i] = sample * gain
+	}
+	return result
+}
+
+// InvertPolarity returns a copy of data with the sign of every sample flipped
+func InvertPolarity(data []float64) []float64 {
+	result := make([]float64, len(data))
+	for i, sample := range data {
+		result[i] = -sample
+	}
+	return result
+}
+
+// peakLevel returns the largest absolute sample value of data
+func peakLevel(data []float64) float64 {
+	peak := 0.0
+	for _, sample := range data {
+		peak = math.Max(peak, math.Abs(sample))
+	}
+	return peak
+}
@@ -0,0 +1,34 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSumTracks(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks [][]float64
+		want   []float64
+	}{
+		{"identical tracks double", [][]float64{{0.1, -0.2, 0.3}, {0.1, -0.2, 0.3}}, []float64{0.2, -0.4, 0.6}},
+		{"shorter track is silence past its end", [][]float64{{0.1, 0.1}, {0.2, 0.2, 0.2, 0.2}}, []float64{0.3, 0.3, 0.2, 0.2}},
+		{"scaled down to full scale", [][]float64{{0.8, -0.4}, {0.8, -0.4}}, []float64{1, -0.5}},
+		{"single track", [][]float64{{0.5, -0.5}}, []float64{0.5, -0.5}},
+		{"no tracks", nil, []float64{}},
+	}
+
+	for _, tt := range tests {
+		got := SumTracks(tt.tracks)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: SumTracks = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("%s: SumTracks = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MixdownMode selects how multi-channel audio is collapsed to mono
+type MixdownMode string
+
+const (
+	MixdownAverage   MixdownMode = "average"    // Equal-weight average of all channels
+	MixdownChannel   MixdownMode = "channel"    // A single channel (left, right or channel:N)
+	MixdownMaxEnergy MixdownMode = "max-energy" // The channel with the highest RMS level
+)
+
+// Mixdown is a mixdown strategy; the zero value averages all channels
+type Mixdown struct {
+	Mode    MixdownMode
+	Channel int // Zero-based channel index for MixdownChannel (0 = left, 1 = right)
+}
+
+// ParseMixdown converts a mixdown name (average, left, right, channel:N with N from 1, or max-energy) into a Mixdown
+func ParseMixdown(name string) (Mixdown, error) {
+	switch name {
+	case string(MixdownAverage):
+		return Mixdown{Mode: MixdownAverage}, nil
+	case "left":
+		return Mixdown{Mode: MixdownChannel, Channel: 0}, nil
+	case "right":
+		return Mixdown{Mode: MixdownChannel, Channel: 1}, nil
+	case string(MixdownMaxEnergy):
+		return Mixdown{Mode: MixdownMaxEnergy}, nil
+	}
+
+	if number, ok := strings.CutPrefix(name, string(MixdownChannel)+":"); ok {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 {
+			return Mixdown{}, fmt.Errorf("invalid mixdown channel %q (expected channel:N with N >= 1)", name)
+		}
+		return Mixdown{Mode: MixdownChannel, Channel: n - 1}, nil
+	}
+
+	return Mixdown{}, fmt.Errorf("unknown mixdown %q (expected average, left, right, channel:N or max-energy)", name)
+}
+
+// ToMonoMixdown converts multi-channel audio to mono with the given strategy
+// Mono data is returned unchanged whatever the strategy. Selecting a channel the audio
+// does not have is an error.
+func ToMonoMixdown(data []float64, channels int, mixdown Mixdown) ([]float64, error) {
+	if mixdown.Mode == "" || mixdown.Mode == MixdownAverage || channels == 1 {
+		return ToMono(data, channels)
+	}
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count: %d", channels)
+	}
+	if len(data)%channels != 0 {
+		return nil, fmt.Errorf("audio data length %d is not a multiple of %d channels", len(data), channels)
+	}
+
+	channel := mixdown.Channel
+	switch mixdown.Mode {
+	case MixdownChannel:
+		if channel < 0 || channel >= channels {
+			return nil, fmt.Errorf("mixdown channel %d is out of range for %d-channel audio", channel+1, channels)
+		}
+	case MixdownMaxEnergy:
+		channel = loudestChannel(data, channels)
+	default:
+		return nil, fmt.Errorf("unknown mixdown %q", mixdown.Mode)
+	}
+
+	return extractChannel(data, channels, channel), nil
+}
+
+// ToMonoMixdownRange converts frames [start, end) of multi-channel audio to mono with the given strategy
+// Only the range is allocated, so a short segment can be taken from a long file without collapsing it all.
+// The result equals that part of ToMonoMixdown (max-energy still picks the loudest channel of the whole data).
+func ToMonoMixdownRange(data []float64, channels int, mixdown Mixdown, start, end int) ([]float64, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count: %d", channels)
+	}
+	if len(data)%channels != 0 {
+		return nil, fmt.Errorf("audio data length %d is not a multiple of %d channels", len(data), channels)
+	}
+	if start < 0 || end > len(data)/channels || start > end {
+		return nil, fmt.Errorf("frame range [%d, %d) is out of bounds for %d frames", start, end, len(data)/channels)
+	}
+	if channels > 1 && mixdown.Mode == MixdownMaxEnergy {
+		mixdown = Mixdown{Mode: MixdownChannel, Channel: loudestChannel(data, channels)}
+	}
+	return ToMonoMixdown(data[start*channels:end*channels], channels, mixdown)
+}
+
+// loudestChannel returns the index of the channel with the highest energy (and so the highest RMS)
+func loudestChannel(data []float64, channels int) int {
+	energy := make([]float64, channels)
+	for i, sample := range data {
+		energy[i%channels] += sample * sample
+	}
+
+	loudest := 0
+	for ch := range energy {
+		if energy[ch] > energy[loudest] {
+			loudest = ch
+		}
+	}
+	return loudest
+}
+
+// extractChannel returns one channel of interleaved audio data
+func extractChannel(data []float64, channels, channel int) []float64 {
+	mono := make([]float64, len(data)/channels)
+	for i := range mono {
+		mono[i] = data[i*channels+channel]
+	}
+	return mono
+}
+
+// ToStereo converts audio to two interleaved channels: mono is copied to both, stereo is returned unchanged,
+// and audio with more channels is first collapsed to mono with the given strategy
+func ToStereo(data []float64, channels int, mixdown Mixdown) ([]float64, error) {
+	if channels == 2 {
+		return data, nil
+	}
+	mono, err := ToMonoMixdown(data, channels, mixdown)
+	if err != nil {
+		return nil, err
+	}
+
+	stereo := make([]float64, 2*len(mono))
+	for i, sample := range mono {
+		stereo[2*i], stereo[2*i+1] = sample, sample
+	}
+	return stereo, nil
+}
+
+// ToChannels converts audio to mono (target 1) with the given strategy or to stereo (target 2) as ToStereo does
+// Audio that already has target channels is returned unchanged.
+func ToChannels(data []float64, channels, target int, mixdown Mixdown) ([]float64, error) {
+	switch {
+	case channels == target:
+		return data, nil
+	case target == 1:
+		return ToMonoMixdown(data, channels, mixdown)
+	case target == 2:
+		return ToStereo(data, channels, mixdown)
+	default:
+		return nil, fmt.Errorf("cannot convert to %d channels (expected 1 or 2)", target)
+	}
+}
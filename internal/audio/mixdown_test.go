@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestToMonoMixdown(t *testing.T) {
+	// The voice is on the left channel only; the right channel holds faint noise
+	voice := []float64{0.5, -0.4, 0.3, -0.2}
+	stereo := make([]float64, 2*len(voice))
+	for i, v := range voice {
+		stereo[2*i], stereo[2*i+1] = v, 0.01*float64(i%2*2-1)
+	}
+	noise := []float64{-0.01, 0.01, -0.01, 0.01}
+	average := make([]float64, len(voice))
+	for i := range voice {
+		average[i] = (voice[i] + noise[i]) / 2
+	}
+
+	tests := []struct {
+		name    string
+		mixdown string
+		want    []float64
+		wantErr bool
+	}{
+		{"average halves the voice", "average", average, false},
+		{"left", "left", voice, false},
+		{"right", "right", noise, false},
+		{"channel:1", "channel:1", voice, false},
+		{"channel:2", "channel:2", noise, false},
+		{"max-energy picks the voice", "max-energy", voice, false},
+		{"channel out of range", "channel:3", nil, true},
+	}
+
+	for _, tt := range tests {
+		mixdown, err := ParseMixdown(tt.mixdown)
+		if err != nil {
+			t.Fatalf("%s: ParseMixdown: %v", tt.name, err)
+		}
+		got, err := ToMonoMixdown(stereo, 2, mixdown)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ToMonoMixdown = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("%s: ToMonoMixdown = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseMixdownInvalid(t *testing.T) {
+	for _, name := range []string{"", "center", "channel:0", "channel:x", "channel:-1"} {
+		if _, err := ParseMixdown(name); err == nil {
+			t.Errorf("ParseMixdown(%q) did not fail", name)
+		}
+	}
+}
+
+func TestToStereo(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []float64
+		channels int
+		mixdown  Mixdown
+		want     []float64
+	}{
+		{"mono is copied to both channels", []float64{0.5, -0.25}, 1, Mixdown{}, []float64{0.5, 0.5, -0.25, -0.25}},
+		{"stereo is unchanged", []float64{0.5, 0.1, -0.25, 0.2}, 2, Mixdown{}, []float64{0.5, 0.1, -0.25, 0.2}},
+		{"wider audio is mixed down first", []float64{0.3, 0.6, 0, -0.3, 0, 0}, 3, Mixdown{}, []float64{0.3, 0.3, -0.1, -0.1}},
+		{"with the selected strategy", []float64{0.3, 0.6, 0, -0.3, 0, 0}, 3, Mixdown{Mode: MixdownChannel, Channel: 1}, []float64{0.6, 0.6, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		got, err := ToStereo(tt.data, tt.channels, tt.mixdown)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestToMonoMixdownRange(t *testing.T) {
+	// The right channel is louder overall, but the left one is louder in the range
+	stereo := []float64{0.1, 0.9, 0.2, -0.8, 0.5, 0.1, -0.6, 0.2, 0.3, 0.7}
+	start, end := 2, 4
+
+	for _, name := range []string{"average", "left", "right", "max-energy"} {
+		mixdown, err := ParseMixdown(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		whole, err := ToMonoMixdown(stereo, 2, mixdown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ToMonoMixdownRange(stereo, 2, mixdown, start, end)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !slices.Equal(got, whole[start:end]) {
+			t.Errorf("%s: %v, want %v as from the whole file", name, got, whole[start:end])
+		}
+	}
+
+	for _, bounds := range [][2]int{{-1, 2}, {3, 6}, {3, 2}} {
+		if _, err := ToMonoMixdownRange(stereo, 2, Mixdown{}, bounds[0], bounds[1]); err == nil {
+			t.Errorf("range %v: no error", bounds)
+		}
+	}
+}
+
+func BenchmarkToMonoMixdownRange(b *testing.B) {
+	// 30 s of a 2.5 minute stereo file at 48 kHz, as fine-tuning takes
+	frames := benchmarkFrames / 4
+	stereo := make([]float64, 2*frames)
+	start, end := frames/2, frames/2+30*48000
+
+	b.Run("whole file", func(b *testing.B) {
+		reportPeakHeap(b, func() {
+			for range b.N {
+				mono, err := ToMonoMixdown(stereo, 2, Mixdown{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = slices.Clone(mono[start:end])
+			}
+		})
+	})
+	b.Run("segment", func(b *testing.B) {
+		reportPeakHeap(b, func() {
+			for range b.N {
+				if _, err := ToMonoMixdownRange(stereo, 2, Mixdown{}, start, end); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
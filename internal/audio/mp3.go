@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// LoadMP3 reads an MP3 file and returns its data in the same form as LoadWAV
+// The decoder always produces 16-bit stereo, so mono files come back as two identical channels.
+// MP3 encoders and decoders add a few milliseconds of delay at the start; correlation-based
+// alignment measures it along with the recording offset, so no special handling is needed.
+func LoadMP3(path string) (*WAVData, error) {
+	// Open MP3 file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MP3 file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Decode MP3
+	decoder, err := mp3.NewDecoder(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: MP3 file %s: %w", ErrInvalidFile, path, err)
+	}
+
+	pcm, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode MP3 data from %s: %w", path, err)
+	}
+
+	// Check if file contains any audio data
+	const channels, bitDepth = 2, 16
+	if len(pcm) < 2*channels {
+		return nil, fmt.Errorf("MP3 %w: %s", ErrNoAudioData, path)
+	}
+
+	// Convert 16-bit little-endian samples to float64 (normalized to -1.0 to 1.0)
+	data := make([]float64, len(pcm)/2)
+	maxVal := pcmScale(bitDepth)
+	for i := range data {
+		sample := int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+		data[i] = float64(sample) / float64(maxVal)
+	}
+
+	sampleRate := decoder.SampleRate()
+	return &WAVData{
+		Path:       path,
+		SampleRate: sampleRate,
+		Channels:   channels,
+		BitDepth:   bitDepth,
+		Data:       data,
+		Format: &audio.Format{
+			NumChannels: channels,
+			SampleRate:  sampleRate,
+		},
+	}, nil
+}
@@ -0,0 +1,25 @@
+package audio
+
+import "testing"
+
+func TestLoadMP3(t *testing.T) {
+	// 200 frames (about 5.2 s) of 22.05 kHz MPEG-2 speech, cut from the public domain sample of the go-mp3 decoder
+	const path = "testdata/speech.mp3"
+	loaded, err := LoadAudio(path)
+	if err != nil {
+		t.Fatalf("LoadAudio: %v", err)
+	}
+	if loaded.SampleRate != 22050 || loaded.Channels != 2 || loaded.BitDepth != 16 {
+		t.Errorf("got %d channels at %d Hz (%d-bit), want stereo at 22050 Hz (16-bit)", loaded.Channels, loaded.SampleRate, loaded.BitDepth)
+	}
+	if frames := len(loaded.Data) / loaded.Channels; frames < 5*22050 {
+		t.Fatalf("decoded %d frames, want at least 5 s", frames)
+	}
+	peak := 0.0
+	for _, v := range loaded.Data {
+		peak = max(peak, v, -v)
+	}
+	if peak == 0 || peak > 1 {
+		t.Errorf("decoded peak %g, want audio within full scale", peak)
+	}
+}
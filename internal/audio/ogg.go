@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// LoadOgg reads an Ogg Vorbis file and returns its data in the same form as LoadWAV
+// The decoder produces float samples, so the data is kept as 32-bit float like a float WAV file.
+func LoadOgg(path string) (*WAVData, error) {
+	// Open Ogg file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Ogg file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// Decode Vorbis (samples are interleaved float32)
+	samples, format, err := oggvorbis.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Ogg Vorbis file %s: %w", ErrInvalidFile, path, err)
+	}
+
+	// Check if file contains any audio data
+	if len(samples) < format.Channels {
+		return nil, fmt.Errorf("Ogg %w: %s", ErrNoAudioData, path)
+	}
+
+	data := make([]float64, len(samples))
+	for i, sample := range samples {
+		data[i] = float64(sample)
+	}
+
+	return &WAVData{
+		Path:       path,
+		SampleRate: format.SampleRate,
+		Channels:   format.Channels,
+		BitDepth:   32,
+		Float:      true,
+		Data:       data,
+		Format: &audio.Format{
+			NumChannels: format.Channels,
+			SampleRate:  format.SampleRate,
+		},
+	}, nil
+}
+
+// probeOgg reads the headers of an Ogg Vorbis file
+func probeOgg(f *os.File, path string) (*Info, error) {
+	length, format, err := oggvorbis.GetLength(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Ogg Vorbis file %s: %w", ErrInvalidFile, path, err)
+	}
+	return &Info{
+		SampleRate: format.SampleRate,
+		Channels:   format.Channels,
+		BitDepth:   32,
+		Frames:     int(length),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadOgg(t *testing.T) {
+	// A second of 44.1 kHz mono Ogg Vorbis
+	const path = "testdata/vorbis.ogg"
+	loaded, err := LoadAudio(path)
+	if err != nil {
+		t.Fatalf("LoadAudio: %v", err)
+	}
+	if loaded.SampleRate != 44100 || loaded.Channels != 1 || !loaded.Float {
+		t.Errorf("got %d channels at %d Hz (float %v), want mono at 44100 Hz as float", loaded.Channels, loaded.SampleRate, loaded.Float)
+	}
+	if len(loaded.Data) != 44100 {
+		t.Fatalf("decoded %d samples, want 44100", len(loaded.Data))
+	}
+	peak := 0.0
+	for _, v := range loaded.Data {
+		peak = max(peak, v, -v)
+	}
+	if peak == 0 {
+		t.Error("decoded audio is silent")
+	}
+
+	info, err := Probe(path)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if info.SampleRate != 44100 || info.Channels != 1 || info.Frames != len(loaded.Data) {
+		t.Errorf("Probe = %+v, want %d frames of 44100 Hz mono", info, len(loaded.Data))
+	}
+}
+
+func TestOpusUnsupported(t *testing.T) {
+	// Opus is out of scope: it is not taken for Ogg Vorbis by its extension
+	if IsSupported("guest.opus") {
+		t.Error("IsSupported(guest.opus) = true, want false")
+	}
+	if _, err := LoadAudio("guest.opus"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("LoadAudio error %v, want ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-audio/aiff"
+	"github.com/go-audio/wav"
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+)
+
+// decodedSampleBytes is the memory each sample takes once decoded: the integer sample
+// the decoder produces and the float64 it is converted to (both held until loading ends)
+const decodedSampleBytes = 8 + 8
+
+// Info describes an audio file without its samples
+type Info struct {
+	SampleRate int
+	Channels   int
+	BitDepth   int
+	Frames     int // Length in frames (samples per channel)
+}
+
+// Footprint estimates the peak memory in bytes used to load the file fully with LoadAudio:
+// the encoded samples read by the decoder plus the decoded integer and float64 samples
+func (info Info) Footprint() int64 {
+	samples := int64(info.Frames) * int64(info.Channels)
+	return samples * (int64(info.BitDepth)/8 + decodedSampleBytes)
+}
+
+// Probe reads only the header of an audio file, choosing the format from its extension
+// MP3 has no length in its header, so its frames are counted without decoding them.
+func Probe(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		decoder := wav.NewDecoder(f)
+		if !decoder.IsValidFile() {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+		}
+		if err := decoder.FwdToPCM(); err != nil {
+			return nil, fmt.Errorf("failed to find PCM data in %s: %w", path, err)
+		}
+		frameBytes := int64(decoder.NumChans) * int64(decoder.BitDepth) / 8
+		if frameBytes == 0 {
+			return nil, fmt.Errorf("%w: invalid format in %s", ErrInvalidWAV, path)
+		}
+		return &Info{
+			SampleRate: int(decoder.SampleRate),
+			Channels:   int(decoder.NumChans),
+			BitDepth:   int(decoder.BitDepth),
+			Frames:     int(decoder.PCMLen() / frameBytes),
+		}, nil
+
+	case ".flac":
+		stream, err := flac.New(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: FLAC file %s: %w", ErrInvalidFile, path, err)
+		}
+		return &Info{
+			SampleRate: int(stream.Info.SampleRate),
+			Channels:   int(stream.Info.NChannels),
+			BitDepth:   int(stream.Info.BitsPerSample),
+			Frames:     int(stream.Info.NSamples),
+		}, nil
+
+	case ".aif", ".aiff":
+		decoder := aiff.NewDecoder(f)
+		if !decoder.IsValidFile() {
+			return nil, fmt.Errorf("%w: AIFF file %s", ErrInvalidFile, path)
+		}
+		return &Info{
+			SampleRate: decoder.SampleRate,
+			Channels:   int(decoder.NumChans),
+			BitDepth:   int(decoder.BitDepth),
+			Frames:     int(decoder.NumSampleFrames),
+		}, nil
+
+	case ".mp3":
+		// The decoder always produces 16-bit stereo, as in LoadMP3
+		decoder, err := mp3.NewDecoder(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: MP3 file %s: %w", ErrInvalidFile, path, err)
+		}
+		return &Info{
+			SampleRate: decoder.SampleRate(),
+			Channels:   2,
+			BitDepth:   16,
+			Frames:     int(max(decoder.Length(), 0) / 4),
+		}, nil
+
+	case ".ogg", ".oga":
+		return probeOgg(f, path)
+
+	case ".raw", ".pcm":
+		// Headerless: the format comes from the registered spec and the length from the file size
+		spec := registeredRawSpec
+		if spec == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		return &Info{
+			SampleRate: spec.SampleRate,
+			Channels:   spec.Channels,
+			BitDepth:   spec.BitDepth,
+			Frames:     int(stat.Size() / int64(spec.Channels*spec.BitDepth/8)),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+}
@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// rawExtensions are the file extensions read as headerless PCM once a RawSpec is registered with UseRawSpec
+var rawExtensions = []string{".raw", ".pcm"}
+
+// RawSpec describes the sample format of headerless PCM data
+type RawSpec struct {
+	SampleRate int
+	Channels   int
+	BitDepth   int  // 8 (unsigned, as in WAV), 16, 24 or 32 bits per sample
+	Float      bool // Samples are 32-bit IEEE float (BitDepth is 32)
+	BigEndian  bool // Samples are stored most significant byte first
+}
+
+// Validate checks that the spec describes a format LoadRawPCM can decode
+func (s RawSpec) Validate() error {
+	if s.SampleRate <= 0 {
+		return fmt.Errorf("raw sample rate must be positive, got %d", s.SampleRate)
+	}
+	if s.Channels < 1 {
+		return fmt.Errorf("raw channel count must be at least 1, got %d", s.Channels)
+	}
+	switch s.BitDepth {
+	case 8, 16, 24:
+		if s.Float {
+			return fmt.Errorf("raw float samples must be 32 bits, got %d", s.BitDepth)
+		}
+	case 32:
+	default:
+		return fmt.Errorf("raw bit depth must be 8, 16, 24 or 32, got %d", s.BitDepth)
+	}
+	return nil
+}
+
+// UseRawSpec registers spec as the format of .raw and .pcm files, so LoadAudio, IsSupported and Probe accept them
+func UseRawSpec(spec RawSpec) {
+	for _, ext := range rawExtensions {
+		loaders[ext] = func(path string) (*WAVData, error) {
+			return LoadRawPCM(path, spec)
+		}
+	}
+	registeredRawSpec = &spec
+}
+
+// registeredRawSpec is the spec set by UseRawSpec (nil = raw files are not supported)
+var registeredRawSpec *RawSpec
+
+// LoadRawPCM reads a headerless PCM file in the format given by spec
+// A trailing partial frame is an error, as it means the spec does not match the data.
+func LoadRawPCM(path string, spec RawSpec) (*WAVData, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	sampleBytes := spec.BitDepth / 8
+	if len(raw)%(sampleBytes*spec.Channels) != 0 {
+		return nil, fmt.Errorf("%w: %s is %d bytes, not a whole number of %d-channel %d-bit frames",
+			ErrInvalidFile, path, len(raw), spec.Channels, spec.BitDepth)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoAudioData, path)
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if spec.BigEndian {
+		order = binary.BigEndian
+	}
+	data := make([]float64, len(raw)/sampleBytes)
+	for i := range data {
+		data[i] = rawSample(raw[i*sampleBytes:(i+1)*sampleBytes], order, spec)
+	}
+
+	return &WAVData{
+		Path:       path,
+		SampleRate: spec.SampleRate,
+		Channels:   spec.Channels,
+		BitDepth:   spec.BitDepth,
+		Float:      spec.Float,
+		Data:       data,
+	}, nil
+}
+
+// rawSample decodes one sample of b, which holds exactly spec.BitDepth/8 bytes, to float64
+// Integer samples are scaled like WAV samples by fromPCM.
+func rawSample(b []byte, order binary.ByteOrder, spec RawSpec) float64 {
+	switch spec.BitDepth {
+	case 8:
+		return fromPCM(int(b[0]), 8, false)
+	case 16:
+		return fromPCM(int(int16(order.Uint16(b))), 16, false)
+	case 24:
+		// Sign-extend the 24-bit value through the top of an int32
+		var v int32
+		if spec.BigEndian {
+			v = int32(uint32(b[0])<<24|uint32(b[1])<<16|uint32(b[2])<<8) >> 8
+		} else {
+			v = int32(uint32(b[2])<<24|uint32(b[1])<<16|uint32(b[0])<<8) >> 8
+		}
+		return fromPCM(int(v), 24, false)
+	default:
+		bits := order.Uint32(b)
+		if spec.Float {
+			return float64(math.Float32frombits(bits))
+		}
+		return fromPCM(int(int32(bits)), 32, false)
+	}
+}
@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadRawPCM(t *testing.T) {
+	floatLE := binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.25))
+	floatLE = binary.LittleEndian.AppendUint32(floatLE, math.Float32bits(-0.75))
+
+	tests := []struct {
+		name string
+		spec RawSpec
+		raw  []byte
+		want []float64
+	}{
+		{"8-bit unsigned", RawSpec{BitDepth: 8}, []byte{0xC0, 0x40, 0x80}, []float64{0.5, -0.5, 0}},
+		{"16-bit little-endian", RawSpec{BitDepth: 16}, []byte{0x00, 0x40, 0x00, 0xC0}, []float64{0.5, -0.5}},
+		{"16-bit big-endian", RawSpec{BitDepth: 16, BigEndian: true}, []byte{0x40, 0x00, 0xC0, 0x00}, []float64{0.5, -0.5}},
+		{"24-bit little-endian", RawSpec{BitDepth: 24}, []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xC0}, []float64{0.5, -0.5}},
+		{"24-bit big-endian", RawSpec{BitDepth: 24, BigEndian: true}, []byte{0x40, 0x00, 0x00, 0xC0, 0x00, 0x00}, []float64{0.5, -0.5}},
+		{"32-bit integer", RawSpec{BitDepth: 32}, []byte{0x00, 0x00, 0x00, 0x40, 0x00, 0x00, 0x00, 0xC0}, []float64{0.5, -0.5}},
+		{"32-bit float", RawSpec{BitDepth: 32, Float: true}, floatLE, []float64{0.25, -0.75}},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, "capture.raw")
+		if err := os.WriteFile(path, tt.raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+		tt.spec.SampleRate, tt.spec.Channels = 8000, 1
+		loaded, err := LoadRawPCM(path, tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !slices.Equal(loaded.Data, tt.want) {
+			t.Errorf("%s: samples %v, want %v", tt.name, loaded.Data, tt.want)
+		}
+		if loaded.SampleRate != 8000 || loaded.BitDepth != tt.spec.BitDepth || loaded.Float != tt.spec.Float {
+			t.Errorf("%s: %d Hz, %d-bit (float %v), want the spec", tt.name, loaded.SampleRate, loaded.BitDepth, loaded.Float)
+		}
+	}
+}
+
+func TestLoadRawPCMErrors(t *testing.T) {
+	dir := t.TempDir()
+	stereo16 := RawSpec{SampleRate: 8000, Channels: 2, BitDepth: 16}
+
+	tests := []struct {
+		name    string
+		spec    RawSpec
+		raw     []byte
+		wantErr error // nil = any error
+	}{
+		{"partial frame", stereo16, []byte{0, 0, 0, 0, 0, 0}, ErrInvalidFile},
+		{"empty", stereo16, nil, ErrNoAudioData},
+		{"no sample rate", RawSpec{Channels: 1, BitDepth: 16}, []byte{0, 0}, nil},
+		{"unsupported depth", RawSpec{SampleRate: 8000, Channels: 1, BitDepth: 12}, []byte{0, 0}, nil},
+		{"16-bit float", RawSpec{SampleRate: 8000, Channels: 1, BitDepth: 16, Float: true}, []byte{0, 0}, nil},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "capture.pcm")
+		if err := os.WriteFile(path, tt.raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadRawPCM(path, tt.spec)
+		if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package audio
+
+import "math"
+
+// Resample converts interleaved audio data from srcRate to dstRate using linear interpolation
+// Each channel is interpolated independently so the channel layout is preserved
+func Resample(data []float64, srcRate, dstRate, channels int) []float64 {
+	if srcRate == dstRate || srcRate <= 0 || dstRate <= 0 || len(data) == 0 {
+		return data
+	}
+
+	dstFrames := ResampledFrames(len(data)/channels, srcRate, dstRate)
+	return interpolate(data, channels, dstFrames, float64(srcRate)/float64(dstRate))
+}
+
+// ResampledFrames returns the number of frames Resample produces from frames at srcRate
+func ResampledFrames(frames, srcRate, dstRate int) int {
+	if srcRate == dstRate || srcRate <= 0 || dstRate <= 0 {
+		return frames
+	}
+	return int(int64(frames) * int64(dstRate) / int64(srcRate))
+}
+
+// Stretch changes the length of interleaved audio data by ratio using linear interpolation
+// A ratio above 1.0 lengthens the audio (e.g. 1.0001 adds 100 frames per million)
+func Stretch(data []float64, ratio float64, channels int) []float64 {
+	if ratio == 1.0 || ratio <= 0 || len(data) == 0 {
+		return data
+	}
+
+	srcFrames := len(data) / channels
+	dstFrames := int(float64(srcFrames) * ratio)
+	return interpolate(data, channels, dstFrames, 1/ratio)
+}
+
+// interpolate produces dstFrames output frames, reading the source at step frames per output frame
+func interpolate(data []float64, channels, dstFrames int, step float64) []float64 {
+	srcFrames := len(data) / channels
+
+	result := make([]float64, dstFrames*channels)
+	for i := 0; i < dstFrames; i++ {
+		// Position of this output frame on the source timeline
+		pos := float64(i) * step
+		idx := int(pos)
+		if idx >= srcFrames {
+			idx = srcFrames - 1
+		}
+		frac := pos - float64(idx)
+
+		next := idx + 1
+		if next >= srcFrames {
+			next = srcFrames - 1
+		}
+
+		for ch := 0; ch < channels; ch++ {
+			a := data[idx*channels+ch]
+			b := data[next*channels+ch]
+			result[i*channels+ch] = a + (b-a)*frac
+		}
+	}
+
+	return result
+}
+
+// fractionalDelayTaps is the number of windowed-sinc taps on each side of the FractionalDelay filter
+const fractionalDelayTaps = 16
+
+// FractionalDelay delays interleaved audio data by frac frames (-1 < frac < 1, negative = earlier)
+// using a Hann-windowed sinc interpolator. The result has the same length; frames beyond the ends
+// are treated as silence.
+func FractionalDelay(data []float64, frac float64, channels int) []float64 {
+	if frac == 0 || len(data) == 0 {
+		return data
+	}
+
+	// h[k] weights the input frame k frames before the output frame, normalized to unity DC gain
+	taps := make([]float64, 2*fractionalDelayTaps+1)
+	sum := 0.0
+	for i := range taps {
+		x := float64(i-fractionalDelayTaps) - frac
+		window := 0.5 * (1 + math.Cos(math.Pi*x/(fractionalDelayTaps+1)))
+		taps[i] = sinc(x) * window
+		sum += taps[i]
+	}
+	for i := range taps {
+		taps[i] /= sum
+	}
+
+	frames := len(data) / channels
+	result := make([]float64, len(data))
+	for n := 0; n < frames; n++ {
+		for i, h := range taps {
+			src := n - (i - fractionalDelayTaps)
+			if src < 0 || src >= frames {
+				continue
+			}
+			for ch := 0; ch < channels; ch++ {
+				result[n*channels+ch] += h * data[src*channels+ch]
+			}
+		}
+	}
+
+	return result
+}
+
+// sinc returns the normalized sinc function sin(πx)/(πx)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns frames of a stereo sine at freq Hz, with the right channel at half the level of the left
+func sine(freq float64, rate, frames int) []float64 {
+	data := make([]float64, frames*2)
+	for i := range frames {
+		v := math.Sin(2 * math.Pi * freq * float64(i) / float64(rate))
+		data[2*i] = v
+		data[2*i+1] = 0.5 * v
+	}
+	return data
+}
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		srcRate, dstRate int
+	}{
+		{48000, 44100},
+		{44100, 48000},
+		{22050, 44100},
+		{96000, 48000},
+	}
+
+	const freq = 440.0
+	for _, tt := range tests {
+		src := sine(freq, tt.srcRate, tt.srcRate) // One second
+		got := Resample(src, tt.srcRate, tt.dstRate, 2)
+
+		if frames := len(got) / 2; frames != tt.dstRate {
+			t.Errorf("%d -> %d Hz: %d frames, want %d", tt.srcRate, tt.dstRate, frames, tt.dstRate)
+			continue
+		}
+
+		// The resampled sine must still be freq Hz at the new rate, in both channels
+		// The last frames interpolate past the end of the source, which repeats its final frame
+		want := sine(freq, tt.dstRate, tt.dstRate)
+		maxErr := 0.0
+		for i := range len(got) - 8 {
+			maxErr = math.Max(maxErr, math.Abs(got[i]-want[i]))
+		}
+		if maxErr > 0.01 {
+			t.Errorf("%d -> %d Hz: max deviation from a %g Hz sine is %g", tt.srcRate, tt.dstRate, freq, maxErr)
+		}
+	}
+}
+
+func TestResampleSameRate(t *testing.T) {
+	src := sine(440, 44100, 100)
+	if got := Resample(src, 44100, 44100, 2); &got[0] != &src[0] {
+		t.Error("Resample at the same rate copied the data")
+	}
+}
+
+func TestResampledFrames(t *testing.T) {
+	if got := ResampledFrames(48000*3600, 48000, 44100); got != 44100*3600 {
+		t.Errorf("ResampledFrames(1h at 48 kHz) = %d, want %d", got, 44100*3600)
+	}
+}
+
+func TestFractionalDelay(t *testing.T) {
+	const rate, freq, frames = 44100, 1000.0, 4410
+	data := sine(freq, rate, frames)
+
+	for _, frac := range []float64{0.5, -0.25} {
+		delayed := FractionalDelay(data, frac, 2)
+		if len(delayed) != len(data) {
+			t.Fatalf("delay %g: %d samples, want %d", frac, len(delayed), len(data))
+		}
+		// Away from the ends, the output is the sine sampled frac frames earlier
+		for n := 2 * fractionalDelayTaps; n < frames-2*fractionalDelayTaps; n++ {
+			want := math.Sin(2 * math.Pi * freq * (float64(n) - frac) / rate)
+			if math.Abs(delayed[2*n]-want) > 1e-3 || math.Abs(delayed[2*n+1]-0.5*want) > 1e-3 {
+				t.Fatalf("delay %g: frame %d = %g, %g; want %g, %g", frac, n, delayed[2*n], delayed[2*n+1], want, 0.5*want)
+			}
+		}
+	}
+
+	if got := FractionalDelay(data, 0, 2); &got[0] != &data[0] {
+		t.Error("a zero delay copied the data")
+	}
+}
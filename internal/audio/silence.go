@@ -1,5 +1,10 @@
 package audio
 
+import "math"
+
+// silenceBlockSeconds is the length of the blocks TrimSilence measures
+const silenceBlockSeconds = 0.01
+
 // GenerateSilence creates silence samples of specified duration
 func GenerateSilence(numSamples int) []float64 {
 	return make([]float64, numSamples)
@@ -18,6 +23,77 @@ func PrependSilence(data []float64, silenceSamples int) []float64 {
 	return result
 }
 
+// AppendSilence adds silence to the end of audio data
+func AppendSilence(data []float64, samples int) []float64 {
+	if samples <= 0 {
+		return data
+	}
+	result := make([]float64, len(data)+samples)
+	copy(result, data)
+	return result
+}
+
+// TrimLeading removes samples frames from the beginning of interleaved audio data
+func TrimLeading(data []float64, samples, channels int) []float64 {
+	if samples <= 0 {
+		return data
+	}
+
+	trim := samples * channels
+	if trim >= len(data) {
+		return []float64{}
+	}
+	return data[trim:]
+}
+
+// FadeIn ramps the first frames of interleaved audio data linearly up from silence, in place
+// Used after prepended silence or a trimmed start, it avoids a click at the new boundary
+func FadeIn(data []float64, frames, channels int) {
+	fadeFrom(data, 0, frames, channels)
+}
+
+// fadeFrom applies the FadeIn ramp to data whose first frame is frame position of the faded signal
+// This lets streamed chunks continue a ramp that started in an earlier chunk
+func fadeFrom(data []float64, position, frames, channels int) {
+	for i := 0; position+i < frames && (i+1)*channels <= len(data); i++ {
+		gain := float64(position+i) / float64(frames)
+		for ch := 0; ch < channels; ch++ {
+			data[i*channels+ch] *= gain
+		}
+	}
+}
+
+// TrimSilence finds the active region of mono audio data, leaving out leading and trailing
+// blocks whose RMS level is below thresholdDB (dBFS, e.g. -50)
+// data[start:end] is the active region; if no block reaches the threshold the whole data is returned.
+func TrimSilence(data []float64, thresholdDB float64, sampleRate int) (start, end int) {
+	blockSize := max(int(silenceBlockSeconds*float64(sampleRate)), 1)
+	threshold := math.Pow(10, thresholdDB/20)
+
+	active := func(blockStart int) bool {
+		blockEnd := min(blockStart+blockSize, len(data))
+		sum := 0.0
+		for _, v := range data[blockStart:blockEnd] {
+			sum += v * v
+		}
+		return math.Sqrt(sum/float64(blockEnd-blockStart)) >= threshold
+	}
+
+	start = -1
+	for blockStart := 0; blockStart < len(data); blockStart += blockSize {
+		if active(blockStart) {
+			if start < 0 {
+				start = blockStart
+			}
+			end = min(blockStart+blockSize, len(data))
+		}
+	}
+	if start < 0 {
+		return 0, len(data)
+	}
+	return start, end
+}
+
 // SamplesToSeconds converts sample count to seconds
 func SamplesToSeconds(samples, sampleRate int) float64 {
 	return float64(samples) / float64(sampleRate)
@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestTrimLeading(t *testing.T) {
+	stereo := []float64{1, -1, 2, -2, 3, -3}
+	tests := []struct {
+		name    string
+		samples int
+		want    []float64
+	}{
+		{"none", 0, stereo},
+		{"whole frames", 2, []float64{3, -3}},
+		{"longer than the data", 5, []float64{}},
+	}
+
+	for _, tt := range tests {
+		if got := TrimLeading(stereo, tt.samples, 2); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: TrimLeading = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFadeIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		frames   int // Length of the data in frames
+		fade     int
+		channels int
+	}{
+		{"mono", 100, 20, 1},
+		{"stereo", 100, 20, 2},
+		{"longer than the data", 10, 20, 2},
+		{"no fade", 10, 0, 1},
+	}
+
+	for _, tt := range tests {
+		data := make([]float64, tt.frames*tt.channels)
+		for i := range data {
+			data[i] = 1
+		}
+		FadeIn(data, tt.fade, tt.channels)
+
+		for i := range tt.frames {
+			want := 1.0
+			if i < tt.fade {
+				want = float64(i) / float64(tt.fade)
+			}
+			for ch := range tt.channels {
+				if got := data[i*tt.channels+ch]; got != want {
+					t.Fatalf("%s: frame %d channel %d = %g, want %g", tt.name, i, ch, got, want)
+				}
+			}
+			// The ramp rises with every frame until the fade is over
+			if i > 0 && i < tt.fade && data[i*tt.channels] <= data[(i-1)*tt.channels] {
+				t.Errorf("%s: frame %d = %g does not rise above frame %d = %g", tt.name, i, data[i*tt.channels], i-1, data[(i-1)*tt.channels])
+			}
+		}
+	}
+}
+
+func TestFadeFromContinuesAcrossChunks(t *testing.T) {
+	whole := make([]float64, 2*50)
+	chunked := make([]float64, len(whole))
+	for i := range whole {
+		whole[i], chunked[i] = 0.5, 0.5
+	}
+	FadeIn(whole, 30, 2)
+	// Streamed copies fade each chunk from its position in the file
+	for start := 0; start < 50; start += 7 {
+		end := min(start+7, 50)
+		fadeFrom(chunked[2*start:2*end], start, 30, 2)
+	}
+
+	for i := range whole {
+		if chunked[i] != whole[i] {
+			t.Fatalf("sample %d = %g chunked, want %g", i, chunked[i], whole[i])
+		}
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	const rate = 8000
+	// 0.5 s of silence, 1 s at -10 dBFS, then 0.25 s of silence
+	data := make([]float64, rate*7/4)
+	for i := rate / 2; i < rate*3/2; i++ {
+		data[i] = 0.3 * math.Sin(float64(i))
+	}
+
+	tests := []struct {
+		name               string
+		data               []float64
+		thresholdDB        float64
+		wantStart, wantEnd int
+	}{
+		{"padded", data, -50, rate / 2, rate * 3 / 2},
+		{"threshold above the signal", data, -3, 0, len(data)},
+		{"all silent", make([]float64, rate), -50, 0, rate},
+		{"no silence", data[rate/2 : rate*3/2], -50, 0, rate},
+	}
+
+	for _, tt := range tests {
+		start, end := TrimSilence(tt.data, tt.thresholdDB, rate)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: TrimSilence = [%d, %d), want [%d, %d)", tt.name, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestAppendSilence(t *testing.T) {
+	stereo := []float64{1, -1, 2, -2}
+	tests := []struct {
+		name    string
+		samples int
+		want    []float64
+	}{
+		{"none", 0, stereo},
+		{"negative", -2, stereo},
+		{"two samples", 2, []float64{1, -1, 2, -2, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := AppendSilence(stereo, tt.samples); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: AppendSilence = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// StdinPath is the path that LoadAudio reads from standard input
+// The data must be WAV, since there is no file extension to choose a decoder from.
+const StdinPath = "-"
+
+// loadStdin reads WAV data from standard input
+// A pipe cannot seek, so its contents are buffered to a temporary file first.
+func loadStdin() (*WAVData, error) {
+	var data *WAVData
+	var err error
+	if _, seekErr := os.Stdin.Seek(0, io.SeekCurrent); seekErr == nil {
+		data, err = LoadWAVReader(os.Stdin)
+	} else {
+		data, err = loadBuffered(os.Stdin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV from stdin: %w", err)
+	}
+
+	data.Path = StdinPath
+	return data, nil
+}
+
+// loadBuffered copies r to a temporary file and decodes it as WAV
+func loadBuffered(r io.Reader) (*WAVData, error) {
+	tmp, err := os.CreateTemp("", "clapless-stdin-*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return nil, fmt.Errorf("failed to buffer input: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind buffered input: %w", err)
+	}
+
+	return LoadWAVReader(tmp)
+}
@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadWAVReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "piped.wav")
+	if err := WriteWAV(path, sine(440, 8000, 1000), 8000, 2, 16, false); err != nil {
+		t.Fatal(err)
+	}
+	want, err := LoadWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		load func() (*WAVData, error)
+	}{
+		{"seekable", func() (*WAVData, error) { return LoadWAVReader(bytes.NewReader(encoded)) }},
+		// A pipe cannot seek, so it is buffered to a temporary file first
+		{"pipe", func() (*WAVData, error) { return loadBuffered(struct{ io.Reader }{bytes.NewReader(encoded)}) }},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.load()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got.SampleRate != want.SampleRate || got.Channels != want.Channels || got.BitDepth != want.BitDepth || !slices.Equal(got.Data, want.Data) {
+			t.Errorf("%s: got %d Hz, %d channels, %d-bit, %d samples; want the file's %d Hz, %d channels, %d-bit, %d samples", tt.name,
+				got.SampleRate, got.Channels, got.BitDepth, len(got.Data), want.SampleRate, want.Channels, want.BitDepth, len(want.Data))
+		}
+	}
+
+	if _, err := LoadWAVReader(bytes.NewReader([]byte("not a wav file"))); err == nil {
+		t.Error("LoadWAVReader accepted data that is not WAV")
+	}
+}
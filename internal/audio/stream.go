@@ -0,0 +1,257 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// streamChunkFrames is the number of frames decoded per chunk when streaming
+const streamChunkFrames = 4096
+
+// streamWAV decodes a WAV file chunk by chunk without loading it into memory
+// fn receives whole interleaved frames normalized to -1.0 to 1.0; the slice is reused between calls
+func streamWAV(path string, fn func(chunk []float64, channels int) error) (*WAVData, error) {
+	// Open WAV file
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAV file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	float, err := wavEncoding(f, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode WAV
+	decoder := wav.NewDecoder(f)
+	if !decoder.IsValidFile() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+	}
+
+	// Read format information
+	format := decoder.Format()
+	channels := int(decoder.NumChans)
+	bitDepth := int(decoder.BitDepth)
+	if float && bitDepth != 32 {
+		return nil, fmt.Errorf("%w: %d-bit float WAV file %s", ErrUnsupportedFormat, bitDepth, path)
+	}
+
+	buf := &audio.IntBuffer{
+		Data:   make([]int, streamChunkFrames*channels),
+		Format: format,
+	}
+	chunk := make([]float64, len(buf.Data))
+	total := 0
+
+	for {
+		n, err := decoder.PCMBuffer(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PCM data from %s: %w", path, err)
+		}
+		if n == 0 {
+			break
+		}
+
+		// Drop a trailing partial frame from a truncated file
+		n -= n % channels
+		for i := 0; i < n; i++ {
+			chunk[i] = fromPCM(buf.Data[i], bitDepth, float)
+		}
+		if err := fn(chunk[:n], channels); err != nil {
+			return nil, err
+		}
+		total += n
+	}
+
+	// Check if file contains any audio data
+	if total == 0 {
+		return nil, fmt.Errorf("WAV %w: %s", ErrNoAudioData, path)
+	}
+
+	return &WAVData{
+		Path:       path,
+		SampleRate: int(decoder.SampleRate),
+		Channels:   channels,
+		BitDepth:   bitDepth,
+		Float:      float,
+		Format:     format,
+		Tags:       readWAVTags(f),
+	}, nil
+}
+
+// WAVFrameCount reads only the header of a WAV file and returns its sample rate and length in frames
+func WAVFrameCount(path string) (sampleRate, frames int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open WAV file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	decoder := wav.NewDecoder(f)
+	if !decoder.IsValidFile() {
+		return 0, 0, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+	}
+	if err := decoder.FwdToPCM(); err != nil {
+		return 0, 0, fmt.Errorf("failed to find PCM data in %s: %w", path, err)
+	}
+
+	frameBytes := int64(decoder.NumChans) * int64(decoder.BitDepth) / 8
+	if frameBytes == 0 {
+		return 0, 0, fmt.Errorf("%w: invalid format in %s", ErrInvalidWAV, path)
+	}
+	return int(decoder.SampleRate), int(decoder.PCMLen() / frameBytes), nil
+}
+
+// LoadWAVDownsampled streams a WAV file and returns mono data keeping only every factor-th frame
+// The returned Data is mono (Channels is 1) and SampleRate is the original rate,
+// so peak memory stays proportional to the decimated length rather than the file size
+func LoadWAVDownsampled(path string, factor int) (*WAVData, error) {
+	if factor < 1 {
+		factor = 1
+	}
+
+	var data []float64
+	frame := 0
+
+	info, err := streamWAV(path, func(chunk []float64, channels int) error {
+		for i := 0; i < len(chunk); i += channels {
+			if frame%factor == 0 {
+				sum := 0.0
+				for ch := 0; ch < channels; ch++ {
+					sum += chunk[i+ch]
+				}
+				data = append(data, sum/float64(channels))
+			}
+			frame++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info.Channels = 1
+	info.Data = data
+	info.DownsampleFactor = factor
+	return info, nil
+}
+
+// LoadWAVSegment streams a WAV file and returns the mono frames in [startFrame, endFrame)
+func LoadWAVSegment(path string, startFrame, endFrame int) ([]float64, error) {
+	if startFrame < 0 || startFrame >= endFrame {
+		return nil, fmt.Errorf("invalid segment bounds: [%d, %d)", startFrame, endFrame)
+	}
+
+	segment := make([]float64, 0, endFrame-startFrame)
+	frame := 0
+
+	_, err := streamWAV(path, func(chunk []float64, channels int) error {
+		for i := 0; i < len(chunk) && frame < endFrame; i += channels {
+			if frame >= startFrame {
+				sum := 0.0
+				for ch := 0; ch < channels; ch++ {
+					sum += chunk[i+ch]
+				}
+				segment = append(segment, sum/float64(channels))
+			}
+			frame++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segment) != endFrame-startFrame {
+		return nil, fmt.Errorf("segment [%d, %d) is out of bounds for %s (%d frames)", startFrame, endFrame, path, frame)
+	}
+	return segment, nil
+}
+
+// CopyWAVAligned streams srcPath into a new WAV file at dstPath,
+// prepending paddingFrames of silence and dropping the first trimFrames frames
+// bitDepth and float set the output sample format (bitDepth 0 = keep the source format),
+// and fadeFrames ramps in the first copied frames (see FadeIn); tags are written after the audio (nil = none)
+func CopyWAVAligned(srcPath, dstPath string, paddingFrames, trimFrames, bitDepth int, float bool, fadeFrames int, tags *wav.Metadata) error {
+	// Read the header first so the encoder can be configured before streaming
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open WAV file %s: %w", srcPath, err)
+	}
+	srcFloat, err := wavEncoding(src, srcPath)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	decoder := wav.NewDecoder(src)
+	valid := decoder.IsValidFile()
+	sampleRate := int(decoder.SampleRate)
+	channels := int(decoder.NumChans)
+	if bitDepth == 0 && !float {
+		bitDepth = int(decoder.BitDepth)
+		float = srcFloat
+	}
+	src.Close()
+	if !valid {
+		return fmt.Errorf("%w: %s", ErrInvalidWAV, srcPath)
+	}
+
+	// Create output file
+	f, err := os.Create(dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to create WAV file %s: %w", dstPath, err)
+	}
+	defer f.Close()
+
+	audioFormat, encode := wavFormatPCM, func(data []float64) []int { return toWAVPCM(data, bitDepth) }
+	if float {
+		audioFormat, bitDepth, encode = wavFormatIEEEFloat, 32, toFloatBits
+	}
+	encoder := wav.NewEncoder(f, sampleRate, bitDepth, channels, audioFormat)
+	if tags != nil {
+		encoder.Metadata = encodableTags(tags)
+	}
+	defer encoder.Close()
+
+	format := &audio.Format{
+		NumChannels: channels,
+		SampleRate:  sampleRate,
+	}
+	write := func(data []float64) error {
+		if err := encoder.Write(&audio.IntBuffer{Data: encode(data), Format: format}); err != nil {
+			return fmt.Errorf("failed to write WAV data to %s: %w", dstPath, err)
+		}
+		return nil
+	}
+
+	// Write silence in chunks
+	silence := GenerateSilence(streamChunkFrames * channels)
+	for remaining := paddingFrames; remaining > 0; remaining -= streamChunkFrames {
+		frames := min(remaining, streamChunkFrames)
+		if err := write(silence[:frames*channels]); err != nil {
+			return err
+		}
+	}
+
+	// Copy the source, skipping trimmed frames and fading in the first copied ones
+	toSkip := trimFrames * channels
+	copied := 0
+	_, err = streamWAV(srcPath, func(chunk []float64, _ int) error {
+		if toSkip >= len(chunk) {
+			toSkip -= len(chunk)
+			return nil
+		}
+		chunk = chunk[toSkip:]
+		toSkip = 0
+		if copied < fadeFrames {
+			fadeFrom(chunk, copied, fadeFrames, channels)
+		}
+		copied += len(chunk) / channels
+		return write(chunk)
+	})
+	return err
+}
@@ -0,0 +1,173 @@
+package audio
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeTestWAV writes frames of stereo 16-bit audio whose left channel counts up and right channel counts down
+func writeTestWAV(tb testing.TB, frames int) string {
+	tb.Helper()
+	data := make([]float64, frames*2)
+	for i := range frames {
+		v := float64(i%1000) / 1000
+		data[2*i] = v
+		data[2*i+1] = -v
+	}
+	path := filepath.Join(tb.TempDir(), "stream.wav")
+	if err := WriteWAV(path, data, 48000, 2, 16, false); err != nil {
+		tb.Fatalf("WriteWAV: %v", err)
+	}
+	return path
+}
+
+func TestLoadWAVDownsampled(t *testing.T) {
+	path := writeTestWAV(t, 3*streamChunkFrames+123) // Not a whole number of chunks
+	full, err := LoadWAV(path)
+	if err != nil {
+		t.Fatalf("LoadWAV: %v", err)
+	}
+	mono, err := ToMono(full.Data, full.Channels)
+	if err != nil {
+		t.Fatalf("ToMono: %v", err)
+	}
+
+	for _, factor := range []int{1, 7, 64} {
+		streamed, err := LoadWAVDownsampled(path, factor)
+		if err != nil {
+			t.Fatalf("LoadWAVDownsampled(%d): %v", factor, err)
+		}
+		if streamed.Channels != 1 || streamed.SampleRate != 48000 || streamed.DownsampleFactor != factor {
+			t.Errorf("factor %d: got %d channels at %d Hz, factor %d", factor, streamed.Channels, streamed.SampleRate, streamed.DownsampleFactor)
+		}
+		if want := (len(mono) + factor - 1) / factor; len(streamed.Data) != want {
+			t.Fatalf("factor %d: %d samples, want %d", factor, len(streamed.Data), want)
+		}
+		for i, v := range streamed.Data {
+			if v != mono[i*factor] {
+				t.Fatalf("factor %d: sample %d = %g, want %g", factor, i, v, mono[i*factor])
+			}
+		}
+		// The duration is counted in kept frames, so it may round up to the next multiple of the factor
+		if diff := streamed.Duration() - full.Duration(); diff < 0 || diff >= float64(factor)/48000 {
+			t.Errorf("factor %d: duration %g, want %g", factor, streamed.Duration(), full.Duration())
+		}
+	}
+}
+
+func TestLoadWAVSegment(t *testing.T) {
+	path := writeTestWAV(t, 2*streamChunkFrames)
+	full, _ := LoadWAV(path)
+
+	segment, err := LoadWAVSegment(path, streamChunkFrames-10, streamChunkFrames+10)
+	if err != nil {
+		t.Fatalf("LoadWAVSegment: %v", err)
+	}
+	for i, v := range segment {
+		frame := streamChunkFrames - 10 + i
+		if want := (full.Data[2*frame] + full.Data[2*frame+1]) / 2; v != want {
+			t.Errorf("frame %d = %g, want %g", frame, v, want)
+		}
+	}
+
+	if _, err := LoadWAVSegment(path, 0, 3*streamChunkFrames); err == nil {
+		t.Error("LoadWAVSegment past the end of the file did not fail")
+	}
+}
+
+func TestCopyWAVAligned(t *testing.T) {
+	src := writeTestWAV(t, 1000)
+	full, _ := LoadWAV(src)
+
+	tests := []struct {
+		name           string
+		padding, trim  int
+		wantFirstFrame int // Source frame at output frame padding
+	}{
+		{"padded", 5000, 0, 0},
+		{"trimmed", 0, 300, 300},
+	}
+
+	for _, tt := range tests {
+		dst := filepath.Join(t.TempDir(), "aligned.wav")
+		if err := CopyWAVAligned(src, dst, tt.padding, tt.trim, 0, false, 0, nil); err != nil {
+			t.Fatalf("%s: CopyWAVAligned: %v", tt.name, err)
+		}
+		out, err := LoadWAV(dst)
+		if err != nil {
+			t.Fatalf("%s: LoadWAV: %v", tt.name, err)
+		}
+		if frames := len(out.Data) / 2; frames != 1000+tt.padding-tt.trim {
+			t.Errorf("%s: %d frames, want %d", tt.name, frames, 1000+tt.padding-tt.trim)
+		}
+		for i := range 2 * tt.padding {
+			if out.Data[i] != 0 {
+				t.Fatalf("%s: padding sample %d = %g, want silence", tt.name, i, out.Data[i])
+			}
+		}
+		if got, want := out.Data[2*tt.padding+2], full.Data[2*tt.wantFirstFrame+2]; got != want {
+			t.Errorf("%s: first copied frame = %g, want %g", tt.name, got, want)
+		}
+	}
+}
+
+// reportPeakHeap runs fn and reports the highest heap use above the starting level, sampled every millisecond
+func reportPeakHeap(b *testing.B, fn func()) {
+	b.Helper()
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base, peak := stats.HeapAlloc, stats.HeapAlloc
+
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				peak = max(peak, stats.HeapAlloc)
+			}
+		}
+	}()
+	fn()
+	close(done)
+	<-sampled
+
+	b.ReportMetric(float64(peak-base)/(1<<20), "peak-MB")
+}
+
+// benchmarkFrames is 10 minutes of 48 kHz audio, a 230 MB WAV file in memory as float64
+const benchmarkFrames = 10 * 60 * 48000
+
+func BenchmarkLoadWAV(b *testing.B) {
+	path := writeTestWAV(b, benchmarkFrames)
+	b.ResetTimer()
+	reportPeakHeap(b, func() {
+		for range b.N {
+			if _, err := LoadWAV(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoadWAVDownsampled(b *testing.B) {
+	path := writeTestWAV(b, benchmarkFrames)
+	b.ResetTimer()
+	reportPeakHeap(b, func() {
+		for range b.N {
+			if _, err := LoadWAVDownsampled(path, 16); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
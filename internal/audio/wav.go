@@ -1,23 +1,40 @@
 package audio
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 	"os"
 
 	"github.com/go-audio/audio"
 	"github.com/go-audio/wav"
 )
 
+// WAV format tags (the fmt chunk's audio format field)
+const (
+	wavFormatPCM        = 1
+	wavFormatIEEEFloat  = 3
+	wavFormatExtensible = 0xFFFE // The actual format tag is the first two bytes of the SubFormat GUID
+)
+
 // WAVData represents WAV file metadata and audio data
 type WAVData struct {
 	Path       string
 	SampleRate int
 	Channels   int
 	BitDepth   int
+	Float      bool      // Samples are stored as 32-bit IEEE float (BitDepth is 32)
 	Data       []float64 // Audio data as float64 samples (normalized to -1.0 to 1.0)
 	Format     *audio.Format
+	Tags       *wav.Metadata // Text tags of the LIST/INFO chunk of a WAV file, written back by WriteWAVTagged (nil = none)
+
+	DownsampleFactor int // Decimation applied to Data by LoadWAVDownsampled (0 or 1 = full resolution)
 }
 
+// readerName stands in for the file path in errors from LoadWAVReader
+const readerName = "<reader>"
+
 // LoadWAV reads a WAV file and returns its data
 func LoadWAV(path string) (*WAVData, error) {
 	// Open WAV file
@@ -27,10 +44,31 @@ func LoadWAV(path string) (*WAVData, error) {
 	}
 	defer f.Close()
 
+	return decodeWAV(f, path)
+}
+
+// LoadWAVReader reads WAV data from r and returns it; the decoder needs to seek between chunks
+// The returned Path is empty.
+func LoadWAVReader(r io.ReadSeeker) (*WAVData, error) {
+	data, err := decodeWAV(r, readerName)
+	if err != nil {
+		return nil, err
+	}
+	data.Path = ""
+	return data, nil
+}
+
+// decodeWAV decodes a whole WAV stream, using path to identify it
+func decodeWAV(r io.ReadSeeker, path string) (*WAVData, error) {
+	float, err := wavEncoding(r, path)
+	if err != nil {
+		return nil, err
+	}
+
 	// Decode WAV
-	decoder := wav.NewDecoder(f)
+	decoder := wav.NewDecoder(r)
 	if !decoder.IsValidFile() {
-		return nil, fmt.Errorf("invalid WAV file: %s", path)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
 	}
 
 	// Read format information
@@ -38,6 +76,9 @@ func LoadWAV(path string) (*WAVData, error) {
 	sampleRate := int(decoder.SampleRate)
 	channels := int(decoder.NumChans)
 	bitDepth := int(decoder.BitDepth)
+	if float && bitDepth != 32 {
+		return nil, fmt.Errorf("%w: %d-bit float WAV file %s", ErrUnsupportedFormat, bitDepth, path)
+	}
 
 	// Read all audio data in chunks
 	const bufferSize = 4096
@@ -63,14 +104,14 @@ func LoadWAV(path string) (*WAVData, error) {
 
 	// Check if file contains any audio data
 	if len(allData) == 0 {
-		return nil, fmt.Errorf("WAV file contains no audio data: %s", path)
+		return nil, fmt.Errorf("WAV %w: %s", ErrNoAudioData, path)
 	}
 
 	// Convert int samples to float64 (normalized to -1.0 to 1.0)
+	// Uses the same 2^(bitDepth-1) scale as WriteWAV so that round-tripping preserves amplitude
 	data := make([]float64, len(allData))
-	maxVal := 1 << uint(bitDepth-1)
 	for i, sample := range allData {
-		data[i] = float64(sample) / float64(maxVal)
+		data[i] = fromPCM(sample, bitDepth, float)
 	}
 
 	return &WAVData{
@@ -78,13 +119,72 @@ func LoadWAV(path string) (*WAVData, error) {
 		SampleRate: sampleRate,
 		Channels:   channels,
 		BitDepth:   bitDepth,
+		Float:      float,
 		Data:       data,
 		Format:     format,
+		Tags:       readWAVTags(r),
 	}, nil
 }
 
+// wavEncoding reads the fmt chunk of a WAV stream, reporting whether its samples are IEEE float,
+// and rewinds r to the start. Anything other than integer PCM or float (e.g. ADPCM or µ-law) is rejected.
+// WAVE_FORMAT_EXTENSIBLE files use the tag in their SubFormat; their valid bits are left-justified
+// in the container (BitsPerSample), so they decode like plain PCM of the container depth.
+func wavEncoding(r io.ReadSeeker, path string) (bool, error) {
+	defer r.Seek(0, io.SeekStart)
+
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return false, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+	}
+
+	// Walk the chunks until the fmt chunk
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return false, fmt.Errorf("%w: no fmt chunk in %s", ErrInvalidWAV, path)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) != "fmt " {
+			// Chunks are padded to an even size
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return false, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+			}
+			continue
+		}
+
+		fmtChunk := make([]byte, size)
+		if size < 16 {
+			return false, fmt.Errorf("%w: short fmt chunk in %s", ErrInvalidWAV, path)
+		}
+		if _, err := io.ReadFull(r, fmtChunk); err != nil {
+			return false, fmt.Errorf("%w: %s", ErrInvalidWAV, path)
+		}
+
+		tag := binary.LittleEndian.Uint16(fmtChunk[0:2])
+		if tag == wavFormatExtensible && size >= 40 {
+			tag = binary.LittleEndian.Uint16(fmtChunk[24:26])
+		}
+		switch tag {
+		case wavFormatPCM:
+			return false, nil
+		case wavFormatIEEEFloat:
+			return true, nil
+		default:
+			return false, fmt.Errorf("%w: WAV encoding 0x%04X in %s (only integer PCM and 32-bit float are supported)",
+				ErrUnsupportedFormat, tag, path)
+		}
+	}
+}
+
 // WriteWAV writes audio data to a WAV file
-func WriteWAV(path string, data []float64, sampleRate, channels, bitDepth int) error {
+// With float set, samples are written unclamped as 32-bit IEEE float and bitDepth is ignored
+func WriteWAV(path string, data []float64, sampleRate, channels, bitDepth int, float bool) error {
+	return WriteWAVTagged(path, data, sampleRate, channels, bitDepth, float, nil)
+}
+
+// WriteWAVTagged is WriteWAV that also stores tags in a LIST/INFO chunk after the audio (nil = none)
+func WriteWAVTagged(path string, data []float64, sampleRate, channels, bitDepth int, float bool, tags *wav.Metadata) error {
 	// Create output file
 	f, err := os.Create(path)
 	if err != nil {
@@ -93,25 +193,20 @@ func WriteWAV(path string, data []float64, sampleRate, channels, bitDepth int) e
 	defer f.Close()
 
 	// Create encoder
-	encoder := wav.NewEncoder(f, sampleRate, bitDepth, channels, 1)
-	defer encoder.Close()
-
-	// Convert float64 samples back to int
-	maxVal := 1 << uint(bitDepth-1)
-	intData := make([]int, len(data))
-	for i, sample := range data {
-		// Clamp to [-1.0, 1.0] range
-		if sample > 1.0 {
-			sample = 1.0
-		} else if sample < -1.0 {
-			sample = -1.0
-		}
-		intData[i] = int(sample * float64(maxVal))
+	audioFormat := wavFormatPCM
+	samples := toWAVPCM(data, bitDepth)
+	if float {
+		audioFormat, bitDepth = wavFormatIEEEFloat, 32
+		samples = toFloatBits(data)
+	}
+	encoder := wav.NewEncoder(f, sampleRate, bitDepth, channels, audioFormat)
+	if tags != nil {
+		encoder.Metadata = encodableTags(tags)
 	}
 
 	// Create buffer
 	buf := &audio.IntBuffer{
-		Data: intData,
+		Data: samples,
 		Format: &audio.Format{
 			NumChannels: channels,
 			SampleRate:  sampleRate,
@@ -123,13 +218,135 @@ func WriteWAV(path string, data []float64, sampleRate, channels, bitDepth int) e
 		return fmt.Errorf("failed to write WAV data to %s: %w", path, err)
 	}
 
-	return nil
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to finalize WAV file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// InterleaveChannels writes equal-rate tracks of trackChannels interleaved channels each into a single file,
+// the channels of each track following those of the previous one
+// The encoder is chosen from the path's extension
+// Shorter tracks are padded with silence at the end to the length of the longest one
+func InterleaveChannels(path string, tracks [][]float64, trackChannels, sampleRate, bitDepth int, float bool) error {
+	data, err := Interleave(tracks, trackChannels)
+	if err != nil {
+		return err
+	}
+	return WriteAudio(path, data, sampleRate, len(tracks)*trackChannels, bitDepth, float)
+}
+
+// Interleave combines tracks of trackChannels interleaved channels each into audio with all their channels,
+// the channels of each track following those of the previous one
+// Shorter tracks are padded with silence at the end to the length of the longest one
+func Interleave(tracks [][]float64, trackChannels int) ([]float64, error) {
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("no tracks to interleave")
+	}
+
+	if trackChannels < 1 {
+		return nil, fmt.Errorf("invalid channel count: %d", trackChannels)
+	}
+
+	// Find the common length in frames
+	length := 0
+	for _, track := range tracks {
+		if len(track)%trackChannels != 0 {
+			return nil, fmt.Errorf("track length %d is not a multiple of %d channels", len(track), trackChannels)
+		}
+		if frames := len(track) / trackChannels; frames > length {
+			length = frames
+		}
+	}
+
+	// Interleave tracks; samples past the end of a track stay zero
+	channels := len(tracks) * trackChannels
+	data := make([]float64, length*channels)
+	for t, track := range tracks {
+		for i, sample := range track {
+			frame, ch := i/trackChannels, i%trackChannels
+			data[frame*channels+t*trackChannels+ch] = sample
+		}
+	}
+	return data, nil
+}
+
+// wavUnsignedBias is the midpoint of 8-bit WAV samples, which unlike every other depth are unsigned
+const wavUnsignedBias = 128
+
+// pcmScale returns the full-scale value 2^(bitDepth-1) of signed integer PCM
+// It is computed in 64 bits so 32-bit samples do not overflow on 32-bit platforms
+func pcmScale(bitDepth int) int64 {
+	return int64(1) << uint(bitDepth-1)
+}
+
+// toPCM converts float64 samples back to signed integer PCM values
+func toPCM(data []float64, bitDepth int) []int {
+	maxVal := pcmScale(bitDepth)
+	intData := make([]int, len(data))
+	for i, sample := range data {
+		// Clamp to [-1.0, 1.0] range
+		if sample > 1.0 {
+			sample = 1.0
+		} else if sample < -1.0 {
+			sample = -1.0
+		}
+		value := int64(sample * float64(maxVal))
+		// Signed PCM range is asymmetric (-maxVal to maxVal-1), so +1.0 would wrap around
+		if value > maxVal-1 {
+			value = maxVal - 1
+		}
+		intData[i] = int(value)
+	}
+	return intData
+}
+
+// toWAVPCM converts float64 samples to WAV integer PCM values, offsetting 8-bit samples to unsigned
+func toWAVPCM(data []float64, bitDepth int) []int {
+	intData := toPCM(data, bitDepth)
+	if bitDepth == 8 {
+		for i := range intData {
+			intData[i] += wavUnsignedBias
+		}
+	}
+	return intData
+}
+
+// toFloatBits stores float64 samples as the bit patterns of 32-bit floats
+// The WAV encoder writes 32-bit values verbatim, so this produces IEEE float sample data
+func toFloatBits(data []float64) []int {
+	bits := make([]int, len(data))
+	for i, sample := range data {
+		bits[i] = int(int32(math.Float32bits(float32(sample))))
+	}
+	return bits
+}
+
+// fromPCM converts a decoded WAV sample to float64, scaling integer PCM by pcmScale
+// Float samples arrive from the decoder as the bit patterns of 32-bit floats,
+// and 8-bit samples are unsigned around wavUnsignedBias
+func fromPCM(sample, bitDepth int, float bool) float64 {
+	if float {
+		return float64(math.Float32frombits(uint32(sample)))
+	}
+	if bitDepth == 8 {
+		sample -= wavUnsignedBias
+	}
+	return float64(sample) / float64(pcmScale(bitDepth))
 }
 
 // ToMono converts stereo (or multi-channel) audio to mono by averaging channels
-func ToMono(data []float64, channels int) []float64 {
+// It returns an error if the data does not contain a whole number of frames,
+// which indicates a corrupt or truncated file
+func ToMono(data []float64, channels int) ([]float64, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count: %d", channels)
+	}
+	if len(data)%channels != 0 {
+		return nil, fmt.Errorf("audio data length %d is not a multiple of %d channels", len(data), channels)
+	}
 	if channels == 1 {
-		return data
+		return data, nil
 	}
 
 	numSamples := len(data) / channels
@@ -143,12 +360,15 @@ func ToMono(data []float64, channels int) []float64 {
 		mono[i] = sum / float64(channels)
 	}
 
-	return mono
+	return mono, nil
 }
 
 // Duration returns the duration of the audio in seconds
 func (w *WAVData) Duration() float64 {
 	totalSamples := len(w.Data) / w.Channels
+	if w.DownsampleFactor > 1 {
+		totalSamples *= w.DownsampleFactor
+	}
 	return float64(totalSamples) / float64(w.SampleRate)
 }
 
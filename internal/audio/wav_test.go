@@ -0,0 +1,313 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/wav"
+)
+
+// roundTrip writes data as a WAV file with the given format and loads it back
+func roundTrip(t *testing.T, data []float64, channels, bitDepth int, float bool) *WAVData {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "roundtrip.wav")
+	if err := WriteWAV(path, data, 44100, channels, bitDepth, float); err != nil {
+		t.Fatalf("WriteWAV: %v", err)
+	}
+	loaded, err := LoadWAV(path)
+	if err != nil {
+		t.Fatalf("LoadWAV: %v", err)
+	}
+	return loaded
+}
+
+func TestWriteWAVFullScale(t *testing.T) {
+	tests := []struct {
+		bitDepth int
+		input    float64
+		want     float64 // Tolerance is one quantization step
+	}{
+		{16, 1.0, 1.0},
+		{16, -1.0, -1.0},
+		{16, 1.5, 1.0},
+		{16, -1.5, -1.0},
+		{24, 1.0, 1.0},
+		{24, -1.0, -1.0},
+	}
+
+	for _, tt := range tests {
+		loaded := roundTrip(t, []float64{tt.input, 0}, 1, tt.bitDepth, false)
+		step := 1 / float64(pcmScale(tt.bitDepth))
+		if got := loaded.Data[0]; math.Abs(got-tt.want) > step {
+			t.Errorf("%d-bit %+g: read back %g, want %g", tt.bitDepth, tt.input, got, tt.want)
+		}
+		// A positive full-scale sample must not wrap around to the negative extreme
+		if tt.input > 0 && loaded.Data[0] < 0 {
+			t.Errorf("%d-bit %+g wrapped around to %g", tt.bitDepth, tt.input, loaded.Data[0])
+		}
+	}
+}
+
+func TestToPCMClampsPositiveFullScale(t *testing.T) {
+	for _, bitDepth := range []int{16, 24} {
+		maxVal := pcmScale(bitDepth)
+		got := toPCM([]float64{1.0, -1.0}, bitDepth)
+		if int64(got[0]) != maxVal-1 || int64(got[1]) != -maxVal {
+			t.Errorf("toPCM(±1.0, %d) = %v, want [%d %d]", bitDepth, got, maxVal-1, -maxVal)
+		}
+	}
+}
+
+func TestToMono(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []float64
+		channels int
+		want     []float64
+		wantErr  bool
+	}{
+		{"mono unchanged", []float64{0.1, 0.2, 0.3}, 1, []float64{0.1, 0.2, 0.3}, false},
+		{"stereo averaged", []float64{1, 0, 0.5, -0.5}, 2, []float64{0.5, 0}, false},
+		{"three channels", []float64{0.3, 0.6, 0.9}, 3, []float64{0.6}, false},
+		{"empty", nil, 2, []float64{}, false},
+		{"odd length stereo", []float64{0.1, 0.2, 0.3}, 2, nil, true},
+		{"truncated frame", []float64{0.1, 0.2, 0.3, 0.4}, 3, nil, true},
+		{"no channels", []float64{0.1}, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ToMono(tt.data, tt.channels)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ToMono = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("%s: ToMono = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteWAVFloat(t *testing.T) {
+	// Float output keeps values beyond full scale and needs no quantization
+	data := []float64{0, 0.123456789, -0.5, 1.5, -2.25, 1e-6}
+	loaded := roundTrip(t, data, 2, 16, true) // The bit depth is ignored for float output
+
+	if !loaded.Float || loaded.BitDepth != 32 {
+		t.Fatalf("read %d-bit (float %v), want 32-bit float", loaded.BitDepth, loaded.Float)
+	}
+	for i, want := range data {
+		if got := loaded.Data[i]; got != float64(float32(want)) {
+			t.Errorf("sample %d = %g, want %g", i, got, float64(float32(want)))
+		}
+	}
+}
+
+func TestWAVRoundTripBitDepths(t *testing.T) {
+	data := []float64{0, 0.5, -0.5, 0.999, -1, 0.25, -0.125, 0.0078125}
+	for _, bitDepth := range []int{8, 16, 24, 32} {
+		loaded := roundTrip(t, data, 2, bitDepth, false)
+		if loaded.BitDepth != bitDepth || loaded.Float {
+			t.Errorf("%d-bit: read %d-bit (float %v)", bitDepth, loaded.BitDepth, loaded.Float)
+		}
+		// Amplitude and sign survive within one quantization step
+		step := 1 / float64(pcmScale(bitDepth))
+		for i, want := range data {
+			if got := loaded.Data[i]; math.Abs(got-want) > step {
+				t.Errorf("%d-bit: sample %d = %g, want %g", bitDepth, i, got, want)
+			}
+		}
+	}
+}
+
+func TestWAV8BitUnsigned(t *testing.T) {
+	// 8-bit WAV stores silence as the midpoint 128, full negative scale as 0 and positive as 255
+	path := filepath.Join(t.TempDir(), "8bit.wav")
+	if err := WriteWAV(path, []float64{0, -1, 1}, 8000, 1, 8, false); err != nil {
+		t.Fatalf("WriteWAV: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := raw[len(raw)-3:]; got[0] != 128 || got[1] != 0 || got[2] != 255 {
+		t.Errorf("8-bit samples stored as %v, want [128 0 255]", got)
+	}
+}
+
+func TestInterleaveChannels(t *testing.T) {
+	tests := []struct {
+		name          string
+		tracks        [][]float64
+		trackChannels int
+		want          []float64
+	}{
+		{"mono tracks, the shorter padded", [][]float64{{0.1, 0.2, 0.3}, {-0.1}, {0.5, 0.5}}, 1,
+			[]float64{0.1, -0.1, 0.5, 0.2, 0, 0.5, 0.3, 0, 0}},
+		{"stereo tracks", [][]float64{{0.1, 0.2, 0.3, 0.4}, {-0.1, -0.2}}, 2,
+			[]float64{0.1, 0.2, -0.1, -0.2, 0.3, 0.4, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "combined.wav")
+		if err := InterleaveChannels(path, tt.tracks, tt.trackChannels, 44100, 32, true); err != nil {
+			t.Fatalf("%s: InterleaveChannels: %v", tt.name, err)
+		}
+		loaded, err := LoadWAV(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// One channel per input track channel
+		if want := len(tt.tracks) * tt.trackChannels; loaded.Channels != want {
+			t.Errorf("%s: %d channels, want %d", tt.name, loaded.Channels, want)
+		}
+		if len(loaded.Data) != len(tt.want) {
+			t.Fatalf("%s: %d samples, want %d", tt.name, len(loaded.Data), len(tt.want))
+		}
+		for i, v := range loaded.Data {
+			if math.Abs(v-tt.want[i]) > 1e-7 {
+				t.Errorf("%s: sample %d = %g, want %g", tt.name, i, v, tt.want[i])
+			}
+		}
+	}
+
+	if _, err := Interleave([][]float64{{0.1, 0.2, 0.3}}, 2); err == nil {
+		t.Error("Interleave accepted a track that is not whole frames")
+	}
+}
+
+// writeFormatWAV writes 16-bit mono samples with a hand-built fmt chunk carrying tag,
+// wrapped in WAVE_FORMAT_EXTENSIBLE if extensible is set
+func writeFormatWAV(t *testing.T, tag uint16, extensible bool, samples []int16) string {
+	t.Helper()
+	const rate, blockAlign, bits = 8000, 2, 16
+
+	fmtChunk := binary.LittleEndian.AppendUint16(nil, tag)
+	if extensible {
+		fmtChunk = binary.LittleEndian.AppendUint16(nil, wavFormatExtensible)
+	}
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, 1)
+	fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, rate)
+	fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, rate*blockAlign)
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, blockAlign)
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, bits)
+	if extensible {
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, 22)   // Extension size
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, bits) // Valid bits per sample
+		fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, 0x4)  // Channel mask: front center
+		// SubFormat GUID: the format tag followed by the fixed KSDATAFORMAT suffix
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, tag)
+		fmtChunk = append(fmtChunk, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71)
+	}
+
+	var dataChunk []byte
+	for _, s := range samples {
+		dataChunk = binary.LittleEndian.AppendUint16(dataChunk, uint16(s))
+	}
+
+	file := []byte("RIFF")
+	file = binary.LittleEndian.AppendUint32(file, uint32(4+8+len(fmtChunk)+8+len(dataChunk)))
+	file = append(file, "WAVEfmt "...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(fmtChunk)))
+	file = append(file, fmtChunk...)
+	file = append(file, "data"...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(dataChunk)))
+	file = append(file, dataChunk...)
+
+	path := filepath.Join(t.TempDir(), "format.wav")
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWAVFormatTags(t *testing.T) {
+	samples := []int16{0, 16384, -16384, 32767, -32768, 8192}
+
+	// Extensible PCM decodes like plain PCM
+	for _, extensible := range []bool{false, true} {
+		loaded, err := LoadWAV(writeFormatWAV(t, wavFormatPCM, extensible, samples))
+		if err != nil {
+			t.Fatalf("extensible %v: %v", extensible, err)
+		}
+		if loaded.BitDepth != 16 || loaded.Channels != 1 || loaded.Float || len(loaded.Data) != len(samples) {
+			t.Fatalf("extensible %v: %d-bit, %d channels, float %v, %d samples; want 16-bit mono PCM with %d samples",
+				extensible, loaded.BitDepth, loaded.Channels, loaded.Float, len(loaded.Data), len(samples))
+		}
+		for i, s := range samples {
+			if want := float64(s) / float64(pcmScale(16)); math.Abs(loaded.Data[i]-want) > 1e-9 {
+				t.Errorf("extensible %v: sample %d = %g, want %g", extensible, i, loaded.Data[i], want)
+			}
+		}
+	}
+
+	// Compressed encodings are rejected instead of being read as PCM, plain or inside an extensible chunk
+	const wavFormatADPCM = 0x0002
+	for _, extensible := range []bool{false, true} {
+		if _, err := LoadWAV(writeFormatWAV(t, wavFormatADPCM, extensible, samples)); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("ADPCM (extensible %v): error %v, want ErrUnsupportedFormat", extensible, err)
+		}
+	}
+}
+
+func TestWAVTagsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	data := sine(440, 8000, 800)
+	// Tags of odd and even length, as the RIFF padding differs
+	tags := &wav.Metadata{Artist: "Alice", Title: "Interview", Comments: "take 2"}
+
+	path := filepath.Join(dir, "tagged.wav")
+	if err := WriteWAVTagged(path, data, 8000, 2, 16, false, tags); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWAV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameTags(loaded.Tags, tags) {
+		t.Fatalf("tags %+v, want %+v", loaded.Tags, tags)
+	}
+
+	// The note is appended to the comments and the other tags are kept
+	notedPath := filepath.Join(dir, "noted.wav")
+	if err := WriteWAVTagged(notedPath, loaded.Data, 8000, 2, 16, false, WithComment(loaded.Tags, "Synced.")); err != nil {
+		t.Fatal(err)
+	}
+	noted, err := LoadWAV(notedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *tags
+	want.Comments = "take 2 Synced."
+	if !sameTags(noted.Tags, &want) {
+		t.Errorf("tags %+v, want %+v", noted.Tags, want)
+	}
+	if len(noted.Data) != len(data) {
+		t.Errorf("%d samples, want %d", len(noted.Data), len(data))
+	}
+
+	// A file without tags loads without any
+	plain := filepath.Join(dir, "plain.wav")
+	if err := WriteWAV(plain, data, 8000, 2, 16, false); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadWAV(plain); err != nil || loaded.Tags != nil {
+		t.Errorf("untagged file: tags %+v (err %v), want none", loaded.Tags, err)
+	}
+}
+
+// sameTags reports whether the text tags of got are those of want
+func sameTags(got, want *wav.Metadata) bool {
+	return got != nil && got.Artist == want.Artist && got.Title == want.Title && got.Comments == want.Comments &&
+		got.Genre == want.Genre && got.Software == want.Software
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// cacheVersion is bumped whenever detection changes in a way that makes cached offsets stale
+const cacheVersion = 1
+
+// offsetCache stores detected coarse offsets on disk, keyed by a hash of the mixed and local tracks
+// and of the detection settings, so unchanged inputs skip the correlation on the next run
+type offsetCache struct {
+	dir   string        // Directory the cached offsets are stored in
+	mixed []byte        // Digest of the mixed track detections run against (set by against)
+	hits  *atomic.Int32 // Number of offsets read from the cache
+}
+
+// offsetCache returns the cache selected with --cache-dir, or nil if --no-cache is set,
+// the correlation is dumped or a custom detector is used (both need every detection to run)
+func (c *Config) offsetCache() *offsetCache {
+	if c.NoCache || c.DumpCorrelationDir != "" || c.Detector != nil {
+		return nil
+	}
+	dir := c.CacheDir
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			warnf("⚠️  Cannot locate the cache directory, detecting every offset: %v\n", err)
+			return nil
+		}
+		dir = filepath.Join(userDir, "clapless", "offsets")
+	}
+	return &offsetCache{dir: dir, hits: new(atomic.Int32)}
+}
+
+// against returns the cache for detections against the mono mixed track, hashing it once
+// A nil cache returns nil.
+func (oc *offsetCache) against(mixed []float64, sampleRate int) *offsetCache {
+	if oc == nil {
+		return nil
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "clapless offsets v%d\n%d\n", cacheVersion, sampleRate)
+	hashSamples(h, mixed)
+	scoped := *oc
+	scoped.mixed = h.Sum(nil)
+	return &scoped
+}
+
+// detect returns the cached offset of local for opts, or runs detect and caches its result
+// Failing to write the cache only warns, as the offset is still valid. A nil cache always runs detect.
+func (oc *offsetCache) detect(opts audiosync.DetectOptions, localPath string, local []float64, detect func() (*audiosync.OffsetResult, error)) (*audiosync.OffsetResult, error) {
+	if oc == nil || opts.OnCorrelation != nil {
+		return detect()
+	}
+
+	path := filepath.Join(oc.dir, oc.key(opts, local)+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var cached audiosync.OffsetResult
+		if json.Unmarshal(data, &cached) == nil {
+			oc.hits.Add(1)
+			return &cached, nil
+		}
+	}
+
+	result, err := detect()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCacheEntry(path, result); err != nil {
+		warnf("  ⚠️  Cannot cache the offset of %s: %v\n", filepath.Base(localPath), err)
+	}
+	return result, nil
+}
+
+// key hashes the mixed track, the local track and every setting that affects the detected offset
+func (oc *offsetCache) key(opts audiosync.DetectOptions, local []float64) string {
+	params, _ := json.Marshal(struct {
+		SegmentDuration  int
+		DownsampleFactor int
+		BackoffBelow     float64
+		Method           audiosync.CorrelationMethod
+		BandpassLow      int
+		BandpassHigh     int
+		PreFilter        audiosync.PreFilter
+		Window           audiosync.WindowType
+		MaxOffset        float64
+		ExcludeLag       float64
+		CoarseSegment    float64
+		EnergeticSegment bool
+		LevelMatch       bool
+		TrimSilenceDB    float64
+		Chunked          bool
+	}{
+		opts.SegmentDuration, opts.DownsampleFactor, opts.BackoffBelow, opts.Method, opts.BandpassLow, opts.BandpassHigh, opts.PreFilter,
+		opts.Window, opts.MaxOffset, opts.ExcludeLag, opts.CoarseSegment, opts.EnergeticSegment, opts.LevelMatch, opts.TrimSilenceDB, opts.Chunked,
+	})
+
+	h := sha256.New()
+	h.Write(oc.mixed)
+	h.Write(params)
+	hashSamples(h, local)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashSamples writes the length and the exact bits of every sample to h
+func hashSamples(h hash.Hash, samples []float64) {
+	buf := binary.LittleEndian.AppendUint64(make([]byte, 0, 8*4096), uint64(len(samples)))
+	for _, v := range samples {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		if len(buf) == cap(buf) {
+			h.Write(buf)
+			buf = buf[:0]
+		}
+	}
+	h.Write(buf)
+}
+
+// writeCacheEntry stores result at path through a temporary file, so concurrent runs never read a partial entry
+func writeCacheEntry(path string, result *audiosync.OffsetResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".offset-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// reused returns the number of offsets read from the cache (0 for a nil cache)
+func (oc *offsetCache) reused() int {
+	if oc == nil {
+		return 0
+	}
+	return int(oc.hits.Load())
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestOffsetCache(t *testing.T) {
+	captureOutput(t)
+	config := &Config{CacheDir: t.TempDir()}
+	mixed := []float64{0.1, -0.2, 0.3, -0.4}
+	local := []float64{0.3, -0.4}
+	opts := audiosync.DetectOptions{DownsampleFactor: 8, Method: audiosync.MethodStandard}
+
+	detections := 0
+	detect := func() (*audiosync.OffsetResult, error) {
+		detections++
+		return &audiosync.OffsetResult{OffsetSamples: 2, Confidence: 0.9}, nil
+	}
+
+	otherOpts := opts
+	otherOpts.DownsampleFactor = 4
+	tests := []struct {
+		name   string
+		cache  *offsetCache
+		opts   audiosync.DetectOptions
+		local  []float64
+		detect bool // Whether the detection must run
+	}{
+		{"first run", config.offsetCache().against(mixed, 8000), opts, local, true},
+		{"unchanged", config.offsetCache().against(mixed, 8000), opts, local, false},
+		{"detection setting changed", config.offsetCache().against(mixed, 8000), otherOpts, local, true},
+		{"local audio changed", config.offsetCache().against(mixed, 8000), opts, []float64{0.3, -0.5}, true},
+		{"mixed audio changed", config.offsetCache().against([]float64{0.1, -0.2, 0.3}, 8000), opts, local, true},
+		{"sample rate changed", config.offsetCache().against(mixed, 16000), opts, local, true},
+		{"disabled", (&Config{CacheDir: config.CacheDir, NoCache: true}).offsetCache().against(mixed, 8000), opts, local, true},
+	}
+
+	for _, tt := range tests {
+		before := detections
+		result, err := tt.cache.detect(tt.opts, "local.wav", tt.local, detect)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ran := detections > before; ran != tt.detect {
+			t.Errorf("%s: detection ran = %v, want %v", tt.name, ran, tt.detect)
+		}
+		if result.OffsetSamples != 2 || result.Confidence != 0.9 {
+			t.Errorf("%s: offset %d (confidence %g), want the detected 2 (0.9)", tt.name, result.OffsetSamples, result.Confidence)
+		}
+		wantReused := 1
+		if tt.detect {
+			wantReused = 0
+		}
+		if reused := tt.cache.reused(); reused != wantReused {
+			t.Errorf("%s: %d reused offsets, want %d", tt.name, reused, wantReused)
+		}
+	}
+}
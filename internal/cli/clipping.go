@@ -0,0 +1,16 @@
+package cli
+
+import "github.com/shidetake/clapless/internal/render"
+
+// logClipping warns about samples of an output that integer PCM clamps, or reports that
+// --normalize-output scaled the whole output down so its peak is at full scale instead
+func logClipping(name string, out *render.Output) {
+	switch {
+	case out.Clip.Samples == 0:
+	case out.Normalized:
+		logf("  %s: scaled down %.1f dB to avoid clipping\n", name, out.Clip.OvershootDB())
+	default:
+		warnf("  ⚠️  %s: %d samples clipped (peak %+.1f dBFS); use --normalize-output to scale it down instead\n",
+			name, out.Clip.Samples, out.Clip.OvershootDB())
+	}
+}
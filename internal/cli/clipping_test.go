@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
+)
+
+func TestLogClipping(t *testing.T) {
+	clipped := audio.MeasureClipping([]float64{0.5, 2, -1.5})
+
+	tests := []struct {
+		name        string
+		out         *render.Output
+		wantLog     string
+		wantWarning string
+	}{
+		{"clean", &render.Output{}, "", ""},
+		{"clamped", &render.Output{Clip: clipped}, "", "a.wav: 2 samples clipped (peak +6.0 dBFS)"},
+		{"normalized", &render.Output{Clip: clipped, Normalized: true}, "a.wav: scaled down 6.0 dB", ""},
+	}
+
+	for _, tt := range tests {
+		out, warnings := captureOutput(t)
+		logClipping("a.wav", tt.out)
+		if !strings.Contains(out.String(), tt.wantLog) || (tt.wantLog == "") != (out.Len() == 0) {
+			t.Errorf("%s: log %q, want %q", tt.name, out.String(), tt.wantLog)
+		}
+		if !strings.Contains(warnings.String(), tt.wantWarning) || (tt.wantWarning == "") != (warnings.Len() == 0) {
+			t.Errorf("%s: warning %q, want %q", tt.name, warnings.String(), tt.wantWarning)
+		}
+	}
+}
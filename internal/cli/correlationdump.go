@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// correlationDump writes the coarse correlation of each local file to a CSV file for --dump-correlation
+type correlationDump struct {
+	dir  string // Directory the CSV files are written to
+	step int    // Number of lags folded into each written row
+}
+
+// correlationDump returns the dump requested with --dump-correlation, or nil if none was
+func (c *Config) correlationDump() *correlationDump {
+	if c.DumpCorrelationDir == "" {
+		return nil
+	}
+	return &correlationDump{dir: c.DumpCorrelationDir, step: c.DumpCorrelationStep}
+}
+
+// options returns opts with a hook that dumps the correlation of the local file at localPath
+// A nil dump returns opts unchanged.
+func (d *correlationDump) options(opts audiosync.DetectOptions, localPath string) audiosync.DetectOptions {
+	if d == nil {
+		return opts
+	}
+	name := filepath.Base(localPath)
+	if localPath == audio.StdinPath {
+		name = "stdin"
+	}
+	path := filepath.Join(d.dir, name+".csv")
+	opts.OnCorrelation = func(curve *audiosync.CorrelationCurve) error {
+		return writeCorrelationCSV(path, curve, d.step)
+	}
+	return opts
+}
+
+// writeCorrelationCSV writes curve to path as "lag_samples,value" rows in ascending lag order
+// With step > 1 each row holds the largest value of step consecutive lags and its lag, so peaks are kept.
+func writeCorrelationCSV(path string, curve *audiosync.CorrelationCurve, step int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "lag_samples,value")
+	count, bestLag, best := 0, 0, math.Inf(-1)
+	curve.Each(func(lag int, value float64) {
+		if count == 0 || value > best {
+			bestLag, best = lag, value
+		}
+		if count++; count == step {
+			fmt.Fprintf(w, "%d,%g\n", bestLag, best)
+			count = 0
+		}
+	})
+	if count > 0 {
+		fmt.Fprintf(w, "%d,%g\n", bestLag, best)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRunDumpCorrelation(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	rowCounts := map[string]int{} // Number of rows of each file without decimation
+	for _, step := range []int{1, 5} {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, fmt.Sprint("out", step))
+		config.DumpCorrelationDir = filepath.Join(dir, fmt.Sprint("dump", step))
+		config.DumpCorrelationStep = step
+		report := runReport(t, config)
+
+		for _, fo := range report.Files {
+			name := filepath.Base(fo.Path)
+			f, err := os.Open(filepath.Join(config.DumpCorrelationDir, name+".csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(f).ReadAll()
+			f.Close()
+			if err != nil {
+				t.Fatalf("step %d, %s: %v", step, name, err)
+			}
+			if len(rows) < 2 || rows[0][0] != "lag_samples" || rows[0][1] != "value" {
+				t.Fatalf("step %d, %s: missing header or rows", step, name)
+			}
+
+			if step == 1 {
+				rowCounts[name] = len(rows) - 1
+			} else if want := (rowCounts[name] + step - 1) / step; len(rows)-1 != want {
+				t.Errorf("step %d, %s: %d rows, want %d", step, name, len(rows)-1, want)
+			}
+
+			// The peak of the dumped curve is the reported coarse offset, and lags ascend
+			peakLag, peak, prevLag := 0, 0.0, 0
+			for i, row := range rows[1:] {
+				lag, err := strconv.Atoi(row[0])
+				if err != nil {
+					t.Fatalf("step %d, %s: row %d: %v", step, name, i+1, err)
+				}
+				value, err := strconv.ParseFloat(row[1], 64)
+				if err != nil {
+					t.Fatalf("step %d, %s: row %d: %v", step, name, i+1, err)
+				}
+				if i > 0 && lag <= prevLag {
+					t.Fatalf("step %d, %s: lag %d after %d", step, name, lag, prevLag)
+				}
+				if i == 0 || value > peak {
+					peakLag, peak = lag, value
+				}
+				prevLag = lag
+			}
+			if peakLag != fo.OffsetSamples {
+				t.Errorf("step %d, %s: dumped peak at lag %d, reported offset %d", step, name, peakLag, fo.OffsetSamples)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+// duplicatePaths returns an error naming the first input path that refers to the same file as
+// an earlier one (mixed paths come first), following symlinks and hard links
+func duplicatePaths(mixedPaths, localPaths []string) error {
+	type input struct {
+		path string
+		info os.FileInfo
+	}
+	var seen []input
+	for _, path := range append(append([]string{}, mixedPaths...), localPaths...) {
+		if path == audio.StdinPath {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Missing files are reported when they are loaded
+		}
+		for _, prior := range seen {
+			if os.SameFile(prior.info, info) {
+				return fmt.Errorf("%s is passed more than once (as %s); use --allow-duplicates to sync it anyway", path, prior.path)
+			}
+		}
+		seen = append(seen, input{path, info})
+	}
+	return nil
+}
+
+// checkDuplicates fails if two input files have the same audio (same rate, channels and samples),
+// such as the mixed file passed again as a local file, which only produces a meaningless alignment.
+// With --allow-duplicates it only warns.
+func (c *Config) checkDuplicates(mixedFiles, localFiles []*audio.WAVData) error {
+	seen := map[[sha256.Size]byte]string{}
+	for _, f := range append(append([]*audio.WAVData{}, mixedFiles...), localFiles...) {
+		h := sha256.New()
+		fmt.Fprintf(h, "%d\n%d\n", f.SampleRate, f.Channels)
+		hashSamples(h, f.Data)
+		var digest [sha256.Size]byte
+		h.Sum(digest[:0])
+
+		prior, ok := seen[digest]
+		if !ok {
+			seen[digest] = f.Path
+			continue
+		}
+		if !c.AllowDuplicates {
+			return fmt.Errorf("%s has the same audio as %s; use --allow-duplicates to sync it anyway", f.Path, prior)
+		}
+		warnf("⚠️  %s has the same audio as %s\n", filepath.Base(f.Path), filepath.Base(prior))
+	}
+	return nil
+}
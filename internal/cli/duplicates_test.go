@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDuplicatePaths(t *testing.T) {
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+	alice, bob := localPaths[0], localPaths[1]
+	link := filepath.Join(dir, "link.wav")
+	if err := os.Symlink(alice, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		locals []string
+		want   string // Path named in the error ("" = no duplicates)
+	}{
+		{"distinct files", []string{alice, bob}, ""},
+		{"same path twice", []string{alice, bob, alice}, alice},
+		{"spelled differently", []string{alice, filepath.Join(dir, ".", "alice.wav")}, filepath.Join(dir, ".", "alice.wav")},
+		{"through a symlink", []string{alice, link}, link},
+		{"mixed file as a local file", []string{alice, mixedPath}, mixedPath},
+	}
+
+	for _, tt := range tests {
+		err := duplicatePaths([]string{mixedPath}, tt.locals)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want+" is passed more than once") {
+			t.Errorf("%s: error %v, want one naming %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRunDuplicateAudio(t *testing.T) {
+	_, warnings := captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// A copy is another file with the same audio
+	data, err := os.ReadFile(localPaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(dir, "alice copy.wav")
+	if err := os.WriteFile(copyPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	locals := append(localPaths, copyPath)
+
+	config := testConfig(mixedPath, locals)
+	config.OutputDir = filepath.Join(dir, "rejected")
+	if err := Run(context.Background(), config); err == nil || !strings.Contains(err.Error(), "has the same audio as") {
+		t.Errorf("Run error %v, want the copy rejected", err)
+	}
+
+	config = testConfig(mixedPath, locals)
+	config.OutputDir = filepath.Join(dir, "allowed")
+	config.AllowDuplicates = true
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run with duplicates allowed: %v", err)
+	}
+	if !strings.Contains(warnings.String(), "alice copy.wav has the same audio as alice.wav") {
+		t.Errorf("no warning about the copy:\n%s", warnings.String())
+	}
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// FailedFile is a local file that was left out of the run by --continue-on-error
+type FailedFile struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// fileErrors collects the errors of individual local files by index instead of aborting the run
+type fileErrors map[int]error
+
+// setAside removes the local files that failed from localFiles and config.LocalPaths (and results, if given)
+// and records them in config.Failed, so the remaining files are processed as if they had been given alone
+// It returns an error if no local file is left.
+func (c *Config) setAside(failed fileErrors, localFiles []*audio.WAVData, results []*audiosync.OffsetResult) ([]*audio.WAVData, []*audiosync.OffsetResult, error) {
+	if len(failed) == 0 {
+		return localFiles, results, nil
+	}
+
+	var paths []string
+	var keptFiles []*audio.WAVData
+	var keptResults []*audiosync.OffsetResult
+	for i, path := range c.LocalPaths {
+		if err, ok := failed[i]; ok {
+			warnf("  ✗ %s: %v\n", filepath.Base(path), err)
+			c.Failed = append(c.Failed, FailedFile{Path: path, Error: err.Error()})
+			continue
+		}
+		paths = append(paths, path)
+		keptFiles = append(keptFiles, localFiles[i])
+		if results != nil {
+			keptResults = append(keptResults, results[i])
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("every local file failed:\n  %s", c.failureSummary())
+	}
+
+	c.LocalPaths = paths
+	return keptFiles, keptResults, nil
+}
+
+// failureSummary lists the failed files, one per line
+func (c *Config) failureSummary() string {
+	lines := make([]string, len(c.Failed))
+	for i, f := range c.Failed {
+		lines[i] = fmt.Sprintf("%s: %s", f.Path, f.Error)
+	}
+	return strings.Join(lines, "\n  ")
+}
+
+// failedError returns the error reported at the end of a run that set files aside, or nil if none failed
+func (c *Config) failedError() error {
+	if len(c.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d local files failed and were not synchronized:\n  %s",
+		len(c.Failed), len(c.Failed)+len(c.LocalPaths), c.failureSummary())
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+func TestRunContinueOnError(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// One file fails to load and one loads but has nothing to detect
+	corrupt := filepath.Join(dir, "corrupt.wav")
+	if err := os.WriteFile(corrupt, []byte("RIFF\x10\x00\x00\x00WAVEjunk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	silent := filepath.Join(dir, "silent.wav")
+	if err := audio.WriteWAV(silent, make([]float64, 25*selftestRate), selftestRate, 1, 16, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		bad  string
+	}{
+		{"load failure", corrupt},
+		{"detection failure", silent},
+	}
+
+	for _, tt := range tests {
+		paths := []string{localPaths[0], tt.bad, localPaths[1]}
+
+		// By default one bad file aborts the whole run before anything is written
+		config := testConfig(mixedPath, slices.Clone(paths))
+		config.OutputDir = filepath.Join(dir, tt.name, "abort")
+		if err := Run(context.Background(), config); err == nil {
+			t.Errorf("%s: Run without --continue-on-error succeeded", tt.name)
+		}
+		if _, err := os.Stat(filepath.Join(config.OutputDir, "alice_synced.wav")); err == nil {
+			t.Errorf("%s: output written by an aborted run", tt.name)
+		}
+
+		config = testConfig(mixedPath, slices.Clone(paths))
+		config.OutputDir = filepath.Join(dir, tt.name, "continue")
+		config.ContinueOnError = true
+		err := Run(context.Background(), config)
+		if err == nil || !strings.Contains(err.Error(), "1 of 3 local files failed") || !strings.Contains(err.Error(), filepath.Base(tt.bad)) {
+			t.Errorf("%s: Run error %v, want a summary naming %s", tt.name, err, filepath.Base(tt.bad))
+		}
+		if len(config.Failed) != 1 || config.Failed[0].Path != tt.bad || config.Failed[0].Error == "" {
+			t.Errorf("%s: failed files %+v, want %s with its error", tt.name, config.Failed, tt.bad)
+		}
+
+		// The valid files are still synced as if given alone
+		for _, name := range []string{"alice_synced.wav", "bob_synced.wav"} {
+			if _, err := os.Stat(filepath.Join(config.OutputDir, name)); err != nil {
+				t.Errorf("%s: %s not written: %v", tt.name, name, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(config.OutputDir, strings.TrimSuffix(filepath.Base(tt.bad), ".wav")+"_synced.wav")); err == nil {
+			t.Errorf("%s: output written for the failed file", tt.name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// formatFFmpegScript renders a shell script with one ffmpeg command per local file that applies its alignment:
+// adelay prepends the padding in milliseconds and atrim removes the trim in seconds. Both are times rather than
+// sample counts, so the commands also fit higher-resolution originals of the same recordings.
+// Files left out as suspect are listed as comments; outputPath names the output of each file.
+func formatFFmpegScript(fileOffsets []*audiosync.FileOffset, outputPath func(source string) string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Aligns the local files as clapless did; replace the inputs with their originals to keep full resolution\n")
+	b.WriteString("set -e\n")
+	for _, fo := range fileOffsets {
+		if fo.Suspect {
+			fmt.Fprintf(&b, "# %s: suspect offset, not aligned\n", fo.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "ffmpeg -i %s -af %s %s\n", shellQuote(fo.Path), shellQuote(ffmpegFilter(fo)), shellQuote(outputPath(fo.Path)))
+	}
+	return b.String()
+}
+
+// ffmpegFilter returns the audio filter that pads or trims the start of the file as fo does
+func ffmpegFilter(fo *audiosync.FileOffset) string {
+	if fo.TrimSamples > 0 {
+		return fmt.Sprintf("atrim=start=%.6f,asetpts=PTS-STARTPTS", fo.TrimSeconds)
+	}
+	return fmt.Sprintf("adelay=delays=%.3f:all=1", fo.PaddingSeconds*1000)
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeFFmpegScript writes the ffmpeg alignment script to path and makes it executable
+func writeFFmpegScript(path string, fileOffsets []*audiosync.FileOffset, outputPath func(source string) string) error {
+	if err := os.WriteFile(path, []byte(formatFFmpegScript(fileOffsets, outputPath)), 0755); err != nil {
+		return fmt.Errorf("failed to write ffmpeg script %s: %w", path, err)
+	}
+	return nil
+}
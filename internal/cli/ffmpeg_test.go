@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestFormatFFmpegScript(t *testing.T) {
+	fileOffsets := []*audiosync.FileOffset{
+		{Path: "rec/host.wav"},
+		{Path: "rec/guest's mic.wav", PaddingSamples: 18765, PaddingSeconds: 0.3909375},
+		{Path: "rec/early.wav", TrimSamples: 600, TrimSeconds: 0.0125},
+		{Path: "rec/wrong.wav", PaddingSeconds: 7200, Suspect: true},
+	}
+	outputPath := func(source string) string { return strings.TrimSuffix(source, ".wav") + "_synced.wav" }
+
+	want := "#!/bin/sh\n" +
+		"# Aligns the local files as clapless did; replace the inputs with their originals to keep full resolution\n" +
+		"set -e\n" +
+		"ffmpeg -i 'rec/host.wav' -af 'adelay=delays=0.000:all=1' 'rec/host_synced.wav'\n" +
+		`ffmpeg -i 'rec/guest'\''s mic.wav' -af 'adelay=delays=390.938:all=1' 'rec/guest'\''s mic_synced.wav'` + "\n" +
+		"ffmpeg -i 'rec/early.wav' -af 'atrim=start=0.012500,asetpts=PTS-STARTPTS' 'rec/early_synced.wav'\n" +
+		"# rec/wrong.wav: suspect offset, not aligned\n"
+	if got := formatFFmpegScript(fileOffsets, outputPath); got != want {
+		t.Errorf("script:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunEmitFFmpeg(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.FFmpegScriptPath = filepath.Join(dir, "align.sh")
+	report := runReport(t, config)
+
+	data, err := os.ReadFile(config.FFmpegScriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each file is delayed by its computed padding
+	for _, fo := range report.Files {
+		delay := fmt.Sprintf("-af 'adelay=delays=%.3f:all=1' %s\n", fo.PaddingSeconds*1000, shellQuote(config.outputPath(fo.Path)))
+		if !strings.Contains(string(data), shellQuote(fo.Path)+" "+delay) {
+			t.Errorf("%s: no command with a delay of %.3fms in:\n%s", filepath.Base(fo.Path), fo.PaddingSeconds*1000, data)
+		}
+	}
+	if bob := report.Files[1]; bob.PaddingSeconds == 0 {
+		t.Error("bob.wav is not padded, so the delays were not exercised")
+	}
+}
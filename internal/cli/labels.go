@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// formatLabels renders an Audacity label track ("start\tend\tlabel" in seconds) for the synced outputs
+// Each file gets a point label where its audio starts, and the fine-tuning segment gets a region label.
+// Times are on the output timeline, which is shared by all synced files.
+func formatLabels(fileOffsets []*audiosync.FileOffset, sampleRate int) string {
+	var b strings.Builder
+	label := func(start, end float64, text string) {
+		fmt.Fprintf(&b, "%.6f\t%.6f\t%s\n", start, end, text)
+	}
+
+	for _, fo := range fileOffsets {
+		name := filepath.Base(fo.TrackName())
+		if fo.TrimSamples > 0 {
+			label(0, 0, fmt.Sprintf("%s start (trimmed %.3fs)", name, fo.TrimSeconds))
+		} else {
+			label(fo.PaddingSeconds, fo.PaddingSeconds, name+" start")
+		}
+	}
+
+	// The segment is on the mixed timeline; a mixed position p is at p + shift in every output
+	shift := fileOffsets[0].PaddingSamples - fileOffsets[0].TrimSamples - fileOffsets[0].FinalOffsetSamples
+	seen := make(map[audiosync.OverlapRegion]bool)
+	for _, fo := range fileOffsets {
+		if fo.FinetuneResult == nil || fo.FinetuneResult.Skipped || seen[fo.FinetuneResult.SegmentUsed] {
+			continue
+		}
+		segment := fo.FinetuneResult.SegmentUsed
+		seen[segment] = true
+		label(
+			float64(segment.StartSample+shift)/float64(sampleRate),
+			float64(segment.EndSample+shift)/float64(sampleRate),
+			"fine-tune segment",
+		)
+	}
+
+	return b.String()
+}
+
+// writeLabels writes the Audacity label file for the synced outputs to path
+func writeLabels(path string, fileOffsets []*audiosync.FileOffset, sampleRate int) error {
+	if err := os.WriteFile(path, []byte(formatLabels(fileOffsets, sampleRate)), 0644); err != nil {
+		return fmt.Errorf("failed to write labels %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestFormatLabels(t *testing.T) {
+	const rate = 1000
+	segment := audiosync.OverlapRegion{StartSample: 4000, EndSample: 6000, DurationSec: 2}
+	finetuned := &audiosync.FinetuneResult{SegmentUsed: segment}
+
+	tests := []struct {
+		name        string
+		fileOffsets []*audiosync.FileOffset
+		want        string
+	}{
+		{
+			"padded",
+			[]*audiosync.FileOffset{
+				{Path: "rec/a.wav", FinalOffsetSamples: 2000, FinetuneResult: finetuned},
+				{Path: "rec/b.wav", FinalOffsetSamples: 3500, PaddingSamples: 1500, PaddingSeconds: 1.5, FinetuneResult: finetuned},
+				{Path: "rec/c.wav", Channel: 2, FinalOffsetSamples: 2250, PaddingSamples: 250, PaddingSeconds: 0.25,
+					FinetuneResult: &audiosync.FinetuneResult{Skipped: true}},
+			},
+			"0.000000\t0.000000\ta.wav start\n" +
+				"1.500000\t1.500000\tb.wav start\n" +
+				"0.250000\t0.250000\tc.wav (ch 2) start\n" +
+				"2.000000\t4.000000\tfine-tune segment\n",
+		},
+		{
+			"trimmed",
+			[]*audiosync.FileOffset{
+				{Path: "a.wav", FinalOffsetSamples: 2000, TrimSamples: 1500, TrimSeconds: 1.5, FinetuneResult: finetuned},
+				{Path: "b.wav", FinalOffsetSamples: 3500, FinetuneResult: finetuned},
+			},
+			"0.000000\t0.000000\ta.wav start (trimmed 1.500s)\n" +
+				"0.000000\t0.000000\tb.wav start\n" +
+				"0.500000\t2.500000\tfine-tune segment\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := formatLabels(tt.fileOffsets, rate); got != tt.want {
+			t.Errorf("%s: labels\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
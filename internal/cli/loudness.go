@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"math"
+
+	"github.com/shidetake/clapless/internal/render"
+)
+
+// logLoudness reports the level change --target-lufs made to an output (nil = not set)
+func logLoudness(name string, loudness *render.Loudness) {
+	switch {
+	case loudness == nil:
+	case math.IsInf(loudness.Before, -1):
+		warnf("  ⚠️  %s: too short or quiet to measure loudness, level unchanged\n", name)
+	case loudness.Limited:
+		warnf("  ⚠️  %s: %.1f → %.1f LUFS (%+.1f dB), limited by the %.0f dBTP true-peak ceiling\n",
+			name, loudness.Before, loudness.Before+loudness.GainDB, loudness.GainDB, render.LoudnessCeilingDBTP)
+	default:
+		logf("  %s: %.1f → %.1f LUFS (%+.1f dB)\n", name, loudness.Before, loudness.Before+loudness.GainDB, loudness.GainDB)
+	}
+}
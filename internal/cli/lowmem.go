@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// lowMemoryConflict returns why the files cannot be streamed as --low-memory does, or nil if they can
+func (c *Config) lowMemoryConflict() error {
+	switch {
+	case c.CorrectDrift:
+		return fmt.Errorf("--correct-drift cannot be combined with --low-memory")
+	case c.SplitGaps:
+		return fmt.Errorf("--split-gaps cannot be combined with --low-memory")
+	case c.DetectRateMismatch:
+		return fmt.Errorf("--detect-rate-mismatch cannot be combined with --low-memory")
+	case c.CombinePath != "":
+		return fmt.Errorf("--combine cannot be combined with --low-memory")
+	case c.PreviewMixPath != "":
+		return fmt.Errorf("--preview-mix cannot be combined with --low-memory")
+	case c.FractionalDelay:
+		return fmt.Errorf("--fractional-delay cannot be combined with --low-memory")
+	case c.CorrelationMethod == audiosync.MethodOnset:
+		return fmt.Errorf("--correlation-method %s needs the full audio and cannot be combined with --low-memory", audiosync.MethodOnset)
+	case c.Detector != nil:
+		return fmt.Errorf("a custom detector needs the full audio and cannot be combined with --low-memory")
+	case c.TargetLUFS != 0:
+		return fmt.Errorf("--target-lufs cannot be combined with --low-memory")
+	case c.NormalizeOutput:
+		return fmt.Errorf("--normalize-output cannot be combined with --low-memory")
+	case c.SampleRateOut != 0:
+		return fmt.Errorf("--sample-rate-out cannot be combined with --low-memory")
+	case c.VerifyOutput:
+		return fmt.Errorf("--verify-output cannot be combined with --low-memory")
+	case c.Mixdown.Mode != "" && c.Mixdown.Mode != audio.MixdownAverage:
+		return fmt.Errorf("--mixdown other than %s cannot be combined with --low-memory", audio.MixdownAverage)
+	case c.ContinueOnError:
+		return fmt.Errorf("--continue-on-error cannot be combined with --low-memory")
+	case c.PerChannel:
+		return fmt.Errorf("--per-channel cannot be combined with --low-memory")
+	case c.EqualizeLength:
+		return fmt.Errorf("--equalize-length cannot be combined with --low-memory")
+	case c.InvertPolarity:
+		return fmt.Errorf("--invert-polarity cannot be combined with --low-memory")
+	case c.OutputChannels != 0:
+		return fmt.Errorf("--force-stereo and --force-mono cannot be combined with --low-memory")
+	case c.SkipExisting:
+		return fmt.Errorf("--skip-existing cannot be combined with --low-memory")
+	case c.LoadSessionPath != "":
+		return fmt.Errorf("--load-session cannot be combined with --low-memory")
+	case c.OutputPattern != "" && !strings.Contains(c.OutputPattern, "{ext}") && strings.ToLower(filepath.Ext(c.OutputPattern)) != ".wav":
+		return fmt.Errorf("--low-memory writes WAV files and cannot be combined with --output-pattern %s", c.OutputPattern)
+	case c.PreviewDuration > 0:
+		return fmt.Errorf("--preview-duration cannot be combined with --low-memory")
+	case len(c.MixedPaths) > 1:
+		return fmt.Errorf("--low-memory supports a single --mixed file")
+	}
+	for _, path := range append([]string{c.MixedPaths[0]}, c.LocalPaths...) {
+		if path == audio.StdinPath {
+			return fmt.Errorf("--low-memory reads files several times and cannot use stdin (%s)", audio.StdinPath)
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".wav" {
+			return fmt.Errorf("--low-memory requires WAV input (got %s): %s", ext, path)
+		}
+	}
+	return nil
+}
+
+// RunLowMemory executes the synchronization workflow without loading whole files into memory
+// Coarse detection uses streamed, downsampled data; only the fine-tuning segment is read at
+// full resolution, and outputs are written by streaming the source files
+func RunLowMemory(ctx context.Context, config *Config) error {
+	logln("Clapless - Audio Synchronization Tool")
+	logln("======================================")
+	logln()
+
+	timer := newStageTimer(config.Profile)
+
+	// Step 1: Load downsampled audio
+	logln("Loading files (low memory)...")
+	mixedPath := config.MixedPaths[0]
+	if config.DownsampleFactor == 0 {
+		if err := resolveDownsampleStreamed(config); err != nil {
+			return err
+		}
+	}
+	mixed, err := audio.LoadWAVDownsampled(mixedPath, config.DownsampleFactor)
+	if err != nil {
+		return fmt.Errorf("failed to load mixed audio: %w", err)
+	}
+	logf("  ✓ Mixed: %s (%d Hz, %s)\n",
+		filepath.Base(mixedPath),
+		mixed.SampleRate,
+		mixed.DurationString())
+
+	localFiles := make([]*audio.WAVData, len(config.LocalPaths))
+	for i, path := range config.LocalPaths {
+		local, err := audio.LoadWAVDownsampled(path, config.DownsampleFactor)
+		if err != nil {
+			return fmt.Errorf("failed to load local audio %s: %w", path, err)
+		}
+		logf("  ✓ Local %d: %s (%d Hz, %s)\n",
+			i+1,
+			filepath.Base(path),
+			local.SampleRate,
+			local.DurationString())
+		localFiles[i] = local
+	}
+
+	// Streaming cannot resample, so all rates must already match
+	if err := validateSampleRates(mixed, localFiles); err != nil {
+		return err
+	}
+
+	timer.mark("load")
+	logln()
+
+	// Steps 2-4: Detect offsets on the decimated data, calculate padding and fine-tune
+	fileOffsets, err := audiosync.Align(ctx, mixed.SampleRate, audiosync.AlignStages{
+		Detect: func(ctx context.Context) ([]*audiosync.OffsetResult, []string, error) {
+			logf("Detecting offsets (downsample=%d)...\n", config.DownsampleFactor)
+			cache := config.offsetCache()
+			offsetResults, err := detectOffsetsDownsampledParallel(ctx, mixed, localFiles, config.knownOffsets(mixed.SampleRate, nil), config.detectOptions(), config.correlationDump(), cache, config.newProgress())
+			if err != nil {
+				return nil, nil, err
+			}
+			if reused := cache.reused(); reused > 0 {
+				logf("  ✓ Reused %d cached offset(s) from %s\n", reused, cache.dir)
+			}
+			if config.DumpCorrelationDir != "" {
+				logf("  ✓ Correlation: %s\n", config.DumpCorrelationDir)
+			}
+			timer.mark("coarse")
+			return offsetResults, config.LocalPaths, nil
+		},
+		Coarse: func(fileOffsets []*audiosync.FileOffset) error {
+			printCoarseOffsets(config.LocalPaths, fileOffsets)
+			logln()
+			logln("Fine-tuning synchronization...")
+			return nil
+		},
+		// Fine-tune offsets using only the overlap segment at full resolution
+		Finetune: func(ctx context.Context, fileOffsets []*audiosync.FileOffset) ([]*audiosync.FileOffset, error) {
+			if err := finetuneStreamed(ctx, config, mixed, localFiles, fileOffsets); err != nil {
+				return nil, err
+			}
+			printFinetuneResults(config.LocalPaths, fileOffsets)
+			return fileOffsets, nil
+		},
+		FinetuneFailed: warnCoarseOnly,
+	})
+	if err != nil {
+		return err
+	}
+	timer.mark("finetune")
+
+	// Steps 5-6: Compute output alignment and stream synced files
+	err = finishSync(config, fileOffsets, mixed.SampleRate, nil, func(i int, fo *audiosync.FileOffset, outputPath string) error {
+		return audio.CopyWAVAligned(config.LocalPaths[i], outputPath, fo.PaddingSamples, fo.TrimSamples,
+			config.BitDepth, config.FloatOutput, config.renderOptions().FadeFrames(fo, mixed.SampleRate), config.outputTags(i, localFiles[i], fo))
+	})
+	if err != nil {
+		return err
+	}
+	timer.mark("write")
+
+	printComplete(fileOffsets)
+	timer.print()
+	return nil
+}
+
+// resolveDownsampleStreamed chooses the factor for --downsample auto from the WAV headers,
+// since the downsampled loads below already need it
+func resolveDownsampleStreamed(config *Config) error {
+	sampleRate, mixedFrames, err := audio.WAVFrameCount(config.MixedPaths[0])
+	if err != nil {
+		return fmt.Errorf("failed to read mixed audio: %w", err)
+	}
+	localFrames := make([]int, len(config.LocalPaths))
+	for i, path := range config.LocalPaths {
+		if _, localFrames[i], err = audio.WAVFrameCount(path); err != nil {
+			return fmt.Errorf("failed to read local audio %s: %w", path, err)
+		}
+	}
+
+	config.resolveDownsample(sampleRate, mixedFrames, localFrames)
+	return nil
+}
+
+// finetuneStreamed refines the coarse offsets, reading only the fine-tuning segment of each file
+func finetuneStreamed(ctx context.Context, config *Config, mixed *audio.WAVData, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset) error {
+	// Lengths in full-resolution frames (the last decimated frame may be up to factor-1 frames short)
+	localLengths := make([]int, len(localFiles))
+	for i, local := range localFiles {
+		localLengths[i] = fullLength(local)
+	}
+
+	// The decimated data is enough to find a segment where every track has signal
+	tracks := []audiosync.TimelineTrack{{Data: mixed.Data, Channels: 1, Factor: mixed.DownsampleFactor}}
+	for i, local := range localFiles {
+		tracks = append(tracks, audiosync.TimelineTrack{Data: local.Data, Channels: 1, Offset: fileOffsets[i].OffsetSamples, Factor: local.DownsampleFactor})
+	}
+
+	segment, err := audiosync.SelectFinetuneRegion(localLengths, fileOffsets, fullLength(mixed), mixed.SampleRate, tracks, config.detectOptions())
+	if err != nil {
+		// Overlap missing or too small, skip fine-tuning for all files
+		for _, fo := range fileOffsets {
+			audiosync.SkipFinetune(fo, err.Error())
+		}
+		return nil
+	}
+
+	mixedSegment, err := audio.LoadWAVSegment(config.MixedPaths[0], segment.StartSample, segment.EndSample)
+	if err != nil {
+		return fmt.Errorf("failed to extract mixed segment: %w", err)
+	}
+
+	progress := config.newProgress()
+	for i, fo := range fileOffsets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start, end := audiosync.LocalSegmentBounds(*segment, fo)
+		localSegment, err := audio.LoadWAVSegment(config.LocalPaths[i], start, end)
+		if err != nil {
+			audiosync.SkipFinetune(fo, fmt.Sprintf("extraction failed: %v", err))
+		} else {
+			audiosync.FinetuneFile(ctx, mixedSegment, localSegment, *segment, fo, mixed.SampleRate, config.detectOptions())
+		}
+		progress.step("fine-tuned %s", filepath.Base(config.LocalPaths[i]))
+	}
+
+	_, err = audiosync.RecalculatePadding(fileOffsets, mixed.SampleRate)
+	return err
+}
+
+// fullLength returns the approximate full-resolution frame count of downsampled data
+func fullLength(w *audio.WAVData) int {
+	if w.DownsampleFactor <= 1 || len(w.Data) == 0 {
+		return len(w.Data)
+	}
+	return (len(w.Data)-1)*w.DownsampleFactor + 1
+}
+
+// detectOffsetsDownsampledParallel detects offsets for already-decimated mono data in parallel
+// Files with an entry in known use that result, and dump and cache are used like in detectOffsetsParallel.
+func detectOffsetsDownsampledParallel(ctx context.Context, mixed *audio.WAVData, localFiles []*audio.WAVData, known map[string]*audiosync.OffsetResult, opts audiosync.DetectOptions, dump *correlationDump, cache *offsetCache, progress *progressReporter) ([]*audiosync.OffsetResult, error) {
+	cache = cache.against(mixed.Data, mixed.SampleRate)
+	results := make(chan offsetResult, len(localFiles))
+
+	go audiosync.ForEachLimited(len(localFiles), opts.Workers(), func(idx int) {
+		localData := localFiles[idx]
+		if offset, ok := known[filepath.Clean(localData.Path)]; ok {
+			results <- offsetResult{index: idx, offset: offset}
+			return
+		}
+
+		fileOpts := dump.options(opts, localData.Path)
+		offset, err := cache.detect(fileOpts, localData.Path, localData.Data, func() (*audiosync.OffsetResult, error) {
+			return audiosync.DetectOffsetDownsampled(ctx, mixed.Data, localData.Data, mixed.SampleRate, fileOpts)
+		})
+		results <- offsetResult{
+			index:  idx,
+			offset: offset,
+			err:    err,
+		}
+	})
+
+	return collectOffsets(ctx, results, localFiles, nil, progress)
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"slices"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// bytesPerMB converts --max-memory to bytes
+const bytesPerMB = 1 << 20
+
+// memoryEstimate holds the estimated peak memory of each way to run, in bytes
+type memoryEstimate struct {
+	load               int64 // Loading every file fully
+	streamed           int64 // Holding only decimated mono copies, as --low-memory does
+	correlation        int64 // Correlating up to --threads local files at once with single FFTs (or as configured)
+	chunkedCorrelation int64 // Correlating up to --threads local files at once in blocks
+}
+
+// planMemory picks the fastest way to stay within budget bytes, trying in order: loading everything,
+// correlating in blocks, streaming and streaming with correlation in blocks (streaming only if streamable)
+// Blocks are only used when they need less memory than single FFTs, which is the case for long inputs.
+// If nothing fits, the leanest available way is returned.
+func planMemory(budget int64, estimate memoryEstimate, streamable bool) (lowMemory, chunked bool) {
+	chunked = estimate.chunkedCorrelation < estimate.correlation
+	switch {
+	case estimate.load+estimate.correlation <= budget:
+		return false, false
+	case estimate.load+estimate.leanCorrelation() <= budget || !streamable:
+		return false, chunked
+	case estimate.streamed+estimate.correlation <= budget:
+		return true, false
+	default:
+		return true, chunked
+	}
+}
+
+// leanCorrelation returns the memory needed by the leaner of single-FFT and block correlation
+func (e memoryEstimate) leanCorrelation() int64 {
+	return min(e.correlation, e.chunkedCorrelation)
+}
+
+// estimateMemory estimates the memory each way to run needs from the file headers, before loading anything
+// Files read from stdin cannot be probed and are left out.
+func (c *Config) estimateMemory() (memoryEstimate, error) {
+	var estimate memoryEstimate
+	probe := func(path string) (*audio.Info, error) {
+		if path == audio.StdinPath {
+			return nil, nil
+		}
+		info, err := audio.Probe(path)
+		if err != nil {
+			return nil, err
+		}
+		estimate.load += info.Footprint()
+		return info, nil
+	}
+
+	// Local files are resampled to the rate of the first mixed file, so count them at that rate
+	sampleRate, mixedFrames := 0, 0
+	for _, path := range c.MixedPaths {
+		info, err := probe(path)
+		if err != nil || info == nil {
+			return estimate, err
+		}
+		if sampleRate == 0 {
+			sampleRate = info.SampleRate
+		}
+		mixedFrames = max(mixedFrames, info.Frames*sampleRate/info.SampleRate)
+	}
+	var localFrames []int
+	for _, path := range c.LocalPaths {
+		info, err := probe(path)
+		if err != nil {
+			return estimate, err
+		}
+		if info != nil {
+			localFrames = append(localFrames, info.Frames*sampleRate/info.SampleRate)
+		}
+	}
+	if sampleRate == 0 || len(localFrames) == 0 {
+		return estimate, nil
+	}
+
+	factor := c.DownsampleFactor
+	if factor == 0 {
+		factor = audiosync.AutoDownsampleFactor(sampleRate, mixedFrames, slices.Max(localFrames), c.AutoResolutionMs)
+	}
+
+	// Up to --threads local files are correlated at the same time, so count the largest ones
+	opts := c.detectOptions()
+	chunkedOpts := opts
+	chunkedOpts.Chunked = true
+	estimate.streamed = int64(mixedFrames/factor+1) * 8
+	var correlation, chunkedCorrelation []int64
+	for _, frames := range localFrames {
+		estimate.streamed += int64(frames/factor+1) * 8
+		correlation = append(correlation, audiosync.CorrelationFootprint(mixedFrames/factor, frames/factor, opts))
+		chunkedCorrelation = append(chunkedCorrelation, audiosync.CorrelationFootprint(mixedFrames/factor, frames/factor, chunkedOpts))
+	}
+	estimate.correlation = largestSum(correlation, opts.Workers())
+	estimate.chunkedCorrelation = largestSum(chunkedCorrelation, opts.Workers())
+	return estimate, nil
+}
+
+// largestSum returns the sum of the n largest values
+func largestSum(values []int64, n int) int64 {
+	slices.Sort(values)
+	var sum int64
+	for _, v := range values[max(len(values)-n, 0):] {
+		sum += v
+	}
+	return sum
+}
+
+// fitMemory switches to block correlation and/or streaming if the run would not fit into --max-memory
+// These paths are slower but find the same offsets.
+func (c *Config) fitMemory() {
+	estimate, err := c.estimateMemory()
+	if err != nil {
+		warnf("⚠️  Cannot estimate memory use, ignoring --max-memory: %v\n", err)
+		return
+	}
+
+	budget := int64(c.MaxMemoryMB) * bytesPerMB
+	var lowMemory, chunked bool
+	var conflict error
+	if c.LowMemory {
+		chunked = estimate.streamed+estimate.correlation > budget && estimate.chunkedCorrelation < estimate.correlation
+	} else {
+		conflict = c.lowMemoryConflict()
+		lowMemory, chunked = planMemory(budget, estimate, conflict == nil)
+	}
+	if c.CorrelationMethod == audiosync.MethodPHAT {
+		chunked = false // PHAT always correlates in one FFT
+	}
+
+	switch {
+	case lowMemory && !c.LowMemory:
+		logf("Memory budget %d MB: estimated %d MB in memory, streaming files as --low-memory\n",
+			c.MaxMemoryMB, (estimate.load+estimate.correlation)/bytesPerMB)
+	case !c.LowMemory && !lowMemory && estimate.load+estimate.leanCorrelation() > budget:
+		warnf("⚠️  Memory budget %d MB: estimated %d MB even with block correlation\n",
+			c.MaxMemoryMB, (estimate.load+estimate.leanCorrelation())/bytesPerMB)
+		// Say which option kept the files from being streamed, which would have needed less
+		if conflict != nil {
+			warnf("  Not streaming as --low-memory (estimated %d MB): %v\n",
+				(estimate.streamed+estimate.leanCorrelation())/bytesPerMB, conflict)
+		}
+	}
+	if chunked && !c.Chunked {
+		logf("Memory budget %d MB: correlating in blocks\n", c.MaxMemoryMB)
+	}
+
+	c.LowMemory = c.LowMemory || lowMemory
+	c.Chunked = c.Chunked || chunked
+}
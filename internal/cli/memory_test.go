@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestPlanMemory(t *testing.T) {
+	estimate := memoryEstimate{load: 1000, streamed: 100, correlation: 500, chunkedCorrelation: 50}
+
+	tests := []struct {
+		name          string
+		budget        int64
+		streamable    bool
+		wantLowMemory bool
+		wantChunked   bool
+	}{
+		{"everything fits", 1500, true, false, false},
+		{"blocks fit", 1100, true, false, true},
+		{"streaming fits", 600, true, true, false},
+		{"streaming with blocks", 200, true, true, true},
+		{"nothing fits", 10, true, true, true},
+		{"not streamable", 200, false, false, true},
+	}
+
+	for _, tt := range tests {
+		lowMemory, chunked := planMemory(tt.budget, estimate, tt.streamable)
+		if lowMemory != tt.wantLowMemory || chunked != tt.wantChunked {
+			t.Errorf("%s: planMemory = %v, %v, want %v, %v", tt.name, lowMemory, chunked, tt.wantLowMemory, tt.wantChunked)
+		}
+	}
+}
+
+// memoryTestConfig returns a config for a mixed and a local WAV file of a minute of audio each
+func memoryTestConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "mixed.wav"), filepath.Join(dir, "local.wav")}
+	for _, path := range paths {
+		if err := audio.WriteWAV(path, make([]float64, 60*44100), 44100, 1, 16, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Config{
+		MixedPaths:        paths[:1],
+		LocalPaths:        paths[1:],
+		MaxMemoryMB:       1,
+		AutoResolutionMs:  audiosync.DefaultAutoResolutionMs,
+		CorrelationMethod: audiosync.MethodStandard,
+	}
+}
+
+func TestFitMemory(t *testing.T) {
+	t.Run("switches to streaming", func(t *testing.T) {
+		out, _ := captureOutput(t)
+		config := memoryTestConfig(t)
+		config.fitMemory()
+		if !config.LowMemory {
+			t.Errorf("LowMemory is off, want it on; output %q", out.String())
+		}
+	})
+
+	t.Run("reports the option that prevents streaming", func(t *testing.T) {
+		_, warnings := captureOutput(t)
+		config := memoryTestConfig(t)
+		config.CorrectDrift = true
+		config.fitMemory()
+		if config.LowMemory {
+			t.Error("LowMemory is on despite --correct-drift")
+		}
+		if !strings.Contains(warnings.String(), "--correct-drift") {
+			t.Errorf("warnings %q do not name --correct-drift", warnings.String())
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Human-readable output goes to stderr so stdout only carries machine output (e.g. --report -)
+var (
+	console    io.Writer = os.Stderr // Banner, steps and results
+	warnOutput io.Writer = os.Stderr // Warnings about the alignment
+)
+
+// Every human-readable message is a log record: progress at info, warnings at warn
+// and detection details at debug. The console handler prints messages as formatted;
+// --log-format json writes one JSON object per message instead.
+var (
+	logLevel = new(slog.LevelVar) // Lowest level written (info unless --log-level or --quiet change it)
+	logger   = slog.New(&consoleHandler{})
+	jsonLogs bool // Messages are written as JSON records, so their layout whitespace is trimmed
+)
+
+// setLogging selects the lowest level written and the record format ("text" or "json")
+func setLogging(level slog.Level, format string) {
+	logLevel.Set(level)
+	jsonLogs = format == "json"
+	if jsonLogs {
+		logger = slog.New(slog.NewJSONHandler(console, &slog.HandlerOptions{Level: logLevel}))
+	} else {
+		logger = slog.New(&consoleHandler{})
+	}
+}
+
+// setQuiet silences console output at level 1 and warnings as well at level 2
+func setQuiet(level int) {
+	if level >= 1 {
+		logLevel.Set(max(logLevel.Level(), slog.LevelWarn))
+	}
+	if level >= 2 {
+		logLevel.Set(max(logLevel.Level(), slog.LevelError))
+	}
+}
+
+// parseLogLevel converts a --log-level name into a slog level
+func parseLogLevel(name string) (slog.Level, error) {
+	switch name {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info or warn)", name)
+	}
+}
+
+// logf writes formatted progress output
+func logf(format string, args ...any) {
+	logMessage(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// logln writes a line of progress output
+func logln(args ...any) {
+	logMessage(slog.LevelInfo, fmt.Sprintln(args...))
+}
+
+// warnf writes a formatted warning
+func warnf(format string, args ...any) {
+	logMessage(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// warnln writes a line of warning output
+func warnln(args ...any) {
+	logMessage(slog.LevelWarn, fmt.Sprintln(args...))
+}
+
+// logMessage logs msg at level, dropping blank lines from JSON output
+func logMessage(level slog.Level, msg string) {
+	if jsonLogs {
+		if msg = strings.TrimSpace(msg); msg == "" {
+			return
+		}
+	}
+	logger.Log(context.Background(), level, msg)
+}
+
+// consoleHandler prints info and warn messages exactly as formatted, to console and warnOutput,
+// and debug records as a "[debug]" line with their attributes
+type consoleHandler struct {
+	attrs []slog.Attr // Attributes added with WithAttrs, printed before the record's own
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	w := console
+	if r.Level >= slog.LevelWarn {
+		w = warnOutput
+	}
+	if r.Level >= slog.LevelInfo {
+		_, err := io.WriteString(w, r.Message)
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("  [debug] " + r.Message)
+	appendAttr := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		appendAttr(a)
+	}
+	r.Attrs(appendAttr)
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &consoleHandler{attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+// WithGroup returns h unchanged: debug lines are flat, so group names are dropped
+func (h *consoleHandler) WithGroup(string) slog.Handler {
+	return h
+}
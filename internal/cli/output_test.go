@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// captureOutput redirects console output and warnings to buffers at info level for the rest of the test
+func captureOutput(t *testing.T) (out, warnings *bytes.Buffer) {
+	t.Helper()
+	out, warnings = &bytes.Buffer{}, &bytes.Buffer{}
+	oldConsole, oldWarn, oldLevel := console, warnOutput, logLevel.Level()
+	console, warnOutput = out, warnings
+	setLogging(slog.LevelInfo, "text")
+	t.Cleanup(func() {
+		console, warnOutput = oldConsole, oldWarn
+		setLogging(oldLevel, "text")
+	})
+	return out, warnings
+}
+
+func TestConsoleHandler(t *testing.T) {
+	out, warnings := captureOutput(t)
+
+	logf("step %d\n", 1)
+	warnln("careful")
+	logger.Debug("hidden", "key", 1)
+	if out.String() != "step 1\n" || warnings.String() != "careful\n" {
+		t.Errorf("console %q, warnings %q", out.String(), warnings.String())
+	}
+
+	logLevel.Set(slog.LevelDebug)
+	logger.Debug("detail", "key", 2)
+	if want := "step 1\n  [debug] detail key=2\n"; out.String() != want {
+		t.Errorf("console %q, want %q", out.String(), want)
+	}
+}
+
+func TestQuietReportOnStdout(t *testing.T) {
+	out, _ := captureOutput(t)
+	setQuiet(1)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// Capture stdout in a file, as a pipeline reading the report would
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = stdout
+	t.Cleanup(func() { os.Stdout = oldStdout })
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.Quiet = 1
+	config.ReportPath = "-"
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	os.Stdout = oldStdout
+	stdout.Close()
+
+	if out.Len() != 0 {
+		t.Errorf("--quiet printed progress: %q", out.String())
+	}
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("stdout is not a JSON report: %v\n%s", err, data)
+	}
+	if len(report.Files) != len(localPaths) {
+		t.Errorf("report has %d files, want %d", len(report.Files), len(localPaths))
+	}
+}
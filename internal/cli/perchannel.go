@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// errChannelPending is returned by the finishSync write callback for a channel whose file is written
+// together with its last channel
+var errChannelPending = errors.New("waiting for the other channels of the file")
+
+// channelGroup is a multi-channel local file whose channels PerChannel aligns as tracks of their own
+type channelGroup struct {
+	path     string      // The local file
+	first    int         // Index of its first channel among the tracks
+	channels int         // Its number of channels
+	aligned  [][]float64 // Aligned channels collected so far, in channel order
+}
+
+// splitChannels replaces each multi-channel local file with one mono track per channel, so every channel
+// is detected, fine-tuned and padded on its own, and names the tracks in config.LocalPaths
+// Mono files are kept as they are.
+func (c *Config) splitChannels(localFiles []*audio.WAVData) ([]*audio.WAVData, error) {
+	var paths []string
+	var tracks []*audio.WAVData
+	var groups []*channelGroup
+	for i, local := range localFiles {
+		if local.Channels == 1 {
+			paths = append(paths, c.LocalPaths[i])
+			tracks = append(tracks, local)
+			groups = append(groups, nil)
+			continue
+		}
+
+		group := &channelGroup{path: c.LocalPaths[i], first: len(tracks), channels: local.Channels}
+		for ch := range local.Channels {
+			data, err := audio.ToMonoMixdown(local.Data, local.Channels, audio.Mixdown{Mode: audio.MixdownChannel, Channel: ch})
+			if err != nil {
+				return nil, err
+			}
+			name := audiosync.ChannelTrackName(c.LocalPaths[i], ch+1)
+			track := *local
+			track.Path, track.Data, track.Channels = name, data, 1
+			paths = append(paths, name)
+			tracks = append(tracks, &track)
+			groups = append(groups, group)
+		}
+	}
+
+	c.LocalPaths = paths
+	c.channelGroups = groups
+	return tracks, nil
+}
+
+// labelChannels points the entries of split channels back at their file and records the channel
+func (c *Config) labelChannels(fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if group := c.channelGroupOf(i); group != nil {
+			fo.Path, fo.Channel = group.path, i-group.first+1
+		}
+	}
+}
+
+// spreadSuspect marks every channel of a file suspect if one of them is, as the channels are written as one file
+func (c *Config) spreadSuspect(fileOffsets []*audiosync.FileOffset) {
+	suspect := make(map[*channelGroup]bool)
+	for i, fo := range fileOffsets {
+		if group := c.channelGroupOf(i); group != nil && fo.Suspect {
+			suspect[group] = true
+		}
+	}
+	for i, fo := range fileOffsets {
+		if group := c.channelGroupOf(i); group != nil && suspect[group] {
+			fo.Suspect = true
+		}
+	}
+}
+
+// channelGroupOf returns the file track i is a channel of, or nil if the track is a whole file
+func (c *Config) channelGroupOf(i int) *channelGroup {
+	if c.channelGroups == nil {
+		return nil
+	}
+	return c.channelGroups[i]
+}
+
+// lastTrackOf reports whether track i is a whole file or the last channel of its file
+func (c *Config) lastTrackOf(i int) bool {
+	group := c.channelGroupOf(i)
+	return group == nil || i == group.first+group.channels-1
+}
+
+// sourcePath returns the local file track i was taken from
+func (c *Config) sourcePath(i int) string {
+	if group := c.channelGroupOf(i); group != nil {
+		return group.path
+	}
+	return c.LocalPaths[i]
+}
+
+// add collects the next aligned channel of the file and returns all its channels interleaved once the last
+// one has arrived, or nil before that
+// The channels may have been padded differently, so the shorter ones are filled with silence at the end.
+func (g *channelGroup) add(data []float64) ([]float64, error) {
+	g.aligned = append(g.aligned, data)
+	if len(g.aligned) < g.channels {
+		return nil, nil
+	}
+	return audio.Interleave(g.aligned, 1)
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"sync"
+)
+
+// progressReporter prints a "[done/total]" line each time a unit of parallel work finishes
+// It is safe to call from multiple goroutines; when disabled it prints nothing
+type progressReporter struct {
+	mu      sync.Mutex
+	enabled bool
+	done    int
+	total   int
+}
+
+// newProgressReporter creates a reporter for total units of work
+func newProgressReporter(enabled bool, total int) *progressReporter {
+	return &progressReporter{
+		enabled: enabled,
+		total:   total,
+	}
+}
+
+// step records one finished unit and prints its message
+func (p *progressReporter) step(format string, args ...any) {
+	if !p.enabled {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	logf("  [%d/%d] %s\n", p.done, p.total, fmt.Sprintf(format, args...))
+}
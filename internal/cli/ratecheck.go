@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// checkSampleRates looks for local files whose header states the wrong sample rate among those whose confidence
+// is still below minConfidence, and reinterprets the data of each one found at the rate it aligns at
+// headerRates holds the rate in each file's header, before it was resampled to the mixed rate.
+// Manual and otherwise known offsets (keyed by cleaned path) are not checked.
+func checkSampleRates(ctx context.Context, mixedMono []float64, sampleRate int, localFiles []*audio.WAVData, offsetResults []*audiosync.OffsetResult, known map[string]*audiosync.OffsetResult, headerRates map[*audio.WAVData]int, opts audiosync.DetectOptions) error {
+	for i, result := range offsetResults {
+		if result.Confidence >= minConfidence || result.Manual {
+			continue
+		}
+		local := localFiles[i]
+		if _, ok := known[filepath.Clean(local.Path)]; ok {
+			continue
+		}
+
+		localMono, err := audio.ToMonoMixdown(local.Data, local.Channels, opts.Mixdown)
+		if err != nil {
+			return err
+		}
+		mismatch, found, err := audiosync.DetectRateMismatch(ctx, mixedMono, localMono, sampleRate, headerRates[local], result, minConfidence, opts)
+		if err != nil {
+			return err
+		}
+		if mismatch == nil {
+			continue
+		}
+
+		warnf("  ⚠️  %s: header says %d Hz but it aligns as %d Hz (confidence %.2f → %.2f), treating it as %d Hz\n",
+			filepath.Base(local.Path), mismatch.LabeledRate, mismatch.ActualRate, result.Confidence, found.Confidence, mismatch.ActualRate)
+		local.Data = mismatch.Apply(local.Data, local.Channels)
+		found.Retried = result.Retried
+		offsetResults[i] = found
+	}
+	return nil
+}
+
+// sampleRates returns the current sample rate of each local file, keyed by file
+func sampleRates(localFiles []*audio.WAVData) map[*audio.WAVData]int {
+	rates := make(map[*audio.WAVData]int, len(localFiles))
+	for _, local := range localFiles {
+		rates[local] = local.SampleRate
+	}
+	return rates
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// reportSchemaVersion is bumped whenever the JSON report layout changes incompatibly
+const reportSchemaVersion = 1
+
+// Report formats accepted by --report-format
+const (
+	reportJSON = "json" // The full Report
+	reportCSV  = "csv"  // One comma-separated row of reportColumns per local file
+	reportTSV  = "tsv"  // One tab-separated row of reportColumns per local file
+)
+
+// reportColumns is the header of CSV and TSV reports, in the order reportRow fills them
+var reportColumns = []string{"path", "offset_sec", "final_offset_sec", "padding_sec", "confidence", "finetuned"}
+
+// parseReportFormat validates a --report-format value
+func parseReportFormat(s string) (string, error) {
+	switch s {
+	case reportJSON, reportCSV, reportTSV:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q (expected %s, %s or %s)", s, reportJSON, reportCSV, reportTSV)
+	}
+}
+
+// Report is the machine-readable summary of a synchronization run
+type Report struct {
+	SchemaVersion int                        `json:"schema_version"`
+	MixedPath     string                     `json:"mixed_path"`  // First mixed file
+	MixedPaths    []string                   `json:"mixed_paths"` // All mixed files, in session order
+	SampleRate    int                        `json:"sample_rate"`
+	Mode          audiosync.AlignMode        `json:"mode"`
+	Session       []audiosync.SessionSegment `json:"session,omitempty"` // Placement of each mixed file (multiple mixed files only)
+	Files         []*audiosync.FileOffset    `json:"files"`
+	Overlap       *audiosync.OverlapRegion   `json:"overlap,omitempty"` // Region every track covers at the coarse offsets, which fine-tuning picks its segment from
+	Summary       *audiosync.Summary         `json:"summary,omitempty"` // Confidence bands and total padding of the files
+	Failed        []FailedFile               `json:"failed,omitempty"`  // Local files left out by --continue-on-error
+}
+
+// writeReport writes the alignment results to path in config.ReportFormat
+// With several mixed files, offsets are relative to the session timeline described by session
+func writeReport(path string, config *Config, sampleRate int, session []audiosync.SessionSegment, fileOffsets []*audiosync.FileOffset) error {
+	var data []byte
+	var err error
+	switch config.ReportFormat {
+	case reportCSV:
+		data, err = encodeReportTable(fileOffsets, ',')
+	case reportTSV:
+		data, err = encodeReportTable(fileOffsets, '\t')
+	default:
+		data, err = encodeReportJSON(config, sampleRate, session, fileOffsets)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	// "-" writes the report to stdout, which carries no other output
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// encodeReportJSON encodes the full Report as indented JSON
+func encodeReportJSON(config *Config, sampleRate int, session []audiosync.SessionSegment, fileOffsets []*audiosync.FileOffset) ([]byte, error) {
+	report := &Report{
+		SchemaVersion: reportSchemaVersion,
+		MixedPath:     config.MixedPaths[0],
+		MixedPaths:    config.MixedPaths,
+		SampleRate:    sampleRate,
+		Mode:          config.Mode,
+		Session:       session,
+		Files:         fileOffsets,
+		Overlap:       config.Overlap,
+		Summary:       audiosync.Summarize(fileOffsets, minConfidence),
+		Failed:        config.Failed,
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// encodeReportTable encodes a header of reportColumns and one reportRow per file, separated by comma
+// Fields containing the separator, quotes or line breaks (e.g. a comma in a path) are quoted as in RFC 4180.
+func encodeReportTable(fileOffsets []*audiosync.FileOffset, comma rune) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	w.Write(reportColumns)
+	for _, fo := range fileOffsets {
+		w.Write(reportRow(fo))
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// reportRow returns the values of reportColumns for one file
+func reportRow(fo *audiosync.FileOffset) []string {
+	// Offsets are written to the microsecond; adding 0 turns a rounded -0 into 0
+	seconds := func(v float64) string {
+		return strconv.FormatFloat(math.Round(v*1e6)/1e6+0, 'f', 6, 64)
+	}
+	finetuned := fo.FinetuneResult != nil && !fo.FinetuneResult.Skipped
+	return []string{
+		fo.TrackName(),
+		seconds(fo.OffsetSeconds),
+		seconds(fo.FinalOffsetSeconds),
+		seconds(fo.PaddingSeconds),
+		strconv.FormatFloat(fo.Confidence, 'f', 4, 64),
+		strconv.FormatBool(finetuned),
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestRunCSVReport(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// A comma in a path must not split its row
+	renamed := filepath.Join(dir, "smith, bob.wav")
+	if err := os.Rename(localPaths[1], renamed); err != nil {
+		t.Fatal(err)
+	}
+	localPaths[1] = renamed
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.ReportFormat = reportCSV
+	config.ReportPath = filepath.Join(dir, "report.csv")
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(config.ReportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "path,offset_sec,final_offset_sec,padding_sec,confidence,finetuned\n") {
+		t.Errorf("report starts %q, want the stable header", strings.SplitN(string(data), "\n", 2)[0])
+	}
+	if !strings.Contains(string(data), `"`+renamed+`",`) {
+		t.Errorf("path with a comma is not quoted:\n%s", data)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("report is not valid CSV: %v", err)
+	}
+	if len(records) != 1+len(localPaths) {
+		t.Fatalf("%d records, want a header and one row per file (%d)", len(records), 1+len(localPaths))
+	}
+	for i, row := range records[1:] {
+		if len(row) != len(reportColumns) || row[0] != localPaths[i] {
+			t.Errorf("row %d = %q, want %d columns for %s", i, row, len(reportColumns), localPaths[i])
+			continue
+		}
+		final, err := strconv.ParseFloat(row[2], 64)
+		if err != nil || math.Abs(final-testOffsets[i]) > 1.0/selftestRate {
+			t.Errorf("row %d final offset %q, want %g", i, row[2], testOffsets[i])
+		}
+		if row[5] != "true" {
+			t.Errorf("row %d finetuned = %q, want true", i, row[5])
+		}
+	}
+}
+
+func TestEncodeReportTable(t *testing.T) {
+	fileOffsets := []*audiosync.FileOffset{
+		{Path: "a.wav", OffsetSeconds: 1.25, FinalOffsetSeconds: 1.2500004, PaddingSeconds: 0, Confidence: 0.91234,
+			FinetuneResult: &audiosync.FinetuneResult{}},
+		{Path: "tab\there.wav", OffsetSeconds: -0.0000001, FinalOffsetSeconds: 3, PaddingSeconds: 1.75, Confidence: 0.5,
+			FinetuneResult: &audiosync.FinetuneResult{Skipped: true}},
+	}
+
+	tests := []struct {
+		name  string
+		comma rune
+		want  string
+	}{
+		{"csv", ',', "path,offset_sec,final_offset_sec,padding_sec,confidence,finetuned\n" +
+			"a.wav,1.250000,1.250000,0.000000,0.9123,true\n" +
+			"tab\there.wav,0.000000,3.000000,1.750000,0.5000,false\n"},
+		{"tsv", '\t', "path\toffset_sec\tfinal_offset_sec\tpadding_sec\tconfidence\tfinetuned\n" +
+			"a.wav\t1.250000\t1.250000\t0.000000\t0.9123\ttrue\n" +
+			"\"tab\there.wav\"\t0.000000\t3.000000\t1.750000\t0.5000\tfalse\n"},
+	}
+
+	for _, tt := range tests {
+		data, err := encodeReportTable(fileOffsets, tt.comma)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: report\n%q\nwant\n%q", tt.name, data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// retrySegmentSeconds is the length of the most energetic local segment tried by the second pass
+const retrySegmentSeconds = 30.0
+
+// retryLowConfidence runs a second detection pass for files whose first-pass confidence is below minConfidence
+// on the most energetic part of the local track, and keeps whichever result has the higher confidence
+// Less downsampling is not tried again, as the first pass already backed off to finer factors.
+// Manual and otherwise known offsets (keyed by cleaned path) are not retried,
+// and neither are offsets from a custom detector, which does not use the correlation settings.
+func retryLowConfidence(ctx context.Context, mixedMono []float64, sampleRate int, localFiles []*audio.WAVData, offsetResults []*audiosync.OffsetResult, known map[string]*audiosync.OffsetResult, opts audiosync.DetectOptions) error {
+	if opts.Detector != nil {
+		return nil
+	}
+	for i, result := range offsetResults {
+		if result.Confidence >= minConfidence || result.Manual {
+			continue
+		}
+		path := localFiles[i].Path
+		if _, ok := known[filepath.Clean(path)]; ok {
+			continue
+		}
+
+		localMono, err := audio.ToMonoMixdown(localFiles[i].Data, localFiles[i].Channels, opts.Mixdown)
+		if err != nil {
+			return err
+		}
+
+		best := result
+		for _, retry := range retryOptions(opts, sampleRate, len(localMono)) {
+			candidate, err := audiosync.DetectOffset(ctx, mixedMono, localMono, sampleRate, retry)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				warnf("  ⚠️  Retry failed for %s: %v\n", filepath.Base(path), err)
+				continue
+			}
+			if candidate.Confidence > best.Confidence {
+				best = candidate
+			}
+		}
+
+		best.Retried = true
+		offsetResults[i] = best
+		if best == result {
+			logf("  ↻ %s: retried, keeping the first pass (confidence: %.2f)\n", filepath.Base(path), result.Confidence)
+		} else {
+			logf("  ↻ %s: retried, confidence %.2f → %.2f\n", filepath.Base(path), result.Confidence, best.Confidence)
+		}
+	}
+	return nil
+}
+
+// retryOptions returns the detection settings tried by the second pass for a local track of localSamples
+// A track shorter than the segment would repeat the first pass, so nothing is tried for it.
+func retryOptions(opts audiosync.DetectOptions, sampleRate, localSamples int) []audiosync.DetectOptions {
+	var variants []audiosync.DetectOptions
+	segment := opts
+	if segment.CoarseSegment == 0 {
+		segment.CoarseSegment = retrySegmentSeconds
+	}
+	segment.EnergeticSegment = true
+	if int(segment.CoarseSegment*float64(sampleRate)) < localSamples {
+		variants = append(variants, segment)
+	}
+	return variants
+}
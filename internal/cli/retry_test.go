@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestRetryLowConfidence(t *testing.T) {
+	out, _ := captureOutput(t)
+	const rate = 8000
+	rng := rand.New(rand.NewPCG(7, 8))
+	mixed := selftestSignal(rng, 200*rate)
+
+	// 40 s of the mix recorded loudly between long, quieter recordings of something else
+	// Over the whole overlap the unrelated audio drowns the match, but its most energetic segment is the match.
+	unrelated := selftestSignal(rng, 300*rate)
+	for i := range unrelated {
+		unrelated[i] *= 0.8
+	}
+	local := slices.Concat(unrelated[:100*rate], mixed[80*rate:120*rate], unrelated[100*rate:])
+	offset := -20 * rate
+
+	opts := testConfig("", nil).detectOptions()
+	opts.BackoffBelow = 0 // Only the second pass is under test
+	first, err := audiosync.DetectOffset(context.Background(), mixed, local, rate, opts)
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if first.Confidence >= minConfidence {
+		t.Fatalf("first pass confidence %.2f, want below %.2f for the retry to run", first.Confidence, minConfidence)
+	}
+
+	results := []*audiosync.OffsetResult{first}
+	localFiles := []*audio.WAVData{{Path: "guest.wav", SampleRate: rate, Channels: 1, Data: local}}
+	if err := retryLowConfidence(context.Background(), mixed, rate, localFiles, results, nil, opts); err != nil {
+		t.Fatalf("retryLowConfidence: %v", err)
+	}
+	retried := results[0]
+	if !retried.Retried || retried.Confidence < minConfidence {
+		t.Errorf("second pass confidence %.2f (retried %v), want at least %.2f", retried.Confidence, retried.Retried, minConfidence)
+	}
+	if diff := retried.OffsetSamples - offset; diff < -opts.DownsampleFactor || diff > opts.DownsampleFactor {
+		t.Errorf("second pass offset %d, want %d within one coarse sample", retried.OffsetSamples, offset)
+	}
+	if !strings.Contains(out.String(), "guest.wav: retried, confidence") {
+		t.Errorf("output does not report the retry:\n%s", out.String())
+	}
+
+	// A known offset is never retried
+	results = []*audiosync.OffsetResult{first}
+	known := map[string]*audiosync.OffsetResult{"guest.wav": first}
+	if err := retryLowConfidence(context.Background(), mixed, rate, localFiles, results, known, opts); err != nil {
+		t.Fatalf("retryLowConfidence: %v", err)
+	}
+	if results[0] != first {
+		t.Error("a known offset was retried")
+	}
+}
@@ -1,31 +1,197 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime/pprof"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
+	audiosync "github.com/shidetake/clapless/internal/sync"
 	"github.com/spf13/cobra"
 )
 
 // Config holds the parsed command-line configuration
 type Config struct {
-	MixedPath        string
-	LocalPaths       []string
-	SegmentDuration  int // Segment duration in seconds for correlation (default: 600)
-	DownsampleFactor int // Downsample factor for coarse search (default: 50)
+	MixedPaths          []string
+	LocalPaths          []string
+	SegmentDuration     int                         // Segment duration in seconds for correlation (default: 600)
+	DownsampleFactor    int                         // Downsample factor for coarse search (default: 50, 0 = auto)
+	AutoResolutionMs    float64                     // Coarsest coarse-search resolution in milliseconds --downsample auto may pick
+	NoResample          bool                        // Fail on sample rate mismatch instead of resampling local files
+	CorrelationMethod   audiosync.CorrelationMethod // Cross-correlation method (standard, phat or onset)
+	Chunked             bool                        // Always correlate in fixed-size blocks to bound FFT memory
+	BandpassLow         int                         // Band-pass lower cutoff in Hz (default: 300)
+	BandpassHigh        int                         // Band-pass upper cutoff in Hz (default: 3400)
+	PreFilter           audiosync.PreFilter         // Biquad stages applied to both signals after the band-pass (nil = none)
+	Mode                audiosync.AlignMode         // Output alignment mode (pad or trim)
+	AnchorPath          string                      // Local file the others are aligned to (empty = earliest or latest per Mode)
+	ReportPath          string                      // Path of the report (empty = no report)
+	ReportFormat        string                      // Serialization of the report: json, csv or tsv
+	LabelsPath          string                      // Path of an Audacity label file for the synced outputs (empty = none)
+	FFmpegScriptPath    string                      // Path of a shell script applying the alignment with ffmpeg (empty = none)
+	DumpCorrelationDir  string                      // Directory the coarse correlation of each local file is written to as CSV (empty = none)
+	DumpCorrelationStep int                         // Number of lags folded into each dumped row (1 = every lag)
+	LowMemory           bool                        // Stream WAV files instead of loading them fully into memory
+	MaxMemoryMB         int                         // Switch to block correlation and streaming if the estimated memory use exceeds this (0 = no limit)
+	CorrectDrift        bool                        // Estimate and correct linear clock drift of local files
+	DetectRateMismatch  bool                        // Try other sample rates for files that align poorly, in case their header is wrong
+	SplitGaps           bool                        // Find pauses in local files and reinsert the missing time as silence
+	Progress            bool                        // Print a line as each file finishes detection and fine-tuning
+	Quiet               int                         // 1 = no progress output, 2 = no warnings either (errors are always printed)
+	LogLevel            slog.Level                  // Lowest level of messages written (debug adds correlation and fine-tuning details)
+	LogFormat           string                      // Format of the messages written to stderr: text or json
+	Profile             bool                        // Print the time spent in each stage
+	ContinueOnError     bool                        // Leave out local files that fail to load or correlate and sync the rest
+	Failed              []FailedFile                // Local files left out by ContinueOnError (filled in during the run)
+	Overlap             *audiosync.OverlapRegion    // Region every track covers at the coarse offsets (filled in during the run; nil = none)
+	FailBelow           float64                     // Abort without writing files if any confidence is below this (0 = warn only)
+	MaxPaddingSec       float64                     // Leave out files whose padding exceeds this many seconds (0 = no limit)
+	FailOnMaxPadding    bool                        // Abort without writing files instead if any padding exceeds MaxPaddingSec
+	CommonLeadSec       float64                     // Silence in seconds added before every output after alignment (0 = none)
+	PreviewDuration     float64                     // Only process the first this many minutes of every file (0 = whole files)
+	Window              audiosync.WindowType        // Window applied to signals before correlation (none, hann or tukey)
+	LevelMatch          bool                        // Even out the loudness of short blocks before correlation
+	Mixdown             audio.Mixdown               // How multi-channel local files are collapsed to mono
+	PerChannel          bool                        // Align each channel of multi-channel local files on its own instead of their mixdown
+	OutputChannels      int                         // Channels every synced file is converted to before writing: 1 or 2 (0 = keep each file's own)
+	EqualizeLength      bool                        // Pad the end of every synced file with silence to the length of the longest
+	OutputDir           string                      // Directory the synced files are written to (empty = next to each local file)
+	OutputSuffix        string                      // Appended to the name of each local file for its synced file (default: _synced)
+	OutputPattern       string                      // Name of each synced file with {name} and {ext} placeholders, used instead of OutputSuffix (empty = none)
+	InvertPolarity      bool                        // Flip the sign of every sample of the synced files, so each nulls against the aligned mixed track
+	TrimSilenceDB       float64                     // Leave out leading and trailing audio below this dBFS level from the coarse search (0 = disabled)
+	CombinePath         string                      // Path of a multi-channel WAV with one aligned track per channel (empty = none)
+	CombineLayout       string                      // Channels per track in the combined file: mono, stereo or auto
+	PreviewMixPath      string                      // Path of a mono mixdown of all aligned tracks (empty = none)
+	PreviewNormalize    bool                        // Scale each track to the same peak before summing the preview mix
+	MinPeakToSidelobe   float64                     // Warn if a coarse peak-to-sidelobe ratio is below this (0 = disabled)
+	BitDepth            int                         // Output bit depth (0 = keep each file's own depth)
+	FloatOutput         bool                        // Write 32-bit IEEE float WAV output (overrides BitDepth)
+	SampleRateOut       int                         // Resample every output to this rate in Hz (0 = keep the processing rate)
+	NormalizeOutput     bool                        // Scale outputs that would clip down to full scale instead of clamping them
+	TargetLUFS          float64                     // Bring each synced file to this integrated loudness (0 = keep the level)
+	MaxOffset           float64                     // Only search offsets within ±MaxOffset seconds (0 = unlimited)
+	ExcludeLagMs        float64                     // Leave offsets within ±this many milliseconds of zero out of the coarse search (0 = none)
+	CoarseSegment       float64                     // Seconds from the middle of each local file used for the coarse search (0 = whole file)
+	FinetuneTarget      float64                     // Fine-tuning segment length in seconds (default: 60)
+	FinetuneMin         float64                     // Minimum overlap in seconds required to fine-tune (default: 30)
+	FinetuneFixed       bool                        // Place the fine-tuning segment at FinetunePosition instead of on the most energetic part of the overlap
+	FinetunePosition    float64                     // Where a fixed fine-tuning segment lies in the overlap (0 = start, 0.5 = center, 1 = end)
+	FadeInMs            float64                     // Fade-in length after padding or trimming in milliseconds (0 = none)
+	FractionalDelay     bool                        // Apply the sub-sample part of each offset with a fractional-delay filter
+	VerifyOutput        bool                        // Re-read each synced file and check its length and alignment against the source
+	ManualOffsets       map[string]float64          // Known offsets in seconds by cleaned local path, used instead of detection
+	KeepManual          bool                        // Do not fine-tune files with a manual offset
+	SkipExisting        bool                        // Reuse the reported offsets of files whose synced output is newer than the source
+	SaveSessionPath     string                      // Path to save the alignment to for a later --load-session (empty = none)
+	LoadSessionPath     string                      // Path of a saved alignment to write instead of detecting offsets (empty = detect)
+	Detector            audiosync.Detector          // Coarse offset detector used instead of the correlation (nil = built-in; not a flag)
+	Threads             int                         // Maximum number of local files detected or fine-tuned at once (0 = GOMAXPROCS)
+	CacheDir            string                      // Directory detected offsets are cached in (empty = the user cache directory)
+	NoCache             bool                        // Detect every offset instead of reading or writing the cache
+	SyncNote            bool                        // Append a note on the applied alignment to the comments tag of WAV outputs
+	AllowDuplicates     bool                        // Only warn instead of failing when two inputs have the same audio
+
+	channelGroups []*channelGroup // File each track split by PerChannel belongs to (nil for mono files; nil = not split)
 }
 
 var (
-	mixedPath        string
-	segmentDuration  int
-	downsampleFactor int
+	mixedPaths          []string
+	segmentDuration     int
+	downsample          string
+	autoResolutionMs    float64
+	noResample          bool
+	correlationMethod   string
+	chunked             bool
+	bandpassLow         int
+	bandpassHigh        int
+	preFilter           string
+	mode                string
+	anchorPath          string
+	reportPath          string
+	reportFormat        string
+	labelsPath          string
+	ffmpegScriptPath    string
+	dumpCorrelationDir  string
+	dumpCorrelationStep int
+	lowMemory           bool
+	maxMemoryMB         int
+	threads             int
+	cacheDir            string
+	noCache             bool
+	allowDuplicates     bool
+	syncNote            bool
+	correctDrift        bool
+	splitGaps           bool
+	detectRateMismatch  bool
+	progress            bool
+	quiet               int
+	logLevelName        string
+	logFormat           string
+	profile             bool
+	cpuProfilePath      string
+	timeout             time.Duration
+	failBelow           float64
+	maxPaddingSec       float64
+	failOnMaxPadding    bool
+	commonLeadSec       float64
+	previewDuration     float64
+	continueOnError     bool
+	window              string
+	levelMatch          bool
+	mixdown             string
+	perChannel          bool
+	forceStereo         bool
+	forceMono           bool
+	equalizeLength      bool
+	outputDir           string
+	outputSuffix        string
+	outputPattern       string
+	invertPolarity      bool
+	trimSilenceDB       float64
+	combinePath         string
+	combineLayout       string
+	previewMixPath      string
+	previewNormalize    bool
+	minPeakToSidelobe   float64
+	bitDepth            string
+	floatOutput         bool
+	sampleRateOut       int
+	normalizeOutput     bool
+	targetLUFS          float64
+	maxOffset           float64
+	excludeLagMs        float64
+	coarseSegment       float64
+	finetuneTarget      float64
+	finetuneMin         float64
+	finetunePosition    float64
+	fadeInMs            float64
+	fractionalDelay     bool
+	verifyOutput        bool
+	manualOffsets       []string
+	keepManual          bool
+	skipExisting        bool
+	saveSessionPath     string
+	loadSessionPath     string
+	inputFormat         string
+	rawRate             int
+	rawChannels         int
+	rawBits             string
+	rawEndian           string
 )
 
 var rootCmd = &cobra.Command{
 	Use:     "clapless [flags] <local1.wav> <local2.wav> [local3.wav ...]",
 	Short:   "Audio Synchronization Tool",
+	Args:    cobra.ArbitraryArgs, // Local files; without this cobra treats them as unknown subcommands
 	Version: Version,
 	Long: `Clapless - Audio Synchronization Tool
 
@@ -34,14 +200,21 @@ Automatically synchronize local podcast recordings with a mixed source.
 Example:
   clapless --mixed podcast_mix.wav alice.wav bob.wav
   clapless -m podcast_mix.wav -d 100 alice.wav bob.wav
+  clapless -m part1_mix.wav -m part2_mix.wav alice.wav bob.wav
+
+Input files may be WAV, FLAC, MP3, AIFF or Ogg Vorbis, or headerless PCM (.raw, .pcm)
+with --format raw and --raw-rate.
 
 Output:
-  Creates synchronized files with _synced suffix:
+  Creates synchronized files with _synced suffix next to the inputs
+  (AIFF and FLAC inputs keep their format, MP3 and Ogg Vorbis are written as WAV):
     alice_synced.wav
-    bob_synced.wav`,
+    bob_synced.wav
+  --output-dir, --output-suffix and --output-pattern change where and
+  under which name they are written.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Validate mixed path
-		if mixedPath == "" {
+		// Validate mixed paths
+		if len(mixedPaths) == 0 {
 			return fmt.Errorf("--mixed flag is required")
 		}
 
@@ -50,55 +223,655 @@ Output:
 			return fmt.Errorf("at least 2 local audio files are required, got %d", len(args))
 		}
 
+		// Accept headerless PCM files before checking extensions
+		if err := useRawFormat(cmd); err != nil {
+			return err
+		}
+
 		// Validate file existence and format
-		if err := validateFile(mixedPath); err != nil {
-			return fmt.Errorf("mixed file error: %w", err)
+		stdinCount := 0
+		for _, path := range mixedPaths {
+			if path == audio.StdinPath {
+				stdinCount++
+				continue
+			}
+			if err := validateFile(path); err != nil {
+				return fmt.Errorf("mixed file error: %w", err)
+			}
+		}
+		if stdinCount > 1 {
+			return fmt.Errorf("stdin (%s) can be used for only one --mixed file", audio.StdinPath)
 		}
 
 		for i, path := range args {
+			if path == audio.StdinPath {
+				return fmt.Errorf("stdin (%s) is only supported for --mixed; local files must be paths", audio.StdinPath)
+			}
 			if err := validateFile(path); err != nil {
 				return fmt.Errorf("local file %d (%s) error: %w", i+1, path, err)
 			}
 		}
 
+		// Reject the same file passed twice
+		if !allowDuplicates {
+			if err := duplicatePaths(mixedPaths, args); err != nil {
+				return err
+			}
+		}
+
 		// Validate segment duration
 		if segmentDuration <= 0 {
 			return fmt.Errorf("segment duration must be positive, got %d", segmentDuration)
 		}
 
 		// Validate downsample factor
-		if downsampleFactor < 1 {
-			return fmt.Errorf("downsample factor must be >= 1, got %d", downsampleFactor)
+		downsampleFactor, err := parseDownsample(downsample)
+		if err != nil {
+			return err
+		}
+		if autoResolutionMs <= 0 {
+			return fmt.Errorf("--auto-resolution-ms must be positive, got %g", autoResolutionMs)
+		}
+
+		// Validate correlation method
+		method, err := audiosync.ParseCorrelationMethod(correlationMethod)
+		if err != nil {
+			return err
+		}
+
+		if chunked && method == audiosync.MethodPHAT {
+			return fmt.Errorf("--chunked cannot be combined with --correlation-method %s", audiosync.MethodPHAT)
+		}
+
+		// Validate correlation window
+		windowType, err := audiosync.ParseWindowType(window)
+		if err != nil {
+			return err
+		}
+
+		// Validate band-pass cutoffs
+		if bandpassLow < 0 || bandpassHigh < 0 {
+			return fmt.Errorf("band-pass cutoffs must not be negative, got %d-%d Hz", bandpassLow, bandpassHigh)
+		}
+		if bandpassHigh > 0 && bandpassHigh <= bandpassLow {
+			return fmt.Errorf("band-pass upper cutoff must be greater than lower cutoff, got %d-%d Hz", bandpassLow, bandpassHigh)
+		}
+
+		// Validate pre-filter
+		preFilterStages, err := audiosync.ParsePreFilter(preFilter)
+		if err != nil {
+			return fmt.Errorf("--pre-filter: %w", err)
+		}
+
+		// Validate channel mixdown
+		localMixdown, err := audio.ParseMixdown(mixdown)
+		if err != nil {
+			return err
+		}
+
+		// Validate silence threshold
+		if trimSilenceDB > 0 {
+			return fmt.Errorf("--trim-silence-db must be a negative dBFS level (or 0 to disable), got %g", trimSilenceDB)
+		}
+
+		// Validate per-stage segment lengths
+		if coarseSegment < 0 {
+			return fmt.Errorf("--coarse-segment-sec must not be negative, got %g", coarseSegment)
+		}
+		if finetuneTarget <= 0 || finetuneMin <= 0 {
+			return fmt.Errorf("fine-tuning durations must be positive, got target %gs and minimum %gs", finetuneTarget, finetuneMin)
+		}
+		if finetuneMin > finetuneTarget {
+			return fmt.Errorf("--finetune-min-sec (%gs) must not exceed --finetune-target-sec (%gs)", finetuneMin, finetuneTarget)
+		}
+		if finetunePosition < 0 || finetunePosition > 1 {
+			return fmt.Errorf("--finetune-position must be between 0 and 1, got %g", finetunePosition)
+		}
+
+		// Validate fade-in length
+		if fadeInMs < 0 {
+			return fmt.Errorf("--fade-in-ms must not be negative, got %g", fadeInMs)
+		}
+
+		// Validate offset search limit
+		if maxOffset < 0 {
+			return fmt.Errorf("--max-offset must not be negative, got %g", maxOffset)
+		}
+		if excludeLagMs < 0 {
+			return fmt.Errorf("--exclude-lag-ms must not be negative, got %g", excludeLagMs)
+		}
+
+		// Validate abort threshold
+		if failBelow < 0 || failBelow > 1 {
+			return fmt.Errorf("--fail-below must be between 0 and 1, got %g", failBelow)
+		}
+
+		// Validate padding limit
+		if maxPaddingSec < 0 {
+			return fmt.Errorf("--max-padding-sec must not be negative, got %g", maxPaddingSec)
+		}
+		if failOnMaxPadding && maxPaddingSec == 0 {
+			return fmt.Errorf("--fail-on-max-padding requires --max-padding-sec")
+		}
+
+		// Validate common lead
+		if commonLeadSec < 0 {
+			return fmt.Errorf("--common-lead-sec must not be negative, got %g", commonLeadSec)
+		}
+
+		// Validate preview duration
+		if previewDuration < 0 {
+			return fmt.Errorf("--preview-duration must not be negative, got %g", previewDuration)
+		}
+		if previewDuration > 0 && (len(mixedPaths) > 1 || skipExisting) {
+			return fmt.Errorf("--preview-duration cannot be combined with several --mixed files or --skip-existing")
+		}
+
+		// Validate peak-to-sidelobe threshold
+		if minPeakToSidelobe < 0 {
+			return fmt.Errorf("--min-peak-to-sidelobe must not be negative, got %g", minPeakToSidelobe)
+		}
+
+		// Validate output sample format
+		outputBitDepth, outputFloat, err := parseBitDepth(bitDepth)
+		if err != nil {
+			return err
+		}
+		if floatOutput {
+			if bitDepth != "" && !outputFloat {
+				return fmt.Errorf("--float-output cannot be combined with --bit-depth %s", bitDepth)
+			}
+			outputFloat = true
+		}
+		outputs := []string{combinePath, previewMixPath}
+		for _, path := range args {
+			outputs = append(outputs, render.OutputPath(path, outputDir, outputSuffix, outputPattern))
+		}
+		for _, path := range outputs {
+			ext := strings.ToLower(filepath.Ext(path))
+			if outputFloat && (ext == ".aif" || ext == ".aiff" || ext == ".flac") {
+				return fmt.Errorf("float output is only supported for WAV files: %s", path)
+			}
+			if outputBitDepth > 24 && ext == ".flac" {
+				return fmt.Errorf("--bit-depth %s is not supported for FLAC output (at most 24): %s", bitDepth, path)
+			}
+		}
+		if targetLUFS > 0 || targetLUFS < -70 {
+			return fmt.Errorf("--target-lufs must be between -70 and 0, got %g", targetLUFS)
+		}
+		if normalizeOutput && outputFloat {
+			return fmt.Errorf("--normalize-output has no effect on float output, which is not clamped")
+		}
+		if sampleRateOut < 0 {
+			return fmt.Errorf("--sample-rate-out must not be negative, got %d", sampleRateOut)
+		}
+		if sampleRateOut > 0 && verifyOutput {
+			return fmt.Errorf("--verify-output cannot be combined with --sample-rate-out")
+		}
+
+		// Validate alignment mode
+		alignMode, err := audiosync.ParseAlignMode(mode)
+		if err != nil {
+			return err
+		}
+
+		// Validate anchor file
+		if anchorPath != "" {
+			if alignMode == audiosync.ModeTrim {
+				return fmt.Errorf("--anchor cannot be combined with --mode %s", audiosync.ModeTrim)
+			}
+			isLocal := false
+			for _, path := range args {
+				isLocal = isLocal || filepath.Clean(path) == filepath.Clean(anchorPath)
+			}
+			if !isLocal {
+				return fmt.Errorf("--anchor must be one of the local files, got %s", anchorPath)
+			}
+		}
+
+		// Validate memory budget
+		if maxMemoryMB < 0 {
+			return fmt.Errorf("--max-memory must not be negative, got %d", maxMemoryMB)
+		}
+
+		// Validate concurrency limit
+		if threads < 0 {
+			return fmt.Errorf("--threads must not be negative, got %d", threads)
+		}
+
+		// Validate time limit
+		if timeout < 0 {
+			return fmt.Errorf("--timeout must not be negative, got %s", timeout)
+		}
+
+		// Validate manual offsets
+		manual, err := parseManualOffsets(manualOffsets, args)
+		if err != nil {
+			return err
+		}
+		if len(manual) > 0 && len(mixedPaths) > 1 {
+			return fmt.Errorf("--offset cannot be combined with several --mixed files")
+		}
+
+		// Validate report format
+		format, err := parseReportFormat(reportFormat)
+		if err != nil {
+			return err
+		}
+
+		// Skipping files relies on the offsets recorded in the previous report
+		if skipExisting {
+			if reportPath == "" || reportPath == "-" {
+				return fmt.Errorf("--skip-existing requires --report <file> to record offsets between runs")
+			}
+			if format != reportJSON {
+				return fmt.Errorf("--skip-existing reads offsets from a JSON report and cannot be combined with --report-format %s", format)
+			}
+			if lowMemory || correctDrift || splitGaps {
+				return fmt.Errorf("--skip-existing cannot be combined with --low-memory, --correct-drift or --split-gaps")
+			}
+		}
+
+		// A loaded session replaces detection, fine-tuning, gap splitting, drift correction and the rate check
+		if loadSessionPath != "" {
+			if lowMemory || skipExisting || correctDrift || splitGaps || detectRateMismatch || len(manual) > 0 {
+				return fmt.Errorf("--load-session cannot be combined with --low-memory, --skip-existing, --correct-drift, --split-gaps, --detect-rate-mismatch or --offset")
+			}
+		}
+
+		// Split channels are tracks that options naming a file cannot refer to
+		if perChannel {
+			if len(manual) > 0 || skipExisting || anchorPath != "" || saveSessionPath != "" || loadSessionPath != "" || verifyOutput || targetLUFS != 0 || detectRateMismatch || continueOnError {
+				return fmt.Errorf("--per-channel cannot be combined with --offset, --skip-existing, --anchor, --save-session, --load-session, --verify-output, --target-lufs, --detect-rate-mismatch or --continue-on-error")
+			}
+		}
+
+		// Validate the output channel override
+		outputChannels := 0
+		switch {
+		case forceStereo && forceMono:
+			return fmt.Errorf("--force-stereo cannot be combined with --force-mono")
+		case forceStereo:
+			outputChannels = 2
+		case forceMono:
+			outputChannels = 1
+		}
+
+		// Outputs kept by --skip-existing were padded to the longest track of an earlier run
+		if equalizeLength && skipExisting {
+			return fmt.Errorf("--equalize-length cannot be combined with --skip-existing")
+		}
+
+		// The ffmpeg script only delays or trims the start of each file
+		if ffmpegScriptPath != "" && (correctDrift || splitGaps || perChannel) {
+			return fmt.Errorf("--emit-ffmpeg only applies offsets and cannot be combined with --correct-drift, --split-gaps or --per-channel")
+		}
+
+		// Validate correlation dump
+		if dumpCorrelationStep < 1 {
+			return fmt.Errorf("--dump-correlation-step must be at least 1, got %d", dumpCorrelationStep)
+		}
+		if dumpCorrelationDir != "" && len(mixedPaths) > 1 {
+			return fmt.Errorf("--dump-correlation cannot be combined with several --mixed files")
+		}
+
+		// Validate output naming
+		if outputPattern != "" {
+			if !strings.Contains(outputPattern, "{name}") {
+				return fmt.Errorf("--output-pattern must contain {name}, or every output would have the same name: %s", outputPattern)
+			}
+			if !strings.Contains(outputPattern, "{ext}") && !audio.CanWrite(outputPattern) {
+				return fmt.Errorf("--output-pattern must end in {ext} or a .wav, .aiff or .flac extension, got %s", outputPattern)
+			}
+		}
+		for _, path := range args {
+			output := render.OutputPath(path, outputDir, outputSuffix, outputPattern)
+			if sameFile(output, path) {
+				return fmt.Errorf("the synced file of %s would overwrite it; set --output-dir, --output-suffix or --output-pattern", path)
+			}
+		}
+
+		// Validate combined output format
+		if combinePath != "" && !audio.CanWrite(combinePath) {
+			return fmt.Errorf("--combine must be a .wav, .aiff or .flac path, got %s", combinePath)
+		}
+		layout, err := parseCombineLayout(combineLayout)
+		if err != nil {
+			return err
+		}
+		if previewMixPath != "" && !audio.CanWrite(previewMixPath) {
+			return fmt.Errorf("--preview-mix must be a .wav, .aiff or .flac path, got %s", previewMixPath)
+		}
+
+		// Validate logging
+		level, err := parseLogLevel(logLevelName)
+		if err != nil {
+			return err
+		}
+		if logFormat != "text" && logFormat != "json" {
+			return fmt.Errorf("--log-format must be text or json, got %s", logFormat)
 		}
 
 		// Build config
 		config := &Config{
-			MixedPath:        mixedPath,
-			LocalPaths:       args,
-			SegmentDuration:  segmentDuration,
-			DownsampleFactor: downsampleFactor,
+			MixedPaths:          mixedPaths,
+			LocalPaths:          args,
+			SegmentDuration:     segmentDuration,
+			DownsampleFactor:    downsampleFactor,
+			AutoResolutionMs:    autoResolutionMs,
+			NoResample:          noResample,
+			CorrelationMethod:   method,
+			Chunked:             chunked,
+			BandpassLow:         bandpassLow,
+			BandpassHigh:        bandpassHigh,
+			PreFilter:           preFilterStages,
+			Mode:                alignMode,
+			AnchorPath:          anchorPath,
+			ReportPath:          reportPath,
+			ReportFormat:        format,
+			LabelsPath:          labelsPath,
+			FFmpegScriptPath:    ffmpegScriptPath,
+			DumpCorrelationDir:  dumpCorrelationDir,
+			DumpCorrelationStep: dumpCorrelationStep,
+			LowMemory:           lowMemory,
+			MaxMemoryMB:         maxMemoryMB,
+			Threads:             threads,
+			CacheDir:            cacheDir,
+			NoCache:             noCache,
+			SyncNote:            syncNote,
+			AllowDuplicates:     allowDuplicates,
+			CorrectDrift:        correctDrift,
+			SplitGaps:           splitGaps,
+			DetectRateMismatch:  detectRateMismatch,
+			Progress:            progress,
+			Quiet:               quiet,
+			LogLevel:            level,
+			LogFormat:           logFormat,
+			Profile:             profile,
+			FailBelow:           failBelow,
+			MaxPaddingSec:       maxPaddingSec,
+			FailOnMaxPadding:    failOnMaxPadding,
+			CommonLeadSec:       commonLeadSec,
+			PreviewDuration:     previewDuration,
+			ContinueOnError:     continueOnError,
+			Window:              windowType,
+			LevelMatch:          levelMatch,
+			Mixdown:             localMixdown,
+			PerChannel:          perChannel,
+			OutputChannels:      outputChannels,
+			EqualizeLength:      equalizeLength,
+			OutputDir:           outputDir,
+			OutputSuffix:        outputSuffix,
+			OutputPattern:       outputPattern,
+			InvertPolarity:      invertPolarity,
+			TrimSilenceDB:       trimSilenceDB,
+			CombinePath:         combinePath,
+			CombineLayout:       layout,
+			PreviewMixPath:      previewMixPath,
+			PreviewNormalize:    previewNormalize,
+			MinPeakToSidelobe:   minPeakToSidelobe,
+			BitDepth:            outputBitDepth,
+			FloatOutput:         outputFloat,
+			SampleRateOut:       sampleRateOut,
+			NormalizeOutput:     normalizeOutput,
+			TargetLUFS:          targetLUFS,
+			MaxOffset:           maxOffset,
+			ExcludeLagMs:        excludeLagMs,
+			CoarseSegment:       coarseSegment,
+			FinetuneTarget:      finetuneTarget,
+			FinetuneMin:         finetuneMin,
+			FinetuneFixed:       cmd.Flags().Changed("finetune-position"),
+			FinetunePosition:    finetunePosition,
+			FadeInMs:            fadeInMs,
+			FractionalDelay:     fractionalDelay,
+			VerifyOutput:        verifyOutput,
+			ManualOffsets:       manual,
+			KeepManual:          keepManual,
+			SkipExisting:        skipExisting,
+			SaveSessionPath:     saveSessionPath,
+			LoadSessionPath:     loadSessionPath,
+		}
+
+		// Low-memory mode streams WAV files directly and cannot resample
+		if config.LowMemory {
+			if err := config.lowMemoryConflict(); err != nil {
+				return err
+			}
+		}
+
+		// Record a CPU profile of the whole run if requested
+		if cpuProfilePath != "" {
+			f, err := os.Create(cpuProfilePath)
+			if err != nil {
+				return fmt.Errorf("failed to create CPU profile: %w", err)
+			}
+			defer f.Close()
+			if err := pprof.StartCPUProfile(f); err != nil {
+				return fmt.Errorf("failed to start CPU profile: %w", err)
+			}
+			defer pprof.StopCPUProfile()
 		}
 
-		// Run synchronization workflow
-		return Run(config)
+		// Give up after --timeout (Ctrl-C cancels cmd.Context as well)
+		ctx := cmd.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		// Run synchronization workflow, within --max-memory if given
+		setLogging(config.LogLevel, config.LogFormat)
+		setQuiet(config.Quiet)
+		if config.MaxMemoryMB > 0 {
+			config.fitMemory()
+		}
+		if config.LowMemory {
+			return RunLowMemory(ctx, config)
+		}
+		return Run(ctx, config)
 	},
 	SilenceUsage: true, // Don't show usage on errors during execution
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&mixedPath, "mixed", "m", "", "Path to the mixed audio file (required)")
+	rootCmd.Flags().StringArrayVarP(&mixedPaths, "mixed", "m", nil, "Path to the mixed audio file (required; repeat for sessions split into several mixed files)")
 	rootCmd.Flags().IntVar(&segmentDuration, "segment-duration", 600, "Segment duration in seconds for correlation")
-	rootCmd.Flags().IntVarP(&downsampleFactor, "downsample", "d", 50, "Downsample factor for coarse offset search (higher = faster but less accurate)")
+	rootCmd.Flags().Float64Var(&coarseSegment, "coarse-segment-sec", 0, "Seconds from the middle of each local file used for the coarse search (0 = whole file)")
+	rootCmd.Flags().Float64Var(&finetuneTarget, "finetune-target-sec", 60, "Length in seconds of the segment correlated at full resolution during fine-tuning")
+	rootCmd.Flags().Float64Var(&finetuneMin, "finetune-min-sec", 30, "Skip fine-tuning if the files overlap for less than this many seconds")
+	rootCmd.Flags().Float64Var(&finetunePosition, "finetune-position", 0.5, "Place the fine-tuning segment at this position in the overlap (0 = start, 0.5 = center, 1 = end) instead of on its most energetic part")
+	rootCmd.Flags().StringVarP(&downsample, "downsample", "d", "50", "Downsample factor for coarse offset search (higher = faster but less accurate), or auto to choose from the file lengths")
+	rootCmd.Flags().Float64Var(&autoResolutionMs, "auto-resolution-ms", audiosync.DefaultAutoResolutionMs, "Coarsest resolution in milliseconds that --downsample auto may choose")
+	rootCmd.Flags().BoolVar(&noResample, "no-resample", false, "Fail on sample rate mismatch instead of resampling local files to the mixed rate")
+	rootCmd.Flags().StringVar(&correlationMethod, "correlation-method", string(audiosync.MethodStandard), "Cross-correlation method: standard, phat (GCC-PHAT, more robust to reverb), onset (onset envelopes, for music beds) or envelope (Hilbert amplitude envelopes, for re-encoded material)")
+	rootCmd.Flags().BoolVar(&chunked, "chunked", false, "Correlate in fixed-size blocks to bound memory (standard method only; very long inputs use blocks automatically)")
+	rootCmd.Flags().StringVar(&window, "window", string(audiosync.WindowTukey), "Window applied to signals before correlation: none, hann or tukey (tapers only the edges)")
+	rootCmd.Flags().BoolVar(&levelMatch, "level-match", false, "Scale short blocks of each signal to a common loudness before correlation (helps quiet or uneven tracks)")
+	rootCmd.Flags().BoolVar(&perChannel, "per-channel", false, "Align each channel of multi-channel local files against the mixed file on its own and re-interleave them (one detection per channel)")
+	rootCmd.Flags().StringVar(&mixdown, "mixdown", string(audio.MixdownAverage), "How multi-channel local files become mono: average, left, right, channel:N or max-energy (loudest channel)")
+	rootCmd.Flags().Float64Var(&trimSilenceDB, "trim-silence-db", 0, "Leave out leading and trailing audio quieter than this dBFS level (e.g. -50) from the coarse search (0 = disabled)")
+	rootCmd.Flags().IntVar(&bandpassLow, "bandpass-low", 300, "Band-pass lower cutoff in Hz applied before correlation (0 = disabled)")
+	rootCmd.Flags().IntVar(&bandpassHigh, "bandpass-high", 3400, "Band-pass upper cutoff in Hz applied before correlation (0 = disabled)")
+	rootCmd.Flags().StringVar(&preFilter, "pre-filter", "", "EQ applied to both signals before correlation to even out different mics: a preset ("+strings.Join(audiosync.PreFilterPresets(), ", ")+") or stages such as highpass:100,peaking:3000:-6 (type:freq[:gain_db][:q])")
+	rootCmd.Flags().Float64Var(&maxOffset, "max-offset", 0, "Only search offsets within ±this many seconds, ignoring matches further away (0 = unlimited)")
+	rootCmd.Flags().Float64Var(&excludeLagMs, "exclude-lag-ms", 0, "Ignore matches within ±this many milliseconds of a zero offset in the coarse search, e.g. when a track matches itself at the start (0 = none)")
+	rootCmd.Flags().StringVar(&mode, "mode", string(audiosync.ModePad), "Alignment mode: pad (prepend silence) or trim (remove leading audio, may discard audio that exists in only one track)")
+	rootCmd.Flags().Float64Var(&fadeInMs, "fade-in-ms", 0, "Fade in the audio over this many milliseconds where padding or trimming starts it, avoiding clicks (0 = none)")
+	rootCmd.Flags().BoolVar(&fractionalDelay, "fractional-delay", false, "Apply the sub-sample part of each fine-tuned offset with a windowed-sinc fractional delay instead of rounding to whole samples")
+	rootCmd.Flags().BoolVar(&syncNote, "sync-note", false, "Append a note on the applied padding or trim to the comments (ICMT) tag of WAV outputs")
+	rootCmd.Flags().BoolVar(&verifyOutput, "verify-output", false, "Re-read each synced file after writing and fail if its length or alignment does not match the source")
+	rootCmd.Flags().StringArrayVar(&manualOffsets, "offset", nil, "Use a known offset for a local file instead of detecting it, as <path>=<seconds> (repeatable)")
+	rootCmd.Flags().BoolVar(&keepManual, "keep-manual", false, "Do not fine-tune files given with --offset")
+	rootCmd.Flags().BoolVar(&skipExisting, "skip-existing", false, "Skip detecting and writing files whose synced output is newer than the source, reusing their offsets from --report")
+	rootCmd.Flags().StringVar(&saveSessionPath, "save-session", "", "Save the detected alignment to this file so it can be reapplied with --load-session")
+	rootCmd.Flags().StringVar(&loadSessionPath, "load-session", "", "Write the synced files from an alignment saved with --save-session instead of detecting offsets")
+	rootCmd.Flags().StringVar(&combinePath, "combine", "", "Also write all aligned tracks into this multi-channel WAV file, one track per channel")
+	rootCmd.Flags().BoolVar(&forceStereo, "force-stereo", false, "Write every synced file as stereo: mono files are copied to both channels, wider ones mixed down with --mixdown first")
+	rootCmd.Flags().BoolVar(&forceMono, "force-mono", false, "Write every synced file as mono, mixed down with --mixdown")
+	rootCmd.Flags().StringVar(&outputDir, "output-dir", "", "Write the synced files to this directory, creating it if needed (default: next to each local file)")
+	rootCmd.Flags().StringVar(&outputSuffix, "output-suffix", render.DefaultSuffix, "Append this to the name of each local file for its synced file")
+	rootCmd.Flags().StringVar(&outputPattern, "output-pattern", "", "Name the synced files by this pattern instead, with {name} and {ext} standing for the local file's name and extension (e.g. {name}.aligned{ext})")
+	rootCmd.Flags().BoolVar(&equalizeLength, "equalize-length", false, "Pad the end of every synced file with silence so all have the length of the longest, as multitrack imports expect")
+	rootCmd.Flags().BoolVar(&invertPolarity, "invert-polarity", false, "Flip the polarity of every synced file, so summing it with the aligned mixed file cancels the shared audio (for checking the sync)")
+	rootCmd.Flags().StringVar(&combineLayout, "combine-layout", combineMono, "Channels per track in --combine: mono (mixed down with --mixdown), stereo (mono tracks copied to both channels) or auto (stereo if any local file has more than one channel)")
+	rootCmd.Flags().StringVar(&previewMixPath, "preview-mix", "", "Also write a mono mixdown of all aligned tracks to this file for checking the sync by ear")
+	rootCmd.Flags().BoolVar(&previewNormalize, "preview-normalize", false, "Scale each track to the same peak level before summing the --preview-mix")
+	rootCmd.Flags().StringVar(&inputFormat, "format", "auto", "Input format: auto (chosen from the file extension) or raw (also read .raw and .pcm files as headerless PCM described by the --raw-* flags)")
+	rootCmd.Flags().IntVar(&rawRate, "raw-rate", 0, "Sample rate in Hz of --format raw input (required with --format raw)")
+	rootCmd.Flags().IntVar(&rawChannels, "raw-channels", 1, "Number of interleaved channels of --format raw input")
+	rootCmd.Flags().StringVar(&rawBits, "raw-bits", "16", "Sample format of --format raw input: 8 (unsigned), 16, 24, 32 or 32f (32-bit float)")
+	rootCmd.Flags().StringVar(&rawEndian, "raw-endian", "little", "Byte order of --format raw input: little or big")
+	rootCmd.Flags().StringVar(&bitDepth, "bit-depth", "", "Output bit depth: 16, 24, 32 or 32f (32-bit float); empty keeps each file's own depth")
+	rootCmd.Flags().BoolVar(&floatOutput, "float-output", false, "Write 32-bit float WAV files (same as --bit-depth 32f)")
+	rootCmd.Flags().Float64Var(&targetLUFS, "target-lufs", 0, "Adjust the gain of each synced file to this integrated loudness in LUFS (EBU R128), e.g. -16 (0 = keep the level)")
+	rootCmd.Flags().BoolVar(&normalizeOutput, "normalize-output", false, "Scale synced files that exceed full scale down to avoid clipping instead of clamping their peaks")
+	rootCmd.Flags().IntVar(&sampleRateOut, "sample-rate-out", 0, "Resample every synced file, --combine and --preview-mix output to this rate in Hz (0 = the mixed file's rate)")
+	rootCmd.Flags().StringVar(&anchorPath, "anchor", "", "Align all files to this local file instead of the earliest (earlier files are trimmed)")
+	rootCmd.Flags().StringVar(&reportPath, "report", "", "Write alignment results to this path (- = stdout)")
+	rootCmd.Flags().StringVar(&reportFormat, "report-format", reportJSON, "Format of --report: json (full results), csv or tsv (one row per local file)")
+	rootCmd.Flags().StringVar(&dumpCorrelationDir, "dump-correlation", "", "Write the coarse correlation of each local file to <dir>/<file>.csv (lag_samples,value) for plotting")
+	rootCmd.Flags().IntVar(&dumpCorrelationStep, "dump-correlation-step", 1, "Keep only the largest value of every this many lags in --dump-correlation files")
+	rootCmd.Flags().StringVar(&labelsPath, "labels", "", "Write an Audacity label file marking where each track starts and the fine-tuning segment")
+	rootCmd.Flags().StringVar(&ffmpegScriptPath, "emit-ffmpeg", "", "Write a shell script that applies the alignment to the local files (or their originals) with ffmpeg adelay/atrim")
+	rootCmd.Flags().BoolVar(&correctDrift, "correct-drift", false, "Estimate clock drift between recorders and resample local files to correct it")
+	rootCmd.Flags().BoolVar(&detectRateMismatch, "detect-rate-mismatch", false, "If a file aligns poorly, try reading it at other common sample rates in case its header states the wrong one")
+	rootCmd.Flags().BoolVar(&splitGaps, "split-gaps", false, "Find where local recordings were paused and resumed and fill the missing time with silence")
+	rootCmd.Flags().Float64Var(&minPeakToSidelobe, "min-peak-to-sidelobe", 0, "Warn if a correlation peak is not this many times stronger than the next candidate (0 = disabled)")
+	rootCmd.Flags().Float64Var(&maxPaddingSec, "max-padding-sec", 0, "Do not write files that would be padded by more than this many seconds, warning that their offset is suspect (0 = no limit)")
+	rootCmd.Flags().Float64Var(&previewDuration, "preview-duration", 0, "Quick check: detect and write using only the first this many minutes of every file, so drift later on goes unnoticed (0 = whole files)")
+	rootCmd.Flags().Float64Var(&commonLeadSec, "common-lead-sec", 0, "Add this many seconds of silence before every output after alignment, shifting the whole set while keeping it aligned (0 = none)")
+	rootCmd.Flags().BoolVar(&failOnMaxPadding, "fail-on-max-padding", false, "Exit with an error before writing any files if a padding exceeds --max-padding-sec")
+	rootCmd.Flags().Float64Var(&failBelow, "fail-below", 0, "Exit with an error before writing any files if a confidence score is below this value (0 = only warn)")
+	rootCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "Leave out local files that fail to load or whose offset cannot be detected, sync the others and list the failures at the end")
+	rootCmd.Flags().CountVarP(&quiet, "quiet", "q", "Hide progress output (all human-readable output goes to stderr); repeat (-qq) to hide warnings too")
+	rootCmd.Flags().StringVar(&logLevelName, "log-level", "info", "Lowest level of messages written to stderr: debug (adds correlation peaks and fine-tuning segments), info or warn")
+	rootCmd.Flags().StringVar(&logFormat, "log-format", "text", "Format of the messages written to stderr: text or json (one JSON object per message)")
+	rootCmd.Flags().BoolVar(&profile, "profile", false, "Print the time spent loading, detecting, fine-tuning and writing to stderr")
+	rootCmd.Flags().StringVar(&cpuProfilePath, "cpu-profile", "", "Write a runtime/pprof CPU profile of the run to this path")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", 0, "Give up if synchronization takes longer than this (e.g. 10m; 0 = no limit)")
+	rootCmd.Flags().BoolVar(&progress, "progress", false, "Print progress as each file finishes offset detection and fine-tuning")
+	rootCmd.Flags().BoolVar(&lowMemory, "low-memory", false, "Stream WAV files instead of loading them into memory (WAV only, no resampling)")
+	rootCmd.Flags().IntVar(&threads, "threads", 0, "Detect and fine-tune at most this many local files at once, bounding peak memory (0 = GOMAXPROCS)")
+	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Cache detected offsets in this directory, keyed by the audio content and detection settings (default: clapless/offsets in the user cache directory)")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Detect every offset again instead of reusing cached results, and do not cache new ones")
+	rootCmd.Flags().BoolVar(&allowDuplicates, "allow-duplicates", false, "Sync inputs given twice or with identical audio (e.g. the mixed file as a local file) instead of failing")
+	rootCmd.Flags().IntVar(&maxMemoryMB, "max-memory", 0, "Memory budget in MB; correlate in blocks and stream WAV files as --low-memory if the estimate exceeds it (0 = no limit)")
 
 	rootCmd.MarkFlagRequired("mixed")
+	rootCmd.Flags().MarkDeprecated("segment-duration", "it has no effect, use --coarse-segment-sec to limit the coarse search")
 }
 
 // Execute runs the root command
+// An interrupt (Ctrl-C) cancels the command's context so long correlations stop promptly.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
 }
 
-// validateFile checks if a file exists and has .wav extension
+// parseBitDepth parses the --bit-depth value into an integer depth and whether it is float
+// An empty value returns 0, meaning each file keeps its own depth
+func parseBitDepth(s string) (int, bool, error) {
+	switch s {
+	case "":
+		return 0, false, nil
+	case "16", "24", "32":
+		depth, _ := strconv.Atoi(s)
+		return depth, false, nil
+	case "32f":
+		return 32, true, nil
+	default:
+		return 0, false, fmt.Errorf("--bit-depth must be 16, 24, 32 or 32f, got %s", s)
+	}
+}
+
+// useRawFormat registers the --raw-* sample format for .raw and .pcm files if --format is raw
+// The --raw-* flags are rejected with any other format, since they would be ignored.
+func useRawFormat(cmd *cobra.Command) error {
+	switch inputFormat {
+	case "auto":
+		for _, name := range []string{"raw-rate", "raw-channels", "raw-bits", "raw-endian"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s requires --format raw", name)
+			}
+		}
+		return nil
+	case "raw":
+	default:
+		return fmt.Errorf("--format must be auto or raw, got %s", inputFormat)
+	}
+
+	if rawRate <= 0 {
+		return fmt.Errorf("--raw-rate must be positive with --format raw, got %d", rawRate)
+	}
+	if rawChannels < 1 {
+		return fmt.Errorf("--raw-channels must be at least 1, got %d", rawChannels)
+	}
+	spec := audio.RawSpec{SampleRate: rawRate, Channels: rawChannels}
+	if rawBits == "8" {
+		spec.BitDepth = 8
+	} else {
+		depth, float, err := parseBitDepth(rawBits)
+		if err != nil || depth == 0 {
+			return fmt.Errorf("--raw-bits must be 8, 16, 24, 32 or 32f, got %s", rawBits)
+		}
+		spec.BitDepth, spec.Float = depth, float
+	}
+	switch rawEndian {
+	case "little":
+	case "big":
+		spec.BigEndian = true
+	default:
+		return fmt.Errorf("--raw-endian must be little or big, got %s", rawEndian)
+	}
+	audio.UseRawSpec(spec)
+	return nil
+}
+
+// parseDownsample converts a --downsample value into a factor (0 = auto)
+func parseDownsample(s string) (int, error) {
+	if s == "auto" {
+		return 0, nil
+	}
+	factor, err := strconv.Atoi(s)
+	if err != nil || factor < 1 {
+		return 0, fmt.Errorf("downsample factor must be an integer >= 1 or auto, got %s", s)
+	}
+	return factor, nil
+}
+
+// parseManualOffsets parses --offset values of the form <path>=<seconds>
+// Each path must be one of the local files; the result is keyed by the cleaned path.
+func parseManualOffsets(values []string, localPaths []string) (map[string]float64, error) {
+	manual := make(map[string]float64, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("--offset must be <path>=<seconds>, got %s", value)
+		}
+		path := filepath.Clean(value[:i])
+		seconds, err := strconv.ParseFloat(value[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("--offset seconds must be a number, got %s", value)
+		}
+
+		isLocal := false
+		for _, local := range localPaths {
+			isLocal = isLocal || filepath.Clean(local) == path
+		}
+		if !isLocal {
+			return nil, fmt.Errorf("--offset must name one of the local files, got %s", value[:i])
+		}
+		if _, ok := manual[path]; ok {
+			return nil, fmt.Errorf("--offset given more than once for %s", value[:i])
+		}
+		manual[path] = seconds
+	}
+	return manual, nil
+}
+
+// validateFile checks if a file exists and has a supported audio extension
 func validateFile(path string) error {
 	// Check if file exists
 	info, err := os.Stat(path)
@@ -114,11 +887,28 @@ func validateFile(path string) error {
 		return fmt.Errorf("path is a directory, not a file: %s", path)
 	}
 
-	// Check if it has .wav extension
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext != ".wav" {
-		return fmt.Errorf("file must be WAV format (got %s): %s", ext, path)
+	// Check if it has a supported extension
+	if !audio.IsSupported(path) {
+		ext := strings.ToLower(filepath.Ext(path))
+		switch ext {
+		case ".opus", ".webm":
+			return fmt.Errorf("Opus and WebM are not supported, convert to WAV first (e.g. ffmpeg -i %s out.wav): %s", filepath.Base(path), path)
+		}
+		if ext == ".raw" || ext == ".pcm" {
+			return fmt.Errorf("headerless PCM needs --format raw and --raw-rate: %s", path)
+		}
+		return fmt.Errorf("file must be WAV, FLAC, MP3, AIFF or Ogg Vorbis format (got %s): %s", ext, path)
 	}
 
 	return nil
 }
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}
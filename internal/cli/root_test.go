@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseBitDepth(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantDepth int
+		wantFloat bool
+		wantErr   bool
+	}{
+		{"", 0, false, false},
+		{"16", 16, false, false},
+		{"24", 24, false, false},
+		{"32", 32, false, false},
+		{"32f", 32, true, false},
+		{"8", 0, false, true},
+		{"float", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		depth, float, err := parseBitDepth(tt.input)
+		if (err != nil) != tt.wantErr || depth != tt.wantDepth || float != tt.wantFloat {
+			t.Errorf("parseBitDepth(%q) = %d, %v, %v; want %d, %v, error %v", tt.input, depth, float, err, tt.wantDepth, tt.wantFloat, tt.wantErr)
+		}
+	}
+}
+
+func TestParseDownsample(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"50", 50, false},
+		{"1", 1, false},
+		{"auto", 0, false},
+		{"0", 0, true},
+		{"fast", 0, true},
+	}
+
+	for _, tt := range tests {
+		factor, err := parseDownsample(tt.input)
+		if (err != nil) != tt.wantErr || factor != tt.want {
+			t.Errorf("parseDownsample(%q) = %d, %v; want %d, error %v", tt.input, factor, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseManualOffsets(t *testing.T) {
+	locals := []string{"rec/alice.wav", "bob.wav"}
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{"none", nil, map[string]float64{}, false},
+		{"cleaned paths", []string{"./rec/alice.wav=1.5", "bob.wav=-0.25"}, map[string]float64{"rec/alice.wav": 1.5, "bob.wav": -0.25}, false},
+		{"not a local file", []string{"carol.wav=1"}, nil, true},
+		{"no seconds", []string{"bob.wav"}, nil, true},
+		{"not a number", []string{"bob.wav=soon"}, nil, true},
+		{"given twice", []string{"bob.wav=1", "./bob.wav=2"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseManualOffsets(tt.values, locals)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: parseManualOffsets = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// runFields are the Config fields that are not flags: they are filled in during the run or set by library callers
+var runFields = []string{"Failed", "Overlap", "Detector", "channelGroups"}
+
+func TestConfigBuiltFromFlags(t *testing.T) {
+	fset := token.NewFileSet()
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Config is declared once in the package, and the command line is parsed by cobra alone
+	var declared []string
+	var built map[string]bool
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spec := range file.Imports {
+			if spec.Path.Value == `"flag"` {
+				t.Errorf("%s imports the standard flag package", name)
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				if n.Name.Name == "Config" {
+					declared = append(declared, name)
+				}
+			case *ast.CompositeLit:
+				// The configuration the command builds from its flags
+				if ident, ok := n.Type.(*ast.Ident); ok && ident.Name == "Config" && name == "root.go" {
+					built = map[string]bool{}
+					for _, elt := range n.Elts {
+						built[elt.(*ast.KeyValueExpr).Key.(*ast.Ident).Name] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	if len(declared) != 1 {
+		t.Fatalf("Config declared in %v, want once", declared)
+	}
+	if built == nil {
+		t.Fatal("root.go does not build a Config")
+	}
+
+	// Every field is set from a flag, except those filled in later
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+		isFlag := !slices.Contains(runFields, field.Name)
+		if built[field.Name] != isFlag {
+			t.Errorf("Config.%s set from the flags = %v, want %v", field.Name, built[field.Name], isFlag)
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
 
+	"github.com/go-audio/wav"
 	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
 	audiosync "github.com/shidetake/clapless/internal/sync"
 )
 
@@ -15,151 +21,831 @@ const (
 )
 
 // Run executes the main synchronization workflow
-func Run(config *Config) error {
-	fmt.Println("Clapless - Audio Synchronization Tool")
-	fmt.Println("======================================")
-	fmt.Println()
+// Cancelling ctx stops offset detection, fine-tuning and drift correction and returns ctx.Err().
+func Run(ctx context.Context, config *Config) error {
+	logln("Clapless - Audio Synchronization Tool")
+	logln("======================================")
+	logln()
+
+	timer := newStageTimer(config.Profile)
 
 	// Step 1: Load mixed audio
-	fmt.Println("Loading files...")
-	mixed, err := loadMixedAudio(config.MixedPath)
+	logln("Loading files...")
+	mixedFiles, err := loadMixedAudio(config.MixedPaths)
 	if err != nil {
 		return err
 	}
+	mixed := mixedFiles[0]
 
 	// Step 2: Load local audio files
-	localFiles, err := loadLocalAudio(config.LocalPaths)
+	// With --continue-on-error, files that fail are collected in failed and left out instead
+	var failed fileErrors
+	if config.ContinueOnError {
+		failed = fileErrors{}
+	}
+	localFiles, err := loadLocalAudio(config.LocalPaths, failed)
 	if err != nil {
 		return err
 	}
-
-	// Validate sample rates match
-	if err := validateSampleRates(mixed, localFiles); err != nil {
+	if localFiles, _, err = config.setAside(failed, localFiles, nil); err != nil {
+		return err
+	}
+	if err := config.checkDuplicates(mixedFiles, localFiles); err != nil {
 		return err
 	}
 
-	fmt.Println()
+	// Match local (and additional mixed) sample rates to the first mixed file
+	// The header rates are kept for --detect-rate-mismatch
+	headerRates := sampleRates(localFiles)
+	if config.NoResample {
+		if err := validateSampleRates(mixed, localFiles); err != nil {
+			return err
+		}
+		for _, other := range mixedFiles[1:] {
+			if other.SampleRate != mixed.SampleRate {
+				return fmt.Errorf("%w: mixed (%d Hz) vs mixed %s (%d Hz)", audio.ErrSampleRateMismatch,
+					mixed.SampleRate, filepath.Base(other.Path), other.SampleRate)
+			}
+		}
+	} else {
+		resampleLocalAudio(mixed, localFiles)
+		resampleLocalAudio(mixed, mixedFiles[1:])
+	}
+
+	// Keep only the first minutes of every file for a quick --preview-duration check
+	config.truncatePreview(mixedFiles, localFiles)
+
+	// Align each channel of multi-channel local files as a track of its own
+	if config.PerChannel {
+		if localFiles, err = config.splitChannels(localFiles); err != nil {
+			return err
+		}
+	}
+
+	// Pick the downsample factor from the (resampled) lengths for --downsample auto
+	mixedFrames := 0
+	for _, m := range mixedFiles {
+		mixedFrames = max(mixedFrames, len(m.Data)/m.Channels)
+	}
+	localFrames := make([]int, len(localFiles))
+	for i, local := range localFiles {
+		localFrames[i] = len(local.Data) / local.Channels
+	}
+	config.resolveDownsample(mixed.SampleRate, mixedFrames, localFrames)
+
+	// Reapply a saved alignment instead of detecting it again
+	if config.LoadSessionPath != "" {
+		fileOffsets, session, err := loadSessionFile(config.LoadSessionPath, config.LocalPaths, mixed.SampleRate)
+		if err != nil {
+			return err
+		}
+		logf("  ✓ Session: %s\n", config.LoadSessionPath)
+		restoreRates(config.LocalPaths, localFiles, fileOffsets)
+		restoreGaps(config.LocalPaths, localFiles, fileOffsets)
+		restoreDrift(config.LocalPaths, localFiles, fileOffsets)
+		timer.mark("load")
+		return writeSynced(ctx, config, localFiles, fileOffsets, mixed.SampleRate, session, nil, timer)
+	}
 
-	// Step 3: Detect offsets in parallel
-	fmt.Printf("Detecting offsets (downsample=%d)...\n", config.DownsampleFactor)
-	offsetResults, err := detectOffsetsParallel(mixed, localFiles, config.SegmentDuration, config.DownsampleFactor)
+	// Reuse the recorded offsets of files whose synced output is already up to date
+	var prior map[string]*audiosync.FileOffset
+	if config.SkipExisting {
+		if prior, err = upToDateOffsets(config); err != nil {
+			return err
+		}
+		for _, path := range config.LocalPaths {
+			if _, ok := prior[filepath.Clean(path)]; ok {
+				logf("  ⊘ %s: up to date, reusing the offset from %s\n", filepath.Base(path), config.ReportPath)
+			}
+		}
+	}
+	timer.mark("load")
+
+	logln()
+
+	// Steps 3-4.5: Detect offsets, calculate padding and fine-tune
+	var session []audiosync.SessionSegment
+	var mixedMono []float64
+	fileOffsets, err := audiosync.Align(ctx, mixed.SampleRate, audiosync.AlignStages{
+		Detect: func(ctx context.Context) ([]*audiosync.OffsetResult, []string, error) {
+			// Step 3: Detect offsets in parallel
+			logf("Detecting offsets (downsample=%d)...\n", config.DownsampleFactor)
+			known := config.knownOffsets(mixed.SampleRate, prior)
+			cache := config.offsetCache()
+			var offsetResults []*audiosync.OffsetResult
+			if config.ContinueOnError {
+				failed = fileErrors{}
+			}
+			if len(mixedFiles) == 1 {
+				offsetResults, err = detectOffsetsParallel(ctx, mixed, localFiles, known, config.detectOptions(), config.correlationDump(), cache, failed, config.newProgress())
+			} else {
+				// Several mixed files: place them on one session timeline and use it as the mixed track
+				mixed, session, offsetResults, err = detectSessionOffsets(ctx, config, mixedFiles, localFiles, cache)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			if reused := cache.reused(); reused > 0 {
+				logf("  ✓ Reused %d cached offset(s) from %s\n", reused, cache.dir)
+			}
+			if localFiles, offsetResults, err = config.setAside(failed, localFiles, offsetResults); err != nil {
+				return nil, nil, err
+			}
+			if config.DumpCorrelationDir != "" {
+				logf("  ✓ Correlation: %s\n", config.DumpCorrelationDir)
+			}
+
+			if mixedMono, err = audio.ToMono(mixed.Data, mixed.Channels); err != nil {
+				return nil, nil, fmt.Errorf("failed to convert mixed audio to mono: %w", err)
+			}
+
+			// Step 3.5: Retry files that correlated poorly with other settings
+			if err := retryLowConfidence(ctx, mixedMono, mixed.SampleRate, localFiles, offsetResults, known, config.detectOptions()); err != nil {
+				return nil, nil, err
+			}
+
+			// Step 3.6: Check whether files that still correlate poorly were recorded at another rate than their header says
+			if config.DetectRateMismatch {
+				if err := checkSampleRates(ctx, mixedMono, mixed.SampleRate, localFiles, offsetResults, known, headerRates, config.detectOptions()); err != nil {
+					return nil, nil, err
+				}
+			}
+
+			timer.mark("coarse")
+			return offsetResults, config.LocalPaths, nil
+		},
+		// Step 4: Display the coarse offsets and how much material the tracks share
+		Coarse: func(fileOffsets []*audiosync.FileOffset) error {
+			config.labelChannels(fileOffsets)
+			measureGain(fileOffsets, mixedFiles, localFiles)
+
+			printCoarseOffsets(config.LocalPaths, fileOffsets)
+			config.Overlap = printOverlap(config, len(mixedMono), localFiles, fileOffsets, mixed.SampleRate)
+
+			logln()
+			logln("Fine-tuning synchronization...")
+			return nil
+		},
+		// Step 4.5: Fine-tune offsets
+		Finetune: func(ctx context.Context, fileOffsets []*audiosync.FileOffset) ([]*audiosync.FileOffset, error) {
+			finetuneProgress := config.newProgress()
+			finetuned, err := audiosync.FinetuneOffsets(ctx, mixedMono, localFiles, fileOffsets, mixed.SampleRate, config.detectOptions(), func(i int) {
+				finetuneProgress.step("fine-tuned %s", filepath.Base(config.LocalPaths[i]))
+			})
+			if err == nil {
+				printFinetuneResults(config.LocalPaths, finetuned)
+			}
+			return finetuned, err
+		},
+		FinetuneFailed: warnCoarseOnly,
+	})
 	if err != nil {
 		return err
 	}
+	if len(prior) > 0 {
+		if fileOffsets, err = restorePrior(fileOffsets, prior, mixed.SampleRate); err != nil {
+			return err
+		}
+	}
+	timer.mark("finetune")
+
+	// Step 4.6: Reinsert the time local recordings were paused for (before drift, which a jump would distort)
+	if config.SplitGaps {
+		logln()
+		logln("Looking for gaps...")
+
+		split, err := audiosync.SplitGaps(ctx, mixedMono, localFiles, fileOffsets, mixed.SampleRate, config.detectOptions())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			warnf("  ⚠️  Gap detection failed: %v\n", err)
+		} else {
+			fileOffsets = split
+			printGapResults(config.LocalPaths, fileOffsets, mixed.SampleRate)
+		}
+		timer.mark("gaps")
+	}
+
+	// Step 4.7: Correct clock drift
+	if config.CorrectDrift {
+		logln()
+		logln("Correcting clock drift...")
 
-	// Step 4: Calculate padding (coarse)
-	fileOffsets, err := audiosync.CalculatePadding(offsetResults, config.LocalPaths, mixed.SampleRate)
+		corrected, err := audiosync.CorrectDrift(ctx, mixedMono, localFiles, fileOffsets, mixed.SampleRate, config.detectOptions())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			warnf("  ⚠️  Drift correction failed: %v\n", err)
+		} else {
+			fileOffsets = corrected
+			printDriftResults(config.LocalPaths, fileOffsets)
+		}
+		timer.mark("drift")
+	}
+
+	if err := writeSynced(ctx, config, localFiles, fileOffsets, mixed.SampleRate, session, prior, timer); err != nil {
+		return err
+	}
+	return config.failedError()
+}
+
+// writeSynced computes the output alignment and writes the synced files, the combined file and the preview mix
+// Files whose offsets in prior are unchanged keep their existing output.
+func writeSynced(ctx context.Context, config *Config, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset, sampleRate int, session []audiosync.SessionSegment, prior map[string]*audiosync.FileOffset, timer *stageTimer) error {
+	// Steps 5-6: Compute output alignment and write synced files
+	// Keep a copy of each aligned track in the combined file's layout and/or in mono for the preview mix
+	var combined, tracks [][]float64
+	combineChannels := 0
+	if config.CombinePath != "" {
+		combined = make([][]float64, len(localFiles))
+		combineChannels = config.combineChannels(localFiles)
+	}
+	if config.PreviewMixPath != "" {
+		tracks = make([][]float64, len(localFiles))
+	}
+	// The length every output is padded to with --equalize-length, found once the padding is final (-1 = not yet)
+	equalFrames := -1
+	err := finishSync(config, fileOffsets, sampleRate, session, func(i int, fo *audiosync.FileOffset, outputPath string) error {
+		if combined == nil && tracks == nil && unchanged(fo, prior) {
+			return errUpToDate
+		}
+		// Keep the source for --verify-output (only the fade-in at its new start is changed in place)
+		source, sourceFrames := localFiles[i].Data, len(localFiles[i].Data)/localFiles[i].Channels
+		opts := config.renderOptions()
+		out := render.Render(localFiles[i], fo, opts)
+		logLoudness(filepath.Base(outputPath), out.Loudness)
+		logClipping(filepath.Base(outputPath), out)
+		syncedData, outputRate := out.Data, out.SampleRate
+		if combined != nil {
+			track, err := combineTrack(syncedData, localFiles[i].Channels, combineChannels, config.Mixdown)
+			if err != nil {
+				return err
+			}
+			combined[i] = track
+		}
+		if tracks != nil {
+			mono, err := audio.ToMonoMixdown(syncedData, localFiles[i].Channels, config.Mixdown)
+			if err != nil {
+				return err
+			}
+			tracks[i] = mono
+		}
+		channels := localFiles[i].Channels
+		if group := config.channelGroupOf(i); group != nil {
+			// The file is written with its last channel
+			interleaved, err := group.add(syncedData)
+			if err != nil {
+				return err
+			}
+			if interleaved == nil {
+				return errChannelPending
+			}
+			syncedData, channels = interleaved, group.channels
+		}
+		syncedData, channels, err := opts.Layout(syncedData, channels)
+		if err != nil {
+			return err
+		}
+		trailing := 0
+		if config.EqualizeLength {
+			if equalFrames < 0 {
+				equalFrames = longestAligned(localFiles, fileOffsets)
+			}
+			trailing = audio.ResampledFrames(equalFrames, localFiles[i].SampleRate, outputRate) - len(syncedData)/channels
+			syncedData = audio.AppendSilence(syncedData, trailing*channels)
+		}
+		if err := audio.WriteAudioTagged(outputPath, syncedData, outputRate, channels, out.BitDepth, out.Float, config.outputTags(i, localFiles[i], fo)); err != nil {
+			return err
+		}
+		if config.VerifyOutput {
+			return checkWrittenOutput(ctx, outputPath, source, localFiles[i].Channels, channels, sourceFrames, max(trailing, 0), config.InvertPolarity, fo)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	// Display coarse offset results
+	// Step 7: Write all aligned tracks into one multi-channel file and/or a mono mixdown
+	// The tracks were kept after resampling, so they are at the output rate
+	if config.CombinePath != "" {
+		if err := writeCombined(config, combined, combineChannels, localFiles, config.renderOptions().Rate(sampleRate)); err != nil {
+			return err
+		}
+	}
+	if config.PreviewMixPath != "" {
+		if err := writePreviewMix(config, tracks, localFiles, config.renderOptions().Rate(sampleRate)); err != nil {
+			return err
+		}
+	}
+	timer.mark("write")
+
+	printComplete(fileOffsets)
+	timer.print()
+	return nil
+}
+
+// Channel layouts of each track in the --combine file
+const (
+	combineMono   = "mono"   // One channel per track, mixed down with --mixdown
+	combineStereo = "stereo" // Two channels per track: mono tracks are copied to both, wider ones mixed down first
+	combineAuto   = "auto"   // stereo if any local file has more than one channel, mono otherwise
+)
+
+// parseCombineLayout validates a --combine-layout value
+func parseCombineLayout(s string) (string, error) {
+	switch s {
+	case combineMono, combineStereo, combineAuto:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown combine layout %q (expected %s, %s or %s)", s, combineMono, combineStereo, combineAuto)
+	}
+}
+
+// combineChannels returns the number of channels each track takes in the combined file (1 or 2)
+func (c *Config) combineChannels(localFiles []*audio.WAVData) int {
+	switch c.CombineLayout {
+	case combineStereo:
+		return 2
+	case combineAuto:
+		for _, local := range localFiles {
+			if local.Channels > 1 {
+				return 2
+			}
+		}
+	}
+	return 1
+}
+
+// combineTrack converts an aligned track to the combined file's layout of channels per track
+func combineTrack(data []float64, channels, combineChannels int, mixdown audio.Mixdown) ([]float64, error) {
+	if combineChannels == 2 {
+		return audio.ToStereo(data, channels, mixdown)
+	}
+	return audio.ToMonoMixdown(data, channels, mixdown)
+}
+
+// writeCombined writes the aligned tracks as channels channels each, upconverting to the highest output bit depth
+func writeCombined(config *Config, tracks [][]float64, channels int, localFiles []*audio.WAVData, sampleRate int) error {
+	bitDepth, float := config.highestOutputFormat(localFiles)
+	if err := audio.InterleaveChannels(config.CombinePath, tracks, channels, sampleRate, bitDepth, float); err != nil {
+		return fmt.Errorf("failed to write combined file: %w", err)
+	}
+
+	format := fmt.Sprintf("%d-bit", bitDepth)
+	if float {
+		format = "32-bit float"
+	}
+	layout := "mono"
+	if channels == 2 {
+		layout = "stereo"
+	}
+	logf("  ✓ %s (%d channels, %d %s tracks, %s, combined)\n", filepath.Base(config.CombinePath), len(tracks)*channels, len(tracks), layout, format)
+	return nil
+}
+
+// writePreviewMix sums the aligned tracks into one mono file for listening to the sync
+// With PreviewNormalize each track is first scaled to an equal share of full scale, so none drowns out the others
+func writePreviewMix(config *Config, tracks [][]float64, localFiles []*audio.WAVData, sampleRate int) error {
+	if config.PreviewNormalize {
+		normalized := make([][]float64, len(tracks))
+		for i, track := range tracks {
+			normalized[i] = audio.NormalizePeak(track, 1/float64(len(tracks)))
+		}
+		tracks = normalized
+	}
+
+	bitDepth, float := config.highestOutputFormat(localFiles)
+	if err := audio.WriteAudio(config.PreviewMixPath, audio.SumTracks(tracks), sampleRate, 1, bitDepth, float); err != nil {
+		return fmt.Errorf("failed to write preview mix: %w", err)
+	}
+
+	logf("  ✓ %s (mono mixdown of %d tracks)\n", filepath.Base(config.PreviewMixPath), len(tracks))
+	return nil
+}
+
+// highestOutputFormat returns the highest output bit depth among the local files
+// Float counts as higher than any integer depth
+func (c *Config) highestOutputFormat(localFiles []*audio.WAVData) (int, bool) {
+	bitDepth, float := 0, false
+	for _, local := range localFiles {
+		depth, isFloat := c.renderOptions().Format(local)
+		bitDepth = max(bitDepth, depth)
+		float = float || isFloat
+	}
+	return bitDepth, float
+}
+
+// printComplete prints the summary of the batch and the final success message
+func printComplete(fileOffsets []*audiosync.FileOffset) {
+	if summary := audiosync.Summarize(fileOffsets, minConfidence); summary != nil {
+		logln()
+		logln("Summary:")
+		logf("  Confidence: %d high, %d medium, %d low (min %.2f, mean %.2f, max %.2f)\n",
+			summary.HighConfidence, summary.MediumConfidence, summary.LowConfidence,
+			summary.MinConfidence, summary.MeanConfidence, summary.MaxConfidence)
+		logf("  Silence added: %.3fs in total\n", summary.TotalPaddingSeconds)
+		if summary.TotalTrimSeconds > 0 {
+			logf("  Trimmed: %.3fs in total\n", summary.TotalTrimSeconds)
+		}
+	}
+	logln()
+	logln("Synchronization complete!")
+}
+
+// printCoarseOffsets displays coarse offset detection results
+func printCoarseOffsets(paths []string, fileOffsets []*audiosync.FileOffset) {
 	for i, fo := range fileOffsets {
-		fmt.Printf("  ✓ %s: %s (confidence: %.2f)\n",
-			filepath.Base(config.LocalPaths[i]),
+		if fo.Manual {
+			logf("  ✓ %s: %s (manual)\n", filepath.Base(paths[i]), audiosync.FormatOffsetSeconds(fo.OffsetSeconds))
+			continue
+		}
+		retried := ""
+		if fo.Backoffs > 0 {
+			retried = fmt.Sprintf(", downsample %d after %d backoffs", fo.Downsample, fo.Backoffs)
+		}
+		if fo.Retried {
+			retried += ", retried"
+		}
+		logf("  ✓ %s: %s (confidence: %.2f, peak/sidelobe: %.2f%s)\n",
+			filepath.Base(paths[i]),
 			audiosync.FormatOffsetSeconds(fo.OffsetSeconds),
-			fo.Confidence)
+			fo.Confidence,
+			fo.PeakToSidelobe,
+			retried)
 	}
+}
 
-	fmt.Println()
+// measureGain records the level of each local file relative to the mixed files in its FileOffset for the report
+// Several mixed files are measured together, as the session they make up.
+func measureGain(fileOffsets []*audiosync.FileOffset, mixedFiles, localFiles []*audio.WAVData) {
+	parts := make([][]float64, len(mixedFiles))
+	for i, m := range mixedFiles {
+		parts[i] = m.Data
+	}
+	mixedLevel := audio.MeasureLevel(parts...)
+	for i, fo := range fileOffsets {
+		fo.Gain = audiosync.NewGainDiagnostics(audio.MeasureLevel(localFiles[i].Data), mixedLevel)
+	}
+}
 
-	// Step 4.5: Fine-tune offsets
-	fmt.Println("Fine-tuning synchronization...")
+// printOverlap displays the region every track covers at the coarse offsets and returns it (nil if there is none)
+// A region shorter than the fine-tuning minimum is warned about, as it usually means a wrong offset or file.
+func printOverlap(config *Config, mixedLength int, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset, sampleRate int) *audiosync.OverlapRegion {
+	overlap, err := audiosync.ComputeOverlap(localFiles, fileOffsets, mixedLength, sampleRate)
+	if err != nil {
+		warnln("  ⚠️  The tracks share no material at these offsets")
+		return nil
+	}
 
-	mixedMono := audio.ToMono(mixed.Data, mixed.Channels)
+	logf("  Overlap: %.1fs shared by all tracks, from %.3fs on the mixed timeline\n",
+		overlap.DurationSec, float64(overlap.StartSample)/float64(sampleRate))
+	if overlap.DurationSec < config.FinetuneMin {
+		warnf("  ⚠️  The overlap is shorter than the %gs fine-tuning needs\n", config.FinetuneMin)
+	}
+	return overlap
+}
 
-	fileOffsets, err = audiosync.FinetuneOffsets(
-		mixedMono,
-		localFiles,
-		fileOffsets,
-		mixed.SampleRate,
-	)
-	if err != nil {
-		fmt.Printf("  ⚠️  Fine-tuning failed: %v\n", err)
-		fmt.Println("  Continuing with coarse alignment...")
-	} else {
-		// Display fine-tuning results
-		for i, fo := range fileOffsets {
-			if fo.FinetuneResult != nil && !fo.FinetuneResult.Skipped {
-				fmt.Printf("  ✓ %s: fine adjustment %s (confidence: %.2f)\n",
-					filepath.Base(config.LocalPaths[i]),
-					audiosync.FormatOffsetSeconds(fo.FineAdjustmentSeconds),
-					fo.FinetuneResult.Confidence)
-			} else if fo.FinetuneResult != nil && fo.FinetuneResult.Skipped {
-				fmt.Printf("  ⊘ %s: skipped (%s)\n",
-					filepath.Base(config.LocalPaths[i]),
-					fo.FinetuneResult.SkipReason)
-			}
+// warnCoarseOnly reports that fine-tuning failed and the coarse alignment is used
+func warnCoarseOnly(err error) {
+	warnf("  ⚠️  Fine-tuning failed: %v\n", err)
+	warnln("  Continuing with coarse alignment...")
+}
+
+// printFinetuneResults displays fine-tuning results
+func printFinetuneResults(paths []string, fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if fo.FinetuneResult != nil && !fo.FinetuneResult.Skipped {
+			logf("  ✓ %s: fine adjustment %s (confidence: %.2f)\n",
+				filepath.Base(paths[i]),
+				audiosync.FormatOffsetSeconds(fo.FineAdjustmentSeconds),
+				fo.FinetuneResult.Confidence)
+		} else if fo.FinetuneResult != nil && fo.FinetuneResult.Skipped {
+			logf("  ⊘ %s: skipped (%s)\n",
+				filepath.Base(paths[i]),
+				fo.FinetuneResult.SkipReason)
 		}
 	}
+}
+
+// printGapResults displays the segments found in each local file
+func printGapResults(paths []string, fileOffsets []*audiosync.FileOffset, sampleRate int) {
+	for i, fo := range fileOffsets {
+		if len(fo.Segments) == 0 {
+			logf("  ✓ %s: no gaps\n", filepath.Base(paths[i]))
+			continue
+		}
+		logf("  ✓ %s: %d segments\n", filepath.Base(paths[i]), len(fo.Segments))
+		for _, segment := range fo.Segments[1:] {
+			logf("      gap %+.3fs at %.3fs (confidence: %.2f)\n",
+				segment.GapSeconds, float64(segment.StartFrame)/float64(sampleRate), segment.Confidence)
+		}
+	}
+}
 
-	// Check confidence scores
+// printDriftResults displays drift correction results
+func printDriftResults(paths []string, fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if fo.Drift == nil {
+			continue
+		}
+		if fo.Drift.Skipped {
+			logf("  ⊘ %s: skipped (%s)\n", filepath.Base(paths[i]), fo.Drift.SkipReason)
+			continue
+		}
+		logf("  ✓ %s: drift %+.1f ppm (%+.1f ms over %.0fs)\n",
+			filepath.Base(paths[i]),
+			fo.Drift.PPM,
+			fo.Drift.PPM*fo.Drift.SpanSeconds/1e3, // ppm over the span in milliseconds
+			fo.Drift.SpanSeconds)
+	}
+}
+
+// finishSync checks confidence, computes the output alignment, writes the report
+// and calls write for each file to produce its synced output
+func finishSync(
+	config *Config,
+	fileOffsets []*audiosync.FileOffset,
+	sampleRate int,
+	session []audiosync.SessionSegment,
+	write func(i int, fo *audiosync.FileOffset, outputPath string) error,
+) error {
+	// Check confidence scores and polarity (and optionally peak sharpness and the offset limit)
 	warnings := audiosync.ValidateConfidence(fileOffsets, minConfidence)
+	if config.MinPeakToSidelobe > 0 {
+		warnings = append(warnings, audiosync.ValidatePeakToSidelobe(fileOffsets, config.MinPeakToSidelobe)...)
+	}
+	if config.MaxOffset > 0 {
+		warnings = append(warnings, audiosync.ValidateSearchWindow(fileOffsets, config.MaxOffset)...)
+	}
+	warnings = append(warnings, audiosync.ValidatePolarity(fileOffsets)...)
 	if len(warnings) > 0 {
-		fmt.Println()
-		fmt.Println("⚠️  Warnings:")
+		warnln()
+		warnln("⚠️  Warnings:")
 		for _, warning := range warnings {
-			fmt.Printf("  %s\n", warning)
+			warnf("  %s\n", warning)
 		}
-		fmt.Println("  Synchronization may not be accurate. Please verify results.")
+		warnln("  Synchronization may not be accurate. Please verify results.")
 	}
 
-	fmt.Println()
+	// Abort before writing anything if confidence is below the hard threshold
+	if config.FailBelow > 0 {
+		if failures := audiosync.ValidateConfidence(fileOffsets, config.FailBelow); len(failures) > 0 {
+			return fmt.Errorf("confidence below --fail-below %.2f, no files written:\n  %s",
+				config.FailBelow, strings.Join(failures, "\n  "))
+		}
+	}
 
-	// Step 5: Apply padding and write synced files
-	fmt.Println("Calculating synchronization...")
-	for i, fo := range fileOffsets {
-		if fo.IsEarliest {
-			fmt.Printf("  %s: No padding needed (earliest)\n", filepath.Base(config.LocalPaths[i]))
-		} else {
-			fmt.Printf("  %s: Adding %.3fs silence\n", filepath.Base(config.LocalPaths[i]), fo.PaddingSeconds)
+	logln()
+
+	// Step 5: Apply padding (or trim)
+	logln("Calculating synchronization...")
+	if config.AnchorPath != "" {
+		if err := audiosync.CalculateAnchor(fileOffsets, config.AnchorPath, sampleRate); err != nil {
+			return err
+		}
+		for i, fo := range fileOffsets {
+			switch {
+			case fo.PaddingSamples > 0:
+				logf("  %s: Adding %.3fs silence\n", filepath.Base(config.LocalPaths[i]), fo.PaddingSeconds)
+			case fo.TrimSamples > 0:
+				logf("  %s: Trimming %.3fs\n", filepath.Base(config.LocalPaths[i]), fo.TrimSeconds)
+			default:
+				logf("  %s: No change needed (anchor)\n", filepath.Base(config.LocalPaths[i]))
+			}
+		}
+	} else if config.Mode == audiosync.ModeTrim {
+		if err := audiosync.CalculateTrim(fileOffsets, sampleRate); err != nil {
+			return err
+		}
+		for i, fo := range fileOffsets {
+			if fo.TrimSamples == 0 {
+				logf("  %s: No trimming needed (latest)\n", filepath.Base(config.LocalPaths[i]))
+			} else {
+				logf("  %s: Trimming %.3fs\n", filepath.Base(config.LocalPaths[i]), fo.TrimSeconds)
+			}
+		}
+	} else {
+		for i, fo := range fileOffsets {
+			if fo.IsEarliest {
+				logf("  %s: No padding needed (earliest)\n", filepath.Base(config.LocalPaths[i]))
+			} else {
+				logf("  %s: Adding %.3fs silence\n", filepath.Base(config.LocalPaths[i]), fo.PaddingSeconds)
+			}
 		}
 	}
 
-	fmt.Println()
-	fmt.Println("Writing synchronized files...")
+	// Realize the sub-sample part of each offset with a fractional delay
+	if config.FractionalDelay {
+		audiosync.CalculateFractionalPadding(fileOffsets, sampleRate)
+	}
+
+	// Leave out files padded far more than plausible, which usually means a false correlation peak
+	if config.MaxPaddingSec > 0 {
+		if suspects := audiosync.CheckPadding(fileOffsets, config.MaxPaddingSec); len(suspects) > 0 {
+			if config.FailOnMaxPadding {
+				return fmt.Errorf("padding above --max-padding-sec %gs, no files written:\n  %s",
+					config.MaxPaddingSec, strings.Join(suspects, "\n  "))
+			}
+			config.spreadSuspect(fileOffsets)
+			warnln()
+			warnln("⚠️  Suspect offsets:")
+			for _, suspect := range suspects {
+				warnf("  %s\n", suspect)
+			}
+			warnln("  These files are not written.")
+		}
+	}
+
+	// Shift the whole set by a common lead, after the padding limit so the lead does not count towards it
+	if config.CommonLeadSec > 0 {
+		audiosync.AddLead(fileOffsets, int(math.Round(config.CommonLeadSec*float64(sampleRate))), sampleRate)
+		logf("  Common lead: %.3fs added before every file\n", config.CommonLeadSec)
+	}
+
+	// Write the JSON report and session file before the output files so they exist even if writing fails
+	if config.SaveSessionPath != "" {
+		if err := writeSessionFile(config.SaveSessionPath, sampleRate, session, fileOffsets); err != nil {
+			return err
+		}
+		logf("  ✓ Session: %s\n", config.SaveSessionPath)
+	}
+	if config.ReportPath != "" {
+		if err := writeReport(config.ReportPath, config, sampleRate, session, fileOffsets); err != nil {
+			return err
+		}
+		logf("  ✓ Report: %s\n", config.ReportPath)
+	}
+	if config.LabelsPath != "" {
+		if err := writeLabels(config.LabelsPath, fileOffsets, sampleRate); err != nil {
+			return err
+		}
+		logf("  ✓ Labels: %s\n", config.LabelsPath)
+	}
+	if config.FFmpegScriptPath != "" {
+		if err := writeFFmpegScript(config.FFmpegScriptPath, fileOffsets, config.outputPath); err != nil {
+			return err
+		}
+		logf("  ✓ FFmpeg script: %s\n", config.FFmpegScriptPath)
+	}
+
+	// Step 6: Write synced files
+	logln()
+	logln("Writing synchronized files...")
 
 	for i, fo := range fileOffsets {
-		if err := writeSyncedFile(localFiles[i], fo, config.LocalPaths[i]); err != nil {
+		outputPath := config.outputPath(config.sourcePath(i))
+		if fo.Suspect {
+			if config.lastTrackOf(i) {
+				logf("  ⊘ %s (suspect offset, not written)\n", filepath.Base(outputPath))
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := write(i, fo, outputPath); errors.Is(err, errChannelPending) {
+			continue
+		} else if errors.Is(err, errUpToDate) {
+			logf("  ⊘ %s (up to date)\n", filepath.Base(outputPath))
+			continue
+		} else if err != nil {
 			return fmt.Errorf("failed to write synced file for %s: %w", config.LocalPaths[i], err)
 		}
-		outputPath := generateOutputPath(config.LocalPaths[i])
-		fmt.Printf("  ✓ %s\n", filepath.Base(outputPath))
+		logf("  ✓ %s\n", filepath.Base(outputPath))
 	}
 
-	fmt.Println()
-	fmt.Println("Synchronization complete!")
-
 	return nil
 }
 
-// loadMixedAudio loads the mixed audio file
-func loadMixedAudio(path string) (*audio.WAVData, error) {
-	mixed, err := audio.LoadWAV(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load mixed audio: %w", err)
+// detectOptions builds the correlator settings from the config
+func (c *Config) detectOptions() audiosync.DetectOptions {
+	return audiosync.DetectOptions{
+		SegmentDuration:  c.SegmentDuration,
+		DownsampleFactor: c.DownsampleFactor,
+		BackoffBelow:     minConfidence,
+		Method:           c.CorrelationMethod,
+		BandpassLow:      c.BandpassLow,
+		BandpassHigh:     c.BandpassHigh,
+		PreFilter:        c.PreFilter,
+		Window:           c.Window,
+		MaxOffset:        c.MaxOffset,
+		ExcludeLag:       c.ExcludeLagMs / 1000,
+		CoarseSegment:    c.CoarseSegment,
+		FinetuneTarget:   c.FinetuneTarget,
+		FinetuneMin:      c.FinetuneMin,
+		FinetuneFixed:    c.FinetuneFixed,
+		FinetunePosition: c.FinetunePosition,
+		LevelMatch:       c.LevelMatch,
+		KeepManual:       c.KeepManual,
+		TrimSilenceDB:    c.TrimSilenceDB,
+		Chunked:          c.Chunked,
+		Mixdown:          c.Mixdown,
+		Detector:         c.Detector,
+		Threads:          c.Threads,
+		Logger:           logger,
 	}
+}
+
+// truncatePreview cuts every file down to its first PreviewDuration minutes, warning that later drift goes unnoticed
+// The kept samples are copied so the rest of each file can be freed.
+func (c *Config) truncatePreview(fileSets ...[]*audio.WAVData) {
+	if c.PreviewDuration <= 0 {
+		return
+	}
+	for _, files := range fileSets {
+		for _, f := range files {
+			if frames := int(c.PreviewDuration * 60 * float64(f.SampleRate)); len(f.Data) > frames*f.Channels {
+				f.Data = slices.Clone(f.Data[:frames*f.Channels])
+			}
+		}
+	}
+	warnf("⚠️  Preview: offsets are estimated from the first %g minute(s) of each file only and may miss drift later on\n", c.PreviewDuration)
+}
+
+// resolveDownsample replaces an automatic downsample factor with one chosen from the file lengths in frames
+func (c *Config) resolveDownsample(sampleRate, mixedFrames int, localFrames []int) {
+	if c.DownsampleFactor != 0 {
+		return
+	}
+	c.DownsampleFactor = audiosync.AutoDownsampleFactor(sampleRate, mixedFrames, slices.Max(localFrames), c.AutoResolutionMs)
+	logf("  Downsample factor (auto): %d (%.2fms per coarse sample)\n",
+		c.DownsampleFactor, float64(c.DownsampleFactor)*1000/float64(sampleRate))
+}
+
+// renderOptions returns the settings synced outputs are rendered with
+func (c *Config) renderOptions() render.Options {
+	return render.Options{
+		FadeInMs:        c.FadeInMs,
+		BitDepth:        c.BitDepth,
+		FloatOutput:     c.FloatOutput,
+		SampleRateOut:   c.SampleRateOut,
+		TargetLUFS:      c.TargetLUFS,
+		NormalizeOutput: c.NormalizeOutput,
+		OutputChannels:  c.OutputChannels,
+		Mixdown:         c.Mixdown,
+		InvertPolarity:  c.InvertPolarity,
+	}
+}
 
-	fmt.Printf("  ✓ Mixed: %s (%d channels, %d Hz, %s)\n",
-		filepath.Base(path),
-		mixed.Channels,
-		mixed.SampleRate,
-		mixed.DurationString())
+// outputTags returns the WAV tags written with the output of track i: those of its source,
+// followed by a note on the applied alignment with --sync-note
+func (c *Config) outputTags(i int, source *audio.WAVData, fo *audiosync.FileOffset) *wav.Metadata {
+	if !c.SyncNote {
+		return source.Tags
+	}
+	note := fmt.Sprintf("Synced with clapless %s: padded with %.6fs of silence.", Version, fo.PaddingSeconds)
+	switch {
+	case c.channelGroupOf(i) != nil:
+		note = fmt.Sprintf("Synced with clapless %s, each channel aligned on its own.", Version)
+	case fo.TrimSamples > 0:
+		note = fmt.Sprintf("Synced with clapless %s: trimmed %.6fs from the start.", Version, fo.TrimSeconds)
+	}
+	return audio.WithComment(source.Tags, note)
+}
 
-	return mixed, nil
+// newProgress creates a progress reporter covering one step per local file
+func (c *Config) newProgress() *progressReporter {
+	return newProgressReporter(c.Progress, len(c.LocalPaths))
+}
+
+// loadMixedAudio loads the mixed audio files
+func loadMixedAudio(paths []string) ([]*audio.WAVData, error) {
+	mixedFiles := make([]*audio.WAVData, len(paths))
+
+	for i, path := range paths {
+		mixed, err := audio.LoadAudio(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mixed audio: %w", err)
+		}
+
+		logf("  ✓ Mixed: %s (%d channels, %d Hz, %s)\n",
+			filepath.Base(path),
+			mixed.Channels,
+			mixed.SampleRate,
+			mixed.DurationString())
+
+		mixedFiles[i] = mixed
+	}
+
+	return mixedFiles, nil
 }
 
 // loadLocalAudio loads all local audio files
-func loadLocalAudio(paths []string) ([]*audio.WAVData, error) {
+// A non-nil failed collects the files that cannot be loaded (left nil) instead of returning an error.
+func loadLocalAudio(paths []string, failed fileErrors) ([]*audio.WAVData, error) {
 	localFiles := make([]*audio.WAVData, len(paths))
 
 	for i, path := range paths {
-		local, err := audio.LoadWAV(path)
-		if err != nil {
+		local, err := audio.LoadAudio(path)
+		if err != nil && failed != nil {
+			failed[i] = err
+			continue
+		} else if err != nil {
 			return nil, fmt.Errorf("failed to load local audio %s: %w", path, err)
 		}
 
-		fmt.Printf("  ✓ Local %d: %s (%d channels, %d Hz, %s)\n",
+		logf("  ✓ Local %d: %s (%d channels, %d Hz, %s)\n",
 			i+1,
 			filepath.Base(path),
 			local.Channels,
@@ -176,89 +862,130 @@ func loadLocalAudio(paths []string) ([]*audio.WAVData, error) {
 func validateSampleRates(mixed *audio.WAVData, localFiles []*audio.WAVData) error {
 	for i, local := range localFiles {
 		if local.SampleRate != mixed.SampleRate {
-			return fmt.Errorf("sample rate mismatch: mixed (%d Hz) vs local %d (%d Hz)",
+			return fmt.Errorf("%w: mixed (%d Hz) vs local %d (%d Hz)", audio.ErrSampleRateMismatch,
 				mixed.SampleRate, i+1, local.SampleRate)
 		}
 	}
 	return nil
 }
 
+// resampleLocalAudio converts local files to the mixed file's sample rate
+func resampleLocalAudio(mixed *audio.WAVData, localFiles []*audio.WAVData) {
+	for _, local := range localFiles {
+		if local.SampleRate == mixed.SampleRate {
+			continue
+		}
+
+		logf("  ↻ %s: resampling %d Hz -> %d Hz\n",
+			filepath.Base(local.Path),
+			local.SampleRate,
+			mixed.SampleRate)
+
+		local.Data = audio.Resample(local.Data, local.SampleRate, mixed.SampleRate, local.Channels)
+		local.SampleRate = mixed.SampleRate
+		if local.Format != nil {
+			local.Format.SampleRate = mixed.SampleRate
+		}
+	}
+}
+
 // detectOffsetsParallel detects offsets for all local files in parallel
-func detectOffsetsParallel(mixed *audio.WAVData, localFiles []*audio.WAVData, segmentDuration, downsampleFactor int) ([]*audiosync.OffsetResult, error) {
+// Files with an entry in known (keyed by cleaned path) use that result without correlation.
+// A non-nil dump writes the correlation of each correlated file, and a non-nil cache reuses the offsets of unchanged files.
+// A non-nil failed collects the files whose detection fails (their result is nil) instead of returning an error.
+// If ctx is cancelled it returns ctx.Err() without waiting for the remaining files.
+func detectOffsetsParallel(ctx context.Context, mixed *audio.WAVData, localFiles []*audio.WAVData, known map[string]*audiosync.OffsetResult, opts audiosync.DetectOptions, dump *correlationDump, cache *offsetCache, failed fileErrors, progress *progressReporter) ([]*audiosync.OffsetResult, error) {
 	// Convert mixed to mono for correlation
-	mixedMono := audio.ToMono(mixed.Data, mixed.Channels)
-
-	type result struct {
-		index  int
-		offset *audiosync.OffsetResult
-		err    error
+	mixedMono, err := audio.ToMono(mixed.Data, mixed.Channels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert mixed audio to mono: %w", err)
 	}
 
-	results := make(chan result, len(localFiles))
-	var wg sync.WaitGroup
+	cache = cache.against(mixedMono, mixed.SampleRate)
+	results := make(chan offsetResult, len(localFiles))
 
-	// Launch goroutines for parallel processing
-	for i, local := range localFiles {
-		wg.Add(1)
-		go func(idx int, localData *audio.WAVData) {
-			defer wg.Done()
-
-			// Convert to mono
-			localMono := audio.ToMono(localData.Data, localData.Channels)
-
-			// Detect offset
-			offset, err := audiosync.DetectOffset(mixedMono, localMono, mixed.SampleRate, segmentDuration, downsampleFactor)
-			results <- result{
-				index:  idx,
-				offset: offset,
-				err:    err,
-			}
-		}(i, local)
-	}
+	// Process the files in parallel, at most opts.Workers() at once so their correlation buffers do not all coexist
+	go audiosync.ForEachLimited(len(localFiles), opts.Workers(), func(idx int) {
+		localData := localFiles[idx]
 
-	// Wait for all goroutines to finish
-	wg.Wait()
-	close(results)
+		// Use a known offset as is
+		if offset, ok := known[filepath.Clean(localData.Path)]; ok {
+			results <- offsetResult{index: idx, offset: offset}
+			return
+		}
 
-	// Collect results
-	offsetResults := make([]*audiosync.OffsetResult, len(localFiles))
-	for r := range results {
-		if r.err != nil {
-			return nil, fmt.Errorf("offset detection failed for file %d: %w", r.index+1, r.err)
+		// Convert to mono
+		localMono, err := audio.ToMonoMixdown(localData.Data, localData.Channels, opts.Mixdown)
+		if err != nil {
+			results <- offsetResult{index: idx, err: err}
+			return
 		}
-		offsetResults[r.index] = r.offset
-	}
 
-	return offsetResults, nil
-}
+		// Detect offset
+		fileOpts := dump.options(opts, localData.Path)
+		offset, err := cache.detect(fileOpts, localData.Path, localMono, func() (*audiosync.OffsetResult, error) {
+			return fileOpts.CoarseDetector().Detect(ctx, mixedMono, localMono, mixed.SampleRate)
+		})
+		results <- offsetResult{
+			index:  idx,
+			offset: offset,
+			err:    err,
+		}
+	})
 
-// writeSyncedFile writes a synchronized audio file with padding
-func writeSyncedFile(localData *audio.WAVData, fo *audiosync.FileOffset, originalPath string) error {
-	// Prepend silence if needed
-	syncedData := localData.Data
-	if fo.PaddingSamples > 0 {
-		// For multi-channel audio, we need to prepend silence for each channel
-		silenceSamples := fo.PaddingSamples * localData.Channels
-		syncedData = audio.PrependSilence(localData.Data, silenceSamples)
-	}
+	// Collect results as they arrive
+	// The channel is buffered for every file, so goroutines still running after an early return do not block
+	return collectOffsets(ctx, results, localFiles, failed, progress)
+}
 
-	// Generate output path
-	outputPath := generateOutputPath(originalPath)
+// offsetResult is the outcome of detecting the offset of one local file
+type offsetResult struct {
+	index  int
+	offset *audiosync.OffsetResult
+	err    error
+}
 
-	// Write synced WAV file
-	if err := audio.WriteWAV(outputPath, syncedData, localData.SampleRate, localData.Channels, localData.BitDepth); err != nil {
-		return err
+// collectOffsets gathers one result per local file from results, stopping early on an error
+// (unless failed collects it) or when ctx is cancelled
+func collectOffsets(ctx context.Context, results <-chan offsetResult, localFiles []*audio.WAVData, failed fileErrors, progress *progressReporter) ([]*audiosync.OffsetResult, error) {
+	offsetResults := make([]*audiosync.OffsetResult, len(localFiles))
+	for range localFiles {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-results:
+			// A silent track has no offset, and padding the others to an arbitrary one would misalign them
+			if r.err == nil && r.offset.Silent {
+				r.err = audiosync.ErrSilentInput
+			}
+			if r.err != nil && failed != nil && ctx.Err() == nil {
+				failed[r.index] = r.err
+				progress.step("failed to detect the offset of %s", filepath.Base(localFiles[r.index].Path))
+				continue
+			} else if r.err != nil {
+				return nil, fmt.Errorf("offset detection failed for file %d: %w", r.index+1, r.err)
+			}
+			offsetResults[r.index] = r.offset
+			progress.step("detected offset for %s", filepath.Base(localFiles[r.index].Path))
+		}
 	}
 
-	return nil
+	return offsetResults, nil
 }
 
-// generateOutputPath creates the output file path with _synced suffix
-func generateOutputPath(originalPath string) string {
-	dir := filepath.Dir(originalPath)
-	base := filepath.Base(originalPath)
-	ext := filepath.Ext(base)
-	nameWithoutExt := strings.TrimSuffix(base, ext)
+// longestAligned returns the length in frames of the longest aligned track that is written (suspect ones are not)
+func longestAligned(localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset) int {
+	longest := 0
+	for i, fo := range fileOffsets {
+		if !fo.Suspect {
+			frames := len(localFiles[i].Data)/localFiles[i].Channels + fo.PaddingSamples - fo.TrimSamples
+			longest = max(longest, frames)
+		}
+	}
+	return longest
+}
 
-	return filepath.Join(dir, nameWithoutExt+"_synced"+ext)
+// outputPath returns the path the synced copy of the local file at source is written to
+func (c *Config) outputPath(source string) string {
+	return render.OutputPath(source, c.OutputDir, c.OutputSuffix, c.OutputPattern)
 }
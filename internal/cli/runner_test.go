@@ -0,0 +1,770 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-audio/wav"
+	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// testOffsets are the offsets in seconds of the local files written by writeTestSession
+var testOffsets = []float64{2.5, 6.25}
+
+// writeTestSession writes a mixed file and one local file per testOffsets cut from it into dir,
+// and returns their paths
+func writeTestSession(t *testing.T, dir string) (mixedPath string, localPaths []string) {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(3, 4))
+	mixed := selftestSignal(rng, 40*selftestRate)
+	mixedPath = filepath.Join(dir, "mixed.wav")
+	if err := audio.WriteWAV(mixedPath, mixed, selftestRate, 1, 16, false); err != nil {
+		t.Fatal(err)
+	}
+	for i, offset := range testOffsets {
+		start := int(offset * selftestRate)
+		path := filepath.Join(dir, []string{"alice.wav", "bob.wav"}[i])
+		if err := audio.WriteWAV(path, mixed[start:start+25*selftestRate], selftestRate, 1, 16, false); err != nil {
+			t.Fatal(err)
+		}
+		localPaths = append(localPaths, path)
+	}
+	return mixedPath, localPaths
+}
+
+// testConfig returns the configuration of a run with the default flags
+func testConfig(mixedPath string, localPaths []string) *Config {
+	return &Config{
+		MixedPaths:          []string{mixedPath},
+		LocalPaths:          localPaths,
+		SegmentDuration:     600,
+		DownsampleFactor:    8,
+		AutoResolutionMs:    audiosync.DefaultAutoResolutionMs,
+		CorrelationMethod:   audiosync.MethodStandard,
+		BandpassLow:         300,
+		BandpassHigh:        3400,
+		Mode:                audiosync.ModePad,
+		OutputSuffix:        render.DefaultSuffix,
+		ReportFormat:        reportJSON,
+		DumpCorrelationStep: 1,
+		Window:              audiosync.WindowTukey,
+		CombineLayout:       combineMono,
+		FinetuneTarget:      10,
+		FinetuneMin:         5,
+		NoCache:             true,
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out", "synced") // Created by the run
+	config.OutputSuffix = ".take1"
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, name := range []string{"alice.take1.wav", "bob.take1.wav"} {
+		if _, err := os.Stat(filepath.Join(config.OutputDir, name)); err != nil {
+			t.Errorf("output %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice_synced.wav")); err == nil {
+		t.Error("an output was also written next to the input")
+	}
+}
+
+func TestRunFailBelow(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// A recording of something else correlates with nothing in the mix
+	unrelated := filepath.Join(dir, "unrelated.wav")
+	if err := audio.WriteWAV(unrelated, selftestSignal(rand.New(rand.NewPCG(5, 6)), 25*selftestRate), selftestRate, 1, 16, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		failBelow float64
+		wantErr   bool
+	}{
+		{"warn only", 0, false},
+		{"abort", 0.5, true},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(mixedPath, append(slices.Clone(localPaths), unrelated))
+		config.OutputDir = filepath.Join(dir, tt.name)
+		config.FailBelow = tt.failBelow
+		err := Run(context.Background(), config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Run error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "unrelated.wav") {
+			t.Errorf("%s: error %q does not name the failing file", tt.name, err)
+		}
+
+		_, statErr := os.Stat(filepath.Join(config.OutputDir, "alice_synced.wav"))
+		if written := statErr == nil; written == tt.wantErr {
+			t.Errorf("%s: output written = %v, want %v", tt.name, written, !tt.wantErr)
+		}
+	}
+}
+
+func TestRunBitDepth(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	tests := []struct {
+		name      string
+		bitDepth  int
+		float     bool
+		wantDepth int
+	}{
+		{"keeps the source depth", 0, false, 16},
+		{"override", 24, false, 24},
+		{"float", 0, true, 32},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, tt.name)
+		config.BitDepth, config.FloatOutput = tt.bitDepth, tt.float
+		if err := Run(context.Background(), config); err != nil {
+			t.Fatalf("%s: Run: %v", tt.name, err)
+		}
+
+		output, err := audio.LoadWAV(filepath.Join(config.OutputDir, "alice_synced.wav"))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if output.BitDepth != tt.wantDepth || output.Float != tt.float {
+			t.Errorf("%s: output is %d-bit (float %v), want %d-bit (float %v)", tt.name, output.BitDepth, output.Float, tt.wantDepth, tt.float)
+		}
+	}
+}
+
+func TestRunTrimMode(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "trimmed")
+	config.Mode = audiosync.ModeTrim
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	alice, err := audio.LoadWAV(filepath.Join(config.OutputDir, "alice_synced.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := audio.LoadWAV(filepath.Join(config.OutputDir, "bob_synced.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alice.wav starts earlier, so it loses its lead and then matches bob.wav sample for sample
+	trim := int(math.Round((testOffsets[1] - testOffsets[0]) * selftestRate))
+	if want := 25*selftestRate - trim; len(alice.Data) != want || len(bob.Data) != 25*selftestRate {
+		t.Fatalf("outputs have %d and %d frames, want %d and %d", len(alice.Data), len(bob.Data), want, 25*selftestRate)
+	}
+	for i, v := range alice.Data {
+		if v != bob.Data[i] {
+			t.Fatalf("frame %d: alice %g, bob %g; want the same sample", i, v, bob.Data[i])
+		}
+	}
+}
+
+func TestRunCombine(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.CombinePath = filepath.Join(dir, "combined.wav")
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	combined, err := audio.LoadWAV(config.CombinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One channel per local file, as long as the padded bob.wav
+	if combined.Channels != len(localPaths) {
+		t.Fatalf("%d channels, want %d", combined.Channels, len(localPaths))
+	}
+	padding := int(math.Round((testOffsets[1] - testOffsets[0]) * selftestRate))
+	if frames := len(combined.Data) / combined.Channels; frames != padding+25*selftestRate {
+		t.Errorf("%d frames, want %d", frames, padding+25*selftestRate)
+	}
+
+	// Both channels carry the mixed audio at the same time once bob.wav starts
+	for frame := padding; frame < 25*selftestRate; frame++ {
+		if alice, bob := combined.Data[2*frame], combined.Data[2*frame+1]; alice != bob {
+			t.Fatalf("frame %d: alice %g, bob %g; want the same sample", frame, alice, bob)
+		}
+	}
+}
+
+func TestRunCombineLayout(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// Make bob.wav stereo, its right channel at half the level of the left
+	bob, err := audio.LoadWAV(localPaths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	stereo := make([]float64, 2*len(bob.Data))
+	for i, sample := range bob.Data {
+		stereo[2*i], stereo[2*i+1] = sample, sample/2
+	}
+	if err := audio.WriteWAV(localPaths[1], stereo, selftestRate, 2, 16, false); err != nil {
+		t.Fatal(err)
+	}
+	padding := int(math.Round((testOffsets[1] - testOffsets[0]) * selftestRate))
+
+	tests := []struct {
+		layout       string
+		wantChannels int
+		// want returns the expected channels of a frame where both files carry the mixed sample s
+		want func(s float64) []float64
+	}{
+		// The mono alice.wav is copied to both of its channels and bob.wav keeps its own
+		{combineAuto, 4, func(s float64) []float64 { return []float64{s, s, s, s / 2} }},
+		{combineStereo, 4, func(s float64) []float64 { return []float64{s, s, s, s / 2} }},
+		// bob.wav is averaged down to one channel
+		{combineMono, 2, func(s float64) []float64 { return []float64{s, s * 3 / 4} }},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, tt.layout)
+		config.CombinePath = filepath.Join(dir, tt.layout+".wav")
+		config.CombineLayout = tt.layout
+		if err := Run(context.Background(), config); err != nil {
+			t.Fatalf("%s: Run: %v", tt.layout, err)
+		}
+
+		combined, err := audio.LoadWAV(config.CombinePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if combined.Channels != tt.wantChannels {
+			t.Fatalf("%s: %d channels, want %d", tt.layout, combined.Channels, tt.wantChannels)
+		}
+
+		// Once bob.wav starts, alice and bob carry the same mixed audio
+		for frame := padding; frame < padding+selftestRate; frame++ {
+			got := combined.Data[frame*tt.wantChannels : (frame+1)*tt.wantChannels]
+			want := tt.want(got[0])
+			for ch := range want {
+				if math.Abs(got[ch]-want[ch]) > 1.0/32768 {
+					t.Fatalf("%s: frame %d: channels %v, want %v", tt.layout, frame, got, want)
+				}
+			}
+		}
+	}
+}
+
+// runReport runs config with a JSON report and returns the report
+func runReport(t *testing.T, config *Config) *Report {
+	t.Helper()
+	config.ReportPath = filepath.Join(t.TempDir(), "report.json")
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(config.ReportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	return &report
+}
+
+func TestRunManualOffset(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+	manual := testOffsets[1] - 0.01 // A little off the true offset of bob.wav
+
+	tests := []struct {
+		name       string
+		keepManual bool
+		want       float64 // Final offset of bob.wav in seconds
+	}{
+		{"refined by fine-tuning", false, testOffsets[1]},
+		{"kept as given", true, manual},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, tt.name)
+		config.ManualOffsets = map[string]float64{filepath.Clean(localPaths[1]): manual}
+		config.KeepManual = tt.keepManual
+		report := runReport(t, config)
+
+		alice, bob := report.Files[0], report.Files[1]
+		if !bob.Manual || bob.OffsetSeconds != manual || bob.Confidence != 1 {
+			t.Errorf("%s: bob.wav coarse offset %gs (manual %v, confidence %g), want the manual %gs", tt.name, bob.OffsetSeconds, bob.Manual, bob.Confidence, manual)
+		}
+		if math.Abs(bob.FinalOffsetSeconds-tt.want) > 1.0/selftestRate {
+			t.Errorf("%s: bob.wav final offset %gs, want %gs", tt.name, bob.FinalOffsetSeconds, tt.want)
+		}
+		// The other file is still detected
+		if alice.Manual || math.Abs(alice.FinalOffsetSeconds-testOffsets[0]) > 1.0/selftestRate {
+			t.Errorf("%s: alice.wav final offset %gs (manual %v), want the detected %gs", tt.name, alice.FinalOffsetSeconds, alice.Manual, testOffsets[0])
+		}
+	}
+}
+
+func TestRunDownsample(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	for _, factor := range []int{1, 4, 16} {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, fmt.Sprint(factor))
+		config.DownsampleFactor = factor
+		report := runReport(t, config)
+
+		for i, fo := range report.Files {
+			// The coarse search ran at the factor given and still finds the offset
+			if fo.Downsample != factor {
+				t.Errorf("downsample %d: %s searched at factor %d", factor, fo.Path, fo.Downsample)
+			}
+			if math.Abs(fo.OffsetSeconds-testOffsets[i]) > 1.0/selftestRate || math.Abs(fo.FinalOffsetSeconds-testOffsets[i]) > 1.0/selftestRate {
+				t.Errorf("downsample %d: %s offset %gs (final %gs), want %gs", factor, fo.Path, fo.OffsetSeconds, fo.FinalOffsetSeconds, testOffsets[i])
+			}
+		}
+	}
+}
+
+func TestRunMultipleMixed(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+
+	// Two mixed files with a break between them, and local files recorded throughout
+	recording := selftestSignal(rand.New(rand.NewPCG(7, 8)), 60*selftestRate)
+	write := func(name string, from, to float64) string {
+		path := filepath.Join(dir, name)
+		if err := audio.WriteWAV(path, recording[int(from*selftestRate):int(to*selftestRate)], selftestRate, 1, 16, false); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	mixedPaths := []string{write("before.wav", 0, 25), write("after.wav", 32, 60)}
+	localPaths := []string{write("alice.wav", 2, 52), write("bob.wav", 5, 55)}
+
+	config := testConfig(mixedPaths[0], localPaths)
+	config.MixedPaths = mixedPaths
+	config.OutputDir = filepath.Join(dir, "out")
+	report := runReport(t, config)
+
+	if len(report.Session) != 2 || report.Session[0].StartSamples != 0 || report.Session[1].StartSamples != 32*selftestRate {
+		t.Fatalf("session %+v, want the second mixed file 32s after the first", report.Session)
+	}
+	for i, want := range []float64{2, 5} {
+		if fo := report.Files[i]; math.Abs(fo.FinalOffsetSeconds-want) > 1.0/selftestRate {
+			t.Errorf("%s: final offset %gs on the session timeline, want %gs", fo.Path, fo.FinalOffsetSeconds, want)
+		}
+	}
+}
+
+func TestCollectOffsetsCancelled(t *testing.T) {
+	// No detection ever finishes, so only the cancellation can end the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan offsetResult)
+	localFiles := []*audio.WAVData{{Path: "alice.wav"}, {Path: "bob.wav"}}
+
+	done := make(chan error)
+	go func() {
+		_, err := collectOffsets(ctx, results, localFiles, nil, nil)
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("collectOffsets did not return after cancellation")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	for _, timeout := range []time.Duration{0, time.Millisecond, 50 * time.Millisecond} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, timeout.String())
+
+		done := make(chan error)
+		go func() { done <- Run(ctx, config) }()
+		select {
+		case err := <-done:
+			// A run that finishes before the deadline is fine; one that stops must report why
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("timeout %s: error %v, want context.DeadlineExceeded", timeout, err)
+			}
+			if timeout == 0 && err == nil {
+				t.Errorf("timeout %s: Run finished although ctx was already done", timeout)
+			}
+		case <-time.After(time.Minute):
+			t.Fatalf("timeout %s: Run did not return after cancellation", timeout)
+		}
+		cancel()
+	}
+}
+
+func TestRunSkipExisting(t *testing.T) {
+	out, _ := captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	newConfig := func() *Config {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, "out")
+		config.ReportPath = filepath.Join(dir, "report.json")
+		config.SkipExisting = true
+		return config
+	}
+	config := newConfig()
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// alice.wav's output is up to date, so it must be left as it is (a marker instead of audio here)
+	aliceOut, bobOut := config.outputPath(localPaths[0]), config.outputPath(localPaths[1])
+	marker := []byte("left untouched")
+	if err := os.WriteFile(aliceOut, marker, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(aliceOut, later, later); err != nil {
+		t.Fatal(err)
+	}
+	// bob.wav was edited after its output was written, so the output is stale
+	if err := os.Chtimes(localPaths[1], later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bobOut, marker, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := Run(context.Background(), newConfig()); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "alice.wav: up to date") || strings.Contains(out.String(), "bob.wav: up to date") {
+		t.Errorf("output does not report only alice.wav as up to date:\n%s", out.String())
+	}
+	if data, err := os.ReadFile(aliceOut); err != nil || !slices.Equal(data, marker) {
+		t.Errorf("up-to-date output was rewritten (err %v)", err)
+	}
+	if _, err := audio.LoadWAV(bobOut); err != nil {
+		t.Errorf("stale output was not regenerated: %v", err)
+	}
+
+	// The skipped file still takes part in the alignment with its recorded offset
+	data, err := os.ReadFile(config.ReportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	for i, fo := range report.Files {
+		if math.Abs(fo.FinalOffsetSeconds-testOffsets[i]) > 1.0/selftestRate {
+			t.Errorf("%s: final offset %gs, want %gs", filepath.Base(fo.Path), fo.FinalOffsetSeconds, testOffsets[i])
+		}
+	}
+}
+
+func TestRunPerChannel(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// Make bob.wav stereo with its right microphone 20 ms later than its left
+	mixed, err := audio.LoadWAV(mixedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets := []float64{testOffsets[1], testOffsets[1] + 0.02}
+	channels := make([][]float64, len(offsets))
+	for ch, offset := range offsets {
+		start := int(math.Round(offset * selftestRate))
+		channels[ch] = mixed.Data[start : start+25*selftestRate]
+	}
+	stereo, err := audio.Interleave(channels, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := audio.WriteWAV(localPaths[1], stereo, selftestRate, 2, 16, false); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.PerChannel = true
+	report := runReport(t, config)
+
+	// Each channel of bob.wav is reported and corrected on its own
+	if len(report.Files) != 3 {
+		t.Fatalf("%d report entries, want alice.wav and both channels of bob.wav", len(report.Files))
+	}
+	for ch, offset := range offsets {
+		fo := report.Files[1+ch]
+		if filepath.Base(fo.Path) != "bob.wav" || fo.Channel != ch+1 {
+			t.Errorf("entry %d: %s channel %d, want bob.wav channel %d", 1+ch, filepath.Base(fo.Path), fo.Channel, ch+1)
+		}
+		if math.Abs(fo.FinalOffsetSeconds-offset) > 1.0/selftestRate {
+			t.Errorf("channel %d: final offset %gs, want %gs", ch+1, fo.FinalOffsetSeconds, offset)
+		}
+	}
+
+	// The channels are written back into one stereo file, where they now line up
+	synced, err := audio.LoadWAV(config.outputPath(localPaths[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced.Channels != 2 {
+		t.Fatalf("output has %d channels, want 2", synced.Channels)
+	}
+	from := int(math.Round((offsets[1] - testOffsets[0]) * selftestRate)) // Where the later channel starts
+	for frame := from; frame < from+selftestRate; frame++ {
+		if left, right := synced.Data[2*frame], synced.Data[2*frame+1]; left != right {
+			t.Fatalf("frame %d: left %g, right %g; want the same sample", frame, left, right)
+		}
+	}
+}
+
+func TestRunMaxPadding(t *testing.T) {
+	_, warnings := captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	tests := []struct {
+		name    string
+		fail    bool
+		wantErr bool
+	}{
+		{"leave out", false, false},
+		{"abort", true, true},
+	}
+
+	for _, tt := range tests {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, tt.name)
+		// An offset an hour into the mix stands in for a false peak
+		config.ManualOffsets = map[string]float64{filepath.Clean(localPaths[1]): 3600}
+		config.KeepManual = true
+		config.MaxPaddingSec = 60
+		config.FailOnMaxPadding = tt.fail
+
+		err := Run(context.Background(), config)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Run error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "bob.wav") {
+			t.Errorf("%s: error %q does not name the suspect file", tt.name, err)
+		}
+		if !tt.wantErr && !strings.Contains(warnings.String(), "bob.wav: padding of") {
+			t.Errorf("%s: no warning about bob.wav:\n%s", tt.name, warnings.String())
+		}
+
+		// The suspect file is never written; the other one only if the run goes on
+		if _, err := os.Stat(filepath.Join(config.OutputDir, "bob_synced.wav")); err == nil {
+			t.Errorf("%s: the suspect file was written", tt.name)
+		}
+		_, statErr := os.Stat(filepath.Join(config.OutputDir, "alice_synced.wav"))
+		if written := statErr == nil; written == tt.wantErr {
+			t.Errorf("%s: alice_synced.wav written = %v, want %v", tt.name, written, !tt.wantErr)
+		}
+	}
+}
+
+func TestRunSyncNote(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// Tag bob.wav, which is padded
+	bob, err := audio.LoadWAV(localPaths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := audio.WriteWAVTagged(localPaths[1], bob.Data, selftestRate, 1, 16, false, &wav.Metadata{Artist: "Bob", Comments: "guest mic"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.SyncNote = true
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	synced, err := audio.LoadWAV(config.outputPath(localPaths[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := testOffsets[1] - testOffsets[0]
+	wantComments := fmt.Sprintf("guest mic Synced with clapless %s: padded with %.6fs of silence.", Version, padding)
+	if synced.Tags == nil || synced.Tags.Artist != "Bob" || synced.Tags.Comments != wantComments {
+		t.Errorf("tags %+v, want artist Bob and comments %q", synced.Tags, wantComments)
+	}
+
+	// An untagged source gets the note alone
+	alice, err := audio.LoadWAV(config.outputPath(localPaths[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Tags == nil || !strings.HasPrefix(alice.Tags.Comments, "Synced with clapless") {
+		t.Errorf("alice.wav tags %+v, want only the note", alice.Tags)
+	}
+}
+
+func TestRunCommonLead(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+	const lead = 1.5
+	leadFrames := int(lead * selftestRate)
+
+	outputs := make(map[float64][][]float64) // Output of each file by lead
+	for _, leadSec := range []float64{0, lead} {
+		config := testConfig(mixedPath, localPaths)
+		config.OutputDir = filepath.Join(dir, fmt.Sprint("lead", leadSec))
+		config.CommonLeadSec = leadSec
+		if err := Run(context.Background(), config); err != nil {
+			t.Fatalf("lead %gs: Run: %v", leadSec, err)
+		}
+		for _, path := range localPaths {
+			synced, err := audio.LoadWAV(config.outputPath(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs[leadSec] = append(outputs[leadSec], synced.Data)
+		}
+	}
+
+	// Every output is the one without a lead, after the same amount of silence, so the files stay aligned
+	for i, path := range localPaths {
+		without, with := outputs[0][i], outputs[lead][i]
+		if len(with) != len(without)+leadFrames {
+			t.Errorf("%s: grew by %d frames, want %d", filepath.Base(path), len(with)-len(without), leadFrames)
+			continue
+		}
+		if slices.ContainsFunc(with[:leadFrames], func(v float64) bool { return v != 0 }) || !slices.Equal(with[leadFrames:], without) {
+			t.Errorf("%s: not the output without a lead shifted by %d frames", filepath.Base(path), leadFrames)
+		}
+	}
+}
+
+func TestRunPreviewDuration(t *testing.T) {
+	_, warnings := captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	const preview = 0.25 // 15 s of the 40 s mix and the 25 s local files
+	config := testConfig(mixedPath, localPaths)
+	config.OutputDir = filepath.Join(dir, "out")
+	config.PreviewDuration = preview
+	report := runReport(t, config)
+
+	if !strings.Contains(warnings.String(), "Preview: offsets are estimated from the first") {
+		t.Errorf("no warning about the preview:\n%s", warnings.String())
+	}
+	// Without drift the offsets found on the prefix are those of the whole files
+	for i, fo := range report.Files {
+		if math.Abs(fo.FinalOffsetSeconds-testOffsets[i]) > 1.0/selftestRate {
+			t.Errorf("%s: final offset %gs, want %gs", filepath.Base(fo.Path), fo.FinalOffsetSeconds, testOffsets[i])
+		}
+	}
+	// Only the prefix is processed and written, so the work shrinks with it
+	padding := int(math.Round((testOffsets[1] - testOffsets[0]) * selftestRate))
+	synced, err := audio.LoadWAV(config.outputPath(localPaths[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := padding + int(preview*60*selftestRate); len(synced.Data) != want {
+		t.Errorf("bob.wav output has %d frames, want the padding and the %g minute prefix (%d)", len(synced.Data), preview, want)
+	}
+}
+
+func TestRunEqualizeLength(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+
+	// A shorter, stereo third file ends before the others
+	mixed, err := audio.LoadWAV(mixedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 4 * selftestRate
+	short := mixed.Data[start : start+10*selftestRate]
+	stereo, err := audio.Interleave([][]float64{short, short}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol := filepath.Join(dir, "carol.wav")
+	if err := audio.WriteWAV(carol, stereo, selftestRate, 2, 16, false); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testConfig(mixedPath, append(localPaths, carol))
+	config.OutputDir = filepath.Join(dir, "out")
+	config.EqualizeLength = true
+	if err := Run(context.Background(), config); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Every output is as long as bob.wav, which starts last and so ends last
+	padding := int(math.Round((testOffsets[1] - testOffsets[0]) * selftestRate))
+	for _, path := range config.LocalPaths {
+		synced, err := audio.LoadWAV(config.outputPath(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames := len(synced.Data) / synced.Channels; frames != padding+25*selftestRate {
+			t.Errorf("%s: %d frames, want %d", filepath.Base(path), frames, padding+25*selftestRate)
+		}
+	}
+}
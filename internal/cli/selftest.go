@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// Generated signals of the self-test
+const (
+	selftestRate         = 16000 // Sample rate in Hz
+	selftestMixedSeconds = 120.0 // Length of the mixed track
+	selftestLocalSeconds = 70.0  // Length of each local track, long enough for every pair to overlap by over FinetuneMin
+)
+
+// selftestCase is one generated local track with a known offset against the mixed track
+type selftestCase struct {
+	name   string
+	offset float64 // Seconds the local track starts after the mixed track (negative = before)
+	gain   float64 // Level of the mixed signal in the local track (negative = inverted polarity)
+	noise  float64 // RMS of the uncorrelated noise added to the local track
+}
+
+// selftestCases cover the situations detection must handle; offsets are whole samples at selftestRate
+var selftestCases = []selftestCase{
+	{name: "clean", offset: 3.25, gain: 1},
+	{name: "quiet and noisy", offset: 12.5, gain: 0.1, noise: 0.01},
+	{name: "inverted polarity", offset: 20.125, gain: -0.8},
+	{name: "starts before the mix", offset: -2, gain: 1, noise: 0.005},
+	{name: "odd sample offset", offset: 7.0003125, gain: 0.5},
+}
+
+var selftestToleranceMs float64
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest [flags]",
+	Short: "Check offset detection on generated signals with known offsets",
+	Long: `Generate a mixed track and local tracks cut from it at known offsets, run the
+same coarse detection and fine-tuning as a normal sync, and check that every
+recovered offset is within the tolerance. No files are read or written.
+
+The local tracks include a quiet and noisy one, one with inverted polarity and one
+that starts before the mixed track. The exit status is non-zero if any case fails,
+so the command can validate an installation in a script.
+
+Example:
+  clapless selftest
+  clapless selftest --tolerance-ms 0.5`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if selftestToleranceMs <= 0 {
+			return fmt.Errorf("--tolerance-ms must be positive, got %g", selftestToleranceMs)
+		}
+		return SelfTest(cmd.Context(), selftestToleranceMs)
+	},
+	SilenceUsage: true, // Don't show usage on errors during execution
+}
+
+func init() {
+	selftestCmd.Flags().Float64Var(&selftestToleranceMs, "tolerance-ms", 1, "Maximum difference in milliseconds between a recovered and the true offset for a case to pass")
+
+	rootCmd.AddCommand(selftestCmd)
+}
+
+// SelfTest detects and fine-tunes the offsets of generated local tracks against a generated mixed track
+// It returns an error if any recovered offset differs from the true one by more than toleranceMs
+// or could not be fine-tuned.
+func SelfTest(ctx context.Context, toleranceMs float64) error {
+	logln("Clapless - Self-Test")
+	logln("====================")
+	logln()
+
+	logf("Generating test signals (%d Hz, %.0fs mixed, %d local tracks)...\n", selftestRate, selftestMixedSeconds, len(selftestCases))
+	rng := rand.New(rand.NewPCG(1, 2)) // Fixed seed, so every run checks the same signals
+	mixed := selftestSignal(rng, int(selftestMixedSeconds*selftestRate))
+	locals := make([][]float64, len(selftestCases))
+	for i, c := range selftestCases {
+		locals[i] = selftestLocal(rng, mixed, c)
+	}
+
+	config := &Config{
+		SegmentDuration:   600,
+		AutoResolutionMs:  audiosync.DefaultAutoResolutionMs,
+		CorrelationMethod: audiosync.MethodStandard,
+		BandpassLow:       300,
+		BandpassHigh:      3400,
+		Window:            audiosync.WindowTukey,
+		FinetuneTarget:    60,
+		FinetuneMin:       30,
+	}
+	config.resolveDownsample(selftestRate, len(mixed), []int{int(selftestLocalSeconds * selftestRate)})
+	logln()
+
+	logf("Detecting and fine-tuning offsets (downsample=%d)...\n", config.DownsampleFactor)
+	fileOffsets, err := audiosync.AlignBuffers(ctx, mixed, locals, selftestRate, config.detectOptions())
+	if err != nil {
+		return err
+	}
+	logln()
+
+	failed := 0
+	for i, c := range selftestCases {
+		fo := fileOffsets[i]
+		expected := math.Round(c.offset * selftestRate)
+		errorMs := (float64(fo.FinalOffsetSamples) - expected) * 1000 / selftestRate
+		status := "✓"
+		note := ""
+		switch {
+		case fo.FinetuneResult == nil || fo.FinetuneResult.Skipped:
+			status, note = "✗", " (not fine-tuned)"
+			failed++
+		case math.Abs(errorMs) > toleranceMs:
+			status = "✗"
+			failed++
+		}
+		logf("  %s %s: expected %+.6fs, got %+.6fs (error %+.3fms, confidence: %.2f)%s\n",
+			status, c.name, expected/selftestRate, float64(fo.FinalOffsetSamples)/selftestRate, errorMs, fo.Confidence, note)
+	}
+
+	logln()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d self-test cases failed (tolerance %gms)", failed, len(selftestCases), toleranceMs)
+	}
+
+	logf("All %d cases within %gms of the true offset\n", len(selftestCases), toleranceMs)
+	return nil
+}
+
+// selftestSignal generates speech-like audio: noise bursts of random length and level separated by short pauses
+// Every burst is different, so the signal correlates with itself only at the true lag.
+func selftestSignal(rng *rand.Rand, length int) []float64 {
+	data := make([]float64, length)
+	for i := 0; i < length; {
+		burst := int((0.05 + 0.25*rng.Float64()) * selftestRate)
+		level := 0.1 + 0.3*rng.Float64()
+		if rng.IntN(4) == 0 {
+			level = 0.002 // Pause, at the level of a quiet noise floor
+		}
+		for j := i; j < min(i+burst, length); j++ {
+			data[j] = level * rng.NormFloat64()
+		}
+		i += burst
+	}
+	return data
+}
+
+// selftestLocal cuts the local track of c from mixed, scales it, adds noise, and fills
+// the part before the start of mixed with unrelated audio
+func selftestLocal(rng *rand.Rand, mixed []float64, c selftestCase) []float64 {
+	start := int(math.Round(c.offset * selftestRate))
+	length := int(selftestLocalSeconds * selftestRate)
+	lead := selftestSignal(rng, max(-start, 0))
+
+	local := make([]float64, length)
+	for j := range local {
+		if src := start + j; src < 0 {
+			local[j] = lead[j]
+		} else {
+			local[j] = c.gain * mixed[src]
+		}
+		local[j] += c.noise * rng.NormFloat64()
+	}
+	return local
+}
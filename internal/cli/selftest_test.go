@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("self-test detects two minutes of audio")
+	}
+	out, _ := captureOutput(t)
+
+	if err := SelfTest(context.Background(), 1); err != nil {
+		t.Fatalf("SelfTest: %v\n%s", err, out)
+	}
+	for _, c := range selftestCases {
+		if !strings.Contains(out.String(), "✓ "+c.name+":") {
+			t.Errorf("case %q did not pass:\n%s", c.name, out)
+		}
+	}
+	if want := fmt.Sprintf("All %d cases within 1ms", len(selftestCases)); !strings.Contains(out.String(), want) {
+		t.Errorf("no pass summary:\n%s", out)
+	}
+}
+
+func TestSelfTestFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("self-test detects two minutes of audio")
+	}
+	out, _ := captureOutput(t)
+
+	// A local track of noise alone has no offset to find, so its case must fail the run
+	oldCases := selftestCases
+	selftestCases = []selftestCase{oldCases[0], {name: "unrelated", offset: 30, noise: 0.1}}
+	t.Cleanup(func() { selftestCases = oldCases })
+
+	err := SelfTest(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 self-test cases failed") {
+		t.Errorf("SelfTest error %v, want one case failed", err)
+	}
+	if !strings.Contains(out.String(), "✓ clean:") || !strings.Contains(out.String(), "✗ unrelated:") {
+		t.Errorf("want clean to pass and unrelated to fail:\n%s", out)
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// detectSessionOffsets aligns every local file against each mixed file, places the mixed files
+// on one session timeline and returns a mono session track to use as the mixed reference
+// The returned offsets are relative to the start of the session. A non-nil cache reuses unchanged detections.
+func detectSessionOffsets(ctx context.Context, config *Config, mixedFiles []*audio.WAVData, localFiles []*audio.WAVData, cache *offsetCache) (*audio.WAVData, []audiosync.SessionSegment, []*audiosync.OffsetResult, error) {
+	sampleRate := mixedFiles[0].SampleRate
+	perSegment := make([][]*audiosync.OffsetResult, len(mixedFiles))
+	lengths := make([]int, len(mixedFiles))
+	monoData := make([][]float64, len(mixedFiles))
+
+	for k, mixed := range mixedFiles {
+		mono, err := audio.ToMono(mixed.Data, mixed.Channels)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to convert mixed %s to mono: %w", filepath.Base(mixed.Path), err)
+		}
+		monoData[k] = mono
+		lengths[k] = len(mono)
+
+		logf("  Mixed %d: %s\n", k+1, filepath.Base(mixed.Path))
+		offsets, err := detectOffsetsParallel(ctx, mixed, localFiles, nil, config.detectOptions(), nil, cache, nil, config.newProgress())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		perSegment[k] = offsets
+	}
+
+	segments, err := audiosync.PlaceSegments(config.MixedPaths, lengths, config.LocalPaths, perSegment, sampleRate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	logln()
+	logln("Session timeline:")
+	for k, segment := range segments {
+		if segment.AnchorFile == "" {
+			logf("  Mixed %d: %s starts at %.3fs\n", k+1, filepath.Base(segment.Path), segment.StartSeconds)
+		} else {
+			logf("  Mixed %d: %s starts at %.3fs (placed via %s)\n",
+				k+1, filepath.Base(segment.Path), segment.StartSeconds, filepath.Base(segment.AnchorFile))
+		}
+	}
+
+	session := &audio.WAVData{
+		Path:       "session",
+		SampleRate: sampleRate,
+		Channels:   1,
+		BitDepth:   mixedFiles[0].BitDepth,
+		Data:       audiosync.BuildSessionTrack(segments, monoData),
+	}
+
+	return session, segments, audiosync.SessionOffsets(segments, perSegment, sampleRate), nil
+}
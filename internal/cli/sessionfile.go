@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// sessionFileVersion is bumped whenever the session file layout changes incompatibly
+const sessionFileVersion = 1
+
+// SessionFile is an alignment saved with --save-session and reapplied with --load-session
+type SessionFile struct {
+	SchemaVersion int                        `json:"schema_version"`
+	SampleRate    int                        `json:"sample_rate"`       // Rate the offsets are counted at (the first mixed file's)
+	Session       []audiosync.SessionSegment `json:"session,omitempty"` // Placement of each mixed file (multiple mixed files only)
+	Files         []*audiosync.FileOffset    `json:"files"`
+}
+
+// writeSessionFile saves the alignment of every local file to path
+func writeSessionFile(path string, sampleRate int, session []audiosync.SessionSegment, fileOffsets []*audiosync.FileOffset) error {
+	data, err := json.MarshalIndent(&SessionFile{
+		SchemaVersion: sessionFileVersion,
+		SampleRate:    sampleRate,
+		Session:       session,
+		Files:         fileOffsets,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadSessionFile reads a saved alignment and returns its offsets in the order of localPaths
+// Every local file must be in the session and the session must have been saved at sampleRate.
+// Trim and fractional delay are cleared and padding is recalculated, so the current mode applies.
+func loadSessionFile(path string, localPaths []string, sampleRate int) ([]*audiosync.FileOffset, []audiosync.SessionSegment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var saved SessionFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	if saved.SchemaVersion != sessionFileVersion {
+		return nil, nil, fmt.Errorf("session %s has schema version %d, expected %d", path, saved.SchemaVersion, sessionFileVersion)
+	}
+	if saved.SampleRate != sampleRate {
+		return nil, nil, fmt.Errorf("%w: session %s was saved at %d Hz, mixed is %d Hz",
+			audio.ErrSampleRateMismatch, path, saved.SampleRate, sampleRate)
+	}
+
+	recorded := make(map[string]*audiosync.FileOffset, len(saved.Files))
+	for _, fo := range saved.Files {
+		recorded[filepath.Clean(fo.Path)] = fo
+	}
+	if len(recorded) != len(localPaths) {
+		return nil, nil, fmt.Errorf("session %s has %d local files, got %d", path, len(recorded), len(localPaths))
+	}
+
+	fileOffsets := make([]*audiosync.FileOffset, len(localPaths))
+	for i, localPath := range localPaths {
+		fo, ok := recorded[filepath.Clean(localPath)]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not in session %s", localPath, path)
+		}
+		fo.Path = localPath
+		fo.TrimSamples, fo.TrimSeconds, fo.PaddingFraction = 0, 0, 0
+		fileOffsets[i] = fo
+	}
+
+	fileOffsets, err = audiosync.RecalculatePadding(fileOffsets, sampleRate)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileOffsets, saved.Session, nil
+}
+
+// restoreGaps reinserts the gaps recorded in the offsets into local files, whose final offsets
+// already refer to the filled files
+func restoreGaps(paths []string, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if len(fo.Segments) == 0 {
+			continue
+		}
+		localFiles[i].Data = audiosync.FillGaps(localFiles[i].Data, localFiles[i].Channels, fo.Segments)
+		logf("  ✓ %s: %d segments (from session)\n", filepath.Base(paths[i]), len(fo.Segments))
+	}
+}
+
+// restoreRates reinterprets local files recorded at another rate than their header says, as recorded in their offsets
+func restoreRates(paths []string, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if fo.RateMismatch == nil {
+			continue
+		}
+		localFiles[i].Data = fo.RateMismatch.Apply(localFiles[i].Data, localFiles[i].Channels)
+		logf("  ✓ %s: treated as %d Hz (from session)\n", filepath.Base(paths[i]), fo.RateMismatch.ActualRate)
+	}
+}
+
+// restoreDrift stretches local files by the drift correction recorded in their offsets,
+// whose final offsets already refer to the stretched files
+func restoreDrift(paths []string, localFiles []*audio.WAVData, fileOffsets []*audiosync.FileOffset) {
+	for i, fo := range fileOffsets {
+		if fo.Drift == nil || fo.Drift.Skipped {
+			continue
+		}
+		localFiles[i].Data = audio.Stretch(localFiles[i].Data, fo.Drift.Ratio, localFiles[i].Channels)
+		logf("  ✓ %s: drift %+.1f ppm (from session)\n", filepath.Base(paths[i]), fo.Drift.PPM)
+	}
+}
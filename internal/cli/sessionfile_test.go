@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestRunSessionRoundTrip(t *testing.T) {
+	captureOutput(t)
+	dir := t.TempDir()
+	mixedPath, localPaths := writeTestSession(t, dir)
+	sessionPath := filepath.Join(dir, "session.json")
+
+	detected := testConfig(mixedPath, localPaths)
+	detected.OutputDir = filepath.Join(dir, "detected")
+	detected.SaveSessionPath = sessionPath
+	if err := Run(context.Background(), detected); err != nil {
+		t.Fatalf("saving run: %v", err)
+	}
+
+	loaded := testConfig(mixedPath, localPaths)
+	loaded.OutputDir = filepath.Join(dir, "loaded")
+	loaded.LoadSessionPath = sessionPath
+	if err := Run(context.Background(), loaded); err != nil {
+		t.Fatalf("loading run: %v", err)
+	}
+
+	for _, name := range []string{"alice_synced.wav", "bob_synced.wav"} {
+		want, err := os.ReadFile(filepath.Join(detected.OutputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(loaded.OutputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s written from the session differs from the detected one", name)
+		}
+	}
+}
+
+// errAny stands for an error of any kind in test tables
+var errAny = errors.New("any error")
+
+func TestLoadSessionFileValidation(t *testing.T) {
+	dir := t.TempDir()
+	sessionPath := filepath.Join(dir, "session.json")
+	fileOffsets, err := audiosync.CalculatePadding([]*audiosync.OffsetResult{
+		{OffsetSamples: 1000, OffsetSeconds: 1000.0 / selftestRate, Confidence: 0.9},
+		{OffsetSamples: 5000, OffsetSeconds: 5000.0 / selftestRate, Confidence: 0.8},
+	}, []string{"alice.wav", "bob.wav"}, selftestRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSessionFile(sessionPath, selftestRate, nil, fileOffsets); err != nil {
+		t.Fatalf("writeSessionFile: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		localPaths []string
+		sampleRate int
+		wantErr    error
+	}{
+		{"missing session", filepath.Join(dir, "missing.json"), []string{"alice.wav", "bob.wav"}, selftestRate, fs.ErrNotExist},
+		{"other sample rate", sessionPath, []string{"alice.wav", "bob.wav"}, 2 * selftestRate, audio.ErrSampleRateMismatch},
+		{"unknown file", sessionPath, []string{"alice.wav", "carol.wav"}, selftestRate, errAny},
+		{"fewer files", sessionPath, []string{"alice.wav"}, selftestRate, errAny},
+	}
+
+	for _, tt := range tests {
+		_, _, err := loadSessionFile(tt.path, tt.localPaths, tt.sampleRate)
+		if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Offsets are matched to the local files by cleaned path, whatever their order
+	got, _, err := loadSessionFile(sessionPath, []string{"./bob.wav", "alice.wav"}, selftestRate)
+	if err != nil {
+		t.Fatalf("loadSessionFile: %v", err)
+	}
+	if got[0].FinalOffsetSamples != 5000 || got[1].FinalOffsetSamples != 1000 || got[0].PaddingSamples != 4000 {
+		t.Errorf("loaded offsets %d and %d (padding %d), want 5000 and 1000 (padding 4000)", got[0].FinalOffsetSamples, got[1].FinalOffsetSamples, got[0].PaddingSamples)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// errUpToDate is returned by a finishSync write callback to leave an existing synced output untouched
+var errUpToDate = errors.New("synced output is up to date")
+
+// knownOffsets returns the offsets that need no detection, keyed by cleaned local path:
+// manual --offset values and the offsets recorded for up-to-date outputs (prior, may be nil)
+func (c *Config) knownOffsets(sampleRate int, prior map[string]*audiosync.FileOffset) map[string]*audiosync.OffsetResult {
+	known := make(map[string]*audiosync.OffsetResult, len(c.ManualOffsets)+len(prior))
+	for path, seconds := range c.ManualOffsets {
+		known[path] = audiosync.ManualOffset(seconds, sampleRate)
+	}
+	for path, fo := range prior {
+		known[path] = &audiosync.OffsetResult{
+			OffsetSamples:  fo.OffsetSamples,
+			OffsetSeconds:  fo.OffsetSeconds,
+			Confidence:     fo.Confidence,
+			PeakToSidelobe: fo.PeakToSidelobe,
+			Inverted:       fo.Inverted,
+			Manual:         fo.Manual,
+		}
+	}
+	return known
+}
+
+// upToDateOffsets reads the previous report and returns the recorded offsets of local files
+// whose synced output exists and is newer than the source, keyed by cleaned path
+// A missing report means nothing can be skipped.
+func upToDateOffsets(config *Config) (map[string]*audiosync.FileOffset, error) {
+	data, err := os.ReadFile(config.ReportPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse previous report %s: %w", config.ReportPath, err)
+	}
+	if report.SchemaVersion != reportSchemaVersion {
+		return nil, nil
+	}
+
+	recorded := make(map[string]*audiosync.FileOffset, len(report.Files))
+	for _, fo := range report.Files {
+		recorded[filepath.Clean(fo.Path)] = fo
+	}
+
+	prior := make(map[string]*audiosync.FileOffset)
+	for _, path := range config.LocalPaths {
+		fo, ok := recorded[filepath.Clean(path)]
+		if ok && config.outputUpToDate(path) {
+			prior[filepath.Clean(path)] = fo
+		}
+	}
+	return prior, nil
+}
+
+// outputUpToDate reports whether the synced output of path exists and is newer than path
+func (c *Config) outputUpToDate(path string) bool {
+	source, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	output, err := os.Stat(c.outputPath(path))
+	if err != nil {
+		return false
+	}
+	return output.ModTime().After(source.ModTime())
+}
+
+// restorePrior replaces the offsets of up-to-date files with the ones recorded in the previous report
+// and recalculates padding, since a newly added file may have become the earliest
+func restorePrior(fileOffsets []*audiosync.FileOffset, prior map[string]*audiosync.FileOffset, sampleRate int) ([]*audiosync.FileOffset, error) {
+	for i, fo := range fileOffsets {
+		recorded, ok := prior[filepath.Clean(fo.Path)]
+		if !ok {
+			continue
+		}
+		restored := *recorded
+		restored.Path = fo.Path
+		restored.TrimSamples, restored.TrimSeconds, restored.PaddingFraction = 0, 0, 0
+		fileOffsets[i] = &restored
+	}
+	return audiosync.RecalculatePadding(fileOffsets, sampleRate)
+}
+
+// unchanged reports whether an up-to-date output would be written exactly as before
+func unchanged(fo *audiosync.FileOffset, prior map[string]*audiosync.FileOffset) bool {
+	recorded, ok := prior[filepath.Clean(fo.Path)]
+	return ok &&
+		recorded.PaddingSamples == fo.PaddingSamples &&
+		recorded.TrimSamples == fo.TrimSamples &&
+		recorded.PaddingFraction == fo.PaddingFraction
+}
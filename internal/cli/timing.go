@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// stageTimer measures the wall-clock time of each workflow stage for --profile
+// When disabled it records nothing, so callers can mark stages unconditionally
+type stageTimer struct {
+	enabled bool
+	start   time.Time
+	last    time.Time
+	names   []string
+	times   []time.Duration
+}
+
+// newStageTimer starts timing the first stage
+func newStageTimer(enabled bool) *stageTimer {
+	now := time.Now()
+	return &stageTimer{enabled: enabled, start: now, last: now}
+}
+
+// mark ends the current stage, naming it, and starts the next one
+func (t *stageTimer) mark(name string) {
+	if !t.enabled {
+		return
+	}
+	now := time.Now()
+	t.names = append(t.names, name)
+	t.times = append(t.times, now.Sub(t.last))
+	t.last = now
+}
+
+// print logs the "Timings:" header and one "stage<TAB>seconds" line per stage and the total
+// The layout is kept stable so the breakdown can be parsed by scripts; with --log-format json
+// each stage is one record with stage and seconds attributes instead
+func (t *stageTimer) print() {
+	if !t.enabled {
+		return
+	}
+	if !jsonLogs {
+		logln("Timings:")
+	}
+	for i, name := range t.names {
+		logStage(name, t.times[i])
+	}
+	logStage("total", t.last.Sub(t.start))
+}
+
+// logStage logs the time one stage took as an info record
+func logStage(name string, d time.Duration) {
+	seconds := math.Round(d.Seconds()*1000) / 1000
+	msg := fmt.Sprintf("%s\t%.3f\n", name, seconds)
+	if jsonLogs {
+		msg = "stage timing"
+	}
+	logger.Info(msg, "stage", name, "seconds", seconds)
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// timedStages returns a timer that has recorded a load and a write stage
+func timedStages() *stageTimer {
+	timer := newStageTimer(true)
+	timer.mark("load")
+	timer.mark("write")
+	return timer
+}
+
+func TestStageTimerPrint(t *testing.T) {
+	out, _ := captureOutput(t)
+	timedStages().print()
+	if !regexp.MustCompile(`^Timings:\nload\t\d+\.\d{3}\nwrite\t\d+\.\d{3}\ntotal\t\d+\.\d{3}\n$`).MatchString(out.String()) {
+		t.Errorf("timings %q do not follow the stage<TAB>seconds layout", out.String())
+	}
+
+	out.Reset()
+	newStageTimer(false).print()
+	setQuiet(1)
+	timedStages().print()
+	if out.Len() != 0 {
+		t.Errorf("disabled or quiet timer printed %q", out.String())
+	}
+}
+
+func TestStageTimerPrintJSON(t *testing.T) {
+	out, _ := captureOutput(t)
+	setLogging(slog.LevelInfo, "json")
+	timedStages().print()
+
+	var stages []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record struct {
+			Level   string
+			Stage   string
+			Seconds *float64
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("record %q is not JSON: %v", line, err)
+		}
+		if record.Level != "INFO" || record.Seconds == nil {
+			t.Errorf("record %q, want an info record with seconds", line)
+		}
+		stages = append(stages, record.Stage)
+	}
+	if got := strings.Join(stages, ","); got != "load,write,total" {
+		t.Errorf("stages %s, want load,write,total", got)
+	}
+}
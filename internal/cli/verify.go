@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyMixedPath  string
+	verifyDownsample int
+	toleranceMs      float64
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [flags] <synced1.wav> [synced2.wav ...]",
+	Short: "Check that synchronized files line up with the mixed source",
+	Long: `Measure the residual offset between the mixed source and files written by clapless.
+
+Each file is correlated against the mixed file again; a well-aligned file starts
+at the same moment as the mixed file, so its residual offset should be near zero.
+Outputs written with --mode trim start later than the mixed file and will not pass.
+
+Example:
+  clapless verify --mixed podcast_mix.wav alice_synced.wav bob_synced.wav
+  clapless verify -m podcast_mix.wav --tolerance-ms 2 alice_synced.wav`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("at least 1 synchronized file is required")
+		}
+
+		// Validate file existence and format
+		if verifyMixedPath != audio.StdinPath {
+			if err := validateFile(verifyMixedPath); err != nil {
+				return fmt.Errorf("mixed file error: %w", err)
+			}
+		}
+		for i, path := range args {
+			if err := validateFile(path); err != nil {
+				return fmt.Errorf("file %d (%s) error: %w", i+1, path, err)
+			}
+		}
+
+		// Validate downsample factor
+		if verifyDownsample < 1 {
+			return fmt.Errorf("downsample factor must be >= 1, got %d", verifyDownsample)
+		}
+
+		// Validate tolerance
+		if toleranceMs <= 0 {
+			return fmt.Errorf("--tolerance-ms must be positive, got %g", toleranceMs)
+		}
+
+		return Verify(cmd.Context(), &Config{
+			MixedPaths:        []string{verifyMixedPath},
+			LocalPaths:        args,
+			SegmentDuration:   600,
+			DownsampleFactor:  verifyDownsample,
+			CorrelationMethod: audiosync.MethodStandard,
+			BandpassLow:       300,
+			BandpassHigh:      3400,
+			Window:            audiosync.WindowTukey,
+		}, toleranceMs)
+	},
+	SilenceUsage: true, // Don't show usage on errors during execution
+}
+
+func init() {
+	verifyCmd.Flags().StringVarP(&verifyMixedPath, "mixed", "m", "", "Path to the mixed audio file (required)")
+	verifyCmd.Flags().IntVarP(&verifyDownsample, "downsample", "d", 10, "Downsample factor for the offset search (lower = more precise but slower)")
+	verifyCmd.Flags().Float64Var(&toleranceMs, "tolerance-ms", 5, "Maximum residual offset in milliseconds for a file to pass")
+
+	verifyCmd.MarkFlagRequired("mixed")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// Verify correlates already-synchronized files with the mixed file and reports each residual offset
+// It returns an error if any residual exceeds toleranceMs
+func Verify(ctx context.Context, config *Config, toleranceMs float64) error {
+	logln("Clapless - Verify Synchronization")
+	logln("=================================")
+	logln()
+
+	logln("Loading files...")
+	mixedFiles, err := loadMixedAudio(config.MixedPaths)
+	if err != nil {
+		return err
+	}
+	mixed := mixedFiles[0]
+
+	localFiles, err := loadLocalAudio(config.LocalPaths, nil)
+	if err != nil {
+		return err
+	}
+	resampleLocalAudio(mixed, localFiles)
+
+	logln()
+
+	logf("Measuring residual offsets (downsample=%d)...\n", config.DownsampleFactor)
+	offsetResults, err := detectOffsetsParallel(ctx, mixed, localFiles, nil, config.detectOptions(), nil, nil, nil, config.newProgress())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, result := range offsetResults {
+		residualMs := result.OffsetSeconds * 1000
+		status := "✓"
+		if math.Abs(residualMs) > toleranceMs {
+			status = "✗"
+			failed++
+		}
+		logf("  %s %s: residual %+.3fms (confidence: %.2f)\n",
+			status,
+			filepath.Base(config.LocalPaths[i]),
+			residualMs,
+			result.Confidence)
+	}
+
+	logln()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files exceed the %.1fms tolerance", failed, len(offsetResults), toleranceMs)
+	}
+
+	logf("All files within %.1fms of the mixed file\n", toleranceMs)
+	return nil
+}
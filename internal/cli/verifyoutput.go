@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+const (
+	verifySegmentSeconds = 10.0 // Length of the source segment looked up in the written file
+	verifyMarginSeconds  = 0.01 // Lag searched on either side of where the segment should be
+	verifyMinConfidence  = 0.95 // Correlation the segment must reach in the written file
+)
+
+// checkWrittenOutput re-reads a written synced file and checks it against the source it was made from:
+// its length must be the source length plus padding (minus trim), and a segment from the middle
+// of the source must be found at exactly its expected position with a correlation close to 1
+// source holds the samples that were aligned (after drift correction) with channels channels; sourceFrames is its length.
+// The written file must have outputChannels channels, which differ from channels with --force-stereo or --force-mono,
+// and end with trailing frames of silence added by --equalize-length. inverted means it was written with --invert-polarity.
+func checkWrittenOutput(ctx context.Context, path string, source []float64, channels, outputChannels, sourceFrames, trailing int, inverted bool, fo *audiosync.FileOffset) error {
+	written, err := audio.LoadAudio(path)
+	if err != nil {
+		return fmt.Errorf("verification could not read %s: %w", path, err)
+	}
+
+	expectedFrames := max(sourceFrames+fo.PaddingSamples-fo.TrimSamples, 0) + trailing
+	if written.Channels != outputChannels {
+		return fmt.Errorf("verification failed: %s has %d channels, expected %d", path, written.Channels, outputChannels)
+	}
+	if frames := len(written.Data) / written.Channels; frames != expectedFrames {
+		return fmt.Errorf("verification failed: %s has %d frames, expected %d", path, frames, expectedFrames)
+	}
+
+	sourceMono, err := audio.ToMono(source, channels)
+	if err != nil {
+		return err
+	}
+	writtenMono, err := audio.ToMono(written.Data, written.Channels)
+	if err != nil {
+		return err
+	}
+
+	// Take the segment from the middle of the kept source audio, away from any fade-in
+	sampleRate := written.SampleRate
+	kept := sourceFrames - fo.TrimSamples
+	length := min(int(verifySegmentSeconds*float64(sampleRate)), kept)
+	if length <= 0 {
+		return nil
+	}
+	sourceStart := fo.TrimSamples + (kept-length)/2
+	writtenStart := sourceStart - fo.TrimSamples + fo.PaddingSamples
+	segment := sourceMono[sourceStart : sourceStart+length]
+	if inverted {
+		segment = audio.InvertPolarity(segment)
+	}
+
+	// Silence has nothing to correlate, so it passes as long as the length is right
+	if !slices.ContainsFunc(segment, func(v float64) bool { return v != 0 }) {
+		return nil
+	}
+
+	// Search a little around the expected position at full resolution
+	margin := max(int(verifyMarginSeconds*float64(sampleRate)), 1)
+	windowStart := max(writtenStart-margin, 0)
+	windowEnd := min(writtenStart+length+margin, len(writtenMono))
+	result, err := audiosync.DetectOffset(ctx, writtenMono[windowStart:windowEnd], segment, sampleRate,
+		audiosync.DetectOptions{DownsampleFactor: 1})
+	if err != nil {
+		return fmt.Errorf("verification of %s failed: %w", path, err)
+	}
+
+	// A fractional delay may move the peak to the neighbouring sample
+	tolerance := 0
+	if fo.PaddingFraction != 0 {
+		tolerance = 1
+	}
+	// The detector also matches a polarity-flipped segment, which is a wrong output here
+	if result.Inverted {
+		return fmt.Errorf("verification failed: %s has the opposite polarity of its source", path)
+	}
+	shift := result.OffsetSamples - (writtenStart - windowStart)
+	if max(shift, -shift) > tolerance || result.Confidence < verifyMinConfidence {
+		return fmt.Errorf("verification failed: %s matches its source %+d samples from the expected position with confidence %.3f (expected the same position with at least %.2f)",
+			path, shift, result.Confidence, verifyMinConfidence)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestCheckWrittenOutput(t *testing.T) {
+	source := selftestSignal(rand.New(rand.NewPCG(7, 8)), 20*selftestRate)
+	fo := &audiosync.FileOffset{PaddingSamples: 1000}
+	aligned := audio.PrependSilence(source, fo.PaddingSamples)
+	middle := fo.PaddingSamples + len(source)/2
+
+	tests := []struct {
+		name     string
+		written  func() []float64
+		inverted bool
+		wantErr  bool
+	}{
+		{"correct", func() []float64 { return aligned }, false, false},
+		{"inverted as asked", func() []float64 { return audio.InvertPolarity(aligned) }, true, false},
+		{"truncated", func() []float64 { return aligned[:len(aligned)-100] }, false, true},
+		{"shifted by a few samples", func() []float64 {
+			return append(make([]float64, 3), aligned[:len(aligned)-3]...)
+		}, false, true},
+		{"corrupted middle", func() []float64 {
+			corrupted := slices.Clone(aligned)
+			noise := selftestSignal(rand.New(rand.NewPCG(9, 10)), 4*selftestRate)
+			copy(corrupted[middle-len(noise)/2:], noise)
+			return corrupted
+		}, false, true},
+		{"inverted by mistake", func() []float64 { return audio.InvertPolarity(aligned) }, false, true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "synced.wav")
+		if err := audio.WriteWAV(path, tt.written(), selftestRate, 1, 32, true); err != nil {
+			t.Fatal(err)
+		}
+		err := checkWrittenOutput(context.Background(), path, source, 1, 1, len(source), 0, tt.inverted, fo)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
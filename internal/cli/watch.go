@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchMixedPath  string
+	watchDir        string
+	watchDownsample int
+	watchInterval   time.Duration
+	watchSettle     time.Duration
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [flags]",
+	Short: "Synchronize local files as they appear in a directory",
+	Long: `Watch a directory and synchronize the audio files dropped into it with the mixed source.
+
+The directory is checked every --interval. A new file is used once its size and
+modification time have not changed for --settle, so files still being copied or
+exported are left alone until they are complete. Each time files become ready,
+all ready files are synchronized together, so earlier outputs are rewritten if a
+new file starts before them. _synced outputs and the mixed file are ignored.
+Stop watching with Ctrl-C.
+
+Example:
+  clapless watch --mixed podcast_mix.wav --dir ./incoming
+  clapless watch -m podcast_mix.wav --dir ./incoming --settle 5s`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("watch takes no file arguments, local files are read from --dir")
+		}
+
+		// Validate the mixed file and the watched directory
+		if err := validateFile(watchMixedPath); err != nil {
+			return fmt.Errorf("mixed file error: %w", err)
+		}
+		info, err := os.Stat(watchDir)
+		if err != nil {
+			return fmt.Errorf("cannot access --dir: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("--dir is not a directory: %s", watchDir)
+		}
+
+		// Validate downsample factor and timing
+		if watchDownsample < 1 {
+			return fmt.Errorf("downsample factor must be >= 1, got %d", watchDownsample)
+		}
+		if watchInterval <= 0 {
+			return fmt.Errorf("--interval must be positive, got %s", watchInterval)
+		}
+		if watchSettle < 0 {
+			return fmt.Errorf("--settle must not be negative, got %s", watchSettle)
+		}
+
+		return Watch(cmd.Context(), watchMixedPath, watchDir, watchDownsample, watchInterval, watchSettle)
+	},
+	SilenceUsage: true, // Don't show usage on errors during execution
+}
+
+func init() {
+	watchCmd.Flags().StringVarP(&watchMixedPath, "mixed", "m", "", "Path to the mixed audio file (required)")
+	watchCmd.Flags().StringVar(&watchDir, "dir", "", "Directory to watch for local audio files (required)")
+	watchCmd.Flags().IntVarP(&watchDownsample, "downsample", "d", 50, "Downsample factor for coarse offset search (higher = faster but less accurate)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Second, "How often to check the directory for new files")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 2*time.Second, "How long a file's size must stay unchanged before it is synchronized")
+
+	watchCmd.MarkFlagRequired("mixed")
+	watchCmd.MarkFlagRequired("dir")
+	rootCmd.AddCommand(watchCmd)
+}
+
+// Watch polls dir every interval and synchronizes the local files that have settled with the mixed file
+// It runs until ctx is cancelled, which is not an error. Files that fail are reported and not retried
+// unless they change.
+func Watch(ctx context.Context, mixedPath, dir string, downsample int, interval, settle time.Duration) error {
+	logf("Watching %s for local files (Ctrl-C to stop)...\n", dir)
+	logln()
+
+	stable := newStabilizer(settle)
+	var ready []string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		files, err := watchedFiles(dir, mixedPath)
+		if err != nil {
+			return err
+		}
+		if settled := stable.observe(time.Now(), files); len(settled) > 0 {
+			// A file that changed after it was synchronized settles again but is only listed once
+			ready = append(ready, settled...)
+			slices.Sort(ready)
+			ready = slices.Compact(ready)
+			ready = syncWatched(ctx, mixedPath, ready, downsample)
+		}
+
+		select {
+		case <-ctx.Done():
+			logln("Stopped watching")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncWatched synchronizes the ready files with the mixed file and returns those that did not fail
+// A failure is only reported, so watching continues.
+func syncWatched(ctx context.Context, mixedPath string, ready []string, downsample int) []string {
+	config := &Config{
+		MixedPaths:          []string{mixedPath},
+		LocalPaths:          slices.Clone(ready),
+		SegmentDuration:     600,
+		DownsampleFactor:    downsample,
+		AutoResolutionMs:    audiosync.DefaultAutoResolutionMs,
+		CorrelationMethod:   audiosync.MethodStandard,
+		BandpassLow:         300,
+		BandpassHigh:        3400,
+		Mode:                audiosync.ModePad,
+		OutputSuffix:        render.DefaultSuffix,
+		ReportFormat:        reportJSON,
+		DumpCorrelationStep: 1,
+		ContinueOnError:     true,
+		Window:              audiosync.WindowTukey,
+		CombineLayout:       combineMono,
+		FinetuneTarget:      60,
+		FinetuneMin:         30,
+	}
+	if err := Run(ctx, config); err != nil && ctx.Err() == nil {
+		warnf("⚠️  %v\n", err)
+	}
+	logln()
+
+	if len(config.Failed) == 0 {
+		return ready
+	}
+	// Leave out the failed files until they change
+	kept := ready[:0]
+	for _, path := range ready {
+		if !slices.ContainsFunc(config.Failed, func(f FailedFile) bool { return f.Path == path }) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// watchedFiles returns the supported audio files in dir with their current state, leaving out
+// the mixed file and _synced outputs
+func watchedFiles(dir, mixedPath string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	files := make(map[string]os.FileInfo)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || !audio.IsSupported(path) || strings.HasSuffix(name, render.DefaultSuffix) || sameFile(path, mixedPath) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since it was listed
+		}
+		files[path] = info
+	}
+	return files, nil
+}
+
+// fileState is the size and modification time a watched file was last seen with, and since when
+type fileState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+	done    bool // Already handed out as settled
+}
+
+// stabilizer tells when watched files have stopped growing
+// A file settles once its size and modification time have stayed the same for settle and it is not empty.
+// A settled file that changes again (such as one exported a second time) settles again once it stops.
+type stabilizer struct {
+	settle time.Duration
+	files  map[string]*fileState
+}
+
+// newStabilizer returns a stabilizer that waits settle for each file
+func newStabilizer(settle time.Duration) *stabilizer {
+	return &stabilizer{settle: settle, files: make(map[string]*fileState)}
+}
+
+// observe records the files seen at now and returns those that have just settled, sorted by path
+// Files missing from files are forgotten.
+func (s *stabilizer) observe(now time.Time, files map[string]os.FileInfo) []string {
+	for path := range s.files {
+		if _, ok := files[path]; !ok {
+			delete(s.files, path)
+		}
+	}
+
+	var settled []string
+	for path, info := range files {
+		state, ok := s.files[path]
+		if !ok || state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+			s.files[path] = &fileState{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if !state.done && info.Size() > 0 && now.Sub(state.since) >= s.settle {
+			state.done = true
+			settled = append(settled, path)
+		}
+	}
+	slices.Sort(settled)
+	return settled
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStabilizerGrowingFile(t *testing.T) {
+	dir := t.TempDir()
+	growing := filepath.Join(dir, "guest.wav")
+	empty := filepath.Join(dir, "empty.wav")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	appendBytes := func(n int) {
+		f, err := os.OpenFile(growing, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.Write(make([]byte, n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An export writes the file in bursts, then leaves it alone; the stabilizer is polled every second
+	stable := newStabilizer(2 * time.Second)
+	start := time.Unix(1_700_000_000, 0)
+	steps := []struct {
+		write int      // Bytes appended before the poll
+		want  []string // Files that settle at the poll
+	}{
+		{1000, nil},
+		{1000, nil},
+		{1000, nil},
+		{0, nil}, // Unchanged for 1s
+		{0, []string{growing}},
+		{0, nil}, // Handed out once
+		{500, nil},
+		{0, nil},
+		{0, []string{growing}}, // Written again, so it settles again
+	}
+	for i, step := range steps {
+		if step.write > 0 {
+			appendBytes(step.write)
+		}
+		files, err := watchedFiles(dir, filepath.Join(dir, "mix.wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The empty file never settles however long it stays unchanged
+		if got := stable.observe(start.Add(time.Duration(i)*time.Second), files); !slices.Equal(got, step.want) {
+			t.Errorf("poll %d: settled %v, want %v", i, got, step.want)
+		}
+	}
+
+	// A removed file is forgotten, so it starts over if it appears again
+	if err := os.Remove(growing); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := watchedFiles(dir, filepath.Join(dir, "mix.wav"))
+	stable.observe(start.Add(20*time.Second), files)
+	appendBytes(100)
+	files, _ = watchedFiles(dir, filepath.Join(dir, "mix.wav"))
+	for _, at := range []time.Duration{21, 22} {
+		if got := stable.observe(start.Add(at*time.Second), files); len(got) != 0 {
+			t.Errorf("recreated file settled after %ds: %v", at-21, got)
+		}
+	}
+	if got := stable.observe(start.Add(23*time.Second), files); !slices.Equal(got, []string{growing}) {
+		t.Errorf("recreated file: settled %v, want %v", got, []string{growing})
+	}
+}
+
+func TestWatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"mix.wav", "alice.wav", "bob.flac", "alice_synced.wav", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.wav"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := watchedFiles(dir, filepath.Join(dir, "mix.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for path := range files {
+		names = append(names, filepath.Base(path))
+	}
+	slices.Sort(names)
+	if want := []string{"alice.wav", "bob.flac"}; !slices.Equal(names, want) {
+		t.Errorf("watched %v, want %v", names, want)
+	}
+}
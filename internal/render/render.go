@@ -0,0 +1,179 @@
+// Package render turns a local file and its alignment into the audio of its synced output.
+//
+// The clapless command and the clapless library both write their outputs through it,
+// so the same options give the same files.
+package render
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// LoudnessCeilingDBTP is the true-peak level TargetLUFS never raises an output above
+const LoudnessCeilingDBTP = -1.0
+
+// DefaultSuffix is appended to the name of each input for its synced output
+const DefaultSuffix = "_synced"
+
+// Options controls how synced outputs are rendered
+type Options struct {
+	FadeInMs        float64       // Fade-in length after padding or trimming in milliseconds (0 = none)
+	BitDepth        int           // Output bit depth: 16, 24 or 32 (0 = keep each file's own depth)
+	FloatOutput     bool          // Write 32-bit IEEE float (overrides BitDepth)
+	SampleRateOut   int           // Resample every output to this rate in Hz after alignment (0 = keep the processing rate)
+	TargetLUFS      float64       // Bring each output to this integrated loudness in LUFS (0 = keep the level)
+	NormalizeOutput bool          // Scale outputs that would clip down to full scale instead of clamping them (integer output only)
+	OutputChannels  int           // Convert every output to 1 or 2 channels (0 = keep each file's own)
+	Mixdown         audio.Mixdown // How wider outputs are collapsed for OutputChannels
+	InvertPolarity  bool          // Flip the sign of every output sample
+}
+
+// Loudness is the level change TargetLUFS made to an output
+type Loudness struct {
+	Before  float64 // Integrated loudness in LUFS before the gain (-Inf = too short or quiet to measure; level unchanged)
+	GainDB  float64 // Applied gain in dB
+	Limited bool    // The gain was held back by the true-peak ceiling
+}
+
+// Output is the rendered audio of one synced file, still in the channel layout of its source
+type Output struct {
+	Data       []float64
+	SampleRate int
+	Channels   int
+	BitDepth   int
+	Float      bool
+	Loudness   *Loudness       // Level change from TargetLUFS (nil = not set)
+	Clip       audio.ClipStats // Samples integer output would clamp, measured before NormalizeOutput (zero for float output)
+	Normalized bool            // The output was scaled down to full scale instead of clipping
+}
+
+// Render aligns source by fo, then resamples it and adjusts its level as opts ask
+// Channel layout and polarity are left to Layout, so callers can mix tracks in their source layout first.
+// It may modify source.Data, which is not used again after writing.
+func Render(source *audio.WAVData, fo *audiosync.FileOffset, opts Options) *Output {
+	out := &Output{
+		Data:       Align(source, fo, opts.FadeFrames(fo, source.SampleRate)),
+		SampleRate: opts.Rate(source.SampleRate),
+		Channels:   source.Channels,
+	}
+	out.BitDepth, out.Float = opts.Format(source)
+	out.Data = audio.Resample(out.Data, source.SampleRate, out.SampleRate, out.Channels)
+
+	if opts.TargetLUFS != 0 {
+		gain, loudness, limited := audio.LoudnessGain(out.Data, out.SampleRate, out.Channels, opts.TargetLUFS, LoudnessCeilingDBTP)
+		out.Loudness = &Loudness{Before: loudness}
+		if !math.IsInf(loudness, -1) {
+			out.Loudness.GainDB, out.Loudness.Limited = 20*math.Log10(gain), limited
+			out.Data = audio.ApplyGain(out.Data, gain)
+		}
+	}
+
+	// Integer output clamps samples beyond full scale unless the whole file is scaled down
+	if !out.Float {
+		out.Clip = audio.MeasureClipping(out.Data)
+		if out.Clip.Samples > 0 && opts.NormalizeOutput {
+			out.Data, out.Normalized = audio.NormalizePeak(out.Data, 1), true
+		}
+	}
+	return out
+}
+
+// Layout converts data with the given channel count to OutputChannels and flips its polarity as opts ask,
+// returning the data and channel count to write
+func (o Options) Layout(data []float64, channels int) ([]float64, int, error) {
+	if o.OutputChannels != 0 {
+		forced, err := audio.ToChannels(data, channels, o.OutputChannels, o.Mixdown)
+		if err != nil {
+			return nil, 0, err
+		}
+		data, channels = forced, o.OutputChannels
+	}
+	if o.InvertPolarity {
+		data = audio.InvertPolarity(data)
+	}
+	return data, channels, nil
+}
+
+// Format returns the bit depth and float flag used to write the synced copy of source
+func (o Options) Format(source *audio.WAVData) (int, bool) {
+	if o.FloatOutput {
+		return 32, true
+	}
+	if o.BitDepth != 0 {
+		return o.BitDepth, false
+	}
+	return source.BitDepth, source.Float
+}
+
+// Rate returns the sample rate outputs of audio processed at sampleRate are written at
+// Padding and trim are applied at the processing rate and the aligned result is resampled as a whole.
+func (o Options) Rate(sampleRate int) int {
+	if o.SampleRateOut != 0 {
+		return o.SampleRateOut
+	}
+	return sampleRate
+}
+
+// FadeFrames returns the fade-in length for a file's output
+// Only outputs with a new start (padding or trimming) are faded; an untouched start is left as recorded
+func (o Options) FadeFrames(fo *audiosync.FileOffset, sampleRate int) int {
+	if fo.PaddingSamples == 0 && fo.TrimSamples == 0 {
+		return 0
+	}
+	return int(o.FadeInMs / 1000 * float64(sampleRate))
+}
+
+// Align returns the local audio with padding prepended or leading samples trimmed
+// fadeFrames ramps in the local audio where it meets the padding or trimmed start
+// (it may modify localData.Data, which is not used again after writing)
+func Align(localData *audio.WAVData, fo *audiosync.FileOffset, fadeFrames int) []float64 {
+	// Delay by the sub-sample part of the alignment (--fractional-delay)
+	syncedData := localData.Data
+	if fo.PaddingFraction != 0 {
+		syncedData = audio.FractionalDelay(syncedData, fo.PaddingFraction, localData.Channels)
+	}
+
+	// Prepend silence if needed
+	if fo.PaddingSamples > 0 {
+		// For multi-channel audio, we need to prepend silence for each channel
+		silenceSamples := fo.PaddingSamples * localData.Channels
+		syncedData = audio.PrependSilence(syncedData, silenceSamples)
+	}
+
+	// Remove leading samples if needed (trim mode)
+	if fo.TrimSamples > 0 {
+		syncedData = audio.TrimLeading(syncedData, fo.TrimSamples, localData.Channels)
+	}
+
+	// Fade in the first local samples after the new start
+	if fadeFrames > 0 {
+		audio.FadeIn(syncedData[fo.PaddingSamples*localData.Channels:], fadeFrames, localData.Channels)
+	}
+
+	return syncedData
+}
+
+// OutputPath creates the output file path of originalPath in dir (empty = next to the input)
+// The file is named by pattern with {name} and {ext} replaced by the input's name and extension,
+// or is the input's name followed by suffix if pattern is empty.
+// Output keeps the input format when it can be written (WAV, AIFF, FLAC) and is WAV otherwise
+func OutputPath(originalPath, dir, suffix, pattern string) string {
+	if dir == "" {
+		dir = filepath.Dir(originalPath)
+	}
+	base := filepath.Base(originalPath)
+	ext := filepath.Ext(base)
+	nameWithoutExt := strings.TrimSuffix(base, ext)
+
+	if !audio.CanWrite(originalPath) {
+		ext = ".wav"
+	}
+	if pattern == "" {
+		return filepath.Join(dir, nameWithoutExt+suffix+ext)
+	}
+	return filepath.Join(dir, strings.NewReplacer("{name}", nameWithoutExt, "{ext}", ext).Replace(pattern))
+}
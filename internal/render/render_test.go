@@ -0,0 +1,139 @@
+package render
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		name                 string
+		source               string
+		dir, suffix, pattern string
+		want                 string
+	}{
+		{"default", "rec/alice.wav", "", "_synced", "", "rec/alice_synced.wav"},
+		{"keeps AIFF", "rec/alice.aiff", "", "_synced", "", "rec/alice_synced.aiff"},
+		{"MP3 becomes WAV", "rec/alice.mp3", "", "_synced", "", "rec/alice_synced.wav"},
+		{"custom suffix", "rec/alice.wav", "", "-aligned", "", "rec/alice-aligned.wav"},
+		{"directory", "rec/alice.wav", "out", "_synced", "", "out/alice_synced.wav"},
+		{"directory without suffix", "rec/alice.flac", "out", "", "", "out/alice.flac"},
+		{"pattern", "rec/alice.wav", "", "_synced", "{name}.aligned{ext}", "rec/alice.aligned.wav"},
+		{"pattern with directory", "rec/alice.mp3", "out", "_synced", "take1-{name}{ext}", "out/take1-alice.wav"},
+		{"pattern with fixed extension", "rec/alice.wav", "", "", "{name}.flac", "rec/alice.flac"},
+	}
+
+	for _, tt := range tests {
+		got := OutputPath(filepath.FromSlash(tt.source), filepath.FromSlash(tt.dir), tt.suffix, tt.pattern)
+		if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("%s: OutputPath = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+// constant returns frames of stereo audio at level v in both channels
+func constant(v float64, frames int) *audio.WAVData {
+	data := make([]float64, frames*2)
+	for i := range data {
+		data[i] = v
+	}
+	return &audio.WAVData{Data: data, SampleRate: 8000, Channels: 2, BitDepth: 16}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name           string
+		level          float64
+		fo             audiosync.FileOffset
+		opts           Options
+		wantFrames     int
+		wantFirst      float64 // First sample after the padding
+		wantPeak       float64
+		wantClipped    int
+		wantNormalized bool
+	}{
+		{"padded", 0.5, audiosync.FileOffset{PaddingSamples: 100}, Options{}, 1100, 0.5, 0.5, 0, false},
+		{"trimmed", 0.5, audiosync.FileOffset{TrimSamples: 100}, Options{}, 900, 0.5, 0.5, 0, false},
+		{"faded in", 0.5, audiosync.FileOffset{PaddingSamples: 100}, Options{FadeInMs: 10}, 1100, 0, 0.5, 0, false},
+		{"untouched start is not faded", 0.5, audiosync.FileOffset{}, Options{FadeInMs: 10}, 1000, 0.5, 0.5, 0, false},
+		{"resampled", 0.5, audiosync.FileOffset{PaddingSamples: 100}, Options{SampleRateOut: 16000}, 2200, 0, 0.5, 0, false},
+		{"clipped", 1.5, audiosync.FileOffset{}, Options{}, 1000, 1.5, 1.5, 2000, false},
+		{"normalized", 1.5, audiosync.FileOffset{}, Options{NormalizeOutput: true}, 1000, 1, 1, 2000, true},
+		{"float does not clip", 1.5, audiosync.FileOffset{}, Options{FloatOutput: true, NormalizeOutput: true}, 1000, 1.5, 1.5, 0, false},
+	}
+
+	for _, tt := range tests {
+		out := Render(constant(tt.level, 1000), &tt.fo, tt.opts)
+		if frames := len(out.Data) / out.Channels; frames != tt.wantFrames {
+			t.Errorf("%s: %d frames, want %d", tt.name, frames, tt.wantFrames)
+			continue
+		}
+		padding := audio.ResampledFrames(tt.fo.PaddingSamples, 8000, out.SampleRate)
+		if tt.opts.SampleRateOut == 0 && math.Abs(out.Data[2*padding]-tt.wantFirst) > 1e-9 {
+			t.Errorf("%s: first sample %g, want %g", tt.name, out.Data[2*padding], tt.wantFirst)
+		}
+		if peak := audio.MeasureClipping(out.Data).Peak; math.Abs(peak-tt.wantPeak) > 0.01 {
+			t.Errorf("%s: peak %g, want %g", tt.name, peak, tt.wantPeak)
+		}
+		if out.Clip.Samples != tt.wantClipped || out.Normalized != tt.wantNormalized {
+			t.Errorf("%s: %d samples clipped (normalized %v), want %d (%v)", tt.name, out.Clip.Samples, out.Normalized, tt.wantClipped, tt.wantNormalized)
+		}
+	}
+}
+
+func TestRenderLoudness(t *testing.T) {
+	source := &audio.WAVData{Data: make([]float64, 2*8000*5), SampleRate: 8000, Channels: 2, BitDepth: 16}
+	for i := range len(source.Data) / 2 {
+		v := 0.1 * math.Sin(2*math.Pi*1000*float64(i)/8000)
+		source.Data[2*i], source.Data[2*i+1] = v, v
+	}
+	before := audio.MeasureLUFS(source.Data, source.SampleRate, source.Channels)
+
+	out := Render(source, &audiosync.FileOffset{}, Options{TargetLUFS: -16})
+	if out.Loudness == nil || math.Abs(out.Loudness.Before-before) > 0.1 {
+		t.Fatalf("loudness %+v, want measured at %.1f LUFS", out.Loudness, before)
+	}
+	if got := audio.MeasureLUFS(out.Data, out.SampleRate, out.Channels); math.Abs(got-(before+out.Loudness.GainDB)) > 0.1 {
+		t.Errorf("rendered at %.1f LUFS, want %.1f", got, before+out.Loudness.GainDB)
+	}
+
+	silent := Render(&audio.WAVData{Data: make([]float64, 200), SampleRate: 8000, Channels: 2}, &audiosync.FileOffset{}, Options{TargetLUFS: -16})
+	if !math.IsInf(silent.Loudness.Before, -1) || silent.Loudness.GainDB != 0 {
+		t.Errorf("silent output loudness %+v, want unmeasured and unchanged", silent.Loudness)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	stereo := []float64{1, 0.5, -1, -0.5}
+	tests := []struct {
+		name         string
+		opts         Options
+		want         []float64
+		wantChannels int
+	}{
+		{"unchanged", Options{}, []float64{1, 0.5, -1, -0.5}, 2},
+		{"mono", Options{OutputChannels: 1}, []float64{0.75, -0.75}, 1},
+		{"inverted", Options{InvertPolarity: true}, []float64{-1, -0.5, 1, 0.5}, 2},
+	}
+
+	for _, tt := range tests {
+		got, channels, err := tt.opts.Layout(append([]float64(nil), stereo...), 2)
+		if err != nil {
+			t.Fatalf("%s: Layout: %v", tt.name, err)
+		}
+		if channels != tt.wantChannels || len(got) != len(tt.want) {
+			t.Errorf("%s: %v with %d channels, want %v with %d", tt.name, got, channels, tt.want, tt.wantChannels)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
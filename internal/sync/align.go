@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+// AlignStages are the steps of an alignment that depend on where the audio comes from
+// The command loads, caches and streams files in several ways, but all of them run through Align.
+type AlignStages struct {
+	// Detect finds the coarse offset of every local track and returns the offsets with the names of the tracks, in order
+	Detect func(ctx context.Context) ([]*OffsetResult, []string, error)
+	// Coarse, if not nil, is called with the coarse alignment before fine-tuning (e.g. to report it)
+	Coarse func(fileOffsets []*FileOffset) error
+	// Finetune refines the coarse alignment and recalculates the padding, as FinetuneOffsets does
+	Finetune func(ctx context.Context, fileOffsets []*FileOffset) ([]*FileOffset, error)
+	// FinetuneFailed, if not nil, is told why fine-tuning failed, and the coarse alignment is returned instead of the error
+	FinetuneFailed func(err error)
+}
+
+// Align runs coarse detection, padding from the coarse offsets and fine-tuning with the given stages
+// If ctx is cancelled ctx.Err() is returned.
+func Align(ctx context.Context, sampleRate int, stages AlignStages) ([]*FileOffset, error) {
+	// Step 1: Detect coarse offsets
+	offsetResults, paths, err := stages.Detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: Calculate padding from the coarse offsets
+	fileOffsets, err := CalculatePadding(offsetResults, paths, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	if stages.Coarse != nil {
+		if err := stages.Coarse(fileOffsets); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 3: Fine-tune at full resolution (files that cannot be fine-tuned keep their coarse offset)
+	finetuned, err := stages.Finetune(ctx, fileOffsets)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	} else if err != nil {
+		if stages.FinetuneFailed == nil {
+			return nil, err
+		}
+		stages.FinetuneFailed(err)
+		return fileOffsets, nil
+	}
+	return finetuned, nil
+}
+
+// AlignBuffers aligns mono local buffers against a mono mixed buffer without any file I/O
+// It runs coarse detection (in parallel), fine-tuning and padding like the clapless command.
+// All buffers must share sampleRate. The returned FileOffset paths are "local 1", "local 2", ...
+// in the order of locals; callers working with files can replace them.
+func AlignBuffers(ctx context.Context, mixed []float64, locals [][]float64, sampleRate int, opts DetectOptions) ([]*FileOffset, error) {
+	if len(locals) == 0 {
+		return nil, fmt.Errorf("no local buffers provided")
+	}
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("sample rate must be positive, got %d", sampleRate)
+	}
+
+	paths := make([]string, len(locals))
+	localFiles := make([]*audio.WAVData, len(locals))
+	for i, local := range locals {
+		paths[i] = fmt.Sprintf("local %d", i+1)
+		localFiles[i] = &audio.WAVData{Path: paths[i], SampleRate: sampleRate, Channels: 1, Data: local}
+	}
+
+	return Align(ctx, sampleRate, AlignStages{
+		// Detect coarse offsets in parallel, at most opts.Workers() at once
+		// Each goroutine only writes its own index, so no locking is needed
+		Detect: func(ctx context.Context) ([]*OffsetResult, []string, error) {
+			offsetResults := make([]*OffsetResult, len(locals))
+			errs := make([]error, len(locals))
+			ForEachLimited(len(locals), opts.Workers(), func(idx int) {
+				offsetResults[idx], errs[idx] = opts.CoarseDetector().Detect(ctx, mixed, locals[idx], sampleRate)
+			})
+
+			for i, err := range errs {
+				if err == nil && offsetResults[i].Silent {
+					err = ErrSilentInput
+				}
+				if err != nil {
+					return nil, nil, fmt.Errorf("offset detection failed for local %d: %w", i+1, err)
+				}
+			}
+			return offsetResults, paths, nil
+		},
+		Finetune: func(ctx context.Context, fileOffsets []*FileOffset) ([]*FileOffset, error) {
+			return FinetuneOffsets(ctx, mixed, localFiles, fileOffsets, sampleRate, opts, nil)
+		},
+	})
+}
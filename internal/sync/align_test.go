@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAlignStages(t *testing.T) {
+	errFinetune := errors.New("fine-tuning failed")
+	detect := func(ctx context.Context) ([]*OffsetResult, []string, error) {
+		return []*OffsetResult{{OffsetSamples: 100, Confidence: 1}, {OffsetSamples: 400, Confidence: 1}}, []string{"a.wav", "b.wav"}, nil
+	}
+	failing := func(ctx context.Context, fileOffsets []*FileOffset) ([]*FileOffset, error) {
+		return nil, errFinetune
+	}
+
+	// Without FinetuneFailed the error is returned
+	if _, err := Align(context.Background(), testRate, AlignStages{Detect: detect, Finetune: failing}); !errors.Is(err, errFinetune) {
+		t.Errorf("Align = %v, want the fine-tuning error", err)
+	}
+
+	// With it the coarse alignment is kept, padded from the coarse offsets
+	var coarse, reported []*FileOffset
+	fileOffsets, err := Align(context.Background(), testRate, AlignStages{
+		Detect:         detect,
+		Coarse:         func(fileOffsets []*FileOffset) error { coarse = fileOffsets; return nil },
+		Finetune:       failing,
+		FinetuneFailed: func(err error) { reported = coarse },
+	})
+	if err != nil {
+		t.Fatalf("Align: %v", err)
+	}
+	if reported == nil || len(fileOffsets) != 2 || fileOffsets[0] != coarse[0] {
+		t.Fatalf("Align = %+v, want the coarse alignment after reporting the failure", fileOffsets)
+	}
+	if fileOffsets[1].Path != "b.wav" || fileOffsets[1].PaddingSamples != 300 || !fileOffsets[0].IsEarliest {
+		t.Errorf("b.wav padding %d (a.wav earliest %v), want 300 after a.wav", fileOffsets[1].PaddingSamples, fileOffsets[0].IsEarliest)
+	}
+
+	// Cancellation wins over a fallback to the coarse alignment
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Align(ctx, testRate, AlignStages{Detect: detect, Finetune: failing, FinetuneFailed: func(error) {}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Align = %v, want context.Canceled", err)
+	}
+}
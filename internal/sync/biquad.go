@@ -0,0 +1,198 @@
+package sync
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Biquad holds the coefficients of a second-order IIR section, normalized so a0 = 1
+type Biquad struct {
+	B0, B1, B2 float64 // Feed-forward coefficients
+	A1, A2     float64 // Feedback coefficients
+}
+
+// applyBiquad returns data filtered by one biquad section (transposed direct form II, starting at rest)
+func applyBiquad(data []float64, coeffs Biquad) []float64 {
+	result := make([]float64, len(data))
+	z1, z2 := 0.0, 0.0
+	for i, x := range data {
+		y := coeffs.B0*x + z1
+		z1 = coeffs.B1*x - coeffs.A1*y + z2
+		z2 = coeffs.B2*x - coeffs.A2*y
+		result[i] = y
+	}
+	return result
+}
+
+// FilterType is the response of one pre-filter stage
+type FilterType string
+
+const (
+	FilterLowpass   FilterType = "lowpass"   // Removes content above Freq
+	FilterHighpass  FilterType = "highpass"  // Removes content below Freq
+	FilterPeaking   FilterType = "peaking"   // Boosts or cuts a band around Freq by GainDB
+	FilterLowShelf  FilterType = "lowshelf"  // Boosts or cuts content below Freq by GainDB
+	FilterHighShelf FilterType = "highshelf" // Boosts or cuts content above Freq by GainDB
+)
+
+// FilterStage describes one biquad of a pre-filter by its response rather than its coefficients,
+// so it can be designed for whatever rate the signal is correlated at
+type FilterStage struct {
+	Type   FilterType `json:"type"`
+	Freq   float64    `json:"freq"`    // Cutoff, center or shelf frequency in Hz
+	GainDB float64    `json:"gain_db"` // Boost (positive) or cut in dB of peaking and shelf stages
+	Q      float64    `json:"q"`       // Quality factor (bandwidth of peaking stages, resonance of the others)
+}
+
+// PreFilter is a chain of biquad stages applied to both signals before correlation to even out
+// differences between their frequency responses, such as a close mic's boom missing from the mixed feed
+// Filtering both signals the same way delays them equally, so the offset between them is unchanged.
+type PreFilter []FilterStage
+
+// preFilterPresets are the chains --pre-filter accepts by name
+var preFilterPresets = map[string]PreFilter{
+	// Keeps the consonants and formants every mic picks up and tames the low end where mics differ most
+	"speech": {
+		{Type: FilterHighpass, Freq: 100, Q: math.Sqrt2 / 2},
+		{Type: FilterLowShelf, Freq: 250, GainDB: -6, Q: math.Sqrt2 / 2},
+		{Type: FilterPeaking, Freq: 2500, GainDB: 4, Q: 1},
+	},
+	// Cuts the proximity-effect boom of a close dynamic mic
+	"de-boom": {
+		{Type: FilterLowShelf, Freq: 250, GainDB: -12, Q: math.Sqrt2 / 2},
+	},
+	// Cuts the hiss of a noisy recorder or preamp
+	"de-hiss": {
+		{Type: FilterHighShelf, Freq: 5000, GainDB: -12, Q: math.Sqrt2 / 2},
+	},
+}
+
+// ParsePreFilter converts a preset name or a comma-separated list of stages into a PreFilter
+// A stage is type:freq for lowpass and highpass and type:freq:gain for peaking and shelf stages,
+// optionally followed by :q (default 0.707, or 1 for peaking). An empty string is no filter.
+func ParsePreFilter(s string) (PreFilter, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if preset, ok := preFilterPresets[s]; ok {
+		return preset, nil
+	}
+
+	var filter PreFilter
+	for _, spec := range strings.Split(s, ",") {
+		stage, err := parseFilterStage(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pre-filter stage %q: %w", spec, err)
+		}
+		filter = append(filter, stage)
+	}
+	return filter, nil
+}
+
+// parseFilterStage parses one type:freq[:gain][:q] stage of ParsePreFilter
+func parseFilterStage(spec string) (FilterStage, error) {
+	fields := strings.Split(strings.TrimSpace(spec), ":")
+	stage := FilterStage{Type: FilterType(fields[0]), Q: math.Sqrt2 / 2}
+
+	wantGain := false
+	switch stage.Type {
+	case FilterLowpass, FilterHighpass:
+	case FilterPeaking:
+		wantGain, stage.Q = true, 1
+	case FilterLowShelf, FilterHighShelf:
+		wantGain = true
+	default:
+		return FilterStage{}, fmt.Errorf("unknown type %q (expected a preset %s, or %s, %s, %s, %s or %s)", fields[0],
+			strings.Join(PreFilterPresets(), ", "), FilterLowpass, FilterHighpass, FilterPeaking, FilterLowShelf, FilterHighShelf)
+	}
+
+	values := make([]float64, len(fields)-1)
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return FilterStage{}, fmt.Errorf("%q is not a number", field)
+		}
+		values[i] = v
+	}
+	required := 1
+	if wantGain {
+		required = 2
+	}
+	if len(values) < required || len(values) > required+1 {
+		if wantGain {
+			return FilterStage{}, fmt.Errorf("%s needs a frequency and a gain in dB, and optionally a Q", stage.Type)
+		}
+		return FilterStage{}, fmt.Errorf("%s needs a frequency, and optionally a Q", stage.Type)
+	}
+
+	stage.Freq = values[0]
+	if wantGain {
+		stage.GainDB = values[1]
+	}
+	if len(values) > required {
+		stage.Q = values[required]
+	}
+	if stage.Freq <= 0 {
+		return FilterStage{}, fmt.Errorf("frequency must be positive, got %g", stage.Freq)
+	}
+	if stage.Q <= 0 {
+		return FilterStage{}, fmt.Errorf("Q must be positive, got %g", stage.Q)
+	}
+	return stage, nil
+}
+
+// PreFilterPresets returns the names of the built-in pre-filters in alphabetical order
+func PreFilterPresets() []string {
+	return slices.Sorted(maps.Keys(preFilterPresets))
+}
+
+// design computes the coefficients of the stage at sampleRate (Robert Bristow-Johnson's audio EQ cookbook)
+// It reports false if Freq is not below the Nyquist frequency, where the stage cannot act.
+func (s FilterStage) design(sampleRate int) (Biquad, bool) {
+	if s.Freq >= float64(sampleRate)/2 {
+		return Biquad{}, false
+	}
+	w0 := 2 * math.Pi * s.Freq / float64(sampleRate)
+	cos, sin := math.Cos(w0), math.Sin(w0)
+	alpha := sin / (2 * s.Q)
+	a := math.Pow(10, s.GainDB/40)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch s.Type {
+	case FilterLowpass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case FilterHighpass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case FilterPeaking:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case FilterLowShelf:
+		root := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)-(a-1)*cos+root), 2*a*((a-1)-(a+1)*cos), a*((a+1)-(a-1)*cos-root)
+		a0, a1, a2 = (a+1)+(a-1)*cos+root, -2*((a-1)+(a+1)*cos), (a+1)+(a-1)*cos-root
+	case FilterHighShelf:
+		root := 2 * math.Sqrt(a) * alpha
+		b0, b1, b2 = a*((a+1)+(a-1)*cos+root), -2*a*((a-1)+(a+1)*cos), a*((a+1)+(a-1)*cos-root)
+		a0, a1, a2 = (a+1)-(a-1)*cos+root, 2*((a-1)-(a+1)*cos), (a+1)-(a-1)*cos-root
+	default:
+		return Biquad{}, false
+	}
+	return Biquad{B0: b0 / a0, B1: b1 / a0, B2: b2 / a0, A1: a1 / a0, A2: a2 / a0}, true
+}
+
+// apply returns data filtered by every stage designed at sampleRate, or data itself if no stage applies
+// Stages at or above the Nyquist frequency are skipped, as BandpassFilter skips a cutoff it cannot reach.
+func (f PreFilter) apply(data []float64, sampleRate int) []float64 {
+	for _, stage := range f {
+		if coeffs, ok := stage.design(sampleRate); ok {
+			data = applyBiquad(data, coeffs)
+		}
+	}
+	return data
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestPreFilterResponse(t *testing.T) {
+	const rate = 8000
+	tests := []struct {
+		name   string
+		filter string
+		freq   float64
+		gainDB float64
+		atMost bool // The gain only needs to be at most gainDB, e.g. where several stages add up
+	}{
+		{"speech cuts rumble", "speech", 50, -15, true},
+		{"speech boosts presence", "speech", 2500, 4, false},
+		{"de-boom cuts the low end", "de-boom", 60, -12, false},
+		{"de-boom keeps the voice", "de-boom", 2000, 0, false},
+		{"peaking stage", "peaking:1000:-6:2", 1000, -6, false},
+		{"lowpass stage", "lowpass:500", 500, -3, false},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParsePreFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		tone := make([]float64, 2*rate)
+		for i := range tone {
+			tone[i] = math.Sin(2 * math.Pi * tt.freq * float64(i) / rate)
+		}
+		// Leave out the first second, where the filter is still settling
+		filtered := filter.apply(tone, rate)[rate:]
+		gotDB := 20 * math.Log10(toneLevel(filtered, tt.freq, rate)/toneLevel(tone[rate:], tt.freq, rate))
+		if tt.atMost {
+			if gotDB > tt.gainDB {
+				t.Errorf("%s: %.1f dB at %g Hz, want at most %.1f dB", tt.name, gotDB, tt.freq, tt.gainDB)
+			}
+		} else if math.Abs(gotDB-tt.gainDB) > 0.5 {
+			t.Errorf("%s: %.1f dB at %g Hz, want %.1f dB", tt.name, gotDB, tt.freq, tt.gainDB)
+		}
+	}
+}
+
+func TestParsePreFilterInvalid(t *testing.T) {
+	for _, s := range []string{"loud", "lowpass", "lowpass:abc", "peaking:1000", "highpass:-5", "lowpass:500:0", "lowpass:500:1:2"} {
+		if _, err := ParsePreFilter(s); err == nil {
+			t.Errorf("ParsePreFilter(%q): no error", s)
+		}
+	}
+}
+
+func TestDetectOffsetPreFilter(t *testing.T) {
+	mixed := testSignal(121, 20*testRate)
+	offset := 4*testRate + 301
+	local := testLocal(mixed, offset, 8*testRate)
+	filter, err := ParsePreFilter("speech")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filtering both signals changes their spectrum but delays them equally, so the offset stays
+	filtered := filter.apply(local, testRate)
+	if toneLevel(filtered, 2500, testRate) <= toneLevel(local, 2500, testRate) {
+		t.Fatal("the filter does not change the spectrum")
+	}
+	result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1, PreFilter: filter})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if result.OffsetSamples != offset || result.Confidence < 0.9 {
+		t.Errorf("offset %d (confidence %.2f), want %d at high confidence", result.OffsetSamples, result.Confidence, offset)
+	}
+}
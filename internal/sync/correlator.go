@@ -1,23 +1,188 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"math/cmplx"
 
-	"gonum.org/v1/gonum/dsp/fourier"
+	"github.com/shidetake/clapless/internal/audio"
 )
 
+// ErrSilentInput is wrapped when a track has no signal to correlate, so its offset cannot be detected
+var ErrSilentInput = errors.New("no signal to correlate, the audio is silent")
+
+// silentRMS is the level below which a track counts as silent (-90 dBFS, under the dither of 16-bit audio)
+const silentRMS = 3.16e-5
+
+// silentResult returns the result for tracks without signal if mixed or local is silent, or nil otherwise
+// Normalizing a silent track leaves it flat, and its correlation would still have an arbitrary peak.
+func silentResult(mixed, local []float64) *OffsetResult {
+	if rms(mixed) >= silentRMS && rms(local) >= silentRMS {
+		return nil
+	}
+	return &OffsetResult{Silent: true}
+}
+
 // OffsetResult contains the detected offset and confidence score
 type OffsetResult struct {
-	OffsetSamples int     // Offset in samples (positive = local needs to shift later/right = local is ahead/early)
-	OffsetSeconds float64 // Offset in seconds
-	Confidence    float64 // Confidence score (0.0 to 1.0)
+	OffsetSamples int     // Signed offset in samples (positive = local needs to shift later/right, negative = local starts before mixed)
+	OffsetSeconds float64 // Offset in seconds (includes SubSampleOffset)
+	Confidence    float64 // Normalized cross-correlation coefficient at the peak (-1.0 to 1.0, 1.0 = identical)
+
+	SubSampleOffset float64 // Fractional part of the offset in samples, from parabolic peak interpolation
+	PeakToSidelobe  float64 // Peak divided by the largest correlation outside the main lobe (higher = less ambiguous, 0 = undefined)
+	OutsideWindow   bool    // A stronger peak lies beyond DetectOptions.MaxOffset, so the search window may have excluded the true offset
+	Inverted        bool    // The local track correlates with inverted polarity (wired out of phase); the offset is that of the inverted signal
+	Manual          bool    // The offset was given by the user instead of detected
+	Retried         bool    // Detection was repeated with other settings after a low-confidence first pass
+	Downsample      int     // Downsample factor of the coarse search the offset was found with (0 = not downsampled, as with onset envelopes)
+	Backoffs        int     // Times DetectOffset halved the downsample factor after a low confidence (see DetectOptions.BackoffBelow)
+	Silent          bool    // The mixed or local track has no signal above silentRMS, so there is no offset (OffsetSamples and Confidence are 0)
+
+	RateMismatch *RateMismatch // The local track was detected as if recorded at another rate than its header states (nil = as labeled)
 }
 
-// DetectOffset finds the time offset between mixed and local audio using cross-correlation
-func DetectOffset(mixed, local []float64, sampleRate, segmentDuration, downsampleFactor int) (*OffsetResult, error) {
+// ManualOffset returns an OffsetResult for an offset the user already knows (e.g. from a clap)
+// It is treated as certain: confidence is 1.0 and no correlation is run.
+func ManualOffset(seconds float64, sampleRate int) *OffsetResult {
+	exact := seconds * float64(sampleRate)
+	samples := int(math.Round(exact))
+	return &OffsetResult{
+		OffsetSamples:   samples,
+		OffsetSeconds:   seconds,
+		Confidence:      1.0,
+		SubSampleOffset: exact - float64(samples),
+		Manual:          true,
+	}
+}
+
+// polarityInversionRatio is how much stronger the most negative correlation must be than the
+// positive peak before the local track is treated as polarity-inverted
+const polarityInversionRatio = 1.5
+
+// CorrelationMethod selects how the cross-correlation is computed
+type CorrelationMethod string
+
+const (
+	MethodStandard CorrelationMethod = "standard" // Plain cross-correlation
+	MethodPHAT     CorrelationMethod = "phat"     // Generalized cross-correlation with phase transform (GCC-PHAT)
+	MethodOnset    CorrelationMethod = "onset"    // Cross-correlation of spectral-flux onset envelopes (for music beds)
+	MethodEnvelope CorrelationMethod = "envelope" // Cross-correlation of Hilbert magnitude envelopes (for re-encoded material)
+)
+
+// ParseCorrelationMethod converts a method name into a CorrelationMethod
+func ParseCorrelationMethod(name string) (CorrelationMethod, error) {
+	switch CorrelationMethod(name) {
+	case MethodStandard, MethodPHAT, MethodOnset, MethodEnvelope:
+		return CorrelationMethod(name), nil
+	default:
+		return "", fmt.Errorf("unknown correlation method %q (expected %s, %s, %s or %s)", name, MethodStandard, MethodPHAT, MethodOnset, MethodEnvelope)
+	}
+}
+
+// WindowType selects the taper applied to both signals before correlation
+type WindowType string
+
+const (
+	WindowNone  WindowType = "none"  // No windowing
+	WindowHann  WindowType = "hann"  // Hann window over the whole signal
+	WindowTukey WindowType = "tukey" // Tukey window, tapering only the outer tukeyAlpha of the signal
+)
+
+// tukeyAlpha is the fraction of the signal tapered by the Tukey window (half at each end)
+const tukeyAlpha = 0.1
+
+// ParseWindowType converts a window name into a WindowType
+func ParseWindowType(name string) (WindowType, error) {
+	switch WindowType(name) {
+	case WindowNone, WindowHann, WindowTukey:
+		return WindowType(name), nil
+	default:
+		return "", fmt.Errorf("unknown window %q (expected %s, %s or %s)", name, WindowNone, WindowHann, WindowTukey)
+	}
+}
+
+// DetectOptions controls offset detection
+type DetectOptions struct {
+	SegmentDuration  int               // Segment duration in seconds for correlation
+	DownsampleFactor int               // Downsample factor for coarse search (1 = no downsampling)
+	BackoffBelow     float64           // DetectOffset retries with the factor halved (down to 1) while the confidence is below this (0 = disabled)
+	Method           CorrelationMethod // Cross-correlation method (empty = standard)
+	BandpassLow      int               // Band-pass lower cutoff in Hz applied before correlation (0 = no high-pass)
+	BandpassHigh     int               // Band-pass upper cutoff in Hz applied before correlation (0 = no low-pass)
+	PreFilter        PreFilter         // Biquad stages applied to both signals after the band-pass, designed at the rate they run at (nil = none)
+	Window           WindowType        // Window applied to both signals before correlation (empty = none)
+	MaxOffset        float64           // Only search offsets within ±MaxOffset seconds (0 = unlimited)
+	ExcludeLag       float64           // Leave offsets within ±ExcludeLag seconds of zero out of the coarse search (0 = none)
+	CoarseSegment    float64           // Seconds from the middle of the local track used for the search (0 = whole track)
+	EnergeticSegment bool              // Take CoarseSegment from the most energetic part of the local track instead of its middle
+	FinetuneTarget   float64           // Target fine-tuning segment length in seconds (0 = 60)
+	FinetuneMin      float64           // Minimum overlap in seconds required to fine-tune (0 = 30)
+	FinetuneFixed    bool              // Place the fine-tuning segment at FinetunePosition instead of on the most energetic part of the overlap
+	FinetunePosition float64           // Where a fixed fine-tuning segment lies in the overlap (0 = start, 0.5 = center, 1 = end)
+	LevelMatch       bool              // Scale short blocks of both signals to a common loudness before normalizing
+	KeepManual       bool              // Keep manual offsets as given instead of fine-tuning them
+	TrimSilenceDB    float64           // Leave out leading and trailing audio below this level in dBFS from the coarse search (0 = disabled)
+	Chunked          bool              // Always correlate in fixed-size blocks (standard method only; long inputs use blocks automatically)
+	Mixdown          audio.Mixdown     // How multi-channel local tracks are collapsed to mono (zero value = average)
+	Detector         Detector          // Coarse offset detector used instead of the correlation (nil = DetectOffset with these options)
+	Threads          int               // Maximum number of local tracks detected or fine-tuned at once (0 = GOMAXPROCS)
+	Logger           *slog.Logger      // Receives debug records of correlation peaks and fine-tuning (nil = discarded)
+
+	// OnCorrelation is called with the coarse correlation before its peak is picked, for debugging (nil = not called)
+	// An error it returns is returned by the detection.
+	OnCorrelation func(curve *CorrelationCurve) error
+}
+
+// logger returns Logger, or a logger that discards every record if it is nil
+func (o DetectOptions) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger
+}
+
+// CorrelationCurve is the coarse cross-correlation computed by DetectOffsetDownsampled
+type CorrelationCurve struct {
+	Values []float64 // Correlation at each coarse lag in FFT order: non-negative lags, then negative lags wrapped around (negated for an inverted track; only valid during the call)
+
+	positiveLags int // Number of non-negative lags at the start of Values
+	shift        int // Coarse lag of the whole local track when the correlated parts line up, as in DetectOffsetDownsampled
+	factor       int // Downsample factor of the coarse lags
+}
+
+// Lag returns the offset in full-rate samples Values[i] stands for, in the convention of OffsetResult.OffsetSamples
+func (c *CorrelationCurve) Lag(i int) int {
+	lag := i
+	if i >= c.positiveLags {
+		lag = i - len(c.Values)
+	}
+	return (lag - c.shift) * c.factor
+}
+
+// Each calls fn with every lag (as returned by Lag) and its value in ascending lag order
+func (c *CorrelationCurve) Each(fn func(lag int, value float64)) {
+	for i := c.positiveLags; i < len(c.Values); i++ {
+		fn(c.Lag(i), c.Values[i])
+	}
+	for i := range c.positiveLags {
+		fn(c.Lag(i), c.Values[i])
+	}
+}
+
+// levelMatchBlockSeconds is the length of the blocks levelMatch scales independently
+const levelMatchBlockSeconds = 0.5
 
+// levelMatchMaxGain limits how far levelMatch may raise or lower a block (10x = 20 dB)
+// so that near-silent blocks are not boosted into loud noise
+const levelMatchMaxGain = 10.0
+
+// DetectOffset finds the time offset between mixed and local audio using cross-correlation
+// It returns ctx.Err() if ctx is cancelled between stages.
+func DetectOffset(ctx context.Context, mixed, local []float64, sampleRate int, opts DetectOptions) (*OffsetResult, error) {
 	// Validate input data
 	if len(mixed) == 0 {
 		return nil, fmt.Errorf("mixed audio data is empty")
@@ -25,44 +190,260 @@ func DetectOffset(mixed, local []float64, sampleRate, segmentDuration, downsampl
 	if len(local) == 0 {
 		return nil, fmt.Errorf("local audio data is empty")
 	}
+	if silent := silentResult(mixed, local); silent != nil {
+		return silent, nil
+	}
+
+	// Onset envelopes are computed from the full-rate signals and replace the coarse search and pyramid
+	if opts.Method == MethodOnset {
+		return detectOffsetOnset(ctx, mixed, local, sampleRate, opts)
+	}
+
+	best, err := detectOffsetPyramid(ctx, mixed, local, sampleRate, opts)
+	if err != nil {
+		return nil, err
+	}
 
+	// A high factor can average the peak away, so back off to finer factors while the confidence stays low
+	// The correlation hook only sees the first pass.
+	retry := opts
+	retry.OnCorrelation = nil
+	backoffs := 0
+	for best.Confidence < opts.BackoffBelow && retry.DownsampleFactor > 1 {
+		retry.DownsampleFactor /= 2
+		backoffs++
+		opts.logger().Debug("low confidence, retrying at a finer downsample factor",
+			"confidence", best.Confidence, "downsample", retry.DownsampleFactor)
+		candidate, err := detectOffsetPyramid(ctx, mixed, local, sampleRate, retry)
+		if err != nil {
+			return nil, err
+		}
+		if candidate.Confidence > best.Confidence {
+			best = candidate
+		}
+	}
+	best.Backoffs = backoffs
+	return best, nil
+}
+
+// detectOffsetPyramid runs the coarse search at opts.DownsampleFactor and narrows its peak down to full resolution
+func detectOffsetPyramid(ctx context.Context, mixed, local []float64, sampleRate int, opts DetectOptions) (*OffsetResult, error) {
 	// Coarse search with downsampling
-	mixedCoarse := downsample(mixed, downsampleFactor)
-	localCoarse := downsample(local, downsampleFactor)
+	mixedCoarse := downsample(mixed, opts.DownsampleFactor)
+	localCoarse := downsample(local, opts.DownsampleFactor)
+
+	result, err := DetectOffsetDownsampled(ctx, mixedCoarse, localCoarse, sampleRate, opts)
+	if err != nil || result.Silent {
+		return result, err
+	}
+
+	// Narrow the coarse peak down through finer resolutions so fine-tuning starts close to the true offset
+	if opts.DownsampleFactor/pyramidStep > 1 {
+		result.OffsetSamples, result.SubSampleOffset = refineOffset(ctx, mixed, local, sampleRate,
+			result.OffsetSamples, result.SubSampleOffset, result.Inverted, opts)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.OffsetSeconds = (float64(result.OffsetSamples) + result.SubSampleOffset) / float64(sampleRate)
+	}
+
+	return result, nil
+}
+
+// DetectOffsetDownsampled finds the time offset between signals that were already decimated by opts.DownsampleFactor
+// (e.g. by audio.LoadWAVDownsampled); sampleRate is the original rate and the offset is returned at that rate
+func DetectOffsetDownsampled(ctx context.Context, mixedCoarse, localCoarse []float64, sampleRate int, opts DetectOptions) (*OffsetResult, error) {
+	downsampleFactor := max(opts.DownsampleFactor, 1)
+
+	// Validate input data
+	if len(mixedCoarse) == 0 {
+		return nil, fmt.Errorf("mixed audio data is empty")
+	}
+	if len(localCoarse) == 0 {
+		return nil, fmt.Errorf("local audio data is empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if silent := silentResult(mixedCoarse, localCoarse); silent != nil {
+		silent.Downsample = downsampleFactor
+		return silent, nil
+	}
+
+	// Reject hum and rumble outside the band of interest
+	// Filtering runs at the downsampled rate, so the upper cutoff is limited by its Nyquist frequency
+	coarseRate := sampleRate / downsampleFactor
+
+	// Optionally correlate only the active region of both signals, so dead air before anyone
+	// speaks does not dilute normalization and the correlation energy
+	// shift is where the correlated part of the local track starts relative to the correlated part
+	// of the mixed track on the untrimmed timelines; it is subtracted from the lag below
+	shift := 0
+	if opts.TrimSilenceDB != 0 {
+		mixedStart, mixedEnd := audio.TrimSilence(mixedCoarse, opts.TrimSilenceDB, coarseRate)
+		localStart, localEnd := audio.TrimSilence(localCoarse, opts.TrimSilenceDB, coarseRate)
+		mixedCoarse = mixedCoarse[mixedStart:mixedEnd]
+		localCoarse = localCoarse[localStart:localEnd]
+		shift = localStart - mixedStart
+	}
+
+	// Optionally correlate only the middle (or the most energetic part) of the local track
+	if segment := int(opts.CoarseSegment * float64(coarseRate)); segment > 0 && segment < len(localCoarse) {
+		localStart := (len(localCoarse) - segment) / 2
+		if opts.EnergeticSegment {
+			localStart = energeticWindow(localCoarse, segment)
+		}
+		localCoarse = localCoarse[localStart : localStart+segment]
+		shift += localStart
+	}
 
-	// Normalize entire signals
-	mixedNorm := normalize(mixedCoarse)
-	localNorm := normalize(localCoarse)
+	mixedCoarse = BandpassFilter(mixedCoarse, coarseRate, opts.BandpassLow, opts.BandpassHigh)
+	localCoarse = BandpassFilter(localCoarse, coarseRate, opts.BandpassLow, opts.BandpassHigh)
+	mixedCoarse = opts.PreFilter.apply(mixedCoarse, coarseRate)
+	localCoarse = opts.PreFilter.apply(localCoarse, coarseRate)
+
+	// Re-encoding shifts the phase of the waveform but keeps its amplitude, so compare the envelopes instead
+	if opts.Method == MethodEnvelope {
+		mixedCoarse = hilbertEnvelope(mixedCoarse)
+		localCoarse = hilbertEnvelope(localCoarse)
+	}
+
+	// Even out loud and quiet passages so neither signal's level changes dominate the correlation
+	if opts.LevelMatch {
+		mixedCoarse = levelMatch(mixedCoarse, coarseRate)
+		localCoarse = levelMatch(localCoarse, coarseRate)
+	}
+
+	// Normalize entire signals, then taper their edges so the abrupt boundaries do not leak into the spectrum
+	mixedNorm := applyWindow(normalize(mixedCoarse), opts.Window)
+	localNorm := applyWindow(normalize(localCoarse), opts.Window)
 
 	// Compute cross-correlation using FFT
-	correlation := crossCorrelateFFT(mixedNorm, localNorm)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	correlation := crossCorrelate(mixedNorm, localNorm, opts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// A track wired out of phase shows up as a strong negative peak, which the maximum would miss
+	// Negating the correlation is the same as re-correlating with the local signal inverted
+	// Envelopes are never negative, so there is no inverted polarity to look for.
+	_, peakValue := findMaxPeak(correlation)
+	_, troughValue := findMinPeak(correlation)
+	inverted := opts.Method != MethodEnvelope && -troughValue > polarityInversionRatio*peakValue
+	if inverted {
+		for i := range correlation {
+			correlation[i] = -correlation[i]
+		}
+	}
+
+	if opts.OnCorrelation != nil {
+		curve := &CorrelationCurve{Values: correlation, positiveLags: len(mixedNorm), shift: shift, factor: downsampleFactor}
+		if err := opts.OnCorrelation(curve); err != nil {
+			return nil, fmt.Errorf("correlation hook failed: %w", err)
+		}
+	}
+
+	// Leave out the lags around zero, where a track cut from the mixed audio may match itself
+	if opts.ExcludeLag > 0 {
+		excludeLags(correlation, len(mixedNorm), shift, int(opts.ExcludeLag*float64(coarseRate)))
+	}
 
 	// Find peak
 	peakIdx, peakValue := findMaxPeak(correlation)
 
+	// Restrict the search to lags within ±MaxOffset, remembering whether the unrestricted peak was stronger
+	outsideWindow := false
+	if opts.MaxOffset > 0 {
+		strongest := peakValue
+		maskLags(correlation, len(mixedNorm), shift, int(opts.MaxOffset*float64(coarseRate)))
+		peakIdx, peakValue = findMaxPeak(correlation)
+		outsideWindow = strongest > peakValue
+	}
+
 	// Calculate offset from peak position
-	// FFT correlation: result[k] means local should be shifted k samples to the right
-	// So offset = peak_index directly
+	// FFT correlation is circular: result[k] means local should be shifted k samples to the right,
+	// and negative lags wrap around to the end of the array
 	offset := peakIdx
+	if peakIdx >= len(mixedNorm) {
+		// Beyond the largest positive lag, so this is a negative lag (local starts before mixed)
+		offset = peakIdx - len(correlation)
+	}
+
+	fftSize := nextPowerOfTwo(len(correlation))
+	if correlatesInBlocks(len(correlation), opts) {
+		fftSize = chunkFFTSize
+	}
+	opts.logger().Debug("correlation peak",
+		"peak_index", peakIdx, "peak_value", peakValue, "lag", offset-shift, "fft_size", fftSize,
+		"downsample", downsampleFactor, "inverted", inverted, "outside_window", outsideWindow)
+
+	// Compare the peak with the strongest competing candidate, ignoring about 10ms around the peak
+	peakToSidelobe := peakToSidelobeRatio(correlation, peakIdx, peakValue, max(coarseRate/100, 3))
 
-	// Convert to original sample rate
-	finalOffset := offset * downsampleFactor
+	// Refine the peak position between samples
+	subSample := interpolatePeak(correlation, peakIdx)
 
-	// Calculate confidence (normalized correlation peak)
-	confidence := peakValue / float64(len(localNorm))
+	// Convert to the offset of the whole local track at the original sample rate
+	finalOffset := (offset - shift) * downsampleFactor
+	subSampleOffset := subSample * float64(downsampleFactor)
+
+	// Calculate confidence as the normalized cross-correlation coefficient over the overlapping region
+	// PHAT whitens the spectrum, so its peak is already on a 0-1 scale
+	confidence := correlationCoefficient(mixedNorm, localNorm, offset, peakValue)
+	if opts.Method == MethodPHAT {
+		confidence = peakValue
+	}
 
 	return &OffsetResult{
-		OffsetSamples: finalOffset,
-		OffsetSeconds: float64(finalOffset) / float64(sampleRate),
-		Confidence:    confidence,
+		OffsetSamples:   finalOffset,
+		OffsetSeconds:   (float64(finalOffset) + subSampleOffset) / float64(sampleRate),
+		Confidence:      confidence,
+		SubSampleOffset: subSampleOffset,
+		PeakToSidelobe:  peakToSidelobe,
+		OutsideWindow:   outsideWindow,
+		Inverted:        inverted,
+		Downsample:      downsampleFactor,
 	}, nil
 }
 
+// maskLags sets correlation values for lags more than maxLag away from center to -Inf so they are never picked as the peak
+// Indices below positiveLags are lags 0 to positiveLags-1; the rest wrap around as negative lags
+func maskLags(correlation []float64, positiveLags, center, maxLag int) {
+	for i := range correlation {
+		lag := i
+		if i >= positiveLags {
+			lag = i - len(correlation)
+		}
+		if lag-center > maxLag || lag-center < -maxLag {
+			correlation[i] = math.Inf(-1)
+		}
+	}
+}
+
+// excludeLags sets correlation values for lags within radius of center to -Inf, the reverse of maskLags
+func excludeLags(correlation []float64, positiveLags, center, radius int) {
+	for i := range correlation {
+		lag := i
+		if i >= positiveLags {
+			lag = i - len(correlation)
+		}
+		if lag-center <= radius && lag-center >= -radius {
+			correlation[i] = math.Inf(-1)
+		}
+	}
+}
+
 // normalize scales audio data to have zero mean and unit variance
 func normalize(data []float64) []float64 {
 	if len(data) == 0 {
 		return data
 	}
+	if len(data) >= parallelMinSamples {
+		return normalizeParallel(data)
+	}
 
 	// Calculate mean
 	mean := 0.0
@@ -94,10 +475,186 @@ func normalize(data []float64) []float64 {
 	return result
 }
 
+// levelMatch scales each block of levelMatchBlockSeconds to the RMS level of the whole signal
+// Gains are clamped to levelMatchMaxGain in either direction. The result is still z-scored by
+// normalize afterwards, so only the relative loudness of passages changes, not the overall scale.
+func levelMatch(data []float64, sampleRate int) []float64 {
+	blockSize := max(int(levelMatchBlockSeconds*float64(sampleRate)), 1)
+	target := rms(data)
+	if target == 0 {
+		return data
+	}
+
+	result := make([]float64, len(data))
+	for start := 0; start < len(data); start += blockSize {
+		end := min(start+blockSize, len(data))
+		gain := levelMatchMaxGain
+		if level := rms(data[start:end]); level > 0 {
+			gain = math.Max(math.Min(target/level, levelMatchMaxGain), 1/levelMatchMaxGain)
+		}
+		for i := start; i < end; i++ {
+			result[i] = data[i] * gain
+		}
+	}
+
+	return result
+}
+
+// rms returns the root mean square level of data
+func rms(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range data {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(data)))
+}
+
+// applyWindow multiplies the signal by the selected window function
+func applyWindow(data []float64, kind WindowType) []float64 {
+	n := len(data)
+	if n < 2 || kind == "" || kind == WindowNone {
+		return data
+	}
+
+	result := make([]float64, n)
+	for i, v := range data {
+		result[i] = v * windowValue(i, n, kind)
+	}
+	return result
+}
+
+// windowValue returns the weight of sample i in a window of length n
+func windowValue(i, n int, kind WindowType) float64 {
+	x := float64(i) / float64(n-1) // Position from 0.0 to 1.0
+
+	switch kind {
+	case WindowHann:
+		return 0.5 * (1 - math.Cos(2*math.Pi*x))
+	case WindowTukey:
+		// Cosine tapers over the first and last tukeyAlpha/2 of the signal, flat in between
+		if x < tukeyAlpha/2 {
+			return 0.5 * (1 - math.Cos(2*math.Pi*x/tukeyAlpha))
+		}
+		if x > 1-tukeyAlpha/2 {
+			return 0.5 * (1 - math.Cos(2*math.Pi*(1-x)/tukeyAlpha))
+		}
+		return 1
+	default:
+		return 1
+	}
+}
+
+const (
+	maxFFTSize   = 1 << 24 // Largest single FFT before the standard correlation switches to blocks
+	chunkFFTSize = 1 << 20 // FFT size of each block of the segmented correlation
+
+	// Bytes per FFT point: padded inputs and output (3 float64), both spectra and their product
+	// (3 complex128 over half the points) and the plan's work space (4 float64)
+	fftBytesPerPoint = 3*8 + 3*16/2 + 4*8
+	// Bytes per input sample for the filtered, level-matched, normalized and windowed copies
+	signalCopyBytes = 4 * 8
+)
+
+// CorrelationFootprint estimates the peak memory in bytes DetectOffsetDownsampled needs
+// to correlate signals of mixedLength and localLength samples (after downsampling) with opts
+func CorrelationFootprint(mixedLength, localLength int, opts DetectOptions) int64 {
+	lags := mixedLength + localLength - 1
+	copies := int64(mixedLength+localLength) * signalCopyBytes
+	correlation := fftBytesPerPoint * int64(nextPowerOfTwo(lags))
+	if correlatesInBlocks(lags, opts) {
+		correlation = int64(lags)*8 + fftBytesPerPoint*chunkFFTSize
+	}
+	// The Hilbert envelope transforms each signal, zero-padded to twice its length, before correlating
+	if opts.Method == MethodEnvelope {
+		if hilbert := fftBytesPerPoint * int64(nextPowerOfTwo(2*max(mixedLength, localLength))); hilbert > correlation {
+			correlation = hilbert
+		}
+	}
+	return copies + correlation
+}
+
+// correlatesInBlocks reports whether crossCorrelate splits a correlation of lags lags into blocks
+func correlatesInBlocks(lags int, opts DetectOptions) bool {
+	return opts.Method != MethodPHAT && (opts.Chunked || nextPowerOfTwo(lags) > maxFFTSize)
+}
+
+// crossCorrelate correlates signal1 with signal2 in a single FFT, or in blocks when opts.Chunked
+// is set or the single FFT would exceed maxFFTSize. PHAT whitens the whole spectrum at once,
+// so it always uses the single FFT.
+// Both results put lag k >= 0 at index k and negative lags at len(result)+k.
+func crossCorrelate(signal1, signal2 []float64, opts DetectOptions) []float64 {
+	if correlatesInBlocks(len(signal1)+len(signal2)-1, opts) {
+		return crossCorrelateChunked(signal1, signal2, chunkFFTSize)
+	}
+	return crossCorrelateFFT(signal1, signal2, opts.Method)
+}
+
+// crossCorrelateChunked computes the linear cross-correlation with FFTs of at most fftSize points
+// signal2 is split into blocks of fftSize/2 samples, and each block is correlated against
+// signal1 by overlap-save: every FFT of a signal1 segment yields fftSize-blockLength+1 valid lags.
+// The result has exactly len(signal1)+len(signal2)-1 lags.
+func crossCorrelateChunked(signal1, signal2 []float64, fftSize int) []float64 {
+	if len(signal1) == 0 || len(signal2) == 0 {
+		return []float64{0}
+	}
+
+	result := make([]float64, len(signal1)+len(signal2)-1)
+	fft := acquireFFT(fftSize)
+	defer releaseFFT(fft)
+
+	segment := make([]float64, fftSize)
+	blockSize := fftSize / 2
+	var blockCoeffs, segmentCoeffs []complex128
+	var product []complex128
+	var lags []float64
+
+	for blockStart := 0; blockStart < len(signal2); blockStart += blockSize {
+		block := signal2[blockStart:min(blockStart+blockSize, len(signal2))]
+		blockCoeffs = fft.Coefficients(blockCoeffs, padToSize(block, fftSize))
+
+		// Lag m of signal1 against the block is lag m-blockStart of signal1 against signal2
+		// Each segment of signal1 starting at position p yields lags p to p+valid-1
+		valid := fftSize - len(block) + 1
+		for p := -(len(block) - 1); p < len(signal1); p += valid {
+			for i := range segment {
+				if j := p + i; j >= 0 && j < len(signal1) {
+					segment[i] = signal1[j]
+				} else {
+					segment[i] = 0
+				}
+			}
+			segmentCoeffs = fft.Coefficients(segmentCoeffs, segment)
+
+			if product == nil {
+				product = make([]complex128, len(segmentCoeffs))
+			}
+			for i := range product {
+				product[i] = segmentCoeffs[i] * cmplx.Conj(blockCoeffs[i])
+			}
+			lags = fft.Sequence(lags, product)
+
+			for t := 0; t < valid && p+t < len(signal1); t++ {
+				idx := p + t - blockStart
+				if idx < 0 {
+					idx += len(result)
+				}
+				// Gonum FFT is unnormalized, as in crossCorrelateFFT
+				result[idx] += lags[t] / float64(fftSize)
+			}
+		}
+	}
+
+	return result
+}
 
 // crossCorrelateFFT performs FFT-based cross-correlation
-// Returns correlation array where peak indicates best alignment
-func crossCorrelateFFT(signal1, signal2 []float64) []float64 {
+// Returns the circular correlation array where peak indicates best alignment.
+// Index k in [0, len(signal1)) is a lag of +k; negative lags -k are stored at len(result)-k.
+// With MethodPHAT the cross-spectrum is whitened so only phase information contributes to the peak.
+func crossCorrelateFFT(signal1, signal2 []float64, method CorrelationMethod) []float64 {
 	// Validate inputs (defensive check)
 	if len(signal1) == 0 || len(signal2) == 0 {
 		return []float64{0}
@@ -110,8 +667,9 @@ func crossCorrelateFFT(signal1, signal2 []float64) []float64 {
 	padded1 := padToSize(signal1, fftSize)
 	padded2 := padToSize(signal2, fftSize)
 
-	// Create FFT
-	fft := fourier.NewFFT(fftSize)
+	// Reuse an FFT plan of this size
+	fft := acquireFFT(fftSize)
+	defer releaseFFT(fft)
 
 	// Forward FFT (real input to complex output)
 	fft1 := fft.Coefficients(nil, padded1)
@@ -123,6 +681,17 @@ func crossCorrelateFFT(signal1, signal2 []float64) []float64 {
 		product[i] = fft1[i] * cmplx.Conj(fft2[i])
 	}
 
+	// Phase transform: divide each bin by its magnitude
+	if method == MethodPHAT {
+		for i, v := range product {
+			if mag := cmplx.Abs(v); mag > 1e-12 {
+				product[i] = v / complex(mag, 0)
+			} else {
+				product[i] = 0
+			}
+		}
+	}
+
 	// Inverse FFT (complex input to real output)
 	resultReal := fft.Sequence(nil, product)
 
@@ -132,11 +701,53 @@ func crossCorrelateFFT(signal1, signal2 []float64) []float64 {
 		resultReal[i] /= float64(fftSize)
 	}
 
-	// Trim to actual correlation size
-	result := make([]float64, n)
-	copy(result, resultReal[:n])
+	// Keep the full circular result so that negative lags are not discarded
+	return resultReal
+}
 
-	return result
+// correlationCoefficient normalizes a correlation peak at the given lag by the energy of
+// both signals over the region where they overlap, giving a coefficient in [-1, 1]
+// that does not depend on signal length
+func correlationCoefficient(mixed, local []float64, lag int, peakValue float64) float64 {
+	// At lag k the local sample i lines up with mixed sample i+k
+	mixedStart := max(lag, 0)
+	localStart := max(-lag, 0)
+	length := min(len(mixed)-mixedStart, len(local)-localStart)
+	if length <= 0 {
+		return 0
+	}
+
+	mixedEnergy := energy(mixed[mixedStart : mixedStart+length])
+	localEnergy := energy(local[localStart : localStart+length])
+	if mixedEnergy == 0 || localEnergy == 0 {
+		return 0
+	}
+
+	coefficient := peakValue / math.Sqrt(mixedEnergy*localEnergy)
+	// Guard against rounding error pushing the value just outside [-1, 1]
+	return math.Max(-1, math.Min(1, coefficient))
+}
+
+// energeticWindow returns the start of the window of length samples in data with the highest energy
+func energeticWindow(data []float64, length int) int {
+	sum := energy(data[:length])
+	best, bestStart := sum, 0
+	for start := 1; start+length <= len(data); start++ {
+		sum += data[start+length-1]*data[start+length-1] - data[start-1]*data[start-1]
+		if sum > best {
+			best, bestStart = sum, start
+		}
+	}
+	return bestStart
+}
+
+// energy returns the sum of squared samples
+func energy(data []float64) float64 {
+	sum := 0.0
+	for _, v := range data {
+		sum += v * v
+	}
+	return sum
 }
 
 // findMaxPeak finds the index and value of the maximum peak in the correlation
@@ -158,6 +769,74 @@ func findMaxPeak(correlation []float64) (int, float64) {
 	return maxIdx, maxVal
 }
 
+// findMinPeak finds the index and value of the most negative correlation
+func findMinPeak(correlation []float64) (int, float64) {
+	minIdx, minVal := 0, 0.0
+	for i, v := range correlation {
+		if v < minVal {
+			minVal = v
+			minIdx = i
+		}
+	}
+
+	return minIdx, minVal
+}
+
+// peakToSidelobeRatio divides the peak by the largest correlation magnitude more than
+// exclusion samples away from it (the correlation is circular, so distances wrap around)
+// Lags masked by maskLags are ignored
+func peakToSidelobeRatio(correlation []float64, peakIdx int, peakValue float64, exclusion int) float64 {
+	n := len(correlation)
+	sidelobe := 0.0
+	for i, v := range correlation {
+		distance := i - peakIdx
+		if distance < 0 {
+			distance = -distance
+		}
+		if min(distance, n-distance) <= exclusion || math.IsInf(v, -1) {
+			continue
+		}
+		sidelobe = math.Max(sidelobe, math.Abs(v))
+	}
+
+	if sidelobe == 0 {
+		return 0
+	}
+	return peakValue / sidelobe
+}
+
+// interpolatePeak fits a parabola through the peak and its two neighbors
+// and returns the fractional peak position relative to peakIdx (-0.5 to 0.5)
+func interpolatePeak(correlation []float64, peakIdx int) float64 {
+	n := len(correlation)
+	if n < 3 {
+		return 0
+	}
+
+	// The correlation is circular, so neighbors wrap around the array ends
+	prev := correlation[(peakIdx-1+n)%n]
+	peak := correlation[peakIdx]
+	next := correlation[(peakIdx+1)%n]
+
+	// A neighbor masked by maskLags means the peak sits on the search window edge
+	if math.IsInf(prev, -1) || math.IsInf(next, -1) {
+		return 0
+	}
+
+	denom := prev - 2*peak + next
+	if denom == 0 {
+		return 0
+	}
+
+	delta := 0.5 * (prev - next) / denom
+	if delta > 0.5 {
+		delta = 0.5
+	} else if delta < -0.5 {
+		delta = -0.5
+	}
+	return delta
+}
+
 // nextPowerOfTwo returns the next power of 2 >= n
 func nextPowerOfTwo(n int) int {
 	power := 1
@@ -183,6 +862,9 @@ func downsample(data []float64, factor int) []float64 {
 	if factor <= 1 {
 		return data
 	}
+	if len(data) >= parallelMinSamples {
+		return downsampleParallel(data, factor)
+	}
 
 	result := make([]float64, 0, len(data)/factor)
 	for i := 0; i < len(data); i += factor {
@@ -191,6 +873,24 @@ func downsample(data []float64, factor int) []float64 {
 	return result
 }
 
+// maxCoarseSamples bounds the combined decimated length of the signals chosen by AutoDownsampleFactor
+const maxCoarseSamples = 1 << 22
+
+// DefaultAutoResolutionMs is the coarsest coarse-search resolution AutoDownsampleFactor picks by default
+const DefaultAutoResolutionMs = 5.0
+
+// AutoDownsampleFactor picks the smallest downsample factor that keeps the decimated mixed and local
+// signals within maxCoarseSamples combined. The factor never grows beyond one coarse sample per
+// maxResolutionMs (0 = DefaultAutoResolutionMs), so very long files may exceed the bound instead.
+func AutoDownsampleFactor(sampleRate, mixedSamples, localSamples int, maxResolutionMs float64) int {
+	if maxResolutionMs <= 0 {
+		maxResolutionMs = DefaultAutoResolutionMs
+	}
+	factor := (mixedSamples + localSamples + maxCoarseSamples - 1) / maxCoarseSamples
+	limit := int(maxResolutionMs * float64(sampleRate) / 1000)
+	return max(min(factor, limit), 1)
+}
+
 // max returns the maximum of two integers
 func max(a, b int) int {
 	if a > b {
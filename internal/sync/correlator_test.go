@@ -0,0 +1,576 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+const testRate = 8000
+
+// testSignal returns speech-like noise bursts separated by pauses, which correlate with themselves only at lag zero
+func testSignal(seed uint64, length int) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	data := make([]float64, length)
+	for i := 0; i < length; {
+		burst := int((0.05 + 0.2*rng.Float64()) * testRate)
+		level := 0.1 + 0.4*rng.Float64()
+		if rng.IntN(4) == 0 {
+			level = 0.001
+		}
+		for j := i; j < min(i+burst, length); j++ {
+			data[j] = level * rng.NormFloat64()
+		}
+		i += burst
+	}
+	return data
+}
+
+// testLocal cuts length samples from mixed starting at offset, filling the part before mixed starts with unrelated audio
+func testLocal(mixed []float64, offset, length int) []float64 {
+	lead := testSignal(99, length)
+	local := make([]float64, length)
+	for i := range local {
+		if src := offset + i; src >= 0 && src < len(mixed) {
+			local[i] = mixed[src]
+		} else {
+			local[i] = 0.3 * lead[i]
+		}
+	}
+	return local
+}
+
+func TestDetectOffsetLagAndLead(t *testing.T) {
+	mixed := testSignal(1, 20*testRate)
+
+	tests := []struct {
+		name   string
+		offset int
+	}{
+		{"starts with the mix", 0},
+		{"starts after the mix", 3 * testRate},
+		{"odd sample lag", 12345},
+		{"starts before the mix", -2 * testRate},
+		{"odd sample lead", -777},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := testLocal(mixed, tt.offset, 8*testRate)
+			result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1})
+			if err != nil {
+				t.Fatalf("DetectOffset: %v", err)
+			}
+			if result.OffsetSamples != tt.offset {
+				t.Errorf("OffsetSamples = %d, want %d", result.OffsetSamples, tt.offset)
+			}
+			if want := float64(tt.offset) / testRate; result.OffsetSeconds < want-1.0/testRate || result.OffsetSeconds > want+1.0/testRate {
+				t.Errorf("OffsetSeconds = %f, want %f", result.OffsetSeconds, want)
+			}
+		})
+	}
+}
+
+func TestDetectOffsetDownsampledLagAndLead(t *testing.T) {
+	mixed := testSignal(2, 30*testRate)
+
+	for _, offset := range []int{5 * testRate, -3 * testRate} {
+		local := testLocal(mixed, offset, 10*testRate)
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 8})
+		if err != nil {
+			t.Fatalf("DetectOffset(%d): %v", offset, err)
+		}
+		if diff := result.OffsetSamples - offset; diff < -8 || diff > 8 {
+			t.Errorf("offset %d: OffsetSamples = %d, want within one coarse sample", offset, result.OffsetSamples)
+		}
+	}
+}
+
+func TestDetectOffsetConfidence(t *testing.T) {
+	mixed := testSignal(5, 20*testRate)
+	noise := testSignal(6, 8*testRate)
+
+	tests := []struct {
+		name     string
+		local    []float64
+		min, max float64
+	}{
+		{"exact copy", testLocal(mixed, 4*testRate, 8*testRate), 0.99, 1.0},
+		{"quiet copy", scaled(testLocal(mixed, 4*testRate, 8*testRate), 0.01), 0.99, 1.0},
+		{"noisy copy", mixedWith(testLocal(mixed, 4*testRate, 8*testRate), noise, 0.5), 0.6, 0.95},
+		{"unrelated", noise, -0.1, 0.1},
+	}
+
+	for _, tt := range tests {
+		result, err := DetectOffset(context.Background(), mixed, tt.local, testRate, DetectOptions{DownsampleFactor: 1})
+		if err != nil {
+			t.Fatalf("%s: DetectOffset: %v", tt.name, err)
+		}
+		if result.Confidence < tt.min || result.Confidence > tt.max {
+			t.Errorf("%s: confidence %.3f, want %.2f to %.2f", tt.name, result.Confidence, tt.min, tt.max)
+		}
+	}
+}
+
+// scaled returns data multiplied by gain
+func scaled(data []float64, gain float64) []float64 {
+	result := make([]float64, len(data))
+	for i, v := range data {
+		result[i] = gain * v
+	}
+	return result
+}
+
+// mixedWith returns data plus gain times other
+func mixedWith(data, other []float64, gain float64) []float64 {
+	result := make([]float64, len(data))
+	for i := range data {
+		result[i] = data[i] + gain*other[i]
+	}
+	return result
+}
+
+// reverberant returns dry as heard in a room: two early echoes, a decaying diffuse tail and a little room tone
+func reverberant(dry []float64, seed uint64) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	ir := make([]float64, testRate*4/10)
+	ir[0] = 1
+	for i := 1; i < len(ir); i++ {
+		ir[i] = 0.08 * rng.NormFloat64() * math.Exp(-float64(i)/(0.08*testRate))
+	}
+	ir[testRate*20/1000] += 0.6
+	ir[testRate*35/1000] += 0.45
+
+	wet := make([]float64, len(dry))
+	for i, v := range dry {
+		for j, h := range ir[:min(len(ir), len(wet)-i)] {
+			wet[i+j] += v * h
+		}
+		wet[i] += 0.005 * rng.NormFloat64()
+	}
+	return wet
+}
+
+func TestDetectOffsetPHATReverb(t *testing.T) {
+	// A close-miked local track against a mix recorded in a reverberant room, with speech-like coloring
+	dry := BandpassFilter(testSignal(41, 20*testRate), testRate, 0, 1000)
+	mixed := reverberant(dry, 42)
+	offset := 4 * testRate
+	local := dry[offset : offset+10*testRate]
+
+	peakToSidelobe := map[CorrelationMethod]float64{}
+	for _, method := range []CorrelationMethod{MethodStandard, MethodPHAT} {
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1, Method: method, Window: WindowTukey})
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if result.OffsetSamples != offset {
+			t.Errorf("%s: offset %d, want %d", method, result.OffsetSamples, offset)
+		}
+		peakToSidelobe[method] = result.PeakToSidelobe
+	}
+
+	// Whitening keeps the echoes from building peaks of their own next to the direct path
+	if standard, phat := peakToSidelobe[MethodStandard], peakToSidelobe[MethodPHAT]; phat < 1.1*standard {
+		t.Errorf("PHAT peak-to-sidelobe %.2f, want clearly above the standard %.2f", phat, standard)
+	}
+}
+
+func TestDetectOffsetWindowLeakage(t *testing.T) {
+	// Loud hum at nearby frequencies in each recording: cut off abruptly at the ends of the local track,
+	// the two leak into each other and build a broad correlation as large as the speech peak
+	hum := func(data []float64, freq, phase float64) []float64 {
+		result := make([]float64, len(data))
+		for i, v := range data {
+			result[i] = 0.2*v + 0.8*math.Sin(2*math.Pi*freq*float64(i)/testRate+phase)
+		}
+		return result
+	}
+	speech := testSignal(51, 30*testRate)
+	offset := 9 * testRate
+	mixed := hum(speech, 50, 0)
+	local := hum(speech[offset:offset+10*testRate], 53, 1)
+
+	tests := []struct {
+		window  WindowType
+		leakage bool
+	}{
+		{WindowNone, true},
+		{WindowHann, false},
+		{WindowTukey, false},
+	}
+
+	for _, tt := range tests {
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1, Window: tt.window})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.window, err)
+		}
+		if tt.leakage {
+			if result.PeakToSidelobe > 1.5 {
+				t.Errorf("%s: peak-to-sidelobe %.2f, want the leakage to compete with the peak", tt.window, result.PeakToSidelobe)
+			}
+			continue
+		}
+		// The window suppresses the leakage and leaves the true peak where it was
+		if result.OffsetSamples != offset || result.PeakToSidelobe < 5 {
+			t.Errorf("%s: offset %d (peak-to-sidelobe %.2f), want %d well above the sidelobes", tt.window, result.OffsetSamples, result.PeakToSidelobe, offset)
+		}
+	}
+}
+
+func TestDetectOffsetInverted(t *testing.T) {
+	mixed := testSignal(61, 30*testRate)
+	offset := 7*testRate + 16 // A multiple of the factor, so the decimated white noise still lines up
+	local := testLocal(mixed, offset, 15*testRate)
+
+	for _, inverted := range []bool{false, true} {
+		track := local
+		if inverted {
+			track = audio.InvertPolarity(local)
+		}
+		for _, factor := range []int{1, 8} {
+			result, err := DetectOffset(context.Background(), mixed, track, testRate, DetectOptions{DownsampleFactor: factor})
+			if err != nil {
+				t.Fatalf("inverted %v, factor %d: %v", inverted, factor, err)
+			}
+			// An inverted copy is found at the same offset with the same confidence, and reported as inverted
+			if result.Inverted != inverted || result.OffsetSamples != offset || result.Confidence < 0.9 {
+				t.Errorf("inverted %v, factor %d: offset %d (inverted %v, confidence %.2f), want %d (inverted %v)",
+					inverted, factor, result.OffsetSamples, result.Inverted, result.Confidence, offset, inverted)
+			}
+
+			fileOffsets := []*FileOffset{{Path: "guest.wav", Inverted: result.Inverted}}
+			if warnings := ValidatePolarity(fileOffsets); (len(warnings) == 1) != inverted {
+				t.Errorf("inverted %v, factor %d: polarity warnings %q", inverted, factor, warnings)
+			}
+		}
+	}
+}
+
+func TestDetectOffsetLevelMatchQuiet(t *testing.T) {
+	mixed := testSignal(71, 30*testRate)
+	offset := 6*testRate + 5
+	quiet := testLocal(mixed, offset, 15*testRate)
+	for i := range quiet {
+		quiet[i] *= 0.01 // -40 dB
+	}
+
+	result, err := DetectOffset(context.Background(), mixed, quiet, testRate, DetectOptions{DownsampleFactor: 1, LevelMatch: true})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if result.OffsetSamples != offset || result.Confidence < 0.9 {
+		t.Errorf("offset %d (confidence %.2f), want %d at high confidence", result.OffsetSamples, result.Confidence, offset)
+	}
+}
+
+func TestLevelMatchClampsGain(t *testing.T) {
+	// A loud block, a block 40 dB down and a silent block
+	block := int(levelMatchBlockSeconds * testRate)
+	data := make([]float64, 3*block)
+	for i := range block {
+		data[i] = 1
+		data[block+i] = 0.01
+	}
+
+	matched := levelMatch(data, testRate)
+	target := rms(data)
+	if got := matched[0]; math.Abs(got-target) > 1e-9 {
+		t.Errorf("loud block scaled to %g, want the overall level %g", got, target)
+	}
+	if got := matched[block]; math.Abs(got-0.01*levelMatchMaxGain) > 1e-9 {
+		t.Errorf("quiet block scaled to %g, want at most %gx", got, levelMatchMaxGain)
+	}
+	if matched[2*block] != 0 {
+		t.Errorf("silent block scaled to %g, want silence", matched[2*block])
+	}
+}
+
+func TestAutoDownsampleFactor(t *testing.T) {
+	tests := []struct {
+		name         string
+		rate         int
+		mixed, local int // Seconds
+		resolutionMs float64
+		want         int
+		withinBound  bool // The decimated signals fit in maxCoarseSamples
+	}{
+		{"short clips", 44100, 60, 30, 0, 1, true},
+		{"podcast episode", 48000, 600, 300, 0, 11, true},
+		{"long session", 16000, 3 * 3600, 3600, 0, 55, true},
+		{"day-long recording capped at 5ms", 8000, 24 * 3600, 24 * 3600, 0, 40, false},
+		{"finer resolution floor", 48000, 3 * 3600, 3600, 1, 48, false},
+	}
+
+	for _, tt := range tests {
+		mixed, local := tt.mixed*tt.rate, tt.local*tt.rate
+		factor := AutoDownsampleFactor(tt.rate, mixed, local, tt.resolutionMs)
+		if factor != tt.want {
+			t.Errorf("%s: factor %d, want %d", tt.name, factor, tt.want)
+		}
+		coarse := (mixed + local + factor - 1) / factor
+		if fits := coarse <= maxCoarseSamples; fits != tt.withinBound {
+			t.Errorf("%s: %d coarse samples within the bound = %v, want %v", tt.name, coarse, fits, tt.withinBound)
+		}
+		// The factor is the smallest within the bound, unless the resolution floor caps it
+		if tt.withinBound && factor > 1 && (mixed+local+factor-2)/(factor-1) <= maxCoarseSamples {
+			t.Errorf("%s: factor %d, but %d also fits", tt.name, factor, factor-1)
+		}
+	}
+}
+
+func TestPeakToSidelobeRatio(t *testing.T) {
+	correlation := []float64{0.1, 0.2, 1.0, 0.9, 0.2, -0.5, 0.1, 0.25}
+
+	tests := []struct {
+		name      string
+		exclusion int
+		want      float64
+	}{
+		{"main lobe excluded", 1, 2},    // 1.0 / |-0.5|
+		{"neighbor counts", 0, 1 / 0.9}, // 1.0 / 0.9
+		{"wraps around", 3, 10},         // Index 7 is 3 away from the peak across the end, leaving 1.0 / 0.1
+		{"no sidelobes", 4, 0},
+	}
+
+	for _, tt := range tests {
+		if got := peakToSidelobeRatio(correlation, 2, 1.0, tt.exclusion); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: peakToSidelobeRatio = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectOffsetPeakToSidelobe(t *testing.T) {
+	// A phrase repeated every two seconds matches at every repetition equally well
+	phrase := testSignal(10, 2*testRate)
+	var repetitive []float64
+	for range 10 {
+		repetitive = append(repetitive, phrase...)
+	}
+
+	tests := []struct {
+		name     string
+		mixed    []float64
+		min, max float64
+	}{
+		{"unique material", testSignal(11, 20*testRate), 3, math.Inf(1)},
+		{"repetitive material", repetitive, 0, 1.3},
+	}
+
+	for _, tt := range tests {
+		local := testLocal(tt.mixed, 5*testRate, 6*testRate)
+		result, err := DetectOffset(context.Background(), tt.mixed, local, testRate, DetectOptions{DownsampleFactor: 1})
+		if err != nil {
+			t.Fatalf("%s: DetectOffset: %v", tt.name, err)
+		}
+		if result.PeakToSidelobe < tt.min || result.PeakToSidelobe > tt.max {
+			t.Errorf("%s: peak-to-sidelobe ratio %.2f, want %g to %g", tt.name, result.PeakToSidelobe, tt.min, tt.max)
+		}
+	}
+}
+
+func TestDetectOffsetMaxOffset(t *testing.T) {
+	mixed := testSignal(12, 30*testRate)
+	const offset = 8 * testRate
+	local := testLocal(mixed, offset, 6*testRate)
+
+	tests := []struct {
+		name        string
+		maxOffset   float64
+		wantOutside bool
+		wantOffset  int // Checked only inside the window
+	}{
+		{"unlimited", 0, false, offset},
+		{"offset inside the window", 10, false, offset},
+		{"offset outside the window", 5, true, 0},
+	}
+
+	for _, tt := range tests {
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 4, MaxOffset: tt.maxOffset})
+		if err != nil {
+			t.Fatalf("%s: DetectOffset: %v", tt.name, err)
+		}
+		if result.OutsideWindow != tt.wantOutside {
+			t.Errorf("%s: OutsideWindow = %v, want %v", tt.name, result.OutsideWindow, tt.wantOutside)
+		}
+		if tt.wantOutside {
+			if limit := int(tt.maxOffset * testRate); result.OffsetSamples < -limit || result.OffsetSamples > limit {
+				t.Errorf("%s: offset %d lies beyond ±%d", tt.name, result.OffsetSamples, limit)
+			}
+		} else if result.OffsetSamples != tt.wantOffset {
+			t.Errorf("%s: offset %d, want %d", tt.name, result.OffsetSamples, tt.wantOffset)
+		}
+	}
+}
+
+func TestCrossCorrelateChunked(t *testing.T) {
+	tests := []struct {
+		name       string
+		len1, len2 int
+		fftSize    int
+	}{
+		{"many blocks", 5000, 3000, 512},
+		{"uneven blocks", 4321, 1234, 1024},
+		{"one block", 700, 300, 2048},
+		{"longer second signal", 1500, 4000, 512},
+	}
+
+	for _, tt := range tests {
+		signal1, signal2 := testSignal(31, tt.len1), testSignal(32, tt.len2)
+		whole := crossCorrelateFFT(signal1, signal2, MethodStandard)
+		chunked := crossCorrelateChunked(signal1, signal2, tt.fftSize)
+		if len(chunked) != tt.len1+tt.len2-1 {
+			t.Fatalf("%s: %d lags, want %d", tt.name, len(chunked), tt.len1+tt.len2-1)
+		}
+
+		// Compare every lag; the two results store negative lags from their own ends
+		maxErr := 0.0
+		for lag := -(tt.len2 - 1); lag < tt.len1; lag++ {
+			i, j := lag, lag
+			if lag < 0 {
+				i += len(whole)
+				j += len(chunked)
+			}
+			maxErr = math.Max(maxErr, math.Abs(whole[i]-chunked[j]))
+		}
+		if maxErr > 1e-9 {
+			t.Errorf("%s: chunked correlation differs by up to %g", tt.name, maxErr)
+		}
+	}
+}
+
+func TestDetectOffsetChunkedMatches(t *testing.T) {
+	mixed := testSignal(33, 30*testRate)
+	for _, offset := range []int{4321, -1500} {
+		local := testLocal(mixed, offset, 10*testRate)
+		whole, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunked, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 4, Chunked: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunked.OffsetSamples != whole.OffsetSamples || math.Abs(chunked.Confidence-whole.Confidence) > 1e-9 {
+			t.Errorf("offset %d: chunked found %d (confidence %f), monolithic %d (confidence %f)",
+				offset, chunked.OffsetSamples, chunked.Confidence, whole.OffsetSamples, whole.Confidence)
+		}
+	}
+}
+
+func TestDetectOffsetSubSamplePyramid(t *testing.T) {
+	// Band-limited like speech, so a fractional delay is well defined
+	mixed := BandpassFilter(testSignal(31, 60*testRate), testRate, 0, 1000)
+	offset := 7*testRate + 3
+
+	for _, delay := range []float64{0.25, 0.5, 0.75} {
+		// local[i] = mixed(offset + i - delay), so the true offset is offset - delay
+		local := audio.FractionalDelay(mixed, delay, 1)[offset : offset+30*testRate]
+
+		// The command's defaults: the pyramid runs from factor 8 down to full resolution
+		result, err := DetectOffset(context.Background(), mixed, local, testRate,
+			DetectOptions{DownsampleFactor: 8, BandpassLow: 300, BandpassHigh: 3400, Window: WindowTukey})
+		if err != nil {
+			t.Fatalf("delay %g: %v", delay, err)
+		}
+		if result.SubSampleOffset == 0 {
+			t.Errorf("delay %g: sub-sample offset 0, want non-zero", delay)
+		}
+		want := float64(offset) - delay
+		if got := float64(result.OffsetSamples) + result.SubSampleOffset; math.Abs(got-want) > 0.05 {
+			t.Errorf("delay %g: offset %d%+.3f, want %.3f", delay, result.OffsetSamples, result.SubSampleOffset, want)
+		}
+		if got := result.OffsetSeconds * testRate; math.Abs(got-want) > 0.05 {
+			t.Errorf("delay %g: offset %.3f samples in seconds, want %.3f", delay, got, want)
+		}
+	}
+}
+
+func TestDetectOffsetTrimSilence(t *testing.T) {
+	// Both tracks have long, different amounts of dead air around the speech
+	speech := testSignal(81, 20*testRate)
+	mixed := slices.Concat(make([]float64, 10*testRate), speech, make([]float64, 5*testRate))
+
+	tests := []struct {
+		factor    int
+		localLead int // Decimated noise only lines up at leads that are a multiple of the factor
+	}{
+		{1, 3*testRate + 123},
+		{8, 3*testRate + 120},
+	}
+
+	for _, tt := range tests {
+		local := slices.Concat(make([]float64, tt.localLead), mixed[14*testRate:24*testRate], make([]float64, 2*testRate))
+		offset := 14*testRate - tt.localLead // Where the untrimmed local track starts in the untrimmed mixed track
+
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: tt.factor, TrimSilenceDB: -50})
+		if err != nil {
+			t.Fatalf("factor %d: DetectOffset: %v", tt.factor, err)
+		}
+		if result.OffsetSamples != offset {
+			t.Errorf("factor %d: OffsetSamples = %d, want %d on the untrimmed timeline", tt.factor, result.OffsetSamples, offset)
+		}
+	}
+}
+
+func TestDetectOffsetBackoff(t *testing.T) {
+	// Decimated white noise only lines up when the offset is a multiple of the factor,
+	// so a factor of 64 loses the peak of an offset that is a multiple of 16 only
+	mixed := testSignal(101, 30*testRate)
+	offset := 7*testRate + 16
+	local := testLocal(mixed, offset, 12*testRate)
+
+	coarse, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 64})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if coarse.Confidence >= 0.3 || coarse.Backoffs != 0 {
+		t.Fatalf("without backoff: confidence %.2f after %d backoffs, want a lost peak", coarse.Confidence, coarse.Backoffs)
+	}
+
+	result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 64, BackoffBelow: 0.3})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if result.OffsetSamples != offset || result.Confidence < 0.9 {
+		t.Errorf("with backoff: offset %d (confidence %.2f), want %d at high confidence", result.OffsetSamples, result.Confidence, offset)
+	}
+	if result.Downsample != 16 || result.Backoffs != 2 {
+		t.Errorf("with backoff: found at downsample %d after %d backoffs, want 16 after 2", result.Downsample, result.Backoffs)
+	}
+}
+
+func TestDetectOffsetExcludeLag(t *testing.T) {
+	// The local track is the start of the mix, which it matches exactly at lag zero,
+	// but the match wanted is a quieter, noisy repeat of it later on
+	local := testSignal(111, 4*testRate)
+	noise := testSignal(112, 12*testRate)
+	mixed := scaled(noise, 0.05)
+	repeat := 5 * testRate
+	for i, v := range local {
+		mixed[i] += v
+		mixed[repeat+i] += 0.5 * v
+	}
+
+	for _, factor := range []int{1, 8} {
+		result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: factor})
+		if err != nil {
+			t.Fatalf("factor %d: DetectOffset: %v", factor, err)
+		}
+		if result.OffsetSamples != 0 {
+			t.Fatalf("factor %d: offset %d without exclusion, want the zero-lag match", factor, result.OffsetSamples)
+		}
+
+		result, err = DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: factor, ExcludeLag: 0.5})
+		if err != nil {
+			t.Fatalf("factor %d: DetectOffset: %v", factor, err)
+		}
+		if result.OffsetSamples != repeat {
+			t.Errorf("factor %d: offset %d with lags around zero excluded, want %d", factor, result.OffsetSamples, repeat)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package sync
+
+import "context"
+
+// Detector finds the coarse offset of a mono local track within a mono mixed track
+// The result follows DetectOffset: a local track equal to mixed[n:] has OffsetSamples n.
+// Local files are detected in parallel, so implementations must be safe for concurrent use.
+// Fine-tuning still refines the offset it returns unless the result is Manual and KeepManual is set.
+type Detector interface {
+	Detect(ctx context.Context, mixed, local []float64, sampleRate int) (*OffsetResult, error)
+}
+
+// Correlator is the default Detector, finding the offset by FFT cross-correlation with DetectOffset
+type Correlator struct {
+	Options DetectOptions
+}
+
+// Detect runs DetectOffset with the correlator's options
+func (c Correlator) Detect(ctx context.Context, mixed, local []float64, sampleRate int) (*OffsetResult, error) {
+	return DetectOffset(ctx, mixed, local, sampleRate, c.Options)
+}
+
+// CoarseDetector returns the Detector set in the options, or a Correlator using them
+func (o DetectOptions) CoarseDetector() Detector {
+	if o.Detector != nil {
+		return o.Detector
+	}
+	return Correlator{Options: o}
+}
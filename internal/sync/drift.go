@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+const (
+	driftWindowSeconds  = 30.0  // Length of each window correlated to measure drift
+	driftMinSpanSeconds = 120.0 // Minimum distance between the start and end windows
+	driftPasses         = 3     // Number of refinement passes
+)
+
+// DriftResult contains the estimated linear clock drift of a single file relative to the mixed track
+type DriftResult struct {
+	PPM             float64 `json:"ppm"`               // Drift in parts per million (positive = local clock runs slow, so the file is stretched)
+	Ratio           float64 `json:"ratio"`             // Length ratio applied to the local file (1 + PPM/1e6)
+	StartLagSamples float64 `json:"start_lag_samples"` // Lag measured near the start of the overlap before correction
+	EndLagSamples   float64 `json:"end_lag_samples"`   // Lag measured near the end of the overlap before correction
+	SpanSeconds     float64 `json:"span_seconds"`      // Distance between the two measurement windows
+	Skipped         bool    `json:"skipped"`
+	SkipReason      string  `json:"skip_reason,omitempty"`
+
+	offsetSamples float64 // Offset of the file's first sample once the drift is corrected
+}
+
+// EstimateDrift measures how the residual lag between the mixed and local tracks changes
+// across the overlap by correlating a window near its start and another near its end
+// mixed and local are mono; fo must already hold the final offset from fine-tuning.
+// Drift also smears the lag within each window, so the estimate is refined over a few
+// passes, each measuring the residual drift of the local track stretched by the previous estimate.
+func EstimateDrift(ctx context.Context, mixed, local []float64, fo *FileOffset, sampleRate int, opts DetectOptions) *DriftResult {
+	window := int(driftWindowSeconds * float64(sampleRate))
+	minSpan := int(driftMinSpanSeconds * float64(sampleRate))
+
+	result := &DriftResult{Ratio: 1}
+	offset := float64(fo.FinalOffsetSamples)
+
+	for pass := 0; pass < driftPasses; pass++ {
+		intOffset := int(math.Round(offset))
+		stretchedLength := int(float64(len(local)) * result.Ratio)
+
+		// Overlap with the mixed track in (stretched) local sample positions
+		start := max(0, -intOffset)
+		end := min(stretchedLength, len(mixed)-intOffset)
+
+		// The windows start at the overlap edges, so their centers are end-start-window apart
+		span := end - start - window
+		if span < minSpan {
+			return &DriftResult{
+				Skipped:    true,
+				SkipReason: fmt.Sprintf("overlap too short to measure drift (need %.0fs)", driftMinSpanSeconds+driftWindowSeconds),
+			}
+		}
+
+		startLag, err := windowLag(ctx, mixed, local, result.Ratio, intOffset, start, window, sampleRate, opts)
+		if err != nil {
+			return &DriftResult{Skipped: true, SkipReason: fmt.Sprintf("correlation failed: %v", err)}
+		}
+		endLag, err := windowLag(ctx, mixed, local, result.Ratio, intOffset, end-window, window, sampleRate, opts)
+		if err != nil {
+			return &DriftResult{Skipped: true, SkipReason: fmt.Sprintf("correlation failed: %v", err)}
+		}
+
+		// The local sample at position i lands at mixed position offset + i + lag(i), with lag(i) linear in i
+		// Stretching the file by (1 + rate) makes lag constant, leaving only a shift of the first sample
+		rate := (endLag - startLag) / float64(span)
+		startCenter := float64(start + window/2)
+		offset = float64(intOffset) + startLag - rate*startCenter
+
+		if pass == 0 {
+			result.StartLagSamples = startLag
+			result.EndLagSamples = endLag
+			result.SpanSeconds = float64(span) / float64(sampleRate)
+		}
+		result.Ratio *= 1 + rate
+	}
+
+	result.PPM = (result.Ratio - 1) * 1e6
+	result.offsetSamples = offset
+	return result
+}
+
+// windowLag correlates a window of the local track, stretched by ratio, with the mixed audio
+// where the current offset places it and returns the residual lag in (fractional) samples
+func windowLag(ctx context.Context, mixed, local []float64, ratio float64, offset, localStart, window, sampleRate int, opts DetectOptions) (float64, error) {
+	localSegment := stretchedSegment(local, ratio, localStart, window)
+	mixedSegment, err := extractSegment(mixed, localStart+offset, localStart+offset+window)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DetectOffset(ctx, mixedSegment, localSegment, sampleRate, fineOptions(opts))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.OffsetSeconds * float64(sampleRate), nil
+}
+
+// stretchedSegment returns length samples starting at start of data stretched by ratio,
+// interpolating only the requested range instead of stretching the whole signal
+func stretchedSegment(data []float64, ratio float64, start, length int) []float64 {
+	segment := make([]float64, length)
+	last := len(data) - 1
+	for i := range segment {
+		pos := float64(start+i) / ratio
+		idx := min(int(pos), last)
+		next := min(idx+1, last)
+		frac := pos - float64(idx)
+		segment[i] = data[idx] + (data[next]-data[idx])*frac
+	}
+	return segment
+}
+
+// CorrectDrift estimates the clock drift of each local file, stretches its data to cancel it
+// and moves its final offset to where the stretched file starts
+// Files whose drift cannot be measured keep their data and offset unchanged
+// If ctx is cancelled the remaining files are left uncorrected and ctx.Err() is returned.
+func CorrectDrift(
+	ctx context.Context,
+	mixed []float64,
+	localFiles []*audio.WAVData,
+	fileOffsets []*FileOffset,
+	sampleRate int,
+	opts DetectOptions,
+) ([]*FileOffset, error) {
+	if len(localFiles) != len(fileOffsets) {
+		return nil, fmt.Errorf("mismatch between local files (%d) and file offsets (%d)", len(localFiles), len(fileOffsets))
+	}
+
+	for i, localFile := range localFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		fo := fileOffsets[i]
+		localMono, err := audio.ToMonoMixdown(localFile.Data, localFile.Channels, opts.Mixdown)
+		if err != nil {
+			fo.Drift = &DriftResult{Skipped: true, SkipReason: err.Error()}
+			continue
+		}
+
+		drift := EstimateDrift(ctx, mixed, localMono, fo, sampleRate, opts)
+		fo.Drift = drift
+		if drift.Skipped {
+			continue
+		}
+
+		localFile.Data = audio.Stretch(localFile.Data, drift.Ratio, localFile.Channels)
+		fo.FinalOffsetSamples = int(math.Round(drift.offsetSamples))
+		fo.FinalOffsetSeconds = drift.offsetSamples / float64(sampleRate)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Padding depends on the corrected final offsets
+	return RecalculatePadding(fileOffsets, sampleRate)
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+func TestEstimateDrift(t *testing.T) {
+	mixed := testSignal(7, 200*testRate)
+	const offset = 10 * testRate
+
+	tests := []struct {
+		name string
+		ppm  float64 // Positive = the local clock runs slow, so its recording is shorter
+	}{
+		{"no drift", 0},
+		{"slow clock", 100},
+		{"fast clock", -50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := audio.Stretch(mixed[offset:offset+180*testRate], 1/(1+tt.ppm/1e6), 1)
+			fo := &FileOffset{FinalOffsetSamples: offset}
+
+			drift := EstimateDrift(context.Background(), mixed, local, fo, testRate, DetectOptions{})
+			if drift.Skipped {
+				t.Fatalf("drift estimation skipped: %s", drift.SkipReason)
+			}
+			if math.Abs(drift.PPM-tt.ppm) > 2 {
+				t.Errorf("PPM = %.2f, want %.0f", drift.PPM, tt.ppm)
+			}
+			if math.Abs(drift.offsetSamples-offset) > 1 {
+				t.Errorf("corrected offset = %.2f samples, want %d", drift.offsetSamples, offset)
+			}
+		})
+	}
+}
+
+func TestEstimateDriftShortOverlap(t *testing.T) {
+	mixed := testSignal(8, 100*testRate)
+	drift := EstimateDrift(context.Background(), mixed, mixed[:60*testRate], &FileOffset{}, testRate, DetectOptions{})
+	if !drift.Skipped {
+		t.Errorf("drift of a 60s overlap was measured (%.2f ppm), want it skipped", drift.PPM)
+	}
+}
@@ -0,0 +1,34 @@
+package sync
+
+import "math"
+
+// hilbertEnvelope returns the magnitude of the analytic signal of data, |data + i·H(data)|
+// The analytic signal keeps only the positive frequencies; with a real FFT this is the same as rotating
+// every positive bin by -90° to get the Hilbert transform H. The envelope follows the amplitude of the
+// signal but not its phase, so tracks whose waveforms were altered by re-encoding still line up.
+// The signal is zero-padded to a power of two, which keeps the circular transform from wrapping its ends together.
+func hilbertEnvelope(data []float64) []float64 {
+	if len(data) < 2 {
+		return make([]float64, len(data))
+	}
+	fftSize := nextPowerOfTwo(2 * len(data))
+
+	fft := acquireFFT(fftSize)
+	defer releaseFFT(fft)
+
+	coefficients := fft.Coefficients(nil, padToSize(data, fftSize))
+	// DC and Nyquist have no negative counterpart, so they do not contribute to H
+	coefficients[0] = 0
+	coefficients[len(coefficients)-1] = 0
+	for k, c := range coefficients {
+		coefficients[k] = complex(imag(c), -real(c))
+	}
+	hilbert := fft.Sequence(nil, coefficients)
+
+	// Gonum FFT is unnormalized, as in crossCorrelateFFT
+	envelope := make([]float64, len(data))
+	for i, v := range data {
+		envelope[i] = math.Hypot(v, hilbert[i]/float64(fftSize))
+	}
+	return envelope
+}
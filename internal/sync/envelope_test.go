@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// modulated returns a 1 kHz carrier at testRate under the amplitude envelope, its phase wandering at random
+// by up to wander radians per sample (0 = a clean carrier)
+func modulated(envelope []float64, wander float64, seed uint64) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 2))
+	data := make([]float64, len(envelope))
+	phase := 0.0
+	for i, a := range envelope {
+		phase += 2*math.Pi*1000/testRate + wander*(2*rng.Float64()-1)
+		data[i] = a * math.Cos(phase)
+	}
+	return data
+}
+
+// burstEnvelope returns a smooth envelope of bursts at random levels, as of speech
+func burstEnvelope(seed uint64, length int) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 3))
+	envelope := make([]float64, length)
+	level := 0.0
+	for i := 0; i < length; {
+		burst := int((0.05 + 0.2*rng.Float64()) * testRate)
+		target := 0.05 + 0.5*rng.Float64()
+		for j := i; j < min(i+burst, length); j++ {
+			level += (target - level) / 40 // Smooth the steps over about 5 ms
+			envelope[j] = level
+		}
+		i += burst
+	}
+	return envelope
+}
+
+func TestHilbertEnvelope(t *testing.T) {
+	envelope := make([]float64, testRate)
+	for i := range envelope {
+		envelope[i] = 0.5 + 0.25*math.Sin(2*math.Pi*3*float64(i)/testRate)
+	}
+	got := hilbertEnvelope(modulated(envelope, 0, 1))
+
+	// The ends of the finite signal ripple, so only the middle is compared
+	for i := testRate / 10; i < testRate*9/10; i++ {
+		if math.Abs(got[i]-envelope[i]) > 0.01 {
+			t.Fatalf("sample %d: envelope %g, want %g", i, got[i], envelope[i])
+		}
+	}
+}
+
+func TestDetectOffsetEnvelope(t *testing.T) {
+	envelope := burstEnvelope(11, 20*testRate)
+	offset := 6*testRate + 77
+	mixed := modulated(envelope, 0, 12)
+	// The same amplitude envelope, but with a carrier whose phase has nothing to do with the mix
+	local := modulated(envelope[offset:offset+8*testRate], 0.3, 13)
+
+	raw, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if raw.OffsetSamples == offset && raw.Confidence >= 0.3 {
+		t.Fatalf("raw correlation found the offset (confidence %.2f); the carrier is not scrambled enough", raw.Confidence)
+	}
+
+	result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1, Method: MethodEnvelope})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if diff := result.OffsetSamples - offset; diff < -2 || diff > 2 || result.Confidence < 0.9 {
+		t.Errorf("envelope: offset %d (confidence %.2f), want %d at high confidence", result.OffsetSamples, result.Confidence, offset)
+	}
+}
@@ -0,0 +1,32 @@
+package sync
+
+import (
+	gosync "sync"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// fftPlans holds a pool of reusable FFT plans for each transform size
+// A fourier.FFT keeps scratch buffers and must not be shared between goroutines,
+// so plans are pooled per size rather than shared: concurrent detections of
+// equal-length files reuse the twiddle tables without racing on them.
+var fftPlans gosync.Map // int -> *gosync.Pool
+
+// acquireFFT returns an FFT plan for sequences of length n
+// The plan must be handed back with releaseFFT once the caller is done with it.
+func acquireFFT(n int) *fourier.FFT {
+	pool, ok := fftPlans.Load(n)
+	if !ok {
+		pool, _ = fftPlans.LoadOrStore(n, &gosync.Pool{
+			New: func() any { return fourier.NewFFT(n) },
+		})
+	}
+	return pool.(*gosync.Pool).Get().(*fourier.FFT)
+}
+
+// releaseFFT returns a plan obtained from acquireFFT to its pool
+func releaseFFT(fft *fourier.FFT) {
+	if pool, ok := fftPlans.Load(fft.Len()); ok {
+		pool.(*gosync.Pool).Put(fft)
+	}
+}
@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAcquireFFTConcurrent(t *testing.T) {
+	// Plans of the same size are used at once by parallel detections; each needs its own scratch space
+	done := make(chan bool)
+	for range 8 {
+		go func() {
+			signal := testSignal(5, 1024)
+			exact := true
+			for range 20 {
+				fft := acquireFFT(len(signal))
+				back := fft.Sequence(nil, fft.Coefficients(nil, signal))
+				for i := range back {
+					if diff := back[i]/float64(len(signal)) - signal[i]; diff > 1e-9 || diff < -1e-9 {
+						exact = false
+					}
+				}
+				releaseFFT(fft)
+			}
+			done <- exact
+		}()
+	}
+	for range 8 {
+		if !<-done {
+			t.Error("a transform with a shared plan did not round trip")
+		}
+	}
+}
+
+// BenchmarkDetectOffsetsFFTPlans detects eight equal-length files in parallel, as the coarse search does,
+// reusing FFT plans between them or making new plans for every file
+func BenchmarkDetectOffsetsFFTPlans(b *testing.B) {
+	mixed := testSignal(21, 120*testRate)
+	locals := make([][]float64, 8)
+	for i := range locals {
+		locals[i] = testLocal(mixed, (5+7*i)*testRate, 60*testRate)
+	}
+	opts := DetectOptions{DownsampleFactor: 8}
+
+	for _, cached := range []bool{true, false} {
+		name := "cached"
+		if !cached {
+			name = "uncached"
+		}
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				ForEachLimited(len(locals), 0, func(i int) {
+					if !cached {
+						fftPlans.Clear()
+					}
+					if _, err := DetectOffset(context.Background(), mixed, locals[i], testRate, opts); err != nil {
+						b.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
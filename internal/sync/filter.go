@@ -0,0 +1,42 @@
+package sync
+
+// BandpassFilter removes frequency content outside [lowHz, highHz] using an FFT-domain brick-wall filter
+// A lowHz of 0 disables the high-pass side, and a highHz of 0 (or above Nyquist) disables the low-pass side.
+// If lowHz is at or above Nyquist the passband would be empty, so the data is returned unfiltered
+// (this happens when heavy downsampling leaves a low coarse rate, e.g. 16 kHz audio decimated by 50).
+func BandpassFilter(data []float64, sampleRate, lowHz, highHz int) []float64 {
+	if len(data) == 0 || sampleRate <= 0 {
+		return data
+	}
+
+	nyquist := sampleRate / 2
+	if lowHz >= nyquist {
+		return data
+	}
+	if lowHz <= 0 && (highHz <= 0 || highHz >= nyquist) {
+		return data
+	}
+
+	fftSize := nextPowerOfTwo(len(data))
+	fft := acquireFFT(fftSize)
+	defer releaseFFT(fft)
+	coeffs := fft.Coefficients(nil, padToSize(data, fftSize))
+
+	// Zero every bin outside the passband
+	for i := range coeffs {
+		freq := fft.Freq(i) * float64(sampleRate)
+		if freq < float64(lowHz) || (highHz > 0 && freq > float64(highHz)) {
+			coeffs[i] = 0
+		}
+	}
+
+	filtered := fft.Sequence(nil, coeffs)
+
+	// Gonum FFT is unnormalized - need to divide by fftSize
+	result := make([]float64, len(data))
+	for i := range result {
+		result[i] = filtered[i] / float64(fftSize)
+	}
+
+	return result
+}
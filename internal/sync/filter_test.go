@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"math"
+	"testing"
+)
+
+// toneLevel returns the RMS of the freq Hz sine component of data
+func toneLevel(data []float64, freq float64, sampleRate int) float64 {
+	var re, im float64
+	for i, v := range data {
+		phase := 2 * math.Pi * freq * float64(i) / float64(sampleRate)
+		re += v * math.Cos(phase)
+		im += v * math.Sin(phase)
+	}
+	return math.Hypot(re, im) / float64(len(data)) * math.Sqrt2
+}
+
+func TestBandpassFilter(t *testing.T) {
+	const rate = 8000
+	data := make([]float64, rate)
+	for i := range data {
+		x := float64(i) / rate
+		data[i] = math.Sin(2*math.Pi*50*x) + math.Sin(2*math.Pi*1000*x) + math.Sin(2*math.Pi*3500*x)
+	}
+
+	filtered := BandpassFilter(data, rate, 300, 3000)
+	for _, tt := range []struct {
+		freq float64
+		pass bool
+	}{{50, false}, {1000, true}, {3500, false}} {
+		level := toneLevel(filtered, tt.freq, rate)
+		if tt.pass && math.Abs(level-1/math.Sqrt2) > 0.05 {
+			t.Errorf("%g Hz inside the passband has level %f, want %f", tt.freq, level, 1/math.Sqrt2)
+		}
+		if !tt.pass && level > 0.05 {
+			t.Errorf("%g Hz outside the passband has level %f, want about 0", tt.freq, level)
+		}
+	}
+}
+
+func TestBandpassFilterAboveNyquist(t *testing.T) {
+	data := testSignal(4, 1000)
+
+	tests := []struct {
+		name            string
+		rate, low, high int
+	}{
+		{"lower cutoff above Nyquist", 400, 300, 3400}, // e.g. 16 kHz decimated by 40
+		{"lower cutoff at Nyquist", 600, 300, 3400},
+		{"no cutoffs", 8000, 0, 0},
+		{"upper cutoff above Nyquist only", 4000, 0, 3400},
+	}
+
+	for _, tt := range tests {
+		got := BandpassFilter(data, tt.rate, tt.low, tt.high)
+		if &got[0] != &data[0] {
+			t.Errorf("%s: the data was filtered, want it returned unchanged", tt.name)
+		}
+	}
+}
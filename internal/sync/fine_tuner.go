@@ -1,26 +1,48 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/shidetake/clapless/internal/audio"
 )
 
+// ErrNoOverlap is wrapped when the coarsely aligned files share no common time range to fine-tune on
+var ErrNoOverlap = errors.New("no overlapping region found after coarse alignment")
+
+const (
+	finetuneBlockSeconds   = 1.0  // Resolution at which candidate fine-tuning segments are compared
+	finetuneMinGain        = 1.1  // Energy ratio over the centered segment required to move away from the center
+	defaultFinetuneTarget  = 60.0 // Fine-tuning segment length in seconds when DetectOptions.FinetuneTarget is 0
+	defaultFinetuneMinimum = 30.0 // Minimum overlap in seconds when DetectOptions.FinetuneMin is 0
+)
+
+// TimelineTrack is audio positioned on the aligned timeline, used to find where every track has signal
+type TimelineTrack struct {
+	Data     []float64 // Interleaved samples, possibly decimated
+	Channels int       // Number of interleaved channels
+	Offset   int       // Position of the first frame on the aligned timeline (full-rate samples)
+	Factor   int       // Decimation of Data (0 or 1 = full rate)
+}
+
 // OverlapRegion represents the temporal region where all files have data after coarse alignment
 type OverlapRegion struct {
-	StartSample int     // Start position in samples (on aligned timeline)
-	EndSample   int     // End position in samples
-	DurationSec float64 // Duration in seconds
+	StartSample int     `json:"start_sample"` // Start position in samples (on aligned timeline)
+	EndSample   int     `json:"end_sample"`   // End position in samples
+	DurationSec float64 `json:"duration_sec"` // Duration in seconds
 }
 
 // FinetuneResult contains the result of fine-tuning for a single file
 type FinetuneResult struct {
-	FineAdjustmentSamples int     // Adjustment to ADD to coarse offset (positive = shift later)
-	FineAdjustmentSeconds float64 // Adjustment to ADD to coarse offset (positive = shift later)
-	Confidence            float64 // Confidence score
-	SegmentUsed           OverlapRegion
-	Skipped               bool
-	SkipReason            string
+	FineAdjustmentSamples int           `json:"fine_adjustment_samples"` // Adjustment to ADD to coarse offset (positive = shift later)
+	FineAdjustmentSeconds float64       `json:"fine_adjustment_seconds"` // Adjustment to ADD to coarse offset (positive = shift later)
+	Confidence            float64       `json:"confidence"`              // Confidence score
+	PeakToSidelobe        float64       `json:"peak_to_sidelobe"`        // Peak-to-sidelobe ratio of the fine correlation
+	SegmentUsed           OverlapRegion `json:"segment_used"`
+	Skipped               bool          `json:"skipped"`
+	SkipReason            string        `json:"skip_reason,omitempty"`
 }
 
 // extractSegment extracts a portion of audio data
@@ -35,47 +57,42 @@ func extractSegment(data []float64, startSample, endSample int) ([]float64, erro
 	return segment, nil
 }
 
-// findOverlappingRegion determines where all files have data after coarse alignment
+// findOverlappingRegion determines where all files (including the mixed track) have data after coarse alignment
+// localLengths holds the length of each local file in mono samples
 func findOverlappingRegion(
-	localFiles []*audio.WAVData,
+	localLengths []int,
 	fileOffsets []*FileOffset,
+	mixedLength int,
 	sampleRate int,
 ) (*OverlapRegion, error) {
-	if len(localFiles) == 0 {
+	if len(localLengths) == 0 {
 		return nil, fmt.Errorf("no local files provided")
 	}
 
-	// Calculate start and end positions for each file on the aligned timeline
-	var overlapStart int
-	var overlapEnd int
-
-	for i, localFile := range localFiles {
-		// Convert to mono to get actual sample count
-		monoSamples := len(localFile.Data) / localFile.Channels
+	// The aligned timeline is the mixed track's timeline, so start from its extent.
+	// Local files with negative offsets start before the mixed track and are clipped here.
+	overlapStart := 0
+	overlapEnd := mixedLength
 
+	// Calculate start and end positions for each file on the aligned timeline
+	for i, monoSamples := range localLengths {
 		// This file starts at its offset and ends at offset + length
 		fileStart := fileOffsets[i].OffsetSamples
 		fileEnd := fileStart + monoSamples
 
-		if i == 0 {
+		// Overlap starts at the latest start time
+		if fileStart > overlapStart {
 			overlapStart = fileStart
+		}
+		// Overlap ends at the earliest end time
+		if fileEnd < overlapEnd {
 			overlapEnd = fileEnd
-		} else {
-			// Overlap starts at the latest start time
-			if fileStart > overlapStart {
-				overlapStart = fileStart
-			}
-			// Overlap ends at the earliest end time
-			if fileEnd < overlapEnd {
-				overlapEnd = fileEnd
-			}
 		}
 	}
 
 	// Validate overlap exists
 	if overlapEnd <= overlapStart {
-		return nil, fmt.Errorf("no overlapping region found after coarse alignment (start: %d, end: %d)",
-			overlapStart, overlapEnd)
+		return nil, fmt.Errorf("%w (start: %d, end: %d)", ErrNoOverlap, overlapStart, overlapEnd)
 	}
 
 	return &OverlapRegion{
@@ -85,11 +102,27 @@ func findOverlappingRegion(
 	}, nil
 }
 
+// ComputeOverlap returns the region of the mixed track's timeline where the mixed track (mixedLength mono samples)
+// and every local file have data at their coarse offsets; fine-tuning picks its segment from this region
+// It returns an error wrapping ErrNoOverlap if the tracks share no material.
+func ComputeOverlap(localFiles []*audio.WAVData, fileOffsets []*FileOffset, mixedLength, sampleRate int) (*OverlapRegion, error) {
+	localLengths := make([]int, len(localFiles))
+	for i, localFile := range localFiles {
+		localLengths[i] = len(localFile.Data) / localFile.Channels
+	}
+	return findOverlappingRegion(localLengths, fileOffsets, mixedLength, sampleRate)
+}
+
 // selectFinetuneSegment chooses the segment to use for fine-tuning
+// When the overlap is longer than the target, the segment is placed at position if it is not negative.
+// Otherwise the segment whose quietest track is loudest is used, so silent passages are avoided;
+// without tracks, or if no segment is clearly better, the centered one is used.
 func selectFinetuneSegment(
 	overlap *OverlapRegion,
+	tracks []TimelineTrack,
 	targetDuration float64, // Target duration in seconds (e.g., 60.0)
-	minDuration float64,    // Minimum acceptable duration (e.g., 30.0)
+	minDuration float64, // Minimum acceptable duration (e.g., 30.0)
+	position float64, // Position in the overlap, clamped to 0 (start) to 1 (end); negative = choose by energy
 	sampleRate int,
 ) (startSample, endSample int, err error) {
 	targetSamples := int(targetDuration * float64(sampleRate))
@@ -102,19 +135,103 @@ func selectFinetuneSegment(
 			overlap.DurationSec, minDuration)
 	}
 
-	// If overlap is larger than target, use centered segment
+	// If overlap is larger than target, use the segment at position or the most energetic (or centered) one
 	if overlapSamples >= targetSamples {
+		if position >= 0 {
+			slack := float64(overlapSamples - targetSamples)
+			start := overlap.StartSample + int(math.Round(math.Min(position, 1)*slack))
+			return start, start + targetSamples, nil
+		}
 		center := overlap.StartSample + overlapSamples/2
-		halfTarget := targetSamples / 2
-		return center - halfTarget, center - halfTarget + targetSamples, nil
+		start := center - targetSamples/2
+		if energetic, ok := energeticSegmentStart(overlap, tracks, targetSamples, sampleRate); ok {
+			start = energetic
+		}
+		return start, start + targetSamples, nil
 	}
 
 	// Use entire overlap
 	return overlap.StartSample, overlap.EndSample, nil
 }
 
-// recalculatePadding recalculates padding based on final offsets
-func recalculatePadding(fileOffsets []*FileOffset, sampleRate int) ([]*FileOffset, error) {
+// energeticSegmentStart slides a window of targetSamples over the overlap in steps of one block
+// and returns the start of the window with the highest energy in its quietest track
+// It reports false when there are no tracks or the best window is not clearly better than the centered one.
+func energeticSegmentStart(overlap *OverlapRegion, tracks []TimelineTrack, targetSamples, sampleRate int) (int, bool) {
+	blockSize := max(int(finetuneBlockSeconds*float64(sampleRate)), 1)
+	blocks := (overlap.EndSample - overlap.StartSample) / blockSize
+	windowBlocks := targetSamples / blockSize
+	if len(tracks) == 0 || windowBlocks < 1 || blocks <= windowBlocks {
+		return 0, false
+	}
+
+	// Energy of each block, taken from the quietest track
+	score := make([]float64, blocks)
+	for b := range score {
+		score[b] = math.Inf(1)
+	}
+	for _, track := range tracks {
+		for b, e := range blockEnergies(track, overlap.StartSample, blocks, blockSize) {
+			score[b] = math.Min(score[b], e)
+		}
+	}
+
+	// Window sums for every window start
+	sums := make([]float64, blocks-windowBlocks+1)
+	for b := 0; b < windowBlocks; b++ {
+		sums[0] += score[b]
+	}
+	best := 0
+	for w := 1; w < len(sums); w++ {
+		sums[w] = sums[w-1] - score[w-1] + score[w+windowBlocks-1]
+		if sums[w] > sums[best] {
+			best = w
+		}
+	}
+
+	// Keep the centered window unless another one has clearly more energy
+	center := (len(sums) - 1) / 2
+	if sums[best] <= sums[center]*finetuneMinGain {
+		return 0, false
+	}
+	return overlap.StartSample + best*blockSize, true
+}
+
+// blockEnergies returns the mean squared sample value of track in each of blocks consecutive
+// blocks of blockSize samples starting at start on the aligned timeline (0 where the track has no data)
+func blockEnergies(track TimelineTrack, start, blocks, blockSize int) []float64 {
+	factor := max(track.Factor, 1)
+	channels := max(track.Channels, 1)
+	frames := len(track.Data) / channels
+
+	// firstFrame returns the first frame at or after timeline position pos
+	firstFrame := func(pos int) int {
+		rel := pos - track.Offset
+		if rel <= 0 {
+			return 0
+		}
+		return min((rel+factor-1)/factor, frames)
+	}
+
+	energies := make([]float64, blocks)
+	for b := range energies {
+		from := firstFrame(start + b*blockSize)
+		to := firstFrame(start + (b+1)*blockSize)
+		if to <= from {
+			continue
+		}
+
+		sum := 0.0
+		for _, v := range track.Data[from*channels : to*channels] {
+			sum += v * v
+		}
+		energies[b] = sum / float64((to-from)*channels)
+	}
+	return energies
+}
+
+// RecalculatePadding recalculates padding based on final offsets
+func RecalculatePadding(fileOffsets []*FileOffset, sampleRate int) ([]*FileOffset, error) {
 	if len(fileOffsets) == 0 {
 		return nil, fmt.Errorf("no file offsets provided")
 	}
@@ -138,119 +255,237 @@ func recalculatePadding(fileOffsets []*FileOffset, sampleRate int) ([]*FileOffse
 	return fileOffsets, nil
 }
 
+// SkipFinetune marks a file as not fine-tuned and keeps its coarse offset
+func SkipFinetune(fo *FileOffset, reason string) {
+	fo.FinetuneResult = &FinetuneResult{
+		Skipped:    true,
+		SkipReason: reason,
+	}
+	fo.FinalOffsetSamples = fo.OffsetSamples
+	fo.FinalOffsetSeconds = fo.OffsetSeconds
+}
+
+// finetuneDurations returns the target and minimum fine-tuning segment lengths in seconds
+func finetuneDurations(opts DetectOptions) (target, minimum float64) {
+	target, minimum = defaultFinetuneTarget, defaultFinetuneMinimum
+	if opts.FinetuneTarget > 0 {
+		target = opts.FinetuneTarget
+	}
+	if opts.FinetuneMin > 0 {
+		minimum = opts.FinetuneMin
+	}
+	return target, minimum
+}
+
+// finetunePosition returns where the fine-tuning segment is placed in the overlap, or -1 to choose it by energy
+func finetunePosition(opts DetectOptions) float64 {
+	if !opts.FinetuneFixed {
+		return -1
+	}
+	return opts.FinetunePosition
+}
+
+// fineOptions returns the correlator settings for a search around an already known offset:
+// full resolution, and none of the limits that only make sense for the coarse search
+func fineOptions(opts DetectOptions) DetectOptions {
+	opts.DownsampleFactor = 1
+	opts.MaxOffset = 0
+	opts.ExcludeLag = 0
+	opts.CoarseSegment = 0
+	opts.TrimSilenceDB = 0
+	opts.OnCorrelation = nil
+	if opts.Method == MethodOnset {
+		opts.Method = MethodStandard // Onset envelopes only resolve to a hop; the waveforms give the exact lag
+	}
+	return opts
+}
+
+// skipAllFinetune marks every file as not fine-tuned and recalculates padding from the coarse offsets
+func skipAllFinetune(fileOffsets []*FileOffset, reason string, sampleRate int) ([]*FileOffset, error) {
+	for _, fo := range fileOffsets {
+		SkipFinetune(fo, reason)
+	}
+	return RecalculatePadding(fileOffsets, sampleRate)
+}
+
+// SelectFinetuneRegion finds the segment of the aligned timeline used for fine-tuning
+// localLengths holds the length of each local file in mono samples; tracks (may be nil)
+// are used to prefer a segment where every track has signal.
+// If the overlap is too small the returned error explains why fine-tuning should be skipped.
+func SelectFinetuneRegion(
+	localLengths []int,
+	fileOffsets []*FileOffset,
+	mixedLength int,
+	sampleRate int,
+	tracks []TimelineTrack,
+	opts DetectOptions,
+) (*OverlapRegion, error) {
+	// Step 1: Find overlapping region
+	overlap, err := findOverlappingRegion(localLengths, fileOffsets, mixedLength, sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find overlapping region: %w", err)
+	}
+
+	// Step 2: Select segment for fine-tuning (60s target and 30s minimum by default)
+	target, minimum := finetuneDurations(opts)
+	segStart, segEnd, err := selectFinetuneSegment(overlap, tracks, target, minimum, finetunePosition(opts), sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OverlapRegion{
+		StartSample: segStart,
+		EndSample:   segEnd,
+		DurationSec: float64(segEnd-segStart) / float64(sampleRate),
+	}, nil
+}
+
+// LocalSegmentBounds returns where the fine-tuning segment lies within a local file
+// The segment is at [segment.StartSample, segment.EndSample) on the aligned timeline,
+// and the file starts at its coarse offset
+func LocalSegmentBounds(segment OverlapRegion, fo *FileOffset) (start, end int) {
+	return segment.StartSample - fo.OffsetSamples, segment.EndSample - fo.OffsetSamples
+}
+
+// FinetuneFile refines the coarse offset of a single file by correlating
+// the mixed and local segments at full resolution
+func FinetuneFile(
+	ctx context.Context,
+	mixedSegment []float64,
+	localSegment []float64,
+	segment OverlapRegion,
+	fo *FileOffset,
+	sampleRate int,
+	opts DetectOptions,
+) {
+	if fo.Manual && opts.KeepManual {
+		SkipFinetune(fo, "manual offset")
+		return
+	}
+
+	// Run cross-correlation without downsampling (downsampleFactor = 1)
+	fineResult, err := DetectOffset(ctx, mixedSegment, localSegment, sampleRate, fineOptions(opts))
+	if err != nil {
+		SkipFinetune(fo, fmt.Sprintf("correlation failed: %v", err))
+		return
+	}
+	if fineResult.Silent {
+		SkipFinetune(fo, "silent segment")
+		return
+	}
+
+	// Store fine-tuning result
+	// FineAdjustmentSamples is the adjustment to ADD to the coarse offset (the signed lag from DetectOffset)
+	fo.FinetuneResult = &FinetuneResult{
+		FineAdjustmentSamples: fineResult.OffsetSamples,
+		FineAdjustmentSeconds: fineResult.OffsetSeconds,
+		Confidence:            fineResult.Confidence,
+		PeakToSidelobe:        fineResult.PeakToSidelobe,
+		SegmentUsed:           segment,
+		Skipped:               false,
+	}
+
+	// Merge coarse and fine offsets
+	// Time direction convention: positive = shift later (backward in time), negative = shift earlier (forward in time)
+	// - DetectOffset returns the signed lag of the local segment relative to the mixed segment
+	// - A positive lag means the local content appears later in the mixed track than the coarse offset assumed
+	// - FineAdjustmentSamples stores the adjustment to ADD to the offset
+	// - Example: coarse=1000, DetectOffset=+10 -> adjustment=+10 -> final=1000+10=1010
+	fo.FineAdjustmentSamples = fineResult.OffsetSamples
+	fo.FineAdjustmentSeconds = fineResult.OffsetSeconds
+	fo.FinalOffsetSamples = fo.OffsetSamples + fo.FineAdjustmentSamples
+	// The fine lag is measured from the whole-sample coarse offset and already holds its own sub-sample part,
+	// which supersedes the coarse estimate's
+	fo.FinalOffsetSeconds = float64(fo.OffsetSamples)/float64(sampleRate) + fo.FineAdjustmentSeconds
+	opts.logger().Debug("fine adjustment",
+		"path", fo.Path, "adjustment_samples", fo.FineAdjustmentSamples, "final_offset_samples", fo.FinalOffsetSamples,
+		"confidence", fineResult.Confidence)
+}
+
 // FinetuneOffsets performs fine-tuning on coarsely aligned files
+// opts selects the correlation settings and segment lengths; downsampling is always disabled for fine-tuning.
+// onDone, if not nil, is called with the file index as each file finishes, possibly from several goroutines at once.
+// If ctx is cancelled the remaining files are not fine-tuned and ctx.Err() is returned.
 func FinetuneOffsets(
+	ctx context.Context,
 	mixed []float64,
 	localFiles []*audio.WAVData,
 	fileOffsets []*FileOffset,
 	sampleRate int,
+	opts DetectOptions,
+	onDone func(index int),
 ) ([]*FileOffset, error) {
-	// Step 1: Find overlapping region
-	overlap, err := findOverlappingRegion(localFiles, fileOffsets, sampleRate)
+	// Steps 1-2: Find overlapping region
+	overlap, err := ComputeOverlap(localFiles, fileOffsets, len(mixed), sampleRate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find overlapping region: %w", err)
+		// No common region, keep the coarse alignment for all files
+		return skipAllFinetune(fileOffsets, err.Error(), sampleRate)
 	}
 
-	// Step 2: Select segment for fine-tuning (target 60s, minimum 30s)
-	segStart, segEnd, err := selectFinetuneSegment(overlap, 60.0, 30.0, sampleRate)
+	// Step 3: Select segment for fine-tuning (60s target and 30s minimum by default), avoiding passages where a track is silent
+	tracks := []TimelineTrack{{Data: mixed, Channels: 1}}
+	for i, localFile := range localFiles {
+		tracks = append(tracks, TimelineTrack{Data: localFile.Data, Channels: localFile.Channels, Offset: fileOffsets[i].OffsetSamples})
+	}
+	target, minimum := finetuneDurations(opts)
+	segStart, segEnd, err := selectFinetuneSegment(overlap, tracks, target, minimum, finetunePosition(opts), sampleRate)
 	if err != nil {
 		// Overlap too small, skip fine-tuning for all files
-		for i := range fileOffsets {
-			fileOffsets[i].FinetuneResult = &FinetuneResult{
-				Skipped:    true,
-				SkipReason: err.Error(),
-			}
-			fileOffsets[i].FinalOffsetSamples = fileOffsets[i].OffsetSamples
-			fileOffsets[i].FinalOffsetSeconds = fileOffsets[i].OffsetSeconds
-		}
-		return fileOffsets, nil
+		return skipAllFinetune(fileOffsets, err.Error(), sampleRate)
+	}
+	segment := &OverlapRegion{
+		StartSample: segStart,
+		EndSample:   segEnd,
+		DurationSec: float64(segEnd-segStart) / float64(sampleRate),
 	}
+	opts.logger().Debug("fine-tuning segment",
+		"start_sample", segment.StartSample, "end_sample", segment.EndSample, "duration_sec", segment.DurationSec)
 
-	// Step 3: Extract mixed segment
-	mixedSegment, err := extractSegment(mixed, segStart, segEnd)
+	// Step 4: Extract mixed segment
+	mixedSegment, err := extractSegment(mixed, segment.StartSample, segment.EndSample)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract mixed segment: %w", err)
 	}
 
-	// Step 4: Fine-tune each local file
-	for i, localFile := range localFiles {
-		// Convert to mono
-		localMono := audio.ToMono(localFile.Data, localFile.Channels)
-
-		// Calculate where this file's segment should be extracted
-		// The segment is at [segStart, segEnd) on the aligned timeline
-		// This file starts at fileOffsets[i].OffsetSamples
-		localSegStart := segStart - fileOffsets[i].OffsetSamples
-		localSegEnd := segEnd - fileOffsets[i].OffsetSamples
-
-		// Validate bounds
-		if localSegStart < 0 || localSegEnd > len(localMono) {
-			fileOffsets[i].FinetuneResult = &FinetuneResult{
-				Skipped:    true,
-				SkipReason: fmt.Sprintf("segment out of bounds [%d, %d) for file length %d",
-					localSegStart, localSegEnd, len(localMono)),
-			}
-			fileOffsets[i].FinalOffsetSamples = fileOffsets[i].OffsetSamples
-			fileOffsets[i].FinalOffsetSeconds = fileOffsets[i].OffsetSeconds
-			continue
-		}
-
-		// Extract local segment
-		localSegment, err := extractSegment(localMono, localSegStart, localSegEnd)
-		if err != nil {
-			fileOffsets[i].FinetuneResult = &FinetuneResult{
-				Skipped:    true,
-				SkipReason: fmt.Sprintf("extraction failed: %v", err),
-			}
-			fileOffsets[i].FinalOffsetSamples = fileOffsets[i].OffsetSamples
-			fileOffsets[i].FinalOffsetSeconds = fileOffsets[i].OffsetSeconds
-			continue
+	// Step 5: Fine-tune the local files in parallel, at most opts.Workers() at once
+	// Each goroutine only writes to its own fileOffsets[i], so no locking is needed
+	ForEachLimited(len(localFiles), opts.Workers(), func(idx int) {
+		if ctx.Err() != nil {
+			return
 		}
-
-		// Run cross-correlation without downsampling (downsampleFactor = 1)
-		fineResult, err := DetectOffset(
-			mixedSegment,
-			localSegment,
-			sampleRate,
-			0, // segmentDuration not used
-			1, // downsampleFactor = 1 (no downsampling)
-		)
-		if err != nil {
-			fileOffsets[i].FinetuneResult = &FinetuneResult{
-				Skipped:    true,
-				SkipReason: fmt.Sprintf("correlation failed: %v", err),
-			}
-			fileOffsets[i].FinalOffsetSamples = fileOffsets[i].OffsetSamples
-			fileOffsets[i].FinalOffsetSeconds = fileOffsets[i].OffsetSeconds
-			continue
+		finetuneLocal(ctx, mixedSegment, localFiles[idx], *segment, fileOffsets[idx], sampleRate, opts)
+		if onDone != nil {
+			onDone(idx)
 		}
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-		// Store fine-tuning result
-		// FineAdjustmentSamples is the adjustment to ADD to the coarse offset (sign-inverted from DetectOffset)
-		fileOffsets[i].FinetuneResult = &FinetuneResult{
-			FineAdjustmentSamples: -fineResult.OffsetSamples,
-			FineAdjustmentSeconds: -fineResult.OffsetSeconds,
-			Confidence:            fineResult.Confidence,
-			SegmentUsed: OverlapRegion{
-				StartSample: segStart,
-				EndSample:   segEnd,
-				DurationSec: float64(segEnd-segStart) / float64(sampleRate),
-			},
-			Skipped: false,
-		}
+	// Step 6: Recalculate padding based on final offsets
+	return RecalculatePadding(fileOffsets, sampleRate)
+}
 
-		// Merge coarse and fine offsets
-		// Time direction convention: positive = shift later (backward in time), negative = shift earlier (forward in time)
-		// - DetectOffset returns: positive = local segment is ahead (too early)
-		// - If local is ahead, we need to REDUCE the offset to shift it earlier
-		// - FineAdjustmentSamples stores the adjustment to ADD to the offset
-		// - Example: coarse=1000, DetectOffset=+10 (too early) -> adjustment=-10 -> final=1000+(-10)=990
-		fileOffsets[i].FineAdjustmentSamples = -fineResult.OffsetSamples
-		fileOffsets[i].FineAdjustmentSeconds = -fineResult.OffsetSeconds
-		fileOffsets[i].FinalOffsetSamples = fileOffsets[i].OffsetSamples + fileOffsets[i].FineAdjustmentSamples
-		fileOffsets[i].FinalOffsetSeconds = fileOffsets[i].OffsetSeconds + fileOffsets[i].FineAdjustmentSeconds
+// finetuneLocal extracts the segment of a local file that matches the mixed segment and fine-tunes its offset
+func finetuneLocal(
+	ctx context.Context,
+	mixedSegment []float64,
+	localFile *audio.WAVData,
+	segment OverlapRegion,
+	fo *FileOffset,
+	sampleRate int,
+	opts DetectOptions,
+) {
+	// Calculate where this file's segment should be extracted
+	localSegStart, localSegEnd := LocalSegmentBounds(segment, fo)
+	opts.logger().Debug("local fine-tuning segment", "path", fo.Path, "start_sample", localSegStart, "end_sample", localSegEnd)
+
+	// Collapse only the segment to mono, so no file is held a second time while several are fine-tuned at once
+	localSegment, err := audio.ToMonoMixdownRange(localFile.Data, localFile.Channels, opts.Mixdown, localSegStart, localSegEnd)
+	if err != nil {
+		SkipFinetune(fo, err.Error())
+		return
 	}
 
-	// Step 5: Recalculate padding based on final offsets
-	return recalculatePadding(fileOffsets, sampleRate)
+	FinetuneFile(ctx, mixedSegment, localSegment, segment, fo, sampleRate, opts)
 }
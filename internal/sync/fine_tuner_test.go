@@ -0,0 +1,251 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+func TestFinetuneFileFinalOffsetSeconds(t *testing.T) {
+	mixed := testSignal(3, 5*testRate)
+
+	tests := []struct {
+		name       string
+		coarse     int
+		coarseFrac float64 // Sub-sample part of the coarse estimate, superseded by fine-tuning
+		fine       int     // Lag of the local segment against the mixed one
+	}{
+		{"no adjustment", 1000, 0.4, 0},
+		{"later", 1000, -0.3, 7},
+		{"earlier", -500, 0.25, -3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fo := &FileOffset{
+				OffsetSamples: tt.coarse,
+				OffsetSeconds: (float64(tt.coarse) + tt.coarseFrac) / testRate,
+			}
+			local := testLocal(mixed, tt.fine, 4*testRate)
+			FinetuneFile(context.Background(), mixed, local, OverlapRegion{}, fo, testRate, DetectOptions{})
+
+			if fo.FinetuneResult == nil || fo.FinetuneResult.Skipped {
+				t.Fatalf("fine-tuning was skipped: %+v", fo.FinetuneResult)
+			}
+			if want := tt.coarse + tt.fine; fo.FinalOffsetSamples != want {
+				t.Errorf("FinalOffsetSamples = %d, want %d", fo.FinalOffsetSamples, want)
+			}
+			// The seconds must agree with the samples; the coarse fraction must not be counted again
+			want := float64(tt.coarse+tt.fine) / testRate
+			if math.Abs(fo.FinalOffsetSeconds-want) > 0.1/testRate {
+				t.Errorf("FinalOffsetSeconds = %.7f, want %.7f", fo.FinalOffsetSeconds, want)
+			}
+		})
+	}
+}
+
+func TestSkipFinetuneKeepsCoarseOffset(t *testing.T) {
+	fo := &FileOffset{OffsetSamples: 1234, OffsetSeconds: 1234.5 / testRate}
+	SkipFinetune(fo, "test")
+	if fo.FinalOffsetSamples != 1234 || fo.FinalOffsetSeconds != fo.OffsetSeconds || !fo.FinetuneResult.Skipped {
+		t.Errorf("SkipFinetune = %d samples, %f s, %+v", fo.FinalOffsetSamples, fo.FinalOffsetSeconds, fo.FinetuneResult)
+	}
+}
+
+// finetuneFixture returns a mixed signal, local tracks cut from it and their true offsets
+func finetuneFixture(files int) ([]float64, []*audio.WAVData, []int) {
+	mixed := testSignal(9, 120*testRate)
+	localFiles := make([]*audio.WAVData, files)
+	offsets := make([]int, files)
+	for i := range localFiles {
+		offsets[i] = (5+3*i)*testRate + 17*i
+		localFiles[i] = &audio.WAVData{
+			Path:       fmt.Sprintf("local%d.wav", i),
+			SampleRate: testRate,
+			Channels:   1,
+			Data:       testLocal(mixed, offsets[i], 80*testRate),
+		}
+	}
+	return mixed, localFiles, offsets
+}
+
+// coarseOffsets returns file offsets a few samples away from the true offsets, as a downsampled search leaves them
+func coarseOffsets(localFiles []*audio.WAVData, offsets []int) []*FileOffset {
+	results := make([]*OffsetResult, len(offsets))
+	paths := make([]string, len(offsets))
+	for i, offset := range offsets {
+		results[i] = &OffsetResult{OffsetSamples: offset + 4 - i%9, Confidence: 1}
+		paths[i] = localFiles[i].Path
+	}
+	fileOffsets, _ := CalculatePadding(results, paths, testRate)
+	return fileOffsets
+}
+
+func TestFinetuneOffsetsParallel(t *testing.T) {
+	mixed, localFiles, offsets := finetuneFixture(6)
+
+	for _, threads := range []int{1, 4} {
+		fileOffsets, err := FinetuneOffsets(context.Background(), mixed, localFiles, coarseOffsets(localFiles, offsets), testRate, DetectOptions{Threads: threads}, nil)
+		if err != nil {
+			t.Fatalf("%d threads: FinetuneOffsets: %v", threads, err)
+		}
+		for i, fo := range fileOffsets {
+			if fo.FinalOffsetSamples != offsets[i] {
+				t.Errorf("%d threads: file %d final offset %d, want %d", threads, i, fo.FinalOffsetSamples, offsets[i])
+			}
+		}
+	}
+}
+
+func BenchmarkFinetuneOffsets(b *testing.B) {
+	mixed, localFiles, offsets := finetuneFixture(8)
+
+	for _, threads := range []int{1, 0} {
+		name := "sequential"
+		if threads == 0 {
+			name = "parallel"
+		}
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				fileOffsets := coarseOffsets(localFiles, offsets)
+				if _, err := FinetuneOffsets(context.Background(), mixed, localFiles, fileOffsets, testRate, DetectOptions{Threads: threads}, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// burstTrack returns a full-rate mono track of length samples that is quiet except for a loud burst at [start, end)
+func burstTrack(length, start, end int) TimelineTrack {
+	data := testSignal(13, length)
+	for i := range data {
+		if i < start || i >= end {
+			data[i] *= 0.01
+		}
+	}
+	return TimelineTrack{Data: data, Channels: 1}
+}
+
+func TestSelectFinetuneSegment(t *testing.T) {
+	overlap := &OverlapRegion{StartSample: 0, EndSample: 100 * testRate, DurationSec: 100}
+	target, minimum := 20.0, 10.0
+
+	tests := []struct {
+		name      string
+		overlap   *OverlapRegion
+		tracks    []TimelineTrack
+		position  float64
+		wantStart int
+		wantEnd   int
+		wantErr   bool
+	}{
+		{"centered without tracks", overlap, nil, -1, 40 * testRate, 60 * testRate, false},
+		{"energetic part", overlap, []TimelineTrack{burstTrack(100*testRate, 70*testRate, 90*testRate)}, -1, 70 * testRate, 90 * testRate, false},
+		{"quietest track decides", overlap, []TimelineTrack{
+			burstTrack(100*testRate, 10*testRate, 30*testRate),
+			{Data: testSignal(14, 100*testRate), Channels: 1},
+		}, -1, 10 * testRate, 30 * testRate, false},
+		{"fixed at the start", overlap, nil, 0, 0, 20 * testRate, false},
+		{"fixed at the end", overlap, nil, 1, 80 * testRate, 100 * testRate, false},
+		{"shorter than the target", &OverlapRegion{StartSample: 5, EndSample: 5 + 15*testRate, DurationSec: 15}, nil, -1, 5, 5 + 15*testRate, false},
+		{"shorter than the minimum", &OverlapRegion{EndSample: 5 * testRate, DurationSec: 5}, nil, -1, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		start, end, err := selectFinetuneSegment(tt.overlap, tt.tracks, target, minimum, tt.position, testRate)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: segment [%d, %d), want [%d, %d)", tt.name, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestFinetuneOffsetsNoOverlap(t *testing.T) {
+	mixed := testSignal(15, 60*testRate)
+	offsets := []int{2 * testRate, 40 * testRate} // The files do not overlap at all
+	localFiles := []*audio.WAVData{
+		{Path: "early.wav", SampleRate: testRate, Channels: 1, Data: testLocal(mixed, offsets[0], 10*testRate)},
+		{Path: "late.wav", SampleRate: testRate, Channels: 1, Data: testLocal(mixed, offsets[1], 10*testRate)},
+	}
+
+	fileOffsets, err := FinetuneOffsets(context.Background(), mixed, localFiles, coarseOffsets(localFiles, offsets), testRate, DetectOptions{}, nil)
+	if err != nil {
+		t.Fatalf("FinetuneOffsets: %v", err)
+	}
+
+	// Every file keeps its coarse alignment, so outputs can still be written
+	for i, fo := range fileOffsets {
+		if fo.FinetuneResult == nil || !fo.FinetuneResult.Skipped || fo.FinetuneResult.SkipReason == "" {
+			t.Errorf("file %d: fine-tuning result %+v, want skipped with a reason", i, fo.FinetuneResult)
+		}
+		if fo.FinalOffsetSamples != fo.OffsetSamples {
+			t.Errorf("file %d: final offset %d, want the coarse offset %d", i, fo.FinalOffsetSamples, fo.OffsetSamples)
+		}
+	}
+	if want := fileOffsets[1].OffsetSamples - fileOffsets[0].OffsetSamples; fileOffsets[1].PaddingSamples != want || !fileOffsets[0].IsEarliest {
+		t.Errorf("padding %d (earliest %v), want %d after the earliest file", fileOffsets[1].PaddingSamples, fileOffsets[0].IsEarliest, want)
+	}
+}
+
+func TestComputeOverlapMatchesFinetuneSegment(t *testing.T) {
+	mixed, localFiles, offsets := finetuneFixture(3)
+
+	tests := []struct {
+		name string
+		opts DetectOptions
+	}{
+		{"overlap shorter than the target", DetectOptions{FinetuneTarget: 100}},
+		{"segment chosen by energy", DetectOptions{}},
+		{"segment at the start", DetectOptions{FinetuneFixed: true}},
+	}
+
+	for _, tt := range tests {
+		fileOffsets := coarseOffsets(localFiles, offsets)
+		overlap, err := ComputeOverlap(localFiles, fileOffsets, len(mixed), testRate)
+		if err != nil {
+			t.Fatalf("%s: ComputeOverlap: %v", tt.name, err)
+		}
+		// The latest file starts and the earliest file ends inside the mixed track
+		last := fileOffsets[len(fileOffsets)-1]
+		if wantEnd := fileOffsets[0].OffsetSamples + 80*testRate; overlap.StartSample != last.OffsetSamples || overlap.EndSample != wantEnd {
+			t.Errorf("%s: overlap [%d, %d), want [%d, %d)", tt.name, overlap.StartSample, overlap.EndSample, last.OffsetSamples, wantEnd)
+		}
+
+		fileOffsets, err = FinetuneOffsets(context.Background(), mixed, localFiles, fileOffsets, testRate, tt.opts, nil)
+		if err != nil {
+			t.Fatalf("%s: FinetuneOffsets: %v", tt.name, err)
+		}
+		for i, fo := range fileOffsets {
+			if fo.FinetuneResult == nil || fo.FinetuneResult.Skipped {
+				t.Fatalf("%s: file %d: fine-tuning result %+v, want not skipped", tt.name, i, fo.FinetuneResult)
+			}
+			segment := fo.FinetuneResult.SegmentUsed
+			if segment.StartSample < overlap.StartSample || segment.EndSample > overlap.EndSample {
+				t.Errorf("%s: file %d: segment [%d, %d) outside the overlap [%d, %d)",
+					tt.name, i, segment.StartSample, segment.EndSample, overlap.StartSample, overlap.EndSample)
+			}
+			if tt.opts.FinetuneTarget > overlap.DurationSec && segment != *overlap {
+				t.Errorf("%s: file %d: segment %+v, want the whole overlap %+v", tt.name, i, segment, *overlap)
+			}
+
+			// Correlating the reported window again gives the same adjustment, so it is the one that was used
+			start, end := LocalSegmentBounds(segment, fo)
+			result, err := DetectOffset(context.Background(), mixed[segment.StartSample:segment.EndSample],
+				localFiles[i].Data[start:end], testRate, fineOptions(tt.opts))
+			if err != nil {
+				t.Fatalf("%s: file %d: DetectOffset: %v", tt.name, i, err)
+			}
+			if result.OffsetSamples != fo.FineAdjustmentSamples || result.Confidence != fo.FinetuneResult.Confidence {
+				t.Errorf("%s: file %d: reported window gives adjustment %d (confidence %g), fine-tuning found %d (confidence %g)",
+					tt.name, i, result.OffsetSamples, result.Confidence, fo.FineAdjustmentSamples, fo.FinetuneResult.Confidence)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+const (
+	gapWindowSeconds    = 10.0  // Length of each window whose lag is tracked through the local track
+	gapMarginSeconds    = 0.05  // Lag searched around the previous window before searching the whole mixed track
+	gapToleranceSeconds = 0.002 // Lag change between windows still treated as the same segment (clock drift)
+	gapMinConfidence    = 0.5   // Correlation a window needs for its lag to count
+)
+
+// Segment is a contiguous part of a local track that was paused and resumed while the mixed track kept running
+// Its offset follows the convention of FileOffset: local frame f lines up with mixed frame f + offset.
+type Segment struct {
+	StartFrame    int     `json:"start_frame"`    // First local frame of the segment
+	EndFrame      int     `json:"end_frame"`      // Local frame after its last
+	OffsetSamples int     `json:"offset_samples"` // Offset measured at the start of the segment
+	OffsetSeconds float64 `json:"offset_seconds"` // Offset in seconds
+	GapSamples    int     `json:"gap_samples"`    // Silence inserted before the segment (negative = overlap with the previous one)
+	GapSeconds    float64 `json:"gap_seconds"`    // Gap in seconds
+	Confidence    float64 `json:"confidence"`     // Best window correlation within the segment
+}
+
+// gapRun is a series of windows whose lag changes no more than clock drift would
+type gapRun struct {
+	firstStart, lastStart int     // Local start of the first and last window
+	startLag, endLag      int     // Lag of the first and last window
+	confidence            float64 // Best window confidence
+}
+
+// DetectSegments follows the lag of consecutive windows of the local track through the mixed track
+// and splits the track where the lag jumps, as it does when a recording is paused and resumed
+// mixed and local are mono; fo must already hold the final offset from fine-tuning.
+// A window that no longer lines up near the previous lag is searched for in the whole mixed track.
+// It returns nil if the track has a single segment.
+func DetectSegments(ctx context.Context, mixed, local []float64, fo *FileOffset, sampleRate int, opts DetectOptions) ([]*Segment, error) {
+	window := int(gapWindowSeconds * float64(sampleRate))
+	margin := max(int(gapMarginSeconds*float64(sampleRate)), 1)
+	tolerance := int(gapToleranceSeconds * float64(sampleRate))
+
+	search := fineOptions(opts)
+	search.DownsampleFactor = opts.DownsampleFactor
+
+	var runs []*gapRun
+	lag := fo.FinalOffsetSamples
+	for start := 0; start+window <= len(local); start += window {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		current, confidence := gapWindowLag(ctx, mixed, local, start, window, lag, margin, sampleRate, opts)
+		if confidence < gapMinConfidence {
+			found, err := DetectOffset(ctx, mixed, local[start:start+window], sampleRate, search)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				continue
+			}
+			current, confidence = gapWindowLag(ctx, mixed, local, start, window, found.OffsetSamples-start, margin, sampleRate, opts)
+			if confidence < gapMinConfidence {
+				continue
+			}
+		}
+
+		if len(runs) == 0 || max(current-lag, lag-current) > tolerance {
+			runs = append(runs, &gapRun{firstStart: start, startLag: current})
+		}
+		run := runs[len(runs)-1]
+		run.lastStart, run.endLag = start, current
+		run.confidence = math.Max(run.confidence, confidence)
+		lag = current
+	}
+	if len(runs) < 2 {
+		return nil, nil
+	}
+
+	// The local signal correlates negatively with the mixed track when its polarity is inverted
+	sign := 1.0
+	if fo.Inverted {
+		sign = -1
+	}
+
+	segments := make([]*Segment, len(runs))
+	for k, run := range runs {
+		segment := &Segment{OffsetSamples: run.startLag, Confidence: run.confidence, EndFrame: len(local)}
+		if k > 0 {
+			prev := runs[k-1]
+			segment.StartFrame = splitPoint(mixed, local, max(prev.lastStart, segments[k-1].StartFrame),
+				run.firstStart+window, prev.endLag, run.startLag, sign)
+			segment.GapSamples = run.startLag - prev.endLag
+			segments[k-1].EndFrame = segment.StartFrame
+		}
+		segment.OffsetSeconds = float64(segment.OffsetSamples) / float64(sampleRate)
+		segment.GapSeconds = float64(segment.GapSamples) / float64(sampleRate)
+		segments[k] = segment
+	}
+	return segments, nil
+}
+
+// gapWindowLag correlates length local samples from start with the mixed audio around where lag places them
+// and returns the lag found at full resolution and its confidence (0 if the window falls outside the mixed track)
+func gapWindowLag(ctx context.Context, mixed, local []float64, start, length, lag, margin, sampleRate int, opts DetectOptions) (int, float64) {
+	mixedStart := start + lag - margin
+	mixedEnd := start + lag + length + margin
+	if mixedStart < 0 || mixedEnd > len(mixed) {
+		return lag, 0
+	}
+
+	result, err := DetectOffset(ctx, mixed[mixedStart:mixedEnd], local[start:start+length], sampleRate, fineOptions(opts))
+	if err != nil {
+		return lag, 0
+	}
+	return mixedStart + result.OffsetSamples - start, result.Confidence
+}
+
+// splitPoint returns the local frame in [from, to] where the track switches from lagA to lagB:
+// the split that maximizes the correlation of the frames before it at lagA plus those after it at lagB
+func splitPoint(mixed, local []float64, from, to, lagA, lagB int, sign float64) int {
+	at := func(i int) float64 {
+		if i < 0 || i >= len(mixed) {
+			return 0
+		}
+		return mixed[i]
+	}
+
+	best, sum, bestSum := from, 0.0, 0.0
+	for i := from; i < min(to, len(local)); i++ {
+		sum += sign * local[i] * (at(i+lagA) - at(i+lagB))
+		if sum > bestSum {
+			best, bestSum = i+1, sum
+		}
+	}
+	return best
+}
+
+// FillGaps rebuilds interleaved data so each segment lands where its offset places it relative to the first:
+// gaps are filled with silence and, where segments overlap, the end of the earlier one is dropped
+func FillGaps(data []float64, channels int, segments []*Segment) []float64 {
+	positions := make([]int, len(segments))
+	frames, pos := 0, 0
+	for k, segment := range segments {
+		pos += segment.GapSamples
+		positions[k] = pos
+		pos += segment.EndFrame - segment.StartFrame
+		frames = max(frames, pos)
+	}
+
+	filled := make([]float64, frames*channels)
+	for k, segment := range segments {
+		// A segment moved before the start of the output loses its leading frames
+		skip := max(-positions[k], 0)
+		end := min(segment.EndFrame, len(data)/channels)
+		if segment.StartFrame+skip >= end {
+			continue
+		}
+		dst := (positions[k] + skip) * channels
+		copy(filled[dst:], data[(segment.StartFrame+skip)*channels:end*channels])
+	}
+	return filled
+}
+
+// SplitGaps detects pauses in each local file, reinserts the time the mixed track kept running as silence
+// and moves its final offset to the first segment
+// Files with a single segment keep their data and offset unchanged.
+// If ctx is cancelled the remaining files are left unchanged and ctx.Err() is returned.
+func SplitGaps(
+	ctx context.Context,
+	mixed []float64,
+	localFiles []*audio.WAVData,
+	fileOffsets []*FileOffset,
+	sampleRate int,
+	opts DetectOptions,
+) ([]*FileOffset, error) {
+	if len(localFiles) != len(fileOffsets) {
+		return nil, fmt.Errorf("mismatch between local files (%d) and file offsets (%d)", len(localFiles), len(fileOffsets))
+	}
+
+	for i, localFile := range localFiles {
+		fo := fileOffsets[i]
+		localMono, err := audio.ToMonoMixdown(localFile.Data, localFile.Channels, opts.Mixdown)
+		if err != nil {
+			return nil, err
+		}
+
+		segments, err := DetectSegments(ctx, mixed, localMono, fo, sampleRate, opts)
+		if err != nil {
+			return nil, err
+		}
+		if segments == nil {
+			continue
+		}
+
+		localFile.Data = FillGaps(localFile.Data, localFile.Channels, segments)
+		fo.Segments = segments
+		fo.FinalOffsetSamples = segments[0].OffsetSamples
+		fo.FinalOffsetSeconds = segments[0].OffsetSeconds
+	}
+
+	// Padding depends on the moved final offsets
+	return RecalculatePadding(fileOffsets, sampleRate)
+}
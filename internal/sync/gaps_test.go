@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+// pausedLocal returns a local track cut from mixed at offset that was paused after pauseAt frames
+// while the mixed track ran on for gap frames, and then resumed for resume more frames
+func pausedLocal(mixed []float64, offset, pauseAt, gap, resume int) []float64 {
+	local := append([]float64{}, mixed[offset:offset+pauseAt]...)
+	return append(local, mixed[offset+pauseAt+gap:offset+pauseAt+gap+resume]...)
+}
+
+// misplacedEnergy returns the energy of the local frames between a detected split and the true pause,
+// which land on the wrong side of the gap
+// Where the track is quiet around the pause either side matches, so the split may wander through the quiet part.
+func misplacedEnergy(local []float64, split, pauseAt int) float64 {
+	energy := 0.0
+	for _, v := range local[min(split, pauseAt):max(split, pauseAt)] {
+		energy += v * v
+	}
+	return energy
+}
+
+func TestDetectSegmentsInjectedGap(t *testing.T) {
+	mixed := testSignal(21, 120*testRate)
+	offset, pauseAt, gap := 5*testRate, 37*testRate, 8*testRate // The pause falls inside a window
+	local := pausedLocal(mixed, offset, pauseAt, gap, 40*testRate)
+
+	fo := &FileOffset{FinalOffsetSamples: offset}
+	segments, err := DetectSegments(context.Background(), mixed, local, fo, testRate, DetectOptions{})
+	if err != nil {
+		t.Fatalf("DetectSegments: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("%d segments, want 2: %+v", len(segments), segments)
+	}
+
+	first, second := segments[0], segments[1]
+	if first.StartFrame != 0 || first.OffsetSamples != offset {
+		t.Errorf("first segment starts at %d with offset %d, want 0 and %d", first.StartFrame, first.OffsetSamples, offset)
+	}
+	// The split is placed where the signals stop matching: at most a few loud samples from the pause
+	if energy := misplacedEnergy(local, second.StartFrame, pauseAt); energy > 1 || first.EndFrame != second.StartFrame {
+		t.Errorf("split at %d (first ends at %d, misplaced energy %g), want %d", second.StartFrame, first.EndFrame, energy, pauseAt)
+	}
+	if second.OffsetSamples != offset+gap || second.GapSamples != gap || second.EndFrame != len(local) {
+		t.Errorf("second segment offset %d, gap %d, end %d; want %d, %d, %d",
+			second.OffsetSamples, second.GapSamples, second.EndFrame, offset+gap, gap, len(local))
+	}
+	if want := float64(gap) / testRate; second.GapSeconds != want {
+		t.Errorf("gap %g s, want %g s", second.GapSeconds, want)
+	}
+}
+
+func TestDetectSegmentsContinuous(t *testing.T) {
+	mixed := testSignal(22, 60*testRate)
+	fo := &FileOffset{FinalOffsetSamples: 3 * testRate}
+	segments, err := DetectSegments(context.Background(), mixed, testLocal(mixed, 3*testRate, 40*testRate), fo, testRate, DetectOptions{})
+	if err != nil || segments != nil {
+		t.Errorf("DetectSegments on an unpaused track = %+v, %v; want nil", segments, err)
+	}
+}
+
+func TestSplitGapsReinsertsSilence(t *testing.T) {
+	mixed := testSignal(23, 120*testRate)
+	offset, pauseAt, gap, resume := 5*testRate, 30*testRate, 6*testRate, 30*testRate
+	localFile := &audio.WAVData{
+		Path:       "paused.wav",
+		SampleRate: testRate,
+		Channels:   1,
+		Data:       pausedLocal(mixed, offset, pauseAt, gap, resume),
+	}
+	local := localFile.Data
+	fileOffsets := []*FileOffset{{Path: localFile.Path, FinalOffsetSamples: offset, IsEarliest: true}}
+
+	fileOffsets, err := SplitGaps(context.Background(), mixed, []*audio.WAVData{localFile}, fileOffsets, testRate, DetectOptions{})
+	if err != nil {
+		t.Fatalf("SplitGaps: %v", err)
+	}
+	if len(fileOffsets[0].Segments) != 2 || fileOffsets[0].FinalOffsetSamples != offset {
+		t.Fatalf("segments %+v, final offset %d; want 2 segments at offset %d", fileOffsets[0].Segments, fileOffsets[0].FinalOffsetSamples, offset)
+	}
+
+	split := fileOffsets[0].Segments[1].StartFrame
+	if energy := misplacedEnergy(local, split, pauseAt); energy > 1 {
+		t.Errorf("split at %d (misplaced energy %g), want %d", split, energy, pauseAt)
+	}
+
+	// The filled track has silence for the time the recording was paused, so it follows the mixed track again
+	data := localFile.Data
+	if len(data) != pauseAt+gap+resume {
+		t.Fatalf("%d frames, want %d", len(data), pauseAt+gap+resume)
+	}
+	for i, v := range data {
+		want := 0.0
+		switch {
+		case i < split:
+			want = local[i]
+		case i >= split+gap:
+			want = local[i-gap]
+		}
+		if v != want {
+			t.Fatalf("frame %d = %g, want %g (split at %d)", i, v, want, split)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"math"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+// levelFloorDB is the lowest level reported, standing in for the -Inf dB of digital silence (which JSON cannot hold)
+const levelFloorDB = -120.0
+
+// GainDiagnostics describes the level of a local file relative to the mixed file
+// A large difference does not stop detection, but a quiet or clipping track correlates less reliably.
+type GainDiagnostics struct {
+	RMSDB      float64 `json:"rms_dbfs"`     // RMS level of the local file in dBFS
+	PeakDB     float64 `json:"peak_dbfs"`    // Peak sample level of the local file in dBFS
+	RMSDiffDB  float64 `json:"rms_diff_db"`  // Local minus mixed RMS level (negative = local is quieter)
+	PeakDiffDB float64 `json:"peak_diff_db"` // Local minus mixed peak level
+}
+
+// NewGainDiagnostics compares the level of a local file with that of the mixed file
+func NewGainDiagnostics(local, mixed audio.LevelStats) *GainDiagnostics {
+	return &GainDiagnostics{
+		RMSDB:      amplitudeDB(local.RMS),
+		PeakDB:     amplitudeDB(local.Peak),
+		RMSDiffDB:  amplitudeDB(local.RMS) - amplitudeDB(mixed.RMS),
+		PeakDiffDB: amplitudeDB(local.Peak) - amplitudeDB(mixed.Peak),
+	}
+}
+
+// amplitudeDB converts a linear amplitude to dB relative to full scale, no lower than levelFloorDB
+func amplitudeDB(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return levelFloorDB
+	}
+	return math.Max(20*math.Log10(amplitude), levelFloorDB)
+}
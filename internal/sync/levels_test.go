@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+func TestNewGainDiagnostics(t *testing.T) {
+	mixed := audio.LevelStats{RMS: 0.5, Peak: 1}
+
+	tests := []struct {
+		name  string
+		local audio.LevelStats
+		want  GainDiagnostics
+	}{
+		// 20·log10(0.05) = -26.02 dBFS, 20 dB below the mix
+		{"quieter", audio.LevelStats{RMS: 0.05, Peak: 0.5}, GainDiagnostics{RMSDB: -26.0206, PeakDB: -6.0206, RMSDiffDB: -20, PeakDiffDB: -6.0206}},
+		{"same level", mixed, GainDiagnostics{RMSDB: -6.0206, PeakDB: 0, RMSDiffDB: 0, PeakDiffDB: 0}},
+		{"silent", audio.LevelStats{}, GainDiagnostics{RMSDB: levelFloorDB, PeakDB: levelFloorDB, RMSDiffDB: levelFloorDB + 6.0206, PeakDiffDB: levelFloorDB}},
+	}
+
+	for _, tt := range tests {
+		got := NewGainDiagnostics(tt.local, mixed)
+		for _, v := range []struct {
+			field     string
+			got, want float64
+		}{
+			{"RMS", got.RMSDB, tt.want.RMSDB},
+			{"peak", got.PeakDB, tt.want.PeakDB},
+			{"RMS difference", got.RMSDiffDB, tt.want.RMSDiffDB},
+			{"peak difference", got.PeakDiffDB, tt.want.PeakDiffDB},
+		} {
+			if math.Abs(v.got-v.want) > 1e-4 {
+				t.Errorf("%s: %s %.4f dB, want %.4f dB", tt.name, v.field, v.got, v.want)
+			}
+		}
+	}
+}
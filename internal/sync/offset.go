@@ -3,26 +3,58 @@ package sync
 import (
 	"fmt"
 	"math"
+	"path/filepath"
 )
 
 // FileOffset represents the offset and padding information for a single file
 type FileOffset struct {
-	Path            string
-	OffsetSamples   int     // Coarse offset detected (positive = shift later)
-	OffsetSeconds   float64 // Coarse offset in seconds
+	Path          string  `json:"path"`
+	OffsetSamples int     `json:"offset_samples"` // Coarse offset detected (positive = shift later)
+	OffsetSeconds float64 `json:"offset_seconds"` // Coarse offset in seconds
 
 	// Fine-tuning fields
-	FineAdjustmentSamples int     // Adjustment to ADD to coarse offset (positive = shift later)
-	FineAdjustmentSeconds float64 // Adjustment to ADD to coarse offset in seconds
-	FinalOffsetSamples    int     // Coarse + Fine = Final offset (positive = shift later)
-	FinalOffsetSeconds    float64 // Final offset in seconds
+	FineAdjustmentSamples int     `json:"fine_adjustment_samples"` // Adjustment to ADD to coarse offset (positive = shift later)
+	FineAdjustmentSeconds float64 `json:"fine_adjustment_seconds"` // Adjustment to ADD to coarse offset in seconds
+	FinalOffsetSamples    int     `json:"final_offset_samples"`    // Coarse + Fine = Final offset, moved to the stretched file's start by drift correction
+	FinalOffsetSeconds    float64 `json:"final_offset_seconds"`    // Final offset in seconds
 
-	PaddingSamples  int     // Silence to prepend (calculated from final offset)
-	PaddingSeconds  float64 // Silence in seconds
-	Confidence      float64 // Detection confidence
-	IsEarliest      bool    // Whether this is the earliest file
+	PaddingSamples int     `json:"padding_samples"`          // Silence to prepend (calculated from final offset)
+	PaddingSeconds float64 `json:"padding_seconds"`          // Silence in seconds
+	TrimSamples    int     `json:"trim_samples"`             // Leading samples to remove in trim mode (calculated from final offset)
+	TrimSeconds    float64 `json:"trim_seconds"`             // Trim in seconds
+	Confidence     float64 `json:"confidence"`               // Detection confidence
+	PeakToSidelobe float64 `json:"peak_to_sidelobe"`         // Coarse correlation peak-to-sidelobe ratio (higher = less ambiguous)
+	IsEarliest     bool    `json:"is_earliest"`              // Whether this is the earliest file
+	OutsideWindow  bool    `json:"outside_window,omitempty"` // A stronger coarse peak lay beyond the --max-offset search window
+	Inverted       bool    `json:"inverted,omitempty"`       // The local track correlates with inverted polarity
+	Manual         bool    `json:"manual,omitempty"`         // The coarse offset was given with --offset instead of detected
+	Retried        bool    `json:"retried,omitempty"`        // Coarse detection was repeated after a low-confidence first pass
+	Downsample     int     `json:"downsample,omitempty"`     // Downsample factor of the coarse search the offset was found with
+	Backoffs       int     `json:"backoffs,omitempty"`       // Times the downsample factor was halved after a low-confidence coarse search
+	Channel        int     `json:"channel,omitempty"`        // Channel (from 1) of the file aligned as a track of its own with --per-channel (0 = the whole file)
+	Suspect        bool    `json:"suspect,omitempty"`        // The padding exceeds --max-padding-sec, so the offset is probably a false match and no output is written
 
-	FinetuneResult  *FinetuneResult // Fine-tuning result (nil if skipped)
+	// Sub-sample delay in samples (-1 to 1) applied on top of the padding or trim by --fractional-delay
+	PaddingFraction float64 `json:"padding_fraction,omitempty"`
+
+	FinetuneResult *FinetuneResult  `json:"finetune"`                // Fine-tuning result (nil if fine-tuning did not run)
+	RateMismatch   *RateMismatch    `json:"rate_mismatch,omitempty"` // Sample rate the file was found to be recorded at instead of its header's (nil = as labeled)
+	Drift          *DriftResult     `json:"drift,omitempty"`         // Clock drift correction (nil unless drift correction ran)
+	Segments       []*Segment       `json:"segments,omitempty"`      // Parts of a paused and resumed recording (nil unless gaps were found)
+	Gain           *GainDiagnostics `json:"gain,omitempty"`          // Level of the file relative to the mixed file (nil = not measured)
+}
+
+// TrackName returns the file path, followed by the channel if the entry aligns one channel of the file
+func (fo *FileOffset) TrackName() string {
+	if fo.Channel == 0 {
+		return fo.Path
+	}
+	return ChannelTrackName(fo.Path, fo.Channel)
+}
+
+// ChannelTrackName names a channel (from 1) of the local file at path that is aligned as a track of its own
+func ChannelTrackName(path string, channel int) string {
+	return fmt.Sprintf("%s (ch %d)", path, channel)
 }
 
 // CalculatePadding calculates the silence padding needed for each file
@@ -52,20 +84,143 @@ func CalculatePadding(results []*OffsetResult, filePaths []string, sampleRate in
 		padding := result.OffsetSamples - minOffset
 
 		fileOffsets[i] = &FileOffset{
-			Path:            filePaths[i],
-			OffsetSamples:   result.OffsetSamples,
-			OffsetSeconds:   result.OffsetSeconds,
-			PaddingSamples:  padding,
-			PaddingSeconds:  float64(padding) / float64(sampleRate),
-			Confidence:      result.Confidence,
-			IsEarliest:      result.OffsetSamples == minOffset,
+			Path:          filePaths[i],
+			OffsetSamples: result.OffsetSamples,
+			OffsetSeconds: result.OffsetSeconds,
+			// Final offset equals the coarse offset until fine-tuning adjusts it
+			FinalOffsetSamples: result.OffsetSamples,
+			FinalOffsetSeconds: result.OffsetSeconds,
+			PaddingSamples:     padding,
+			PaddingSeconds:     float64(padding) / float64(sampleRate),
+			Confidence:         result.Confidence,
+			PeakToSidelobe:     result.PeakToSidelobe,
+			IsEarliest:         result.OffsetSamples == minOffset,
+			OutsideWindow:      result.OutsideWindow,
+			Inverted:           result.Inverted,
+			Manual:             result.Manual,
+			Retried:            result.Retried,
+			Downsample:         result.Downsample,
+			Backoffs:           result.Backoffs,
+			RateMismatch:       result.RateMismatch,
 		}
 	}
 
 	return fileOffsets, nil
 }
 
+// AlignMode selects how synchronized files are aligned
+type AlignMode string
+
+const (
+	ModePad  AlignMode = "pad"  // Prepend silence so all files start with the earliest one
+	ModeTrim AlignMode = "trim" // Remove leading audio so all files start with the latest one
+)
+
+// ParseAlignMode converts a mode name into an AlignMode
+func ParseAlignMode(name string) (AlignMode, error) {
+	switch AlignMode(name) {
+	case ModePad, ModeTrim:
+		return AlignMode(name), nil
+	default:
+		return "", fmt.Errorf("unknown mode %q (expected %s or %s)", name, ModePad, ModeTrim)
+	}
+}
+
+// CalculateTrim switches the files to trim mode: instead of padding later files,
+// the leading samples of earlier files are removed so every file starts with the latest one.
+// Audio that exists before the latest start is discarded.
+func CalculateTrim(fileOffsets []*FileOffset, sampleRate int) error {
+	if len(fileOffsets) == 0 {
+		return fmt.Errorf("no file offsets provided")
+	}
+
+	// Find the maximum final offset (latest file)
+	maxOffset := fileOffsets[0].FinalOffsetSamples
+	for _, fo := range fileOffsets {
+		if fo.FinalOffsetSamples > maxOffset {
+			maxOffset = fo.FinalOffsetSamples
+		}
+	}
+
+	// Update trim for each file and clear padding
+	for _, fo := range fileOffsets {
+		trim := maxOffset - fo.FinalOffsetSamples
+		fo.TrimSamples = trim
+		fo.TrimSeconds = float64(trim) / float64(sampleRate)
+		fo.PaddingSamples = 0
+		fo.PaddingSeconds = 0
+	}
+
+	return nil
+}
+
+// CalculateAnchor aligns every file to the file at anchorPath instead of the earliest one
+// The anchor keeps its original start: later files are padded and earlier files are trimmed
+// by their distance from the anchor's final offset, so the anchor's timecodes stay valid.
+func CalculateAnchor(fileOffsets []*FileOffset, anchorPath string, sampleRate int) error {
+	var anchor *FileOffset
+	for _, fo := range fileOffsets {
+		if filepath.Clean(fo.Path) == filepath.Clean(anchorPath) {
+			anchor = fo
+			break
+		}
+	}
+	if anchor == nil {
+		return fmt.Errorf("anchor %s is not one of the local files", anchorPath)
+	}
+
+	// Positive relative positions need padding, negative ones need trimming
+	for _, fo := range fileOffsets {
+		relative := fo.FinalOffsetSamples - anchor.FinalOffsetSamples
+		fo.PaddingSamples = max(relative, 0)
+		fo.PaddingSeconds = float64(fo.PaddingSamples) / float64(sampleRate)
+		fo.TrimSamples = max(-relative, 0)
+		fo.TrimSeconds = float64(fo.TrimSamples) / float64(sampleRate)
+	}
+
+	return nil
+}
+
+// AddLead shifts every file later by leadSamples, e.g. to start the set at a common timecode instead of zero
+// The relative alignment is kept: a file's padding grows by the lead, and a trim is reduced by it first.
+func AddLead(fileOffsets []*FileOffset, leadSamples, sampleRate int) {
+	for _, fo := range fileOffsets {
+		shift := fo.PaddingSamples - fo.TrimSamples + leadSamples
+		fo.PaddingSamples = max(shift, 0)
+		fo.PaddingSeconds = float64(fo.PaddingSamples) / float64(sampleRate)
+		fo.TrimSamples = max(-shift, 0)
+		fo.TrimSeconds = float64(fo.TrimSamples) / float64(sampleRate)
+	}
+}
+
+// CalculateFractionalPadding sets PaddingFraction to the sub-sample part of each file's alignment
+// The whole-sample padding (or trim) is kept; the fraction is relative to the file written unshifted
+// (the earliest when padding, the latest when trimming, or the anchor), so that file gets no delay.
+func CalculateFractionalPadding(fileOffsets []*FileOffset, sampleRate int) {
+	reference := 0.0
+	for _, fo := range fileOffsets {
+		if fo.PaddingSamples == 0 && fo.TrimSamples == 0 {
+			reference = subSampleFraction(fo, sampleRate)
+			break
+		}
+	}
+
+	for _, fo := range fileOffsets {
+		fo.PaddingFraction = subSampleFraction(fo, sampleRate) - reference
+	}
+}
+
+// subSampleFraction returns the part of a file's fine adjustment below one sample (0 if it was not fine-tuned)
+func subSampleFraction(fo *FileOffset, sampleRate int) float64 {
+	if fo.FinetuneResult == nil || fo.FinetuneResult.Skipped {
+		return 0
+	}
+	return fo.FineAdjustmentSeconds*float64(sampleRate) - float64(fo.FineAdjustmentSamples)
+}
+
 // ValidateConfidence checks if all confidence scores meet the minimum threshold
+// Confidence is a normalized cross-correlation coefficient, so a threshold applies
+// equally to files of any duration (1.0 = identical, around 0 = unrelated)
 func ValidateConfidence(fileOffsets []*FileOffset, minConfidence float64) []string {
 	var warnings []string
 
@@ -73,7 +228,77 @@ func ValidateConfidence(fileOffsets []*FileOffset, minConfidence float64) []stri
 		if fo.Confidence < minConfidence {
 			warnings = append(warnings, fmt.Sprintf(
 				"%s: low confidence score %.2f (threshold: %.2f)",
-				fo.Path, fo.Confidence, minConfidence,
+				fo.TrackName(), fo.Confidence, minConfidence,
+			))
+		}
+	}
+
+	return warnings
+}
+
+// ValidatePeakToSidelobe checks if every coarse correlation peak stands out from its sidelobes
+// A low ratio means several lags matched almost equally well, e.g. with repetitive material
+func ValidatePeakToSidelobe(fileOffsets []*FileOffset, minRatio float64) []string {
+	var warnings []string
+
+	for _, fo := range fileOffsets {
+		if fo.PeakToSidelobe < minRatio {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s: ambiguous correlation peak, peak-to-sidelobe ratio %.2f (threshold: %.2f)",
+				fo.TrackName(), fo.PeakToSidelobe, minRatio,
+			))
+		}
+	}
+
+	return warnings
+}
+
+// ValidateSearchWindow reports files whose strongest correlation peak was excluded by the offset limit
+// Their offset is the best match inside the window, which may not be the true alignment
+func ValidateSearchWindow(fileOffsets []*FileOffset, maxOffset float64) []string {
+	var warnings []string
+
+	for _, fo := range fileOffsets {
+		if fo.OutsideWindow {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s: a stronger match lies beyond the ±%gs search window, the true offset may have been excluded",
+				fo.TrackName(), maxOffset,
+			))
+		}
+	}
+
+	return warnings
+}
+
+// ValidatePolarity reports files that correlate with inverted polarity
+// Their offset is still valid, but the track was probably wired or recorded out of phase
+func ValidatePolarity(fileOffsets []*FileOffset) []string {
+	var warnings []string
+
+	for _, fo := range fileOffsets {
+		if fo.Inverted {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s: polarity appears inverted relative to the mixed audio (out of phase)",
+				fo.TrackName(),
+			))
+		}
+	}
+
+	return warnings
+}
+
+// CheckPadding marks files whose padding exceeds maxSeconds as suspect and reports them
+// Such a shift usually comes from a false correlation peak, either of the file or of the earliest file
+// the others are padded to, and padding by it would write a huge file.
+func CheckPadding(fileOffsets []*FileOffset, maxSeconds float64) []string {
+	var warnings []string
+
+	for _, fo := range fileOffsets {
+		if fo.PaddingSeconds > maxSeconds {
+			fo.Suspect = true
+			warnings = append(warnings, fmt.Sprintf(
+				"%s: padding of %.3fs exceeds the %gs limit, its offset or that of the earliest file is probably a false match",
+				fo.TrackName(), fo.PaddingSeconds, maxSeconds,
 			))
 		}
 	}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalculateAnchor(t *testing.T) {
+	results := []*OffsetResult{{OffsetSamples: 400}, {OffsetSamples: 100}, {OffsetSamples: 1000}}
+	paths := []string{"host.wav", "guest.wav", "late.wav"}
+
+	fileOffsets, err := CalculatePadding(results, paths, testRate)
+	if err != nil {
+		t.Fatalf("CalculatePadding: %v", err)
+	}
+	// The anchor is neither the earliest nor the latest file
+	if err := CalculateAnchor(fileOffsets, "./host.wav", testRate); err != nil {
+		t.Fatalf("CalculateAnchor: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		padding int
+		trim    int
+	}{
+		{"host.wav", 0, 0},    // Keeps its start
+		{"guest.wav", 0, 300}, // Started earlier, so its lead is trimmed
+		{"late.wav", 600, 0},  // Started later, so it is padded
+	}
+	for i, tt := range tests {
+		fo := fileOffsets[i]
+		if fo.Path != tt.path || fo.PaddingSamples != tt.padding || fo.TrimSamples != tt.trim {
+			t.Errorf("%s: padding %d, trim %d; want %s padded %d, trimmed %d", fo.Path, fo.PaddingSamples, fo.TrimSamples, tt.path, tt.padding, tt.trim)
+		}
+		if fo.PaddingSeconds != float64(tt.padding)/testRate || fo.TrimSeconds != float64(tt.trim)/testRate {
+			t.Errorf("%s: padding %gs, trim %gs; want them in seconds too", fo.Path, fo.PaddingSeconds, fo.TrimSeconds)
+		}
+	}
+
+	if err := CalculateAnchor(fileOffsets, "other.wav", testRate); err == nil {
+		t.Error("CalculateAnchor accepted an anchor that is not a local file")
+	}
+}
+
+func TestCheckPadding(t *testing.T) {
+	// A false peak three hours out pads its file by hours
+	results := []*OffsetResult{{OffsetSamples: 2 * testRate}, {OffsetSamples: 3 * 3600 * testRate}, {OffsetSamples: 5 * testRate}}
+	fileOffsets, err := CalculatePadding(results, []string{"host.wav", "runaway.wav", "guest.wav"}, testRate)
+	if err != nil {
+		t.Fatalf("CalculatePadding: %v", err)
+	}
+
+	warnings := CheckPadding(fileOffsets, 60)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "runaway.wav") {
+		t.Errorf("warnings %q, want one for runaway.wav", warnings)
+	}
+	for _, fo := range fileOffsets {
+		if want := fo.Path == "runaway.wav"; fo.Suspect != want {
+			t.Errorf("%s: suspect %v, want %v", fo.Path, fo.Suspect, want)
+		}
+	}
+}
+
+func TestAddLead(t *testing.T) {
+	fileOffsets := []*FileOffset{
+		{Path: "earliest.wav"},
+		{Path: "padded.wav", PaddingSamples: 300},
+		{Path: "trimmed.wav", TrimSamples: 500},
+	}
+	AddLead(fileOffsets, 200, testRate)
+
+	// Every file moves 200 samples later; the trim is used up first
+	tests := []struct{ padding, trim int }{{200, 0}, {500, 0}, {0, 300}}
+	for i, tt := range tests {
+		fo := fileOffsets[i]
+		if fo.PaddingSamples != tt.padding || fo.TrimSamples != tt.trim {
+			t.Errorf("%s: padding %d, trim %d; want %d, %d", fo.Path, fo.PaddingSamples, fo.TrimSamples, tt.padding, tt.trim)
+		}
+		if fo.PaddingSeconds != float64(tt.padding)/testRate || fo.TrimSeconds != float64(tt.trim)/testRate {
+			t.Errorf("%s: padding %gs, trim %gs do not match the samples", fo.Path, fo.PaddingSeconds, fo.TrimSeconds)
+		}
+	}
+}
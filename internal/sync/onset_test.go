@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// musicBed returns a loop-like music bed: decaying harmonic notes from a few pitches on a steady beat
+func musicBed(seed uint64, length int) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 2))
+	pitches := []float64{220, 277.2, 329.6, 440}
+	beat := testRate / 4
+	data := make([]float64, length)
+	for start := 0; start < length; start += beat * (1 + rng.IntN(3)) {
+		pitch := pitches[rng.IntN(len(pitches))]
+		for i := start; i < min(start+3*beat, length); i++ {
+			x := float64(i-start) / testRate
+			for h := 1.0; h <= 6; h++ {
+				data[i] += 0.3 * math.Exp(-6*x) * math.Sin(2*math.Pi*pitch*h*x) / h
+			}
+		}
+	}
+	return data
+}
+
+// reEQ returns data through a treble boost and a chain of all-pass sections that smear its phase,
+// with a little noise: the notes are where they were, but the waveform no longer matches the original
+func reEQ(data []float64) []float64 {
+	out := make([]float64, len(data))
+	for i := range data {
+		out[i] = data[i]
+		if i > 0 {
+			out[i] -= 0.95 * data[i-1]
+		}
+	}
+	for s := range 40 {
+		a := 0.7
+		if s%2 == 1 {
+			a = -0.6
+		}
+		x1, y1 := 0.0, 0.0
+		for i, x := range out {
+			y := -a*x + x1 + a*y1
+			x1, y1 = x, y
+			out[i] = y
+		}
+	}
+	rng := rand.New(rand.NewPCG(7, 8))
+	for i := range out {
+		out[i] = 0.5*out[i] + 0.02*rng.NormFloat64()
+	}
+	return out
+}
+
+func TestComputeOnsetEnvelope(t *testing.T) {
+	// Clicks of noise at known times stand out of the envelope at those hops
+	data := make([]float64, 5*testRate)
+	clicks := []int{testRate / 2, 2 * testRate, 3*testRate + testRate/4}
+	rng := rand.New(rand.NewPCG(9, 1))
+	for _, click := range clicks {
+		for i := click; i < click+testRate/20; i++ {
+			data[i] = 0.5 * rng.NormFloat64()
+		}
+	}
+
+	envelope := computeOnsetEnvelope(data, testRate)
+	hop := onsetHop(testRate)
+	frameSize := nextPowerOfTwo(testRate / onsetFrameDivisor)
+	if want := (len(data)-frameSize)/hop + 1; len(envelope) != want {
+		t.Fatalf("%d envelope values, want %d", len(envelope), want)
+	}
+	strongest := 0.0
+	for _, v := range envelope {
+		strongest = math.Max(strongest, v)
+	}
+	for _, click := range clicks {
+		// The frame first covering the click (it starts up to one frame earlier) carries the onset
+		from, to := max((click-frameSize)/hop, 0), click/hop+1
+		peak := 0.0
+		for _, v := range envelope[from:to] {
+			peak = math.Max(peak, v)
+		}
+		if peak < strongest/2 {
+			t.Errorf("onset near sample %d is %g, want at least half the strongest %g", click, peak, strongest)
+		}
+	}
+	// Silence between the clicks has no onsets
+	if v := envelope[testRate*13/10/hop]; v != 0 {
+		t.Errorf("envelope in silence = %g, want 0", v)
+	}
+	if computeOnsetEnvelope(data[:frameSize-1], testRate) != nil {
+		t.Error("envelope of data shorter than a frame is not nil")
+	}
+}
+
+func TestDetectOffsetOnsetReEQ(t *testing.T) {
+	mixed := musicBed(1, 60*testRate)
+	offset := 17*testRate + 123
+	local := reEQ(mixed[offset : offset+30*testRate])
+
+	standard, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1})
+	if err != nil {
+		t.Fatalf("standard: %v", err)
+	}
+	onset, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 1, Method: MethodOnset})
+	if err != nil {
+		t.Fatalf("onset: %v", err)
+	}
+
+	// The envelopes still line up to within one hop, while the waveforms hardly correlate
+	if diff := onset.OffsetSamples - offset; diff < -onsetHop(testRate) || diff > onsetHop(testRate) {
+		t.Errorf("onset offset %d, want %d within one hop", onset.OffsetSamples, offset)
+	}
+	if onset.Confidence < HighConfidence || standard.Confidence > 0.5 {
+		t.Errorf("confidence %.2f (onset) and %.2f (standard), want above %.1f and below 0.5", onset.Confidence, standard.Confidence, HighConfidence)
+	}
+	if onset.PeakToSidelobe <= standard.PeakToSidelobe {
+		t.Errorf("peak-to-sidelobe %.2f (onset), want above the standard %.2f", onset.PeakToSidelobe, standard.PeakToSidelobe)
+	}
+}
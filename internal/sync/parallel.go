@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"math"
+	"runtime"
+	gosync "sync"
+)
+
+// parallelMinSamples is the input length below which splitting a pass across goroutines costs more than it saves
+const parallelMinSamples = 1 << 20
+
+// parallelRanges splits [0, n) into one contiguous range per available CPU and calls fn for each
+// concurrently, passing the range's index among them. It returns the number of ranges.
+func parallelRanges(n int, fn func(part, start, end int)) int {
+	parts := min(runtime.GOMAXPROCS(0), max(n/(parallelMinSamples/4), 1))
+	size := (n + parts - 1) / parts
+
+	var wg gosync.WaitGroup
+	for part := 0; part < parts; part++ {
+		start, end := part*size, min((part+1)*size, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(part, start, end)
+		}()
+	}
+	wg.Wait()
+	return parts
+}
+
+// Workers returns how many local tracks may be detected or fine-tuned at once (Threads, or GOMAXPROCS if unset)
+func (o DetectOptions) Workers() int {
+	if o.Threads > 0 {
+		return o.Threads
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// ForEachLimited calls fn for every index in [0, n) from at most workers goroutines and waits for all calls
+// Bounding the goroutines bounds how many large correlation buffers are allocated at the same time.
+func ForEachLimited(n, workers int, fn func(i int)) {
+	indices := make(chan int, n)
+	for i := range n {
+		indices <- i
+	}
+	close(indices)
+
+	var wg gosync.WaitGroup
+	for range min(max(workers, 1), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// moments holds the count, mean and sum of squared deviations of part of a signal
+type moments struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// merge combines the moments of two disjoint parts (Chan et al.), which stays accurate
+// even when the parts have very different means, unlike summing raw squares
+func (a moments) merge(b moments) moments {
+	if a.count == 0 {
+		return b
+	}
+	if b.count == 0 {
+		return a
+	}
+	count := a.count + b.count
+	delta := b.mean - a.mean
+	return moments{
+		count: count,
+		mean:  a.mean + delta*float64(b.count)/float64(count),
+		m2:    a.m2 + b.m2 + delta*delta*float64(a.count)*float64(b.count)/float64(count),
+	}
+}
+
+// momentsOf computes the moments of data with the same two passes as normalize
+func momentsOf(data []float64) moments {
+	if len(data) == 0 {
+		return moments{}
+	}
+	mean := 0.0
+	for _, v := range data {
+		mean += v
+	}
+	mean /= float64(len(data))
+
+	m2 := 0.0
+	for _, v := range data {
+		diff := v - mean
+		m2 += diff * diff
+	}
+	return moments{count: len(data), mean: mean, m2: m2}
+}
+
+// normalizeParallel is normalize for long signals, computing the moments and scaling in parallel parts
+func normalizeParallel(data []float64) []float64 {
+	parts := make([]moments, runtime.GOMAXPROCS(0))
+	used := parallelRanges(len(data), func(part, start, end int) {
+		parts[part] = momentsOf(data[start:end])
+	})
+
+	total := moments{}
+	for _, part := range parts[:used] {
+		total = total.merge(part)
+	}
+	stdDev := math.Sqrt(total.m2 / float64(total.count))
+	if stdDev == 0 {
+		stdDev = 1.0
+	}
+
+	result := make([]float64, len(data))
+	parallelRanges(len(data), func(_, start, end int) {
+		for i := start; i < end; i++ {
+			result[i] = (data[i] - total.mean) / stdDev
+		}
+	})
+	return result
+}
+
+// downsampleParallel is downsample for long signals, filling disjoint parts of the output in parallel
+func downsampleParallel(data []float64, factor int) []float64 {
+	result := make([]float64, (len(data)+factor-1)/factor)
+	parallelRanges(len(result), func(_, start, end int) {
+		for j := start; j < end; j++ {
+			result[j] = data[j*factor]
+		}
+	})
+	return result
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// withProcs runs fn with GOMAXPROCS set to procs, so the parallel passes split into that many parts
+func withProcs(procs int, fn func()) {
+	old := runtime.GOMAXPROCS(procs)
+	defer runtime.GOMAXPROCS(old)
+	fn()
+}
+
+func TestNormalizeParallel(t *testing.T) {
+	// A large DC offset is where combining raw sums of squares would lose precision
+	data := testSignal(41, 2*parallelMinSamples+7)
+	for i := range data {
+		data[i] += 1000
+	}
+
+	mean := 0.0
+	for _, v := range data {
+		mean += v
+	}
+	mean /= float64(len(data))
+	variance := 0.0
+	for _, v := range data {
+		variance += (v - mean) * (v - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(len(data)))
+
+	for _, procs := range []int{1, 3, 8} {
+		var got []float64
+		withProcs(procs, func() { got = normalizeParallel(data) })
+		for i, v := range data {
+			if want := (v - mean) / stdDev; math.Abs(got[i]-want) > 1e-9 {
+				t.Fatalf("%d procs: sample %d = %.12f, want %.12f", procs, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestDownsampleParallel(t *testing.T) {
+	data := testSignal(42, parallelMinSamples+13)
+	for _, factor := range []int{2, 7, 50} {
+		var got []float64
+		withProcs(4, func() { got = downsampleParallel(data, factor) })
+		if want := (len(data) + factor - 1) / factor; len(got) != want {
+			t.Fatalf("factor %d: %d samples, want %d", factor, len(got), want)
+		}
+		for j, v := range got {
+			if v != data[j*factor] {
+				t.Fatalf("factor %d: sample %d = %g, want %g", factor, j, v, data[j*factor])
+			}
+		}
+	}
+}
+
+// benchmarkSamples is the length of the buffer normalized and downsampled by the benchmarks (800 MB as float64)
+const benchmarkSamples = 100_000_000
+
+// benchmarkProcs runs fn with a single part and with one part per CPU (the same run on a single CPU)
+func benchmarkProcs(b *testing.B, fn func()) {
+	for _, procs := range slices.Compact([]int{1, runtime.NumCPU()}) {
+		b.Run(fmt.Sprintf("procs=%d", procs), func(b *testing.B) {
+			withProcs(procs, func() {
+				for range b.N {
+					fn()
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkNormalize(b *testing.B) {
+	data := testSignal(43, benchmarkSamples)
+	benchmarkProcs(b, func() { normalize(data) })
+}
+
+func BenchmarkDownsample(b *testing.B) {
+	data := testSignal(44, benchmarkSamples)
+	benchmarkProcs(b, func() { downsample(data, 50) })
+}
@@ -0,0 +1,76 @@
+package sync
+
+import "context"
+
+const (
+	pyramidStep           = 4    // Each pyramid level decimates this many times less than the previous one
+	pyramidSegmentSeconds = 60.0 // Length of the middle of the local track correlated at each pyramid level
+)
+
+// refineOffset narrows a coarse offset found at opts.DownsampleFactor through successively finer levels
+// down to full resolution (e.g. 50 -> 12 -> 3 -> 1), searching only lags within two samples of the previous level
+// around its peak. The peak of the full-resolution level is interpolated into subSample, as DetectOffset does for
+// the coarse peak. inverted flips the sign of the correlation, as in DetectOffset.
+// If ctx is cancelled the offset of the last finished level is returned, or offset and subSample as given.
+func refineOffset(ctx context.Context, mixed, local []float64, sampleRate, offset int, subSample float64, inverted bool, opts DetectOptions) (int, float64) {
+	segmentLength := min(len(local), int(pyramidSegmentSeconds*float64(sampleRate)))
+	localStart := (len(local) - segmentLength) / 2
+	segment := local[localStart : localStart+segmentLength]
+
+	for prev, factor := opts.DownsampleFactor, max(opts.DownsampleFactor/pyramidStep, 1); prev > 1; prev, factor = factor, max(factor/pyramidStep, 1) {
+		// Mixed position of the segment start, give or take two samples of the previous level
+		center := localStart + offset
+		from := max(center-2*prev, 0)
+		to := min(center+2*prev, len(mixed)-1)
+		if from > to || ctx.Err() != nil {
+			break
+		}
+
+		rate := sampleRate / factor
+		mixedLevel := prepareLevel(downsample(mixed[from:min(to+segmentLength, len(mixed))], factor), rate, opts)
+		localLevel := prepareLevel(downsample(segment, factor), rate, opts)
+
+		scores := make([]float64, (to-from)/factor+1)
+		best := 0
+		for lag := range scores {
+			scores[lag] = correlationCoefficient(mixedLevel, localLevel, lag, dotAtLag(mixedLevel, localLevel, lag))
+			if inverted {
+				scores[lag] = -scores[lag]
+			}
+			if scores[lag] > scores[best] {
+				best = lag
+			}
+		}
+		offset = from + best*factor - localStart
+
+		// A peak on the edge of the searched lags has no neighbor to interpolate with
+		subSample = 0
+		if factor == 1 && best > 0 && best < len(scores)-1 {
+			subSample = interpolatePeak(scores[best-1:best+2], 1)
+		}
+	}
+
+	return offset, subSample
+}
+
+// prepareLevel filters and normalizes one pyramid level the same way as the coarse search
+func prepareLevel(data []float64, sampleRate int, opts DetectOptions) []float64 {
+	data = BandpassFilter(data, sampleRate, opts.BandpassLow, opts.BandpassHigh)
+	data = opts.PreFilter.apply(data, sampleRate)
+	if opts.Method == MethodEnvelope {
+		data = hilbertEnvelope(data)
+	}
+	if opts.LevelMatch {
+		data = levelMatch(data, sampleRate)
+	}
+	return normalize(data)
+}
+
+// dotAtLag returns the correlation of local against mixed at a non-negative lag (local[i] lines up with mixed[i+lag])
+func dotAtLag(mixed, local []float64, lag int) float64 {
+	sum := 0.0
+	for i := 0; i < len(local) && i+lag < len(mixed); i++ {
+		sum += local[i] * mixed[i+lag]
+	}
+	return sum
+}
@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRefineOffsetRecoversCoarseError(t *testing.T) {
+	// Band-limited below the Nyquist frequency of every level, so decimation keeps the signal
+	mixed := BandpassFilter(testSignal(81, 60*testRate), testRate, 0, 400)
+	offset := 11*testRate + 7
+	local := mixed[offset : offset+40*testRate]
+	coarse := offset + 25 // As far off as a search at factor 16 may leave it
+
+	// 16 -> 4 -> 1 searches ±32 samples at factor 4 and then ±8 at full resolution
+	if got, _ := refineOffset(context.Background(), mixed, local, testRate, coarse, 0, false, DetectOptions{DownsampleFactor: 16}); got != offset {
+		t.Errorf("pyramid from factor 16: offset %d, want %d", got, offset)
+	}
+
+	// A single step to full resolution only looks a few samples around the coarse offset
+	if got, _ := refineOffset(context.Background(), mixed, local, testRate, coarse, 0, false, DetectOptions{DownsampleFactor: 2}); got == offset {
+		t.Errorf("single step: offset %d, want it to miss the true offset 25 samples away", got)
+	}
+
+	// DetectOffset runs the pyramid after its coarse search, so the offset comes back at full resolution
+	result, err := DetectOffset(context.Background(), mixed, local, testRate, DetectOptions{DownsampleFactor: 16})
+	if err != nil {
+		t.Fatalf("DetectOffset: %v", err)
+	}
+	if result.OffsetSamples != offset {
+		t.Errorf("DetectOffset at factor 16: offset %d, want %d", result.OffsetSamples, offset)
+	}
+}
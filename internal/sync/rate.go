@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+// commonSampleRates are the rates a recorder may really have used when its header states another
+var commonSampleRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000, 88200, 96000}
+
+const (
+	rateMismatchMaxRatio = 2.0 // Only rates within this factor of the header rate are tried
+	rateMismatchMinGain  = 2.0 // A reinterpreted track must correlate at least this many times better than as labeled
+)
+
+// RateMismatch records a local file whose header states a different sample rate than it was recorded at
+type RateMismatch struct {
+	LabeledRate       int     `json:"labeled_rate"`       // Sample rate in the file header
+	ActualRate        int     `json:"actual_rate"`        // Sample rate the file aligns at, used instead
+	LabeledConfidence float64 `json:"labeled_confidence"` // Coarse confidence when read at the labeled rate
+}
+
+// Apply reinterprets interleaved data converted from LabeledRate as if it had been recorded at ActualRate:
+// a file played back too fast (ActualRate below LabeledRate) is lengthened, and one played too slow shortened
+// The result is at the same processing rate as data.
+func (m *RateMismatch) Apply(data []float64, channels int) []float64 {
+	return audio.Resample(data, m.ActualRate, m.LabeledRate, channels)
+}
+
+// DetectRateMismatch tries reading a local track that aligned poorly as if it had been recorded at each
+// common sample rate within rateMismatchMaxRatio of labeledRate, and detects its offset again
+// local is mono at sampleRate (converted from labeledRate if they differ) and result is its detection as labeled.
+// It returns the rate and detection of the best reinterpretation if it reaches minConfidence and correlates
+// at least rateMismatchMinGain times better than result, and nil otherwise.
+func DetectRateMismatch(ctx context.Context, mixed, local []float64, sampleRate, labeledRate int, result *OffsetResult, minConfidence float64, opts DetectOptions) (*RateMismatch, *OffsetResult, error) {
+	var best *RateMismatch
+	var bestResult *OffsetResult
+	for _, rate := range commonSampleRates {
+		ratio := float64(rate) / float64(labeledRate)
+		if rate == labeledRate || ratio > rateMismatchMaxRatio || ratio < 1/rateMismatchMaxRatio {
+			continue
+		}
+
+		candidate := &RateMismatch{LabeledRate: labeledRate, ActualRate: rate, LabeledConfidence: result.Confidence}
+		found, err := opts.CoarseDetector().Detect(ctx, mixed, candidate.Apply(local, 1), sampleRate)
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if err != nil {
+			continue
+		}
+		if bestResult == nil || found.Confidence > bestResult.Confidence {
+			best, bestResult = candidate, found
+		}
+	}
+
+	if bestResult == nil || bestResult.Confidence < minConfidence || bestResult.Confidence < rateMismatchMinGain*result.Confidence {
+		return nil, nil, nil
+	}
+	bestResult.RateMismatch = best
+	return best, bestResult, nil
+}
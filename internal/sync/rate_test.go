@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+func TestDetectRateMismatch(t *testing.T) {
+	const rate = 16000
+	mixed := testSignal(91, 20*rate)
+	offset := 6*rate + 321
+	recorded := mixed[offset : offset+10*rate] // Recorded at 16 kHz ...
+	opts := DetectOptions{DownsampleFactor: 1}
+
+	tests := []struct {
+		name        string
+		labeledRate int
+		wantRate    int // 0 = no mismatch
+	}{
+		{"mislabeled", 22050, rate}, // ... but labeled 22.05 kHz and converted to 16 kHz from there
+		{"labeled correctly", rate, 0},
+	}
+
+	for _, tt := range tests {
+		local := audio.Resample(recorded, tt.labeledRate, rate, 1)
+		labeled, err := DetectOffset(context.Background(), mixed, local, rate, opts)
+		if err != nil {
+			t.Fatalf("%s: DetectOffset: %v", tt.name, err)
+		}
+
+		mismatch, found, err := DetectRateMismatch(context.Background(), mixed, local, rate, tt.labeledRate, labeled, 0.3, opts)
+		if err != nil {
+			t.Fatalf("%s: DetectRateMismatch: %v", tt.name, err)
+		}
+		if tt.wantRate == 0 {
+			if mismatch != nil {
+				t.Errorf("%s: reported a mismatch as %d Hz", tt.name, mismatch.ActualRate)
+			}
+			continue
+		}
+
+		if mismatch == nil {
+			t.Fatalf("%s: no mismatch found (confidence as labeled %.2f)", tt.name, labeled.Confidence)
+		}
+		if mismatch.LabeledRate != tt.labeledRate || mismatch.ActualRate != tt.wantRate || found.RateMismatch != mismatch {
+			t.Errorf("%s: mismatch %+v, want %d Hz labeled as %d Hz", tt.name, *mismatch, tt.wantRate, tt.labeledRate)
+		}
+		// The linear interpolation of both conversions costs some correlation
+		if found.Confidence < 0.7 || found.Confidence < rateMismatchMinGain*labeled.Confidence {
+			t.Errorf("%s: confidence %.2f as %d Hz, %.2f as labeled", tt.name, found.Confidence, mismatch.ActualRate, labeled.Confidence)
+		}
+		if diff := found.OffsetSamples - offset; diff < -1 || diff > 1 {
+			t.Errorf("%s: offset %d, want %d", tt.name, found.OffsetSamples, offset)
+		}
+	}
+}
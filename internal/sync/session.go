@@ -0,0 +1,111 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+)
+
+// SessionSegment places one mixed reference file on the session timeline
+type SessionSegment struct {
+	Path          string  `json:"path"`
+	StartSamples  int     `json:"start_samples"`  // Position of the segment's first sample on the session timeline
+	StartSeconds  float64 `json:"start_seconds"`  // Start in seconds
+	LengthSamples int     `json:"length_samples"` // Segment length in mono samples
+	AnchorFile    string  `json:"anchor_file"`    // Local file whose offsets placed this segment (empty for the first segment)
+}
+
+// PlaceSegments positions several mixed references on a common session timeline
+// offsets[k][j] is the coarse offset of local file j against mixed segment k.
+// Every local track is assumed to span the whole session, so the difference between its
+// offsets against two segments is the distance between those segments. For each segment the
+// local file that matches both it and the first segment best (highest lower confidence) is used.
+// Segments may overlap or be separated by gaps; the timeline starts at the earliest segment.
+func PlaceSegments(
+	paths []string,
+	lengths []int,
+	localPaths []string,
+	offsets [][]*OffsetResult,
+	sampleRate int,
+) ([]SessionSegment, error) {
+	if len(paths) == 0 || len(paths) != len(offsets) || len(paths) != len(lengths) {
+		return nil, fmt.Errorf("mismatch between mixed segments (%d), lengths (%d) and offsets (%d)",
+			len(paths), len(lengths), len(offsets))
+	}
+
+	segments := make([]SessionSegment, len(paths))
+	segments[0] = SessionSegment{Path: paths[0], LengthSamples: lengths[0]}
+
+	for k := 1; k < len(paths); k++ {
+		if len(offsets[k]) != len(offsets[0]) || len(offsets[0]) == 0 {
+			return nil, fmt.Errorf("mixed segment %d has %d offsets, expected %d", k+1, len(offsets[k]), len(offsets[0]))
+		}
+
+		// Pick the local file that anchors this segment to the first one most reliably
+		anchor := 0
+		bestConfidence := -1.0
+		for j := range offsets[k] {
+			confidence := math.Min(offsets[0][j].Confidence, offsets[k][j].Confidence)
+			if confidence > bestConfidence {
+				anchor = j
+				bestConfidence = confidence
+			}
+		}
+
+		// The local file starts at offsets[0][anchor] in segment 1 and at offsets[k][anchor] in segment k
+		segments[k] = SessionSegment{
+			Path:          paths[k],
+			StartSamples:  offsets[0][anchor].OffsetSamples - offsets[k][anchor].OffsetSamples,
+			LengthSamples: lengths[k],
+			AnchorFile:    localPaths[anchor],
+		}
+	}
+
+	// Shift the timeline so the earliest segment starts at zero
+	earliest := segments[0].StartSamples
+	for _, segment := range segments {
+		earliest = min(earliest, segment.StartSamples)
+	}
+	for k := range segments {
+		segments[k].StartSamples -= earliest
+		segments[k].StartSeconds = float64(segments[k].StartSamples) / float64(sampleRate)
+	}
+
+	return segments, nil
+}
+
+// SessionOffsets converts per-segment offsets into offsets on the session timeline
+// Each local file uses the segment it matched with the highest confidence.
+func SessionOffsets(segments []SessionSegment, offsets [][]*OffsetResult, sampleRate int) []*OffsetResult {
+	results := make([]*OffsetResult, len(offsets[0]))
+
+	for j := range results {
+		best := 0
+		for k := range segments {
+			if offsets[k][j].Confidence > offsets[best][j].Confidence {
+				best = k
+			}
+		}
+
+		result := *offsets[best][j]
+		result.OffsetSamples += segments[best].StartSamples
+		result.OffsetSeconds += segments[best].StartSeconds
+		results[j] = &result
+	}
+
+	return results
+}
+
+// BuildSessionTrack places mono mixed segments on the session timeline
+// Gaps between segments are silent; where segments overlap, later segments overwrite earlier ones.
+func BuildSessionTrack(segments []SessionSegment, data [][]float64) []float64 {
+	length := 0
+	for k, segment := range segments {
+		length = max(length, segment.StartSamples+len(data[k]))
+	}
+
+	track := make([]float64, length)
+	for k, segment := range segments {
+		copy(track[segment.StartSamples:], data[k])
+	}
+	return track
+}
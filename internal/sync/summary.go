@@ -0,0 +1,51 @@
+package sync
+
+import "math"
+
+// HighConfidence is the confidence from which Summarize counts a track as high confidence
+// Tracks below it but at or above the low threshold are medium confidence.
+const HighConfidence = 0.7
+
+// Summary aggregates the results of a batch of tracks for an at-a-glance check
+type Summary struct {
+	Tracks              int     `json:"tracks"`                // Entries summarized (one per channel with --per-channel)
+	HighConfidence      int     `json:"high_confidence"`       // Tracks with a confidence of at least HighConfidence
+	MediumConfidence    int     `json:"medium_confidence"`     // Tracks between the low threshold and HighConfidence
+	LowConfidence       int     `json:"low_confidence"`        // Tracks below the low threshold
+	MinConfidence       float64 `json:"min_confidence"`        // Lowest confidence of any track
+	MaxConfidence       float64 `json:"max_confidence"`        // Highest confidence of any track
+	MeanConfidence      float64 `json:"mean_confidence"`       // Average confidence of the tracks
+	TotalPaddingSeconds float64 `json:"total_padding_seconds"` // Silence prepended to all tracks together
+	TotalTrimSeconds    float64 `json:"total_trim_seconds"`    // Leading audio removed from all tracks together
+}
+
+// Summarize counts the tracks in each confidence band and totals their padding and trim
+// Tracks below lowBelow are low confidence, as ValidateConfidence warns about them. It returns nil for no tracks.
+func Summarize(fileOffsets []*FileOffset, lowBelow float64) *Summary {
+	if len(fileOffsets) == 0 {
+		return nil
+	}
+	summary := &Summary{
+		Tracks:        len(fileOffsets),
+		MinConfidence: fileOffsets[0].Confidence,
+		MaxConfidence: fileOffsets[0].Confidence,
+	}
+	sum := 0.0
+	for _, fo := range fileOffsets {
+		switch {
+		case fo.Confidence >= HighConfidence:
+			summary.HighConfidence++
+		case fo.Confidence >= lowBelow:
+			summary.MediumConfidence++
+		default:
+			summary.LowConfidence++
+		}
+		summary.MinConfidence = math.Min(summary.MinConfidence, fo.Confidence)
+		summary.MaxConfidence = math.Max(summary.MaxConfidence, fo.Confidence)
+		sum += fo.Confidence
+		summary.TotalPaddingSeconds += fo.PaddingSeconds
+		summary.TotalTrimSeconds += fo.TrimSeconds
+	}
+	summary.MeanConfidence = sum / float64(len(fileOffsets))
+	return summary
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	fileOffsets := []*FileOffset{
+		{Confidence: 0.95, PaddingSeconds: 1.5},
+		{Confidence: 0.7},                       // High: the threshold is inclusive
+		{Confidence: 0.5, PaddingSeconds: 2.25}, // Medium
+		{Confidence: 0.3, TrimSeconds: 0.5},     // Medium: the low threshold is inclusive too
+		{Confidence: 0.1, PaddingSeconds: 10},   // Low
+	}
+
+	got := Summarize(fileOffsets, 0.3)
+	want := Summary{
+		Tracks:              5,
+		HighConfidence:      2,
+		MediumConfidence:    2,
+		LowConfidence:       1,
+		MinConfidence:       0.1,
+		MaxConfidence:       0.95,
+		MeanConfidence:      (0.95 + 0.7 + 0.5 + 0.3 + 0.1) / 5,
+		TotalPaddingSeconds: 13.75,
+		TotalTrimSeconds:    0.5,
+	}
+	if got == nil {
+		t.Fatal("no summary")
+	}
+	if math.Abs(got.MeanConfidence-want.MeanConfidence) > 1e-12 {
+		t.Errorf("mean confidence %g, want %g", got.MeanConfidence, want.MeanConfidence)
+	}
+	got.MeanConfidence = want.MeanConfidence
+	if *got != want {
+		t.Errorf("summary %+v, want %+v", *got, want)
+	}
+
+	if Summarize(nil, 0.3) != nil {
+		t.Error("summary of no tracks, want nil")
+	}
+}
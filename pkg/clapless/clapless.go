@@ -0,0 +1,471 @@
+// Package clapless synchronizes local podcast recordings with a mixed source.
+//
+// It exposes the same workflow as the clapless command, but returns structured
+// results instead of printing progress.
+package clapless
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/shidetake/clapless/internal/audio"
+	"github.com/shidetake/clapless/internal/render"
+	audiosync "github.com/shidetake/clapless/internal/sync"
+)
+
+// FileOffset holds the detailed offset and padding information for a single file
+type FileOffset = audiosync.FileOffset
+
+// FinetuneResult contains the result of fine-tuning for a single file
+type FinetuneResult = audiosync.FinetuneResult
+
+// DriftResult contains the estimated clock drift of a single file
+type DriftResult = audiosync.DriftResult
+
+// GainDiagnostics is the level of a local file relative to the mixed file, set by Sync
+type GainDiagnostics = audiosync.GainDiagnostics
+
+// Segment is a part of a local file that was paused and resumed, with its own offset
+type Segment = audiosync.Segment
+
+// OffsetResult is the coarse offset a Detector finds for one local track
+type OffsetResult = audiosync.OffsetResult
+
+// Detector finds the coarse offset of a mono local track within a mono mixed track, replacing
+// the built-in cross-correlation; a local track equal to mixed[n:] has OffsetSamples n.
+// Local files are detected in parallel, so implementations must be safe for concurrent use.
+type Detector = audiosync.Detector
+
+// OverlapRegion represents the temporal region used for fine-tuning
+type OverlapRegion = audiosync.OverlapRegion
+
+// Errors wrapped by Sync and AlignBuffers; compare them with errors.Is
+// Missing input files can be detected with errors.Is(err, fs.ErrNotExist).
+var (
+	ErrInvalidWAV         = audio.ErrInvalidWAV         // A WAV file could not be decoded
+	ErrInvalidFile        = audio.ErrInvalidFile        // A FLAC, MP3, AIFF or Ogg file could not be decoded
+	ErrNoAudioData        = audio.ErrNoAudioData        // An input file has no samples
+	ErrUnsupportedFormat  = audio.ErrUnsupportedFormat  // The file extension or sample format is not supported
+	ErrSampleRateMismatch = audio.ErrSampleRateMismatch // Sample rates differ and NoResample is set
+	ErrNoOverlap          = audiosync.ErrNoOverlap      // The files share no time range after coarse alignment
+	ErrSilentInput        = audiosync.ErrSilentInput    // The mixed or a local file is silent, so no offset can be detected
+)
+
+// CorrelationMethod selects how the cross-correlation is computed
+type CorrelationMethod = audiosync.CorrelationMethod
+
+const (
+	MethodStandard = audiosync.MethodStandard // Plain cross-correlation
+	MethodPHAT     = audiosync.MethodPHAT     // GCC-PHAT, more robust to reverb
+	MethodOnset    = audiosync.MethodOnset    // Onset envelopes, more robust for repetitive music beds
+	MethodEnvelope = audiosync.MethodEnvelope // Hilbert amplitude envelopes, more robust for re-encoded material
+)
+
+// WindowType selects the taper applied to signals before correlation
+type WindowType = audiosync.WindowType
+
+const (
+	WindowNone  = audiosync.WindowNone  // No windowing
+	WindowHann  = audiosync.WindowHann  // Hann window over the whole signal
+	WindowTukey = audiosync.WindowTukey // Tukey window, tapering only the edges
+)
+
+// Mixdown selects how multi-channel local files are collapsed to mono; the zero value averages all channels
+type Mixdown = audio.Mixdown
+
+// MixdownMode is the strategy of a Mixdown
+type MixdownMode = audio.MixdownMode
+
+const (
+	MixdownAverage   = audio.MixdownAverage   // Equal-weight average of all channels
+	MixdownChannel   = audio.MixdownChannel   // The single channel Mixdown.Channel (0 = left)
+	MixdownMaxEnergy = audio.MixdownMaxEnergy // The channel with the highest RMS level
+)
+
+// PreFilter is a chain of EQ stages applied to both signals before correlation
+type PreFilter = audiosync.PreFilter
+
+// FilterStage is one biquad stage of a PreFilter, described by its response
+type FilterStage = audiosync.FilterStage
+
+// ParsePreFilter converts a preset name (speech, de-boom or de-hiss) or stages such as
+// "highpass:100,peaking:3000:-6" into a PreFilter
+func ParsePreFilter(s string) (PreFilter, error) {
+	return audiosync.ParsePreFilter(s)
+}
+
+// AlignMode selects how synchronized files are aligned
+type AlignMode = audiosync.AlignMode
+
+const (
+	ModePad  = audiosync.ModePad  // Prepend silence so all files start with the earliest one
+	ModeTrim = audiosync.ModeTrim // Remove leading audio so all files start with the latest one
+)
+
+// Options controls the synchronization workflow
+type Options struct {
+	SegmentDuration   int               // Segment duration in seconds for correlation
+	DownsampleFactor  int               // Downsample factor for coarse search (0 = choose from the audio lengths)
+	BackoffBelow      float64           // Retry the coarse search with the factor halved (down to 1) while the confidence is below this (0 = disabled)
+	AutoResolutionMs  float64           // Coarsest resolution in milliseconds an automatic factor may have (0 = 5)
+	NoResample        bool              // Fail on sample rate mismatch instead of resampling local files
+	CorrelationMethod CorrelationMethod // Cross-correlation method (empty = standard)
+	Chunked           bool              // Always correlate in fixed-size blocks to bound FFT memory (standard method only)
+	BandpassLow       int               // Band-pass lower cutoff in Hz (0 = disabled)
+	BandpassHigh      int               // Band-pass upper cutoff in Hz (0 = disabled)
+	PreFilter         PreFilter         // EQ stages applied to both signals after the band-pass, e.g. from ParsePreFilter (nil = none)
+	Window            WindowType        // Window applied before correlation (empty = none)
+	LevelMatch        bool              // Even out the loudness of short blocks before correlation
+	Mixdown           Mixdown           // How multi-channel local files are collapsed to mono (zero value = average)
+	Detector          Detector          // Coarse offset detector used instead of the cross-correlation (nil = built-in); fine-tuning still refines its offsets
+	TrimSilenceDB     float64           // Leave out leading and trailing audio below this dBFS level from the coarse search (0 = disabled)
+	Mode              AlignMode         // Output alignment mode (empty = pad)
+	Anchor            string            // Local path Sync aligns the other files to (empty = earliest or latest per Mode)
+	CorrectDrift      bool              // Estimate and correct linear clock drift of local files
+	SplitGaps         bool              // Find pauses in local files and reinsert the missing time as silence
+	BitDepth          int               // Output bit depth: 16, 24 or 32 (0 = keep each file's own depth)
+	FloatOutput       bool              // Write 32-bit IEEE float WAV files (overrides BitDepth)
+	SampleRateOut     int               // Resample every output to this rate in Hz after padding or trimming (0 = the mixed file's rate)
+	OutputChannels    int               // Write every output as mono (1, mixed down with Mixdown) or stereo (2, mono copied to both channels) (0 = keep each file's own)
+	InvertPolarity    bool              // Flip the sign of every output sample, so each output nulls against the aligned mixed track
+	NormalizeOutput   bool              // Scale outputs that would clip down to full scale instead of clamping them (integer output only)
+	TargetLUFS        float64           // Bring each output to this integrated loudness in LUFS, keeping its true peak at or below -1 dBTP (0 = keep the level)
+	MaxOffset         float64           // Only search offsets within ±MaxOffset seconds (0 = unlimited)
+	ExcludeLag        float64           // Leave offsets within ±ExcludeLag seconds of zero out of the coarse search (0 = none)
+	CoarseSegment     float64           // Seconds from the middle of each local file used for the coarse search (0 = whole file)
+	FinetuneTarget    float64           // Fine-tuning segment length in seconds (0 = 60)
+	FinetuneMin       float64           // Minimum overlap in seconds required to fine-tune (0 = 30)
+	FadeInMs          float64           // Fade-in length after padding or trimming in milliseconds (0 = none)
+	FractionalDelay   bool              // Apply the sub-sample part of each offset with a fractional-delay filter
+	CommonLead        float64           // Seconds of silence added before every output after alignment (0 = none)
+	Threads           int               // Maximum number of local files detected or fine-tuned at once (0 = GOMAXPROCS)
+	Logger            *slog.Logger      // Receives debug records of correlation peaks and fine-tuning segments (nil = none)
+}
+
+// DefaultOptions returns the options used by the clapless command by default
+func DefaultOptions() Options {
+	return Options{
+		SegmentDuration:  600,
+		DownsampleFactor: 50,
+		BackoffBelow:     0.3,
+		BandpassLow:      300,
+		BandpassHigh:     3400,
+		Window:           WindowTukey,
+		FinetuneTarget:   60,
+		FinetuneMin:      30,
+	}
+}
+
+// detectOptions builds the correlator settings from the options
+func (o Options) detectOptions() audiosync.DetectOptions {
+	return audiosync.DetectOptions{
+		SegmentDuration:  o.SegmentDuration,
+		DownsampleFactor: o.DownsampleFactor,
+		BackoffBelow:     o.BackoffBelow,
+		Method:           o.CorrelationMethod,
+		BandpassLow:      o.BandpassLow,
+		BandpassHigh:     o.BandpassHigh,
+		PreFilter:        o.PreFilter,
+		Window:           o.Window,
+		MaxOffset:        o.MaxOffset,
+		ExcludeLag:       o.ExcludeLag,
+		CoarseSegment:    o.CoarseSegment,
+		FinetuneTarget:   o.FinetuneTarget,
+		FinetuneMin:      o.FinetuneMin,
+		LevelMatch:       o.LevelMatch,
+		TrimSilenceDB:    o.TrimSilenceDB,
+		Chunked:          o.Chunked,
+		Mixdown:          o.Mixdown,
+		Detector:         o.Detector,
+		Threads:          o.Threads,
+		Logger:           o.Logger,
+	}
+}
+
+// renderOptions builds the output settings from the options
+func (o Options) renderOptions() render.Options {
+	return render.Options{
+		FadeInMs:        o.FadeInMs,
+		BitDepth:        o.BitDepth,
+		FloatOutput:     o.FloatOutput,
+		SampleRateOut:   o.SampleRateOut,
+		TargetLUFS:      o.TargetLUFS,
+		NormalizeOutput: o.NormalizeOutput,
+		OutputChannels:  o.OutputChannels,
+		Mixdown:         o.Mixdown,
+		InvertPolarity:  o.InvertPolarity,
+	}
+}
+
+// Result describes the synchronization of a single local file
+type Result struct {
+	Path           string      // Input local file path
+	OutputPath     string      // Path of the written synchronized file
+	OffsetSamples  int         // Final offset in samples (positive = shift later)
+	OffsetSeconds  float64     // Final offset in seconds
+	PaddingSamples int         // Silence prepended to the output
+	PaddingSeconds float64     // Silence prepended to the output in seconds
+	TrimSamples    int         // Leading samples removed from the output (trim mode)
+	TrimSeconds    float64     // Leading audio removed from the output in seconds (trim mode)
+	Confidence     float64     // Detection confidence (normalized cross-correlation coefficient)
+	PeakToSidelobe float64     // Coarse peak-to-sidelobe ratio (higher = less ambiguous)
+	IsEarliest     bool        // Whether this is the earliest file
+	ClippedSamples int         // Samples beyond full scale that were clamped on write (0 with NormalizeOutput)
+	Detail         *FileOffset // Coarse, fine-tuning, gap and drift details
+}
+
+// Sync aligns the local files against the mixed file and writes a synchronized
+// WAV file with _synced suffix next to each local file
+func Sync(mixed string, locals []string, opts Options) ([]Result, error) {
+	return SyncContext(context.Background(), mixed, locals, opts)
+}
+
+// SyncContext is like Sync but stops offset detection, fine-tuning and drift correction
+// when ctx is cancelled, returning ctx.Err()
+func SyncContext(ctx context.Context, mixed string, locals []string, opts Options) ([]Result, error) {
+	if len(locals) == 0 {
+		return nil, fmt.Errorf("no local audio files provided")
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Load mixed and local audio
+	mixedData, err := audio.LoadAudio(mixed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mixed audio: %w", err)
+	}
+
+	localFiles := make([]*audio.WAVData, len(locals))
+	for i, path := range locals {
+		local, err := audio.LoadAudio(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load local audio %s: %w", path, err)
+		}
+
+		// Match local sample rate to the mixed file
+		if local.SampleRate != mixedData.SampleRate {
+			if opts.NoResample {
+				return nil, fmt.Errorf("%w: mixed (%d Hz) vs local %d (%d Hz)", ErrSampleRateMismatch,
+					mixedData.SampleRate, i+1, local.SampleRate)
+			}
+			local.Data = audio.Resample(local.Data, local.SampleRate, mixedData.SampleRate, local.Channels)
+			local.SampleRate = mixedData.SampleRate
+		}
+
+		localFiles[i] = local
+	}
+
+	// Align mono copies of the audio
+	mixedMono, err := audio.ToMono(mixedData.Data, mixedData.Channels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert mixed audio to mono: %w", err)
+	}
+	localMonos := make([][]float64, len(localFiles))
+	for i, local := range localFiles {
+		if localMonos[i], err = audio.ToMonoMixdown(local.Data, local.Channels, opts.Mixdown); err != nil {
+			return nil, fmt.Errorf("failed to convert %s to mono: %w", locals[i], err)
+		}
+	}
+
+	opts = opts.resolved(mixedMono, localMonos, mixedData.SampleRate)
+	fileOffsets, err := audiosync.AlignBuffers(ctx, mixedMono, localMonos, mixedData.SampleRate, opts.detectOptions())
+	if err != nil {
+		return nil, err
+	}
+	mixedLevel := audio.MeasureLevel(mixedData.Data)
+	for i, fo := range fileOffsets {
+		fo.Path = locals[i]
+		fo.Gain = audiosync.NewGainDiagnostics(audio.MeasureLevel(localFiles[i].Data), mixedLevel)
+	}
+
+	// Reinsert the time local files were paused for, keeping the unsplit alignment if it fails
+	if opts.SplitGaps {
+		if split, err := audiosync.SplitGaps(ctx, mixedMono, localFiles, fileOffsets, mixedData.SampleRate, opts.detectOptions()); err == nil {
+			fileOffsets = split
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	// Correct clock drift, keeping the uncorrected alignment if it fails
+	if opts.CorrectDrift {
+		if corrected, err := audiosync.CorrectDrift(ctx, mixedMono, localFiles, fileOffsets, mixedData.SampleRate, opts.detectOptions()); err == nil {
+			fileOffsets = corrected
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	if opts.Anchor != "" {
+		if err := audiosync.CalculateAnchor(fileOffsets, opts.Anchor, mixedData.SampleRate); err != nil {
+			return nil, err
+		}
+	} else if opts.Mode == ModeTrim {
+		if err := audiosync.CalculateTrim(fileOffsets, mixedData.SampleRate); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.FractionalDelay {
+		audiosync.CalculateFractionalPadding(fileOffsets, mixedData.SampleRate)
+	}
+	if opts.CommonLead > 0 {
+		audiosync.AddLead(fileOffsets, int(math.Round(opts.CommonLead*float64(mixedData.SampleRate))), mixedData.SampleRate)
+	}
+
+	// Apply padding (or trim) and write synced files
+	results := make([]Result, len(fileOffsets))
+	outputOpts := opts.renderOptions()
+	for i, fo := range fileOffsets {
+		out := render.Render(localFiles[i], fo, outputOpts)
+		// Integer output clamps samples beyond full scale unless the whole file is scaled down
+		clipped := 0
+		if !out.Normalized {
+			clipped = out.Clip.Samples
+		}
+
+		syncedData, channels, err := outputOpts.Layout(out.Data, out.Channels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to %d channels: %w", locals[i], opts.OutputChannels, err)
+		}
+
+		outputPath := render.OutputPath(locals[i], "", render.DefaultSuffix, "")
+		if err := audio.WriteAudioTagged(outputPath, syncedData, out.SampleRate, channels, out.BitDepth, out.Float, localFiles[i].Tags); err != nil {
+			return nil, fmt.Errorf("failed to write synced file for %s: %w", locals[i], err)
+		}
+
+		results[i] = Result{
+			Path:           locals[i],
+			OutputPath:     outputPath,
+			OffsetSamples:  fo.FinalOffsetSamples,
+			OffsetSeconds:  fo.FinalOffsetSeconds,
+			PaddingSamples: fo.PaddingSamples,
+			PaddingSeconds: fo.PaddingSeconds,
+			TrimSamples:    fo.TrimSamples,
+			TrimSeconds:    fo.TrimSeconds,
+			Confidence:     fo.Confidence,
+			PeakToSidelobe: fo.PeakToSidelobe,
+			IsEarliest:     fo.IsEarliest,
+			ClippedSamples: clipped,
+			Detail:         fo,
+		}
+	}
+
+	return results, nil
+}
+
+// AlignBuffers computes the alignment of mono local buffers against a mono mixed buffer
+// without reading or writing files. All buffers must share sampleRate.
+// Coarse detection, fine-tuning, padding and (with ModeTrim) trimming follow opts;
+// CorrectDrift and SplitGaps are ignored because both mean rewriting the caller's data.
+func AlignBuffers(mixed []float64, locals [][]float64, sampleRate int, opts Options) ([]*FileOffset, error) {
+	return AlignBuffersContext(context.Background(), mixed, locals, sampleRate, opts)
+}
+
+// AlignBuffersContext is like AlignBuffers but stops when ctx is cancelled, returning ctx.Err()
+func AlignBuffersContext(ctx context.Context, mixed []float64, locals [][]float64, sampleRate int, opts Options) ([]*FileOffset, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	opts = opts.resolved(mixed, locals, sampleRate)
+	fileOffsets, err := audiosync.AlignBuffers(ctx, mixed, locals, sampleRate, opts.detectOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == ModeTrim {
+		if err := audiosync.CalculateTrim(fileOffsets, sampleRate); err != nil {
+			return nil, err
+		}
+	}
+	return fileOffsets, nil
+}
+
+// ComputeOverlap returns the region of the mixed timeline that the mono mixed buffer and every mono local buffer
+// cover at the given offsets (e.g. from AlignBuffers); fine-tuning picks its segment from this region
+// It returns an error if the buffers share no material.
+func ComputeOverlap(mixed []float64, locals [][]float64, offsets []*FileOffset, sampleRate int) (*OverlapRegion, error) {
+	if len(locals) != len(offsets) {
+		return nil, fmt.Errorf("mismatch between buffers (%d) and offsets (%d)", len(locals), len(offsets))
+	}
+	localFiles := make([]*audio.WAVData, len(locals))
+	for i, local := range locals {
+		localFiles[i] = &audio.WAVData{Data: local, Channels: 1, SampleRate: sampleRate}
+	}
+	return audiosync.ComputeOverlap(localFiles, offsets, len(mixed), sampleRate)
+}
+
+// resolved returns the options with an automatic downsample factor chosen for the given mono audio
+func (o Options) resolved(mixed []float64, locals [][]float64, sampleRate int) Options {
+	if o.DownsampleFactor == 0 {
+		longest := 0
+		for _, local := range locals {
+			longest = max(longest, len(local))
+		}
+		o.DownsampleFactor = audiosync.AutoDownsampleFactor(sampleRate, len(mixed), longest, o.AutoResolutionMs)
+	}
+	return o
+}
+
+// validate checks the options for values the workflow cannot use
+func (o Options) validate() error {
+	if o.SegmentDuration <= 0 {
+		return fmt.Errorf("segment duration must be positive, got %d", o.SegmentDuration)
+	}
+	if o.DownsampleFactor < 0 {
+		return fmt.Errorf("downsample factor must not be negative, got %d", o.DownsampleFactor)
+	}
+	if o.CoarseSegment < 0 || o.FinetuneTarget < 0 || o.FinetuneMin < 0 {
+		return fmt.Errorf("segment lengths must not be negative")
+	}
+	if o.FinetuneMin > 0 && o.FinetuneTarget > 0 && o.FinetuneMin > o.FinetuneTarget {
+		return fmt.Errorf("fine-tuning minimum %gs must not exceed target %gs", o.FinetuneMin, o.FinetuneTarget)
+	}
+	if o.Anchor != "" && o.Mode == ModeTrim {
+		return fmt.Errorf("anchor cannot be combined with trim mode")
+	}
+	if o.Chunked && o.CorrelationMethod == MethodPHAT {
+		return fmt.Errorf("chunked correlation cannot be combined with PHAT")
+	}
+	if o.TrimSilenceDB > 0 {
+		return fmt.Errorf("silence threshold must be a negative dBFS level, got %g", o.TrimSilenceDB)
+	}
+	if o.Threads < 0 {
+		return fmt.Errorf("thread count must not be negative, got %d", o.Threads)
+	}
+	if o.CommonLead < 0 {
+		return fmt.Errorf("common lead must not be negative, got %g", o.CommonLead)
+	}
+	if o.FadeInMs < 0 {
+		return fmt.Errorf("fade-in length must not be negative, got %g", o.FadeInMs)
+	}
+	if o.MaxOffset < 0 {
+		return fmt.Errorf("max offset must not be negative, got %g", o.MaxOffset)
+	}
+	if o.ExcludeLag < 0 {
+		return fmt.Errorf("excluded lag must not be negative, got %g", o.ExcludeLag)
+	}
+	for _, stage := range o.PreFilter {
+		if stage.Freq <= 0 || stage.Q <= 0 {
+			return fmt.Errorf("pre-filter %s stage needs a positive frequency and Q, got %g Hz and Q %g", stage.Type, stage.Freq, stage.Q)
+		}
+	}
+	if o.OutputChannels < 0 || o.OutputChannels > 2 {
+		return fmt.Errorf("output channels must be 1 or 2 (0 = keep), got %d", o.OutputChannels)
+	}
+	if o.BitDepth != 0 && o.BitDepth != 16 && o.BitDepth != 24 && o.BitDepth != 32 {
+		return fmt.Errorf("bit depth must be 16, 24 or 32, got %d", o.BitDepth)
+	}
+	if o.TargetLUFS > 0 || o.TargetLUFS < -70 {
+		return fmt.Errorf("target loudness must be between -70 and 0 LUFS, got %g", o.TargetLUFS)
+	}
+	if o.SampleRateOut < 0 {
+		return fmt.Errorf("output sample rate must not be negative, got %d", o.SampleRateOut)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package clapless
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shidetake/clapless/internal/audio"
+)
+
+const testRate = 8000
+
+// noise returns length samples of seeded white noise
+func noise(seed uint64, length int) []float64 {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	data := make([]float64, length)
+	for i := range data {
+		data[i] = 0.3 * rng.NormFloat64()
+	}
+	return data
+}
+
+// writeMono writes data as a mono 16-bit WAV file named name in dir and returns its path
+func writeMono(t *testing.T, dir, name string, data []float64, sampleRate int) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := audio.WriteWAV(path, data, sampleRate, 1, 16, false); err != nil {
+		t.Fatalf("WriteWAV: %v", err)
+	}
+	return path
+}
+
+func TestSyncErrors(t *testing.T) {
+	dir := t.TempDir()
+	mixedData := noise(1, 20*testRate)
+	mixed := writeMono(t, dir, "mixed.wav", mixedData, testRate)
+	local := writeMono(t, dir, "local.wav", mixedData[3*testRate:13*testRate], testRate)
+	fast := writeMono(t, dir, "fast.wav", noise(2, 10*2*testRate), 2*testRate)
+	silent := writeMono(t, dir, "silent.wav", make([]float64, 10*testRate), testRate)
+	empty := writeMono(t, dir, "empty.wav", nil, testRate)
+	garbage := filepath.Join(dir, "garbage.wav")
+	if err := os.WriteFile(garbage, []byte("not a RIFF file at all"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	noResample := DefaultOptions()
+	noResample.NoResample = true
+
+	tests := []struct {
+		name   string
+		mixed  string
+		locals []string
+		opts   Options
+		want   error
+	}{
+		{"missing file", mixed, []string{filepath.Join(dir, "missing.wav")}, DefaultOptions(), fs.ErrNotExist},
+		{"invalid WAV", mixed, []string{garbage}, DefaultOptions(), ErrInvalidWAV},
+		{"no audio data", mixed, []string{empty}, DefaultOptions(), ErrNoAudioData},
+		{"unsupported format", mixed, []string{filepath.Join(dir, "notes.txt")}, DefaultOptions(), ErrUnsupportedFormat},
+		{"sample rate mismatch", mixed, []string{fast}, noResample, ErrSampleRateMismatch},
+		{"silent local", mixed, []string{local, silent}, DefaultOptions(), ErrSilentInput},
+	}
+
+	for _, tt := range tests {
+		if _, err := Sync(tt.mixed, tt.locals, tt.opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want one wrapping %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestComputeOverlapErrNoOverlap(t *testing.T) {
+	mixed := noise(3, 60*testRate)
+	locals := [][]float64{mixed[:10*testRate], mixed[40*testRate : 50*testRate]}
+	offsets := []*FileOffset{{OffsetSamples: 0}, {OffsetSamples: 40 * testRate}}
+
+	if _, err := ComputeOverlap(mixed, locals, offsets, testRate); !errors.Is(err, ErrNoOverlap) {
+		t.Errorf("error %v, want one wrapping ErrNoOverlap", err)
+	}
+}
+
+// stubDetector places each local track at the offset given for its length, whatever the audio holds
+type stubDetector struct {
+	offsets map[int]int // Offset in samples by local track length
+	calls   atomic.Int32
+}
+
+func (d *stubDetector) Detect(ctx context.Context, mixed, local []float64, sampleRate int) (*OffsetResult, error) {
+	d.calls.Add(1)
+	offset := d.offsets[len(local)]
+	return &OffsetResult{OffsetSamples: offset, OffsetSeconds: float64(offset) / float64(sampleRate), Confidence: 1}, nil
+}
+
+func TestSyncCustomDetector(t *testing.T) {
+	dir := t.TempDir()
+	mixed := writeMono(t, dir, "mixed.wav", noise(4, 30*testRate), testRate)
+	// The local tracks do not occur in the mixed track, so only the stub can place them
+	firstData, secondData := noise(5, 10*testRate), noise(6, 8*testRate)
+	first := writeMono(t, dir, "first.wav", firstData, testRate)
+	second := writeMono(t, dir, "second.wav", secondData, testRate)
+
+	detector := &stubDetector{offsets: map[int]int{len(firstData): 2 * testRate, len(secondData): 5 * testRate}}
+	opts := DefaultOptions()
+	opts.Detector = detector
+	opts.FinetuneMin = 60 // Longer than the files, so fine-tuning keeps the stub's offsets
+
+	results, err := Sync(mixed, []string{first, second}, opts)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if calls := detector.calls.Load(); calls != 2 {
+		t.Errorf("detector called %d times, want 2", calls)
+	}
+	if results[0].OffsetSamples != 2*testRate || results[1].OffsetSamples != 5*testRate {
+		t.Errorf("offsets %d and %d, want %d and %d", results[0].OffsetSamples, results[1].OffsetSamples, 2*testRate, 5*testRate)
+	}
+	if !results[0].IsEarliest || results[0].PaddingSamples != 0 || results[1].PaddingSamples != 3*testRate {
+		t.Errorf("padding %d and %d (first earliest %v), want 0 and %d", results[0].PaddingSamples, results[1].PaddingSamples, results[0].IsEarliest, 3*testRate)
+	}
+
+	// The second output is the second file after the padding between the stub's offsets
+	source, err := audio.LoadWAV(second)
+	if err != nil {
+		t.Fatalf("LoadWAV: %v", err)
+	}
+	out, err := audio.LoadWAV(results[1].OutputPath)
+	if err != nil {
+		t.Fatalf("LoadWAV: %v", err)
+	}
+	if len(out.Data) != 3*testRate+len(source.Data) {
+		t.Fatalf("%d output samples, want %d", len(out.Data), 3*testRate+len(source.Data))
+	}
+	for i, v := range out.Data {
+		want := 0.0
+		if i >= 3*testRate {
+			want = source.Data[i-3*testRate]
+		}
+		if v != want {
+			t.Fatalf("output sample %d = %g, want %g", i, v, want)
+		}
+	}
+}